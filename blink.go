@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// blinkOnShare is the part of a blink period the caret is shown.
+const blinkOnShare = 0.65
+
+// blinkInterrupt asks the UI loop to redraw for a caret blink phase change.
+type blinkInterrupt struct{}
+
+// caretBlinkOn reports whether a blinking caret is shown at now. The caret is
+// solid when blinking is off or left to the terminal (no period), and every
+// key or text event restarts the period with the caret shown.
+func caretBlinkOn(app *appState, now time.Time) bool {
+	if app.noBlink || app.blinkPeriod <= 0 {
+		return true
+	}
+	since := now.Sub(app.blinkAt) % app.blinkPeriod
+	return since < time.Duration(float64(app.blinkPeriod)*blinkOnShare)
+}
+
+// nextBlinkChange is how long until the caret next appears or disappears.
+func nextBlinkChange(app *appState, now time.Time) time.Duration {
+	on := time.Duration(float64(app.blinkPeriod) * blinkOnShare)
+	since := now.Sub(app.blinkAt) % app.blinkPeriod
+	if since < on {
+		return on - since
+	}
+	return app.blinkPeriod - since
+}
+
+// scheduleBlink arranges a redraw at the next blink phase change when gc
+// blinks the caret itself. One timer is pending at a time; a redraw it
+// triggers after a key reset the phase simply schedules the next change.
+func scheduleBlink(app *appState) {
+	if app.noBlink || app.blinkPeriod <= 0 || app.requestInterrupt == nil || app.blinkPending {
+		return
+	}
+	app.blinkPending = true
+	post := app.requestInterrupt
+	time.AfterFunc(nextBlinkChange(app, time.Now()), func() {
+		post(blinkInterrupt{})
+	})
+}
+
+// applyBlinkOption reads blink=on|off|<ms>: on leaves blinking to the
+// terminal, off keeps a steady caret, and a period in milliseconds makes gc
+// blink the caret itself.
+func applyBlinkOption(app *appState, value string) (string, error) {
+	switch value {
+	case "on", "":
+		app.noBlink, app.blinkPeriod = false, 0
+		return "blink=on", nil
+	case "off":
+		app.noBlink, app.blinkPeriod = true, 0
+		return "blink=off", nil
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 100 || ms > 10000 {
+		return "", fmt.Errorf("blink: want on, off, or a period of 100-10000 ms")
+	}
+	app.noBlink, app.blinkPeriod = false, time.Duration(ms)*time.Millisecond
+	return fmt.Sprintf("blink=%d", ms), nil
+}
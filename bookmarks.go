@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gc/editor"
+)
+
+// bookmark is a named position. The mark lives on the buffer's editor, so it
+// follows edits; path lets a closed buffer's bookmark reopen the file.
+type bookmark struct {
+	ed   *editor.Editor
+	mark int
+	path string
+}
+
+// pos is the bookmark's current offset.
+func (b bookmark) pos() int {
+	p, _ := b.ed.MarkPos(b.mark)
+	return p
+}
+
+// bookmarkNames lists the bookmarks in name order.
+func bookmarkNames(app *appState) []string {
+	return slices.Sorted(maps.Keys(app.bookmarks))
+}
+
+// nextBookmarkName is the smallest unused number, for a bookmark set without
+// a name.
+func nextBookmarkName(app *appState) string {
+	for n := 1; ; n++ {
+		if _, ok := app.bookmarks[strconv.Itoa(n)]; !ok {
+			return strconv.Itoa(n)
+		}
+	}
+}
+
+func promptSetBookmark(app *appState) {
+	app.inputActive = true
+	app.inputPrompt = "Bookmark name: "
+	app.inputValue = ""
+	app.inputKind = "bookmark"
+	app.lastEvent = fmt.Sprintf("Bookmark the caret: name (Enter = %s), Esc cancels", nextBookmarkName(app))
+}
+
+func promptJumpBookmark(app *appState) {
+	if len(app.bookmarks) == 0 {
+		app.lastEvent = "No bookmarks (Esc+\" sets one)"
+		return
+	}
+	app.inputActive = true
+	app.inputPrompt = "Jump to bookmark: "
+	app.inputValue = ""
+	app.inputKind = "gotobookmark"
+	app.lastEvent = "Bookmarks: " + strings.Join(bookmarkNames(app), " ")
+}
+
+// setBookmark names the caret position in the active buffer, replacing any
+// bookmark of the same name.
+func setBookmark(app *appState, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = nextBookmarkName(app)
+	}
+	if old, ok := app.bookmarks[name]; ok {
+		old.ed.RemoveMark(old.mark)
+	}
+	if app.bookmarks == nil {
+		app.bookmarks = map[string]bookmark{}
+	}
+	app.bookmarks[name] = bookmark{ed: app.ed, mark: app.ed.AddMark(app.ed.Caret), path: app.currentPath}
+	return name
+}
+
+// jumpToBookmark switches to the bookmark's buffer and moves the caret to
+// it. A bookmark whose buffer was closed reopens its file at the position it
+// had when the buffer closed.
+func jumpToBookmark(app *appState, name string) error {
+	name = strings.TrimSpace(name)
+	b, ok := app.bookmarks[name]
+	if !ok {
+		return fmt.Errorf("no bookmark %q", name)
+	}
+	pos := b.pos()
+	from := currentJump(app)
+	if err := showEditor(app, b.ed, b.path, false); err != nil {
+		return fmt.Errorf("bookmark %q: %v", name, err)
+	}
+	pushJump(app, from)
+	if app.ed != b.ed {
+		// Rebind the bookmark to the buffer now holding its file.
+		b.ed.RemoveMark(b.mark)
+		b = bookmark{ed: app.ed, mark: app.ed.AddMark(pos), path: b.path}
+		app.bookmarks[name] = b
+	}
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = clamp(pos, 0, app.ed.RuneLen())
+	return nil
+}
+
+// bookmarkLines returns the lines of ed holding a bookmark, for the gutter.
+func bookmarkLines(app *appState, ed *editor.Editor, lines []string) map[int]struct{} {
+	var out map[int]struct{}
+	for _, b := range app.bookmarks {
+		if b.ed != ed {
+			continue
+		}
+		if out == nil {
+			out = map[int]struct{}{}
+		}
+		out[editor.CaretLineAt(lines, b.pos())] = struct{}{}
+	}
+	return out
+}
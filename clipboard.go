@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gc/editor"
+)
+
+type memoryClipboard struct {
+	mu   sync.Mutex
+	text string
+}
+
+func (m *memoryClipboard) GetText() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.text, nil
+}
+
+func (m *memoryClipboard) SetText(text string) error {
+	m.mu.Lock()
+	m.text = text
+	m.mu.Unlock()
+	return nil
+}
+
+// clipboardCommands is an external copy/paste tool pair for the OS clipboard.
+type clipboardCommands struct {
+	copy  []string
+	paste []string
+}
+
+// detectClipboardCommands picks the first available clipboard tool for the
+// platform and display server, or reports false when none is installed.
+func detectClipboardCommands(goos string, getenv func(string) string, lookPath func(string) (string, error)) (clipboardCommands, bool) {
+	var candidates []clipboardCommands
+	switch {
+	case goos == "darwin":
+		candidates = append(candidates, clipboardCommands{copy: []string{"pbcopy"}, paste: []string{"pbpaste"}})
+	case goos == "windows":
+		return clipboardCommands{}, false
+	default:
+		if getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, clipboardCommands{copy: []string{"wl-copy"}, paste: []string{"wl-paste", "--no-newline"}})
+		}
+		if getenv("DISPLAY") != "" {
+			candidates = append(candidates,
+				clipboardCommands{copy: []string{"xclip", "-selection", "clipboard", "-in"}, paste: []string{"xclip", "-selection", "clipboard", "-out"}},
+				clipboardCommands{copy: []string{"xsel", "--clipboard", "--input"}, paste: []string{"xsel", "--clipboard", "--output"}},
+			)
+		}
+	}
+	for _, c := range candidates {
+		if _, err := lookPath(c.copy[0]); err != nil {
+			continue
+		}
+		if _, err := lookPath(c.paste[0]); err != nil {
+			continue
+		}
+		return c, true
+	}
+	return clipboardCommands{}, false
+}
+
+// clipboardTimeout bounds a clipboard tool run so a stuck tool cannot freeze
+// the editor.
+const clipboardTimeout = 2 * time.Second
+
+// runClipboardCommand runs a clipboard tool. Copy tools get the text on stdin
+// and their stdout is discarded (xclip and wl-copy fork a server that would
+// hold a captured pipe open); paste tools return their stdout.
+var runClipboardCommand = func(argv []string, stdin string, paste bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if !paste {
+		cmd.Stdin = strings.NewReader(stdin)
+		return "", cmd.Run()
+	}
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// systemClipboard shares text with other applications through an external
+// tool. The last copied text is kept in memory so paste still works when the
+// tool fails (e.g. the display went away).
+type systemClipboard struct {
+	cmds clipboardCommands
+	mem  memoryClipboard
+}
+
+func (c *systemClipboard) GetText() (string, error) {
+	if out, err := runClipboardCommand(c.cmds.paste, "", true); err == nil {
+		return out, nil
+	}
+	return c.mem.GetText()
+}
+
+func (c *systemClipboard) SetText(text string) error {
+	_ = c.mem.SetText(text)
+	_, err := runClipboardCommand(c.cmds.copy, text, false)
+	return err
+}
+
+// newClipboard returns the OS clipboard when a copy/paste tool is available,
+// falling back to a per-process memory clipboard.
+func newClipboard() editor.Clipboard {
+	if cmds, ok := detectClipboardCommands(runtime.GOOS, os.Getenv, exec.LookPath); ok {
+		return &systemClipboard{cmds: cmds}
+	}
+	return &memoryClipboard{}
+}
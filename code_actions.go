@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"gc/editor"
+)
+
+// codeActionState is the quick-fix popup: the fixes gopls offered for the
+// caret line.
+type codeActionState struct {
+	active   bool
+	line     int
+	items    []codeAction
+	selected int
+}
+
+// openCodeActions asks gopls for the quick fixes on the caret line of the
+// active Go buffer and lists them in a popup.
+func openCodeActions(app *appState) error {
+	if bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) != syntaxGo {
+		return fmt.Errorf("quick fixes need a Go buffer")
+	}
+	if app.noGopls {
+		return fmt.Errorf("gopls is off (Esc+Shift+Y retries)")
+	}
+	line := editor.CaretLineAt(app.ed.Lines(), app.ed.Caret)
+	_, msgs := activeBufferSyntaxErrors(app, syntaxGo, app.currentPath)
+	items, err := goCodeActions(app.gopls, app.currentPath, string(app.ed.Runes()), line, msgs[line])
+	if err != nil {
+		goplsFailed(app, "Quick fixes")
+		return nil
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no quick fixes on line %d", line+1)
+	}
+	app.codeActions = codeActionState{active: true, line: line, items: items}
+	app.lastEvent = fmt.Sprintf("%d quick fixes: Up/Down choose, Enter applies, Esc closes", len(items))
+	return nil
+}
+
+// applyCodeAction closes the popup and applies the selected fix to the
+// buffer as one undo step, keeping the caret on its text.
+func applyCodeAction(app *appState) {
+	st := app.codeActions
+	app.codeActions = codeActionState{}
+	if st.selected < 0 || st.selected >= len(st.items) || readOnlyBlocked(app) {
+		return
+	}
+	act := st.items[st.selected]
+	out, err := applyTextEdits(app.ed.Runes(), act.edits)
+	if err != nil {
+		app.lastEvent = fmt.Sprintf("FIX ERR: %v", err)
+		return
+	}
+	if app.ed.ApplyText(out) {
+		app.markDirty()
+	}
+	app.lastEvent = "Applied: " + act.title
+}
+
+// handleCodeActionKey handles keys while the quick-fix popup is open.
+func handleCodeActionKey(app *appState, e keyEvent) bool {
+	st := &app.codeActions
+	switch e.key {
+	case keyEscape:
+		app.codeActions = codeActionState{}
+		app.lastEvent = "Quick fixes closed"
+	case keyReturn, keyKpEnter:
+		applyCodeAction(app)
+	case keyUp:
+		st.selected = max(0, st.selected-1)
+	case keyDown:
+		st.selected = min(len(st.items)-1, st.selected+1)
+	case keyTab:
+		n := len(st.items)
+		if e.mods&modShift != 0 {
+			st.selected = (st.selected + n - 1) % n
+		} else {
+			st.selected = (st.selected + 1) % n
+		}
+	case keyHome:
+		st.selected = 0
+	case keyEnd:
+		st.selected = len(st.items) - 1
+	}
+	return true
+}
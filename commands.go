@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gc/editor"
+)
+
+// Command names an app-level operation so frontends and tests can run it
+// without synthesizing key events.
+type Command int
+
+const (
+	CmdNone Command = iota
+	CmdSave
+	CmdSaveAll
+	CmdNewBuffer
+	CmdToggleComment
+	CmdSearch
+	CmdSaveAs
+	CmdFormat
+	CmdRun
+	CmdToggleSplit
+	CmdShortcuts
+	CmdSetOption
+	CmdToggleReadOnly
+	CmdCycleMode
+	CmdDiagnostics
+	CmdTestCompanion
+	CmdTextStats
+	CmdMarkdownPreview
+	CmdRenumberList
+	CmdPalette
+	CmdRepeat
+	CmdCloseOthers
+	CmdReopen
+	CmdSidebar
+	CmdRenameFile
+	CmdDeleteFile
+	CmdBookmark
+	CmdGotoBookmark
+	CmdJumpBack
+	CmdJumpForward
+	CmdFold
+	CmdOutline
+	CmdWrap
+	CmdIncrement
+	CmdDecrement
+	CmdFinalNewline
+	CmdLineEndings
+	CmdSpellIgnore
+	CmdMarkers
+	CmdLeapForward
+	CmdLeapBack
+	CmdLeapAgain
+	CmdLeapAgainBack
+	CmdPasteBelow
+	CmdPasteAbove
+	CmdGoplsRetry
+	CmdQuickFix
+	CmdMessages
+)
+
+// commandSpec describes a Command for lookup by name and for the command
+// palette.
+type commandSpec struct {
+	cmd  Command
+	name string
+	desc string
+	keys string
+}
+
+var commandSpecs = []commandSpec{
+	{CmdSave, "save", "Save current buffer", "Ctrl+S"},
+	{CmdSaveAll, "save-all", "Save dirty buffers", "Esc+Shift+S"},
+	{CmdSaveAs, "save-as", "Write buffer as...", "Esc+W"},
+	{CmdNewBuffer, "new-buffer", "New buffer", "Ctrl+B"},
+	{CmdToggleComment, "toggle-comment", "Toggle comment", "Ctrl+/"},
+	{CmdSearch, "search", "Search (argument is the pattern)", "Esc+/"},
+	{CmdFormat, "format", "Save, go fmt/fix, reload", "Esc+F"},
+	{CmdRun, "run", "Run package (go run .)", "Ctrl+R"},
+	{CmdToggleSplit, "split", "Toggle split view", "Esc+Shift+V"},
+	{CmdShortcuts, "shortcuts", "Open shortcuts buffer", "Ctrl+Shift+/"},
+	{CmdSetOption, "set", "Set option (argument is name=value)", "Esc+Shift+O"},
+	{CmdToggleReadOnly, "read-only", "Toggle read-only", "Esc+Shift+R"},
+	{CmdCycleMode, "mode", "Cycle language mode", "Esc+M"},
+	{CmdDiagnostics, "diagnostics", "Diagnostics summary buffer", "Esc+d"},
+	{CmdTestCompanion, "test-file", "Toggle foo.go / foo_test.go", "Esc+g"},
+	{CmdTextStats, "count", "Word, line and character count", "Esc+Shift+C"},
+	{CmdMarkdownPreview, "preview", "Markdown preview buffer", "Esc+Shift+P"},
+	{CmdRenumberList, "renumber", "Renumber ordered list (Markdown)", "Esc+Shift+M"},
+	{CmdPalette, "palette", "Command palette", "Esc+:"},
+	{CmdRepeat, "repeat", "Repeat last edit", "Esc+y"},
+	{CmdCloseOthers, "close-others", "Close all other buffers", "Esc+Shift+B"},
+	{CmdReopen, "reopen", "Reopen last closed file", "Esc+Shift+L"},
+	{CmdSidebar, "sidebar", "Show and focus the file sidebar", "Esc+Shift+F"},
+	{CmdRenameFile, "rename-file", "Rename the picker entry under the caret", "Esc+Shift+W"},
+	{CmdDeleteFile, "delete-file", "Delete the picker entry under the caret", "Esc+Delete"},
+	{CmdBookmark, "bookmark", "Bookmark the caret (argument is the name)", "Esc+\""},
+	{CmdGotoBookmark, "goto-bookmark", "Jump to a bookmark (argument is the name)", "Esc+'"},
+	{CmdJumpBack, "jump-back", "Back to the position before the last jump", "Esc+-"},
+	{CmdJumpForward, "jump-forward", "Forward again in the jump list", "Esc+Shift+-"},
+	{CmdFold, "fold", "Fold or unfold the block or section at the caret", "Esc+Shift+H"},
+	{CmdOutline, "outline", "Markdown heading / Go symbol outline", "Esc+Shift+I"},
+	{CmdWrap, "wrap", "Wrap selection or word (argument is the opening delimiter)", "Esc+("},
+	{CmdIncrement, "increment", "Add to the number at or after the caret (argument is the step)", "Esc+Shift+="},
+	{CmdDecrement, "decrement", "Subtract from the number at or after the caret (argument is the step)", "Esc+Shift+X"},
+	{CmdFinalNewline, "final-newline", "End the buffer with exactly one newline", "Esc+$"},
+	{CmdLineEndings, "line-endings", "Switch line endings between LF and CRLF", "Esc+;"},
+	{CmdSpellIgnore, "spell-ignore", "Accept the word at the caret for this session (argument is the word)", "Esc+!"},
+	{CmdMarkers, "markers", "List TODO, FIXME, XXX and NOTE comments", "Esc+#"},
+	{CmdLeapForward, "leap-forward", "Leap forward: type to find, Enter keeps", "Esc+j"},
+	{CmdLeapBack, "leap-back", "Leap backward: type to find, Enter keeps", "Esc+`"},
+	{CmdLeapAgain, "leap-again", "Repeat the last leap forward", "Esc+n"},
+	{CmdLeapAgainBack, "leap-again-back", "Repeat the last leap backward", "Esc+Shift+N"},
+	{CmdPasteBelow, "paste-below", "Paste as new lines below the caret line", "Esc+}"},
+	{CmdPasteAbove, "paste-above", "Paste as new lines above the caret line", "Esc+{"},
+	{CmdGoplsRetry, "gopls-retry", "Restart gopls after a failure", "Esc+Shift+Y"},
+	{CmdQuickFix, "quick-fix", "Quick fixes for the caret line (gopls)", "Esc+a"},
+	{CmdMessages, "messages", "Recent status messages buffer", "Esc+Shift+E"},
+}
+
+func (c Command) String() string {
+	for _, s := range commandSpecs {
+		if s.cmd == c {
+			return s.name
+		}
+	}
+	return fmt.Sprintf("Command(%d)", int(c))
+}
+
+// commandByName looks a command up by its name, ignoring case.
+func commandByName(name string) (Command, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, s := range commandSpecs {
+		if s.name == name {
+			return s.cmd, true
+		}
+	}
+	return CmdNone, false
+}
+
+// RunCommand performs cmd on the active buffer. arg is command specific (the
+// pattern for CmdSearch). The outcome, including any error, is also reported
+// in the status line.
+func (app *appState) RunCommand(cmd Command, arg string) error {
+	if app == nil || app.ed == nil {
+		return fmt.Errorf("no active buffer")
+	}
+	switch cmd {
+	case CmdSave:
+		if err := saveCurrent(app); err != nil {
+			app.lastEvent = fmt.Sprintf("SAVE ERR: %v", err)
+			return err
+		}
+		app.lastEvent = fmt.Sprintf("Saved %s", displayPath(app, app.currentPath))
+	case CmdSaveAll:
+		if err := saveAll(app); err != nil {
+			app.lastEvent = fmt.Sprintf("SAVE ALL ERR: %v", err)
+			return err
+		}
+		app.lastEvent = "Saved dirty buffers"
+	case CmdNewBuffer:
+		app.addBuffer()
+		app.lastEvent = fmt.Sprintf("New buffer %d/%d", app.bufIdx+1, len(app.buffers))
+	case CmdToggleComment:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		toggleComment(app.ed)
+		app.lastEvent = "Toggled comment"
+		app.markDirty()
+		noteEdit(app, repeatToggleComment)
+	case CmdSearch:
+		startSearchMode(app)
+		if arg == "" {
+			return nil
+		}
+		app.searchQuery = append(app.searchQuery[:0], []rune(arg)...)
+		updateSearchMatch(app)
+		if app.searchLastMatch < 0 {
+			exitSearchMode(app)
+			app.lastEvent = fmt.Sprintf("Search: no match for %q", arg)
+			return fmt.Errorf("no match for %q", arg)
+		}
+		// Lock the pattern so Tab/Shift+Tab step through matches.
+		app.searchPatternDone = true
+		app.lastSearchQuery = append(app.lastSearchQuery[:0], app.searchQuery...)
+		app.lastEvent = fmt.Sprintf("Search locked: %q", arg)
+	case CmdSaveAs:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		promptSaveAs(app)
+	case CmdFormat:
+		if err := formatFixReloadCurrent(app); err != nil {
+			app.lastEvent = fmt.Sprintf("FMT/FIX ERR: %v", err)
+			return err
+		}
+		app.lastEvent = fmt.Sprintf("Saved, fmt/fix, reloaded %s", displayPath(app, app.currentPath))
+	case CmdRun:
+		if err := runCurrentPackage(app); err != nil {
+			app.lastEvent = fmt.Sprintf("RUN ERR: %v", err)
+			return err
+		}
+	case CmdToggleSplit:
+		app.toggleSplit()
+		if app.splitActive {
+			app.lastEvent = fmt.Sprintf("Split view: buffer %d beside %d (Esc+p switches pane)", app.splitOther+1, app.bufIdx+1)
+		} else {
+			app.lastEvent = "Split view off"
+		}
+	case CmdShortcuts:
+		app.addBuffer()
+		app.ed.SetRunes([]rune(helpText()))
+		app.touchActiveBufferText()
+		app.currentPath = ""
+		app.buffers[app.bufIdx].path = ""
+		app.buffers[app.bufIdx].readOnly = true
+		app.lastEvent = "Opened shortcuts buffer"
+	case CmdSetOption:
+		if arg == "" {
+			promptSetOption(app)
+			return nil
+		}
+		desc, err := applyOption(app, arg)
+		if err != nil {
+			app.lastEvent = fmt.Sprintf("SET ERR: %v", err)
+			return err
+		}
+		app.lastEvent = "Set " + desc
+	case CmdToggleReadOnly:
+		toggleReadOnly(app)
+	case CmdCycleMode:
+		app.lastEvent = "Mode: " + cycleBufferMode(app)
+	case CmdDiagnostics:
+		openDiagnosticsSummary(app)
+	case CmdTestCompanion:
+		if err := openTestCompanion(app); err != nil {
+			app.lastEvent = fmt.Sprintf("OPEN ERR: %v", err)
+			return err
+		}
+	case CmdTextStats:
+		reportTextStats(app)
+	case CmdMarkdownPreview:
+		openMarkdownPreview(app)
+	case CmdRenumberList:
+		renumberList(app)
+	case CmdPalette:
+		openCommandPalette(app)
+	case CmdRepeat:
+		repeatLastEdit(app)
+	case CmdCloseOthers:
+		if n := app.otherDirtyBuffers(); n > 0 {
+			promptCloseOthers(app, n)
+			return nil
+		}
+		app.lastEvent = fmt.Sprintf("Closed %s", plural(app.closeOtherBuffers(), "other buffer"))
+	case CmdReopen:
+		if err := reopenClosedBuffer(app); err != nil {
+			app.lastEvent = fmt.Sprintf("REOPEN ERR: %v", err)
+			return err
+		}
+	case CmdSidebar:
+		if err := focusSidebar(app); err != nil {
+			app.lastEvent = fmt.Sprintf("SIDEBAR ERR: %v", err)
+			return err
+		}
+	case CmdRenameFile:
+		if err := promptPickerRename(app); err != nil {
+			app.lastEvent = fmt.Sprintf("FILE ERR: %v", err)
+			return err
+		}
+	case CmdBookmark:
+		if arg == "" {
+			promptSetBookmark(app)
+			return nil
+		}
+		app.lastEvent = "Bookmarked " + setBookmark(app, arg)
+	case CmdGotoBookmark:
+		if arg == "" {
+			promptJumpBookmark(app)
+			return nil
+		}
+		if err := jumpToBookmark(app, arg); err != nil {
+			app.lastEvent = fmt.Sprintf("BOOKMARK ERR: %v", err)
+			return err
+		}
+		app.lastEvent = "Jumped to bookmark " + strings.TrimSpace(arg)
+	case CmdFold:
+		if err := toggleFold(app); err != nil {
+			app.lastEvent = fmt.Sprintf("FOLD ERR: %v", err)
+			return err
+		}
+	case CmdWrap:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		if arg == "" {
+			promptWrap(app)
+			return nil
+		}
+		if err := wrapSelection(app, arg); err != nil {
+			app.lastEvent = fmt.Sprintf("WRAP ERR: %v", err)
+			return err
+		}
+	case CmdIncrement, CmdDecrement:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		step := 1
+		if arg != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || n < 1 {
+				err = fmt.Errorf("bad step %q", arg)
+				app.lastEvent = fmt.Sprintf("NUMBER ERR: %v", err)
+				return err
+			}
+			step = n
+		}
+		if cmd == CmdDecrement {
+			step = -step
+		}
+		if err := stepNumber(app, step); err != nil {
+			app.lastEvent = fmt.Sprintf("NUMBER ERR: %v", err)
+			return err
+		}
+	case CmdFinalNewline:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		if normalizeFinalNewline(app) {
+			app.lastEvent = "Buffer now ends with one newline"
+		} else {
+			app.lastEvent = "Buffer already ends with one newline"
+		}
+	case CmdLineEndings:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		toggleLineEndings(app)
+	case CmdSpellIgnore:
+		if err := spellIgnoreWord(app, arg); err != nil {
+			app.lastEvent = fmt.Sprintf("SPELL ERR: %v", err)
+			return err
+		}
+	case CmdMarkers:
+		if err := openMarkerList(app); err != nil {
+			app.lastEvent = fmt.Sprintf("MARKERS ERR: %v", err)
+			return err
+		}
+	case CmdLeapForward:
+		app.ed.LeapStart(editor.DirFwd)
+		app.lastEvent = "Leap forward: type to find, Enter keeps, Esc returns"
+	case CmdLeapBack:
+		app.ed.LeapStart(editor.DirBack)
+		app.lastEvent = "Leap back: type to find, Enter keeps, Esc returns"
+	case CmdLeapAgain, CmdLeapAgainBack:
+		dir := editor.DirFwd
+		if cmd == CmdLeapAgainBack {
+			dir = editor.DirBack
+		}
+		if err := leapAgain(app, dir); err != nil {
+			app.lastEvent = fmt.Sprintf("LEAP ERR: %v", err)
+			return err
+		}
+	case CmdGoplsRetry:
+		if err := retryGopls(app); err != nil {
+			app.lastEvent = fmt.Sprintf("GOPLS ERR: %v", err)
+			return err
+		}
+	case CmdMessages:
+		if err := openMessages(app); err != nil {
+			app.lastEvent = fmt.Sprintf("MESSAGES ERR: %v", err)
+			return err
+		}
+	case CmdQuickFix:
+		if err := openCodeActions(app); err != nil {
+			app.lastEvent = fmt.Sprintf("FIX ERR: %v", err)
+			return err
+		}
+	case CmdPasteBelow, CmdPasteAbove:
+		if readOnlyBlocked(app) {
+			return fmt.Errorf("buffer is read-only")
+		}
+		if !app.ed.PasteLines(cmd == CmdPasteAbove) {
+			app.lastEvent = "PASTE ERR: clipboard is empty"
+			return fmt.Errorf("clipboard is empty")
+		}
+		app.markDirty()
+		app.lastEvent = "Pasted lines below"
+		if cmd == CmdPasteAbove {
+			app.lastEvent = "Pasted lines above"
+		}
+	case CmdOutline:
+		if err := openOutline(app); err != nil {
+			app.lastEvent = fmt.Sprintf("OUTLINE ERR: %v", err)
+			return err
+		}
+	case CmdJumpBack, CmdJumpForward:
+		move := jumpBack
+		if cmd == CmdJumpForward {
+			move = jumpForward
+		}
+		if err := move(app); err != nil {
+			app.lastEvent = fmt.Sprintf("JUMP ERR: %v", err)
+			return err
+		}
+	case CmdDeleteFile:
+		if err := promptPickerDelete(app); err != nil {
+			app.lastEvent = fmt.Sprintf("FILE ERR: %v", err)
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown command %v", cmd)
+	}
+	return nil
+}
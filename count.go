@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// maxPendingCount bounds the count prefix so a stray run of digits cannot
+// ask for an absurd number of moves.
+const maxPendingCount = 9999
+
+// countDigit reports the digit typed by an unmodified number key.
+func countDigit(e keyEvent) (int, bool) {
+	if (e.mods & (modCtrl | modShift | modLAlt | modRAlt)) != 0 {
+		return 0, false
+	}
+	r, ok := keyToRune(e.key, e.mods)
+	if !ok || r < '0' || r > '9' {
+		return 0, false
+	}
+	return int(r - '0'), true
+}
+
+// addCountDigit appends a digit to the pending count (Esc+<digit> starts it,
+// further digits extend it).
+func addCountDigit(app *appState, d int) {
+	app.pendingCount = min(app.pendingCount*10+d, maxPendingCount)
+	app.lastEvent = fmt.Sprintf("Count: %d (move key applies it, Esc cancels)", app.pendingCount)
+}
+
+// takeCount returns the pending count, or 1 when none is set, and clears it.
+func takeCount(app *appState) int {
+	n := max(app.pendingCount, 1)
+	app.pendingCount = 0
+	return n
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gc/editor"
+)
+
+// diagnosticsTitle prefixes the path of the diagnostics summary buffer so a
+// repeated Esc+d refreshes it instead of opening another.
+const diagnosticsTitle = "[diagnostics]"
+
+type diagnostic struct {
+	path string
+	line int // 0-based
+	msg  string
+}
+
+// collectDiagnostics runs the Go syntax checker over every open Go file
+// buffer, in buffer order, and returns one entry per error line.
+func collectDiagnostics(app *appState) []diagnostic {
+	if app == nil {
+		return nil
+	}
+	var out []diagnostic
+	for _, b := range app.buffers {
+		if b.ed == nil || b.path == "" || b.picker || strings.HasPrefix(b.path, "[") {
+			continue
+		}
+		buf := b.ed.Runes()
+		kind := b.mode
+		if kind == syntaxNone {
+			kind = detectSyntax(b.path, string(buf))
+		}
+		if kind != syntaxGo {
+			continue
+		}
+		checker := newGoSyntaxChecker()
+		lines := checker.lineErrorsFor(b.path, buf)
+		start := len(out)
+		for ln := range lines {
+			out = append(out, diagnostic{path: b.path, line: ln, msg: checker.lineMsgs[ln]})
+		}
+		sort.Slice(out[start:], func(i, j int) bool { return out[start+i].line < out[start+j].line })
+	}
+	return out
+}
+
+// diagnosticsSummaryLines formats diagnostics as "path:line: message" so
+// Ctrl+L on a line jumps to it.
+func diagnosticsSummaryLines(diags []diagnostic) []string {
+	out := make([]string, 0, len(diags))
+	for _, d := range diags {
+		out = append(out, fmt.Sprintf("%s:%d: %s", d.path, d.line+1, d.msg))
+	}
+	return out
+}
+
+// openDiagnosticsSummary lists diagnostics for all open Go buffers in a
+// read-only buffer titled with the count, reusing an earlier summary buffer.
+func openDiagnosticsSummary(app *appState) {
+	if app == nil || len(app.buffers) == 0 {
+		return
+	}
+	diags := collectDiagnostics(app)
+	if len(diags) == 0 {
+		app.lastEvent = "No diagnostics in open Go buffers"
+		return
+	}
+	title := fmt.Sprintf("%s %d", diagnosticsTitle, len(diags))
+	idx := -1
+	for i, b := range app.buffers {
+		if strings.HasPrefix(b.path, diagnosticsTitle) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		app.addBuffer()
+		idx = app.bufIdx
+	} else {
+		app.bufIdx = idx
+		app.syncActiveBuffer()
+	}
+	slot := &app.buffers[idx]
+	slot.path = title
+	slot.dirty = false
+	slot.readOnly = true
+	app.currentPath = title
+	app.ed.SetRunes([]rune(strings.Join(diagnosticsSummaryLines(diags), "\n") + "\n"))
+	app.ed.Caret = 0
+	app.ed.Sel = editor.Sel{}
+	app.touchActiveBufferText()
+	app.lastEvent = fmt.Sprintf("%d diagnostics (Ctrl+L on a line jumps to it)", len(diags))
+}
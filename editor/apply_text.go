@@ -0,0 +1,192 @@
+package editor
+
+// maxDiffEdits bounds the line diff; past it ApplyText falls back to one
+// change spanning the differing middle of the buffer.
+const maxDiffEdits = 1000
+
+// lineHunk replaces old lines [a0, a1) with new lines [b0, b1).
+type lineHunk struct {
+	a0, a1, b0, b1 int
+}
+
+// ApplyText makes the buffer equal to text through small edits, as one undo
+// step: changed lines are found with a line diff, and within them only the
+// runes that differ are replaced. The caret, selection and marks stay on the
+// text they were on, so reformatting keeps the caret in place. It reports
+// whether the buffer changed.
+func (e *Editor) ApplyText(text []rune) bool {
+	if e == nil {
+		return false
+	}
+	old := e.Runes()
+	if string(old) == string(text) {
+		return false
+	}
+	oldLines, oldStarts := splitLinesKeepNL(old)
+	newLines, newStarts := splitLinesKeepNL(text)
+	hunks := diffLines(oldLines, newLines)
+	e.recordUndo()
+	// Later hunks first, so earlier offsets stay valid.
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		if h.a1-h.a0 == h.b1-h.b0 {
+			for k := h.a1 - h.a0 - 1; k >= 0; k-- {
+				e.replaceDiffering(oldStarts[h.a0+k], oldStarts[h.a0+k+1], text[newStarts[h.b0+k]:newStarts[h.b0+k+1]])
+			}
+			continue
+		}
+		e.replaceDiffering(oldStarts[h.a0], oldStarts[h.a1], text[newStarts[h.b0]:newStarts[h.b1]])
+	}
+	e.dirty = true
+	e.lineSelActive = false
+	e.goalValid = false
+	return true
+}
+
+// replaceDiffering replaces runes a..b with rs, skipping the prefix and
+// suffix they share. Positions past the change shift with it; positions in
+// the replaced part move to its end.
+func (e *Editor) replaceDiffering(a, b int, rs []rune) {
+	for a < b && len(rs) > 0 {
+		if r, _ := e.buf.RuneAt(a); r != rs[0] {
+			break
+		}
+		a, rs = a+1, rs[1:]
+	}
+	for a < b && len(rs) > 0 {
+		if r, _ := e.buf.RuneAt(b - 1); r != rs[len(rs)-1] {
+			break
+		}
+		b, rs = b-1, rs[:len(rs)-1]
+	}
+	if a == b && len(rs) == 0 {
+		return
+	}
+	e.deleteRange(a, b)
+	e.insertRunesAt(a, rs)
+	adjust := func(pos int) int {
+		switch {
+		case pos >= b:
+			return pos + len(rs) - (b - a)
+		case pos > a:
+			return a + len(rs)
+		}
+		return pos
+	}
+	e.Caret = adjust(e.Caret)
+	if e.Sel.Active {
+		e.Sel.A, e.Sel.B = adjust(e.Sel.A), adjust(e.Sel.B)
+		e.Sel.Active = e.Sel.A != e.Sel.B
+	}
+}
+
+// splitLinesKeepNL splits rs into lines that keep their newline, with the
+// rune offset of each line start and a final entry for the end.
+func splitLinesKeepNL(rs []rune) ([]string, []int) {
+	var lines []string
+	starts := []int{0}
+	start := 0
+	for i, r := range rs {
+		if r == '\n' {
+			lines = append(lines, string(rs[start:i+1]))
+			start = i + 1
+			starts = append(starts, start)
+		}
+	}
+	if start < len(rs) {
+		lines = append(lines, string(rs[start:]))
+		starts = append(starts, len(rs))
+	}
+	return lines, starts
+}
+
+// diffLines returns the hunks that turn a into b, using Myers' algorithm
+// after trimming the lines they share at both ends.
+func diffLines(a, b []string) []lineHunk {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(ma) == 0 && len(mb) == 0 {
+		return nil
+	}
+	matches, ok := myersMatches(ma, mb)
+	if !ok {
+		return []lineHunk{{pre, pre + len(ma), pre, pre + len(mb)}}
+	}
+	var hunks []lineHunk
+	x, y := 0, 0
+	for _, m := range append(matches, [2]int{len(ma), len(mb)}) {
+		if m[0] > x || m[1] > y {
+			hunks = append(hunks, lineHunk{pre + x, pre + m[0], pre + y, pre + m[1]})
+		}
+		x, y = m[0]+1, m[1]+1
+	}
+	return hunks
+}
+
+// myersMatches returns the pairs of equal lines of a shortest edit script
+// from a to b, in order. It gives up past maxDiffEdits edits.
+func myersMatches(a, b []string) ([][2]int, bool) {
+	n, m := len(a), len(b)
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	// trace[d] holds v for diagonals -d-1..d+1 before round d.
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if d > maxDiffEdits {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	var matches [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		tv := trace[d]
+		at := func(k int) int { return tv[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		if d == 0 {
+			prevX, prevY = 0, 0
+		}
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, true
+}
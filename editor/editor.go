@@ -2,6 +2,8 @@
 package editor
 
 import (
+	"slices"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -60,16 +62,60 @@ type Editor struct {
 	Leap  LeapState
 
 	clip Clipboard
-	undo []undoState
+	undo []undoStep
+	redo []undoStep
+
+	// rev counts buffer mutations; lines caches SplitLines for linesRev.
+	rev      uint64
+	lines    []string
+	linesRev uint64
+
+	// leapHistory holds committed leap queries, most recent first.
+	leapHistory [][]rune
 
 	lineSelAnchorLine int
 	lineSelActive     bool
+
+	// goalCol is the column vertical moves aim for. It holds while the caret
+	// stays where the last vertical move left it (goalPos) in an unedited
+	// buffer (goalRev), so passing through short lines keeps the column.
+	goalCol   int
+	goalPos   int
+	goalRev   uint64
+	goalValid bool
+
+	// marks are positions that follow edits (bookmarks); a removed mark is
+	// -1 so ids stay stable.
+	marks []int
+
+	// wordChars are runes word operations treat as part of a word besides
+	// letters, digits and underscore.
+	wordChars string
 }
 
-type undoState struct {
-	buf   []rune
-	caret int
-	sel   Sel
+// leapHistoryMax bounds the number of committed leap queries kept for recall.
+const leapHistoryMax = 16
+
+// undoLimit bounds the number of undo steps kept.
+const undoLimit = 256
+
+// undoPatch records that old was replaced by new at pos.
+type undoPatch struct {
+	pos int
+	old []rune
+	new []rune
+}
+
+// undoStep is one undoable edit: the patches made since it was recorded, in
+// order, plus the caret and selection before (and, once undone, after) it.
+// Storing patches keeps undo memory proportional to the edits rather than
+// the buffer size.
+type undoStep struct {
+	patches    []undoPatch
+	caret      int
+	sel        Sel
+	afterCaret int
+	afterSel   Sel
 }
 
 func NewEditor(initial string) *Editor {
@@ -96,6 +142,27 @@ func (e *Editor) Runes() []rune {
 	return e.snap
 }
 
+// Revision changes whenever the buffer text changes.
+func (e *Editor) Revision() uint64 {
+	if e == nil {
+		return 0
+	}
+	return e.rev
+}
+
+// Lines returns SplitLines of the buffer, cached until the next text change.
+// Callers must not modify the returned slice.
+func (e *Editor) Lines() []string {
+	if e == nil {
+		return []string{""}
+	}
+	if e.lines == nil || e.linesRev != e.rev {
+		e.lines = SplitLines(e.Runes())
+		e.linesRev = e.rev
+	}
+	return e.lines
+}
+
 func (e *Editor) String() string {
 	return string(e.Runes())
 }
@@ -105,7 +172,24 @@ func (e *Editor) RuneAt(i int) (rune, bool) {
 }
 
 func (e *Editor) SetRunes(rs []rune) {
+	if len(e.undo) > 0 || len(e.redo) > 0 || len(e.marks) > 0 {
+		old := e.Runes()
+		pre := 0
+		for pre < len(old) && pre < len(rs) && old[pre] == rs[pre] {
+			pre++
+		}
+		suf := 0
+		for suf < len(old)-pre && suf < len(rs)-pre && old[len(old)-1-suf] == rs[len(rs)-1-suf] {
+			suf++
+		}
+		if pre+suf < len(old) || pre+suf < len(rs) {
+			e.shiftMarks(pre, len(old)-suf-pre, len(rs)-suf-pre)
+			// Changed text drops the redo history, even with nothing to undo.
+			e.notePatch(pre, slices.Clone(old[pre:len(old)-suf]), slices.Clone(rs[pre:len(rs)-suf]))
+		}
+	}
 	e.buf = newGapBufferNoCopy(rs)
+	e.rev++
 	e.snap = rs
 	e.dirty = false
 	e.Caret = clamp(e.Caret, 0, e.RuneLen())
@@ -116,6 +200,12 @@ func (e *Editor) SetClipboard(c Clipboard) {
 	e.clip = c
 }
 
+// SetWordChars sets the extra runes that word operations count as part of a
+// word, e.g. "-" so foo-bar is one word. Empty restores the default rule.
+func (e *Editor) SetWordChars(chars string) {
+	e.wordChars = chars
+}
+
 // ======================
 // Leap + selection logic
 // ======================
@@ -131,10 +221,21 @@ func (e *Editor) LeapStart(dir Dir) {
 	// Starting a leap keeps any existing selection; later edits may replace it.
 }
 
+// LeapStartSelecting starts a leap that selects from the origin caret to each
+// match as the query is refined.
+func (e *Editor) LeapStartSelecting(dir Dir) {
+	e.LeapStart(dir)
+	e.Leap.Selecting = true
+	e.Leap.SelAnchor = e.Caret
+	e.Sel = Sel{}
+	e.lineSelActive = false
+}
+
 func (e *Editor) LeapEndCommit() {
 	// Commit keeps caret and stores the query for Leap Again.
 	if len(e.Leap.Query) > 0 {
 		e.Leap.LastCommit = append(e.Leap.LastCommit[:0], e.Leap.Query...)
+		e.pushLeapHistory(e.Leap.Query)
 	}
 
 	e.Leap.Active = false
@@ -187,6 +288,8 @@ func (e *Editor) leapSearch() {
 		e.Caret = pos
 		e.Leap.LastFoundPos = pos
 	} else {
+		// No match: back to the origin rather than a stale match.
+		e.Caret = e.Leap.OriginCaret
 		e.Leap.LastFoundPos = -1
 	}
 	if e.Leap.Selecting {
@@ -219,6 +322,61 @@ func (e *Editor) LeapAgain(dir Dir) {
 	}
 }
 
+func (e *Editor) pushLeapHistory(q []rune) {
+	if len(e.leapHistory) > 0 && string(e.leapHistory[0]) == string(q) {
+		return
+	}
+	entry := append([]rune(nil), q...)
+	e.leapHistory = append([][]rune{entry}, e.leapHistory...)
+	if len(e.leapHistory) > leapHistoryMax {
+		e.leapHistory = e.leapHistory[:leapHistoryMax]
+	}
+}
+
+// LeapHistory returns committed leap queries, most recent first.
+func (e *Editor) LeapHistory() []string {
+	out := make([]string, len(e.leapHistory))
+	for i, q := range e.leapHistory {
+		out[i] = string(q)
+	}
+	return out
+}
+
+// LeapAgainFromHistory repeats the history entry at index (0 is the most
+// recent; out-of-range indexes wrap) like LeapAgain. It returns the query used.
+func (e *Editor) LeapAgainFromHistory(index int, dir Dir) (string, bool) {
+	n := len(e.leapHistory)
+	if n == 0 {
+		return "", false
+	}
+	q := e.leapHistory[((index%n)+n)%n]
+	start := max(0, e.Caret-1)
+	if dir == DirFwd {
+		start = min(e.RuneLen(), e.Caret+1)
+	}
+	if pos, ok := FindInDir(e.Runes(), q, start, dir, true /*wrap*/); ok {
+		e.Caret = pos
+	}
+	return string(q), true
+}
+
+// JumpToChar moves the caret to the next occurrence of r in dir, excluding the
+// rune under the caret (like vim's f/F). It does not wrap and reports whether
+// the caret moved.
+func (e *Editor) JumpToChar(r rune, dir Dir) bool {
+	start := e.Caret
+	if dir == DirFwd {
+		start = min(e.RuneLen(), e.Caret+1)
+	}
+	pos, ok := FindInDir(e.Runes(), []rune{r}, start, dir, false)
+	if !ok {
+		return false
+	}
+	e.Caret = pos
+	e.Sel.Active = false
+	return true
+}
+
 // ======================
 // Editing + selection
 // ======================
@@ -265,15 +423,14 @@ func (e *Editor) BackspaceOrDeleteSelection(isBackspace bool) {
 	e.dirty = true
 }
 
-// DeleteWordAtCaret removes the word under the caret (letters/digits/underscore).
-// If the caret is on a non-word rune, deletes that single rune instead.
+// DeleteWordAtCaret removes the word under the caret (letters/digits/underscore
+// and any extra word characters). If the caret is on a non-word rune, deletes
+// that single rune instead.
 func (e *Editor) DeleteWordAtCaret() bool {
 	if e == nil {
 		return false
 	}
-	isWord := func(r rune) bool {
-		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
-	}
+	isWord := e.isWordRune
 	at := func(i int) rune {
 		r, _ := e.buf.RuneAt(i)
 		return r
@@ -338,6 +495,154 @@ func (e *Editor) DeleteWordAtCaret() bool {
 	return true
 }
 
+// SelectWordAtCaret selects the word under the caret, or the word directly left of it
+// when the caret sits just past a word. It reports whether a word was found.
+func (e *Editor) SelectWordAtCaret() bool {
+	if e == nil {
+		return false
+	}
+	a, b, ok := e.wordRangeAt(e.Caret)
+	if !ok {
+		return false
+	}
+	e.lineSelActive = false
+	e.Sel = Sel{Active: true, A: a, B: b}
+	e.Caret = b
+	return true
+}
+
+// ExpandSelection grows the selection on successive calls: word, then the
+// covered lines (including their newline), then the whole buffer.
+func (e *Editor) ExpandSelection() {
+	if e == nil {
+		return
+	}
+	a, b := e.Sel.Normalised()
+	if !e.Sel.Active || a == b {
+		if e.SelectWordAtCaret() {
+			return
+		}
+		a, b = e.Caret, e.Caret
+	}
+	lines := SplitLines(e.Runes())
+	from, _ := LineColForPos(lines, a)
+	to, _ := LineColForPos(lines, b)
+	if to > from && b == LineStartOffset(lines, to) {
+		// A selection ending at a line start already covers the previous line's newline.
+		to--
+	}
+	ls := LineStartOffset(lines, from)
+	le := lineEndExclusivePos(lines, to, e.RuneLen())
+	e.lineSelActive = false
+	if a > ls || b < le {
+		e.Sel = Sel{Active: true, A: ls, B: le}
+		e.Caret = le
+		return
+	}
+	e.Sel = Sel{Active: true, A: 0, B: e.RuneLen()}
+	e.Caret = e.RuneLen()
+}
+
+func (e *Editor) wordRangeAt(pos int) (int, int, bool) {
+	n := e.RuneLen()
+	pos = clamp(pos, 0, n)
+	at := func(i int) rune {
+		r, _ := e.buf.RuneAt(i)
+		return r
+	}
+	if pos == n || !e.isWordRune(at(pos)) {
+		if pos == 0 || !e.isWordRune(at(pos-1)) {
+			return 0, 0, false
+		}
+		pos--
+	}
+	start := pos
+	for start > 0 && e.isWordRune(at(start-1)) {
+		start--
+	}
+	end := pos + 1
+	for end < n && e.isWordRune(at(end)) {
+		end++
+	}
+	return start, end, true
+}
+
+// Case selects a ChangeCase transform.
+type Case int
+
+const (
+	CaseUpper Case = iota
+	CaseLower
+	CaseTitle
+)
+
+// ChangeCase rewrites the selection, or the word under the caret without one,
+// in the given case as one undo step. The selection stays active over the
+// transformed text.
+func (e *Editor) ChangeCase(c Case) bool {
+	if e == nil {
+		return false
+	}
+	var a, b int
+	if e.Sel.Active {
+		a, b = e.Sel.Normalised()
+		a = clamp(a, 0, e.RuneLen())
+		b = clamp(b, 0, e.RuneLen())
+	} else {
+		var ok bool
+		if a, b, ok = e.wordRangeAt(e.Caret); !ok {
+			return false
+		}
+	}
+	if a == b {
+		return false
+	}
+	old := string(e.buf.Slice(a, b))
+	var repl string
+	switch c {
+	case CaseUpper:
+		repl = strings.ToUpper(old)
+	case CaseLower:
+		repl = strings.ToLower(old)
+	default:
+		repl = titleCase(old, e.isWordRune)
+	}
+	if repl == old {
+		e.Sel = Sel{Active: true, A: a, B: b}
+		return false
+	}
+	e.recordUndo()
+	rs := []rune(repl)
+	e.deleteRange(a, b)
+	e.insertRunesAt(a, rs)
+	e.dirty = true
+	e.lineSelActive = false
+	e.Sel = Sel{Active: true, A: a, B: a + len(rs)}
+	e.Caret = a + len(rs)
+	return true
+}
+
+// titleCase upper-cases the first letter of each word and lower-cases the
+// rest, isWord deciding which runes belong to words. An apostrophe inside a
+// word does not start a new one.
+func titleCase(s string, isWord func(rune) bool) string {
+	var out strings.Builder
+	inWord := false
+	for _, r := range s {
+		switch {
+		case isWord(r) && inWord:
+			out.WriteRune(unicode.ToLower(r))
+		case isWord(r):
+			out.WriteRune(unicode.ToUpper(r))
+			inWord = true
+		default:
+			out.WriteRune(r)
+			inWord = inWord && r == '\''
+		}
+	}
+	return out.String()
+}
+
 // DeleteLineAtCaret removes the entire line containing the caret.
 func (e *Editor) DeleteLineAtCaret() bool {
 	if e == nil {
@@ -352,11 +657,8 @@ func (e *Editor) DeleteLineAtCaret() bool {
 	if lineIdx < 0 || lineIdx >= len(lines) {
 		return false
 	}
-	start := 0
-	for i := range lineIdx {
-		start += utf8.RuneCountInString(lines[i]) + 1
-	}
-	end := start + utf8.RuneCountInString(lines[lineIdx])
+	start := LineStartOffset(lines, lineIdx)
+	end := LineEndOffset(lines, lineIdx)
 	// remove newline if not last line
 	if lineIdx < len(lines)-1 {
 		end++
@@ -377,6 +679,194 @@ func (e *Editor) DeleteLineAtCaret() bool {
 	return true
 }
 
+// UniqueLines removes repeated lines among those covered by the selection, or
+// the whole buffer without one. Only adjacent repeats collapse unless global
+// is set, in which case every later copy of a line is dropped. It records one
+// undo step when anything changes and returns the number of lines removed.
+func (e *Editor) UniqueLines(global bool) int {
+	if e == nil {
+		return 0
+	}
+	lines := e.Lines()
+	first, last, ok := e.SelectedLineRange(lines)
+	if !ok {
+		first, last = 0, len(lines)-1
+		if last > 0 && lines[last] == "" {
+			// Keep the final newline out of the comparison.
+			last--
+		}
+	}
+	kept := make([]string, 0, last-first+1)
+	seen := map[string]bool{}
+	for _, ln := range lines[first : last+1] {
+		if global {
+			if seen[ln] {
+				continue
+			}
+			seen[ln] = true
+		} else if len(kept) > 0 && kept[len(kept)-1] == ln {
+			continue
+		}
+		kept = append(kept, ln)
+	}
+	removed := last - first + 1 - len(kept)
+	if removed == 0 {
+		return 0
+	}
+	caretLine, _ := LineColForPos(lines, e.Caret)
+	switch {
+	case caretLine > last:
+		caretLine -= removed
+	case caretLine >= first:
+		caretLine = min(caretLine, first+len(kept)-1)
+	}
+	e.ReplaceLines(lines, first, last, kept)
+	e.Caret = LineStartOffset(e.Lines(), caretLine)
+	return removed
+}
+
+// AlignLines pads the selected lines so the first delim on each lines up. With
+// no selection it aligns the run of lines around the caret that contain delim.
+// Lines without delim are left alone. It records one undo step when anything
+// changes and returns the number of lines changed.
+func (e *Editor) AlignLines(delim string) int {
+	if e == nil || delim == "" {
+		return 0
+	}
+	lines := e.Lines()
+	first, last, ok := e.SelectedLineRange(lines)
+	if !ok {
+		first, _ = LineColForPos(lines, e.Caret)
+		if !strings.Contains(lines[first], delim) {
+			return 0
+		}
+		last = first
+		for first > 0 && strings.Contains(lines[first-1], delim) {
+			first--
+		}
+		for last < len(lines)-1 && strings.Contains(lines[last+1], delim) {
+			last++
+		}
+	}
+	width, gap := 0, ""
+	for _, ln := range lines[first : last+1] {
+		before, _, found := strings.Cut(ln, delim)
+		if !found {
+			continue
+		}
+		pre := strings.TrimRight(before, " \t")
+		width = max(width, utf8.RuneCountInString(pre))
+		if len(pre) < len(before) {
+			gap = " "
+		}
+	}
+	out := make([]string, 0, last-first+1)
+	changed := 0
+	for _, ln := range lines[first : last+1] {
+		before, after, found := strings.Cut(ln, delim)
+		if found {
+			pre := strings.TrimRight(before, " \t")
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(pre))
+			if aligned := pre + pad + gap + delim + after; aligned != ln {
+				ln = aligned
+				changed++
+			}
+		}
+		out = append(out, ln)
+	}
+	if changed == 0 {
+		return 0
+	}
+	e.ReplaceLines(lines, first, last, out)
+	e.Caret = LineStartOffset(e.Lines(), first)
+	return changed
+}
+
+// SelectedLineRange returns the lines covered by an active selection. A
+// selection ending at a line start does not cover that line.
+func (e *Editor) SelectedLineRange(lines []string) (int, int, bool) {
+	if !e.Sel.Active {
+		return 0, 0, false
+	}
+	a, b := e.Sel.Normalised()
+	first, _ := LineColForPos(lines, a)
+	last, col := LineColForPos(lines, b)
+	if col == 0 && last > first {
+		last--
+	}
+	return first, last, true
+}
+
+// ReplaceLines swaps lines first..last (inclusive, without the final newline)
+// of lines, which must be the current Lines, for repl as one undo step and
+// clears the selection.
+func (e *Editor) ReplaceLines(lines []string, first, last int, repl []string) {
+	e.recordUndo()
+	start := LineStartOffset(lines, first)
+	end := LineEndOffset(lines, last)
+	e.deleteRange(start, end)
+	e.insertRunesAt(start, []rune(strings.Join(repl, "\n")))
+	e.dirty = true
+	e.Sel.Active = false
+	e.lineSelActive = false
+}
+
+// ReplaceRange replaces the runes in [a,b) with text as one undo step. The
+// range is clamped to the buffer. Positions after the range shift by the size
+// change and positions inside it move to the end of the new text, so a caret
+// at b (or an empty range at the caret) ends up after the replacement; a
+// selection that collapses is cleared.
+func (e *Editor) ReplaceRange(a, b int, text string) {
+	n := e.RuneLen()
+	a, b = clamp(a, 0, n), clamp(b, 0, n)
+	if b < a {
+		a, b = b, a
+	}
+	rs := []rune(text)
+	e.recordUndo()
+	e.deleteRange(a, b)
+	e.insertRunesAt(a, rs)
+	e.dirty = true
+	adjust := func(pos int) int {
+		switch {
+		case pos >= b:
+			return pos + len(rs) - (b - a)
+		case pos > a:
+			return a + len(rs)
+		}
+		return pos
+	}
+	e.Caret = adjust(e.Caret)
+	if e.Sel.Active {
+		e.Sel.A, e.Sel.B = adjust(e.Sel.A), adjust(e.Sel.B)
+		e.Sel.Active = e.Sel.A != e.Sel.B
+	}
+	e.lineSelActive = false
+}
+
+// WrapSelection surrounds the selection, or the word at the caret when
+// nothing is selected, with openDelim and closeDelim as one undo step. The
+// selection then covers the inner text. It returns false when there is
+// nothing to wrap.
+func (e *Editor) WrapSelection(openDelim, closeDelim string) bool {
+	a, b := e.Sel.Normalised()
+	if !e.Sel.Active || a == b {
+		var ok bool
+		if a, b, ok = e.wordRangeAt(e.Caret); !ok {
+			return false
+		}
+	}
+	o, c := []rune(openDelim), []rune(closeDelim)
+	e.recordUndo()
+	e.insertRunesAt(b, c)
+	e.insertRunesAt(a, o)
+	e.dirty = true
+	e.lineSelActive = false
+	e.Sel = Sel{Active: true, A: a + len(o), B: b + len(o)}
+	e.Caret = e.Sel.B
+	return true
+}
+
 func (e *Editor) deleteSelection() {
 	a, b := e.Sel.Normalised()
 	a = clamp(a, 0, e.RuneLen())
@@ -391,34 +881,117 @@ func (e *Editor) deleteSelection() {
 	e.dirty = true
 }
 
-// Undo restores the most recent recorded state (single-step).
+// Undo reverts every change since the most recent recorded step and restores
+// the caret and selection from before it. The step can then be redone.
 func (e *Editor) Undo() {
 	if len(e.undo) == 0 {
 		return
 	}
 	last := e.undo[len(e.undo)-1]
 	e.undo = e.undo[:len(e.undo)-1]
-	e.SetRunes(last.buf)
-	e.Caret = last.caret
+	last.afterCaret, last.afterSel = e.Caret, e.Sel
+	for i := len(last.patches) - 1; i >= 0; i-- {
+		p := last.patches[i]
+		e.applyPatch(p.pos, len(p.new), p.old)
+	}
+	e.redo = append(e.redo, last)
+	e.Caret = clamp(last.caret, 0, e.RuneLen())
 	e.Sel = last.sel
 	e.Leap = LeapState{LastFoundPos: -1}
 }
 
+// Redo reapplies the most recently undone step. Any new edit clears the redo
+// history.
+func (e *Editor) Redo() bool {
+	if len(e.redo) == 0 {
+		return false
+	}
+	next := e.redo[len(e.redo)-1]
+	e.redo = e.redo[:len(e.redo)-1]
+	for _, p := range next.patches {
+		e.applyPatch(p.pos, len(p.old), p.new)
+	}
+	e.undo = append(e.undo, next)
+	e.Caret = clamp(next.afterCaret, 0, e.RuneLen())
+	e.Sel = next.afterSel
+	e.Leap = LeapState{LastFoundPos: -1}
+	return true
+}
+
+// recordUndo starts a new undo step; the buffer changes that follow are
+// collected into it until the next step starts.
 func (e *Editor) recordUndo() {
-	cur := e.buf.Runes()
-	snap := undoState{
-		buf:   cur,
-		caret: e.Caret,
-		sel:   e.Sel,
+	e.undo = append(e.undo, undoStep{caret: e.Caret, sel: e.Sel})
+	if len(e.undo) > undoLimit {
+		e.undo = slices.Delete(e.undo, 0, len(e.undo)-undoLimit)
 	}
-	e.undo = append(e.undo, snap)
-	if len(e.undo) > 256 {
-		e.undo = e.undo[len(e.undo)-256:]
+	e.redo = nil
+}
+
+// notePatch adds a buffer change to the open undo step. Changes made before
+// anything was recorded (such as loading a file) are not undoable.
+func (e *Editor) notePatch(pos int, removed, added []rune) {
+	e.redo = nil
+	if len(e.undo) == 0 {
+		return
+	}
+	step := &e.undo[len(e.undo)-1]
+	step.patches = append(step.patches, undoPatch{pos: pos, old: removed, new: added})
+}
+
+// applyPatch replaces n runes at pos with rs without recording undo.
+func (e *Editor) applyPatch(pos, n int, rs []rune) {
+	e.shiftMarks(pos, n, len(rs))
+	if n > 0 {
+		e.buf.Delete(pos, pos+n)
+	}
+	if len(rs) > 0 {
+		e.buf.Insert(pos, rs)
+	}
+	e.rev++
+	e.dirty = true
+}
+
+// AddMark records a position that moves with later edits and returns its id.
+func (e *Editor) AddMark(pos int) int {
+	e.marks = append(e.marks, clamp(pos, 0, e.RuneLen()))
+	return len(e.marks) - 1
+}
+
+// MarkPos returns the current position of a mark.
+func (e *Editor) MarkPos(id int) (int, bool) {
+	if id < 0 || id >= len(e.marks) || e.marks[id] < 0 {
+		return 0, false
+	}
+	return e.marks[id], true
+}
+
+// RemoveMark forgets a mark; its id is not reused.
+func (e *Editor) RemoveMark(id int) {
+	if id >= 0 && id < len(e.marks) {
+		e.marks[id] = -1
+	}
+}
+
+// shiftMarks moves marks for the replacement of removed runes at pos by
+// inserted runes. Marks after the change shift, marks inside it collapse to
+// pos, and an insertion exactly at a mark pushes the mark along with the
+// text that follows it.
+func (e *Editor) shiftMarks(pos, removed, inserted int) {
+	for i, m := range e.marks {
+		switch {
+		case m < 0:
+		case m >= pos+removed:
+			e.marks[i] = m + inserted - removed
+		case m > pos:
+			e.marks[i] = pos
+		}
 	}
 }
 
 func (e *Editor) MoveCaret(delta int, extendSelection bool) {
 	e.lineSelActive = false
+	e.goalValid = false
 	newPos := clamp(e.Caret+delta, 0, e.RuneLen())
 	if extendSelection {
 		if !e.Sel.Active {
@@ -435,22 +1008,23 @@ func (e *Editor) MoveCaret(delta int, extendSelection bool) {
 }
 
 // MoveCaretLine moves caret by whole lines using a line/col mapping.
+// Consecutive vertical moves keep aiming for the column they started from.
 func (e *Editor) MoveCaretLine(lines []string, deltaLines int, extendSelection bool) {
 	e.lineSelActive = false
 	if deltaLines == 0 {
 		return
 	}
 	curLine, curCol := LineColForPos(lines, e.Caret)
+	if !e.goalValid || e.goalPos != e.Caret || e.goalRev != e.rev {
+		e.goalCol = curCol
+	}
 	targetLine := clamp(curLine+deltaLines, 0, len(lines)-1)
-	// Clamp col to target line length
-	targetCol := min(curCol, utf8.RuneCountInString(lines[targetLine]))
+	// Clamp the goal column to the target line length
+	targetCol := min(e.goalCol, utf8.RuneCountInString(lines[targetLine]))
 
 	// Compute new caret absolute position
-	pos := 0
-	for i := range targetLine {
-		pos += utf8.RuneCountInString(lines[i]) + 1 // include newline
-	}
-	pos += targetCol
+	pos := LineStartOffset(lines, targetLine) + targetCol
+	e.goalPos, e.goalRev, e.goalValid = pos, e.rev, true
 
 	if extendSelection {
 		if !e.Sel.Active {
@@ -479,12 +1053,12 @@ func (e *Editor) MoveCaretLineByLine(lines []string, deltaLines int) {
 	targetLine := clamp(curLine+deltaLines, 0, len(lines)-1)
 	from := min(e.lineSelAnchorLine, targetLine)
 	to := max(e.lineSelAnchorLine, targetLine)
-	selA := lineStartPos(lines, from)
+	selA := LineStartOffset(lines, from)
 	selB := lineEndExclusivePos(lines, to, e.RuneLen())
 	e.Sel.Active = true
 	e.Sel.A = selA
 	e.Sel.B = selB
-	e.Caret = lineStartPos(lines, targetLine)
+	e.Caret = LineStartOffset(lines, targetLine)
 	if from == to {
 		// Single-line mark remains active; keep mode so next Shift+Up/Down extends from anchor.
 	}
@@ -531,17 +1105,14 @@ func (e *Editor) CaretToBufferEdge(lines []string, toEnd bool, extendSelection b
 
 func (e *Editor) moveCaretTo(lineIdx int, col int, lines []string, extendSelection bool) {
 	e.lineSelActive = false
+	e.goalValid = false
 	if lineIdx < 0 {
 		lineIdx = 0
 	}
 	if lineIdx >= len(lines) {
 		lineIdx = len(lines) - 1
 	}
-	pos := 0
-	for i := 0; i < lineIdx; i++ {
-		pos += utf8.RuneCountInString(lines[i]) + 1
-	}
-	pos += col
+	pos := LineStartOffset(lines, lineIdx) + col
 
 	if extendSelection {
 		if !e.Sel.Active {
@@ -557,23 +1128,30 @@ func (e *Editor) moveCaretTo(lineIdx int, col int, lines []string, extendSelecti
 	e.Caret = pos
 }
 
-func lineStartPos(lines []string, lineIdx int) int {
+// LineStartOffset returns the buffer offset of the first rune of line lineIdx
+// of lines (from SplitLines). Out-of-range indexes are clamped.
+func LineStartOffset(lines []string, lineIdx int) int {
 	if len(lines) == 0 {
 		return 0
 	}
-	if lineIdx < 0 {
-		lineIdx = 0
-	}
-	if lineIdx >= len(lines) {
-		lineIdx = len(lines) - 1
-	}
+	lineIdx = clamp(lineIdx, 0, len(lines)-1)
 	pos := 0
-	for i := 0; i < lineIdx; i++ {
+	for i := range lineIdx {
 		pos += utf8.RuneCountInString(lines[i]) + 1
 	}
 	return pos
 }
 
+// LineEndOffset returns the buffer offset just past the last rune of line
+// lineIdx, before its newline. Out-of-range indexes are clamped.
+func LineEndOffset(lines []string, lineIdx int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	lineIdx = clamp(lineIdx, 0, len(lines)-1)
+	return LineStartOffset(lines, lineIdx) + utf8.RuneCountInString(lines[lineIdx])
+}
+
 func lineEndExclusivePos(lines []string, lineIdx int, bufLen int) int {
 	if len(lines) == 0 {
 		return 0
@@ -584,7 +1162,7 @@ func lineEndExclusivePos(lines []string, lineIdx int, bufLen int) int {
 	if lineIdx >= len(lines)-1 {
 		return bufLen
 	}
-	return lineStartPos(lines, lineIdx+1)
+	return LineStartOffset(lines, lineIdx+1)
 }
 
 // KillToLineEnd deletes from caret to end-of-line (including newline if at EOL).
@@ -626,6 +1204,55 @@ func (e *Editor) CopySelection() {
 	_ = e.clip.SetText(string(e.buf.Slice(a, b)))
 }
 
+// CopyText puts text that is not in the buffer, such as popup content, on the
+// clipboard. It reports whether the clipboard took it.
+func (e *Editor) CopyText(text string) bool {
+	if e == nil || e.clip == nil {
+		return false
+	}
+	return e.clip.SetText(text) == nil
+}
+
+// CopyLine copies the caret's line with its newline to the clipboard; the
+// last line gets one added so a later paste inserts a whole line. The buffer
+// and selection are unchanged.
+func (e *Editor) CopyLine() bool {
+	if e == nil || e.clip == nil {
+		return false
+	}
+	lines := e.Lines()
+	lineIdx, _ := LineColForPos(lines, e.Caret)
+	if lineIdx < 0 || lineIdx >= len(lines) {
+		return false
+	}
+	return e.clip.SetText(lines[lineIdx]+"\n") == nil
+}
+
+// CutLine copies the caret's line like CopyLine and deletes it with its
+// newline as one undo step, leaving the caret at the start of the next line.
+// The last line takes the newline before it, the caret going to the start of
+// the line that is now last.
+func (e *Editor) CutLine() bool {
+	if e == nil || e.RuneLen() == 0 || !e.CopyLine() {
+		return false
+	}
+	lines := e.Lines()
+	lineIdx, _ := LineColForPos(lines, e.Caret)
+	start := LineStartOffset(lines, lineIdx)
+	end := lineEndExclusivePos(lines, lineIdx, e.RuneLen())
+	caret := start
+	if lineIdx == len(lines)-1 && lineIdx > 0 {
+		start--
+		caret = LineStartOffset(lines, lineIdx-1)
+	}
+	e.recordUndo()
+	e.deleteRange(start, end)
+	e.Caret = clamp(caret, 0, e.RuneLen())
+	e.Sel.Active = false
+	e.dirty = true
+	return true
+}
+
 func (e *Editor) CutSelection() {
 	e.recordUndo()
 	if !e.Sel.Active || e.clip == nil {
@@ -636,14 +1263,54 @@ func (e *Editor) CutSelection() {
 }
 
 func (e *Editor) PasteClipboard() {
+	if txt, ok := e.ClipboardText(); ok {
+		e.InsertText(txt)
+	}
+}
+
+// ClipboardText reads the clipboard, reporting false when it is unavailable
+// or empty.
+func (e *Editor) ClipboardText() (string, bool) {
 	if e.clip == nil {
-		return
+		return "", false
 	}
 	txt, err := e.clip.GetText()
 	if err != nil || txt == "" {
-		return
+		return "", false
 	}
-	e.InsertText(txt)
+	return txt, true
+}
+
+// PasteLines inserts the clipboard text as whole lines above or below the
+// caret's line, whatever the caret column, as one undo step. The text gets
+// exactly one trailing newline so it fills its own lines; the caret goes to
+// the start of the first pasted line and any selection is dropped.
+func (e *Editor) PasteLines(above bool) bool {
+	if e == nil || e.clip == nil {
+		return false
+	}
+	txt, err := e.clip.GetText()
+	if err != nil || txt == "" {
+		return false
+	}
+	txt = strings.TrimSuffix(txt, "\n") + "\n"
+	lines := e.Lines()
+	lineIdx, _ := LineColForPos(lines, e.Caret)
+	pos := LineStartOffset(lines, lineIdx)
+	if !above {
+		pos = lineEndExclusivePos(lines, lineIdx, e.RuneLen())
+		if lineIdx == len(lines)-1 && pos > 0 {
+			// The last line has no newline: end it and drop the block's.
+			txt = "\n" + strings.TrimSuffix(txt, "\n")
+		}
+	}
+	e.Sel = Sel{}
+	e.ReplaceRange(pos, pos, txt)
+	e.Caret = pos
+	if strings.HasPrefix(txt, "\n") {
+		e.Caret++
+	}
+	return true
 }
 
 // ======================
@@ -688,11 +1355,23 @@ func LineColForPos(lines []string, pos int) (int, int) {
 }
 
 func (e *Editor) insertRunesAt(pos int, rs []rune) {
+	if len(rs) > 0 {
+		e.notePatch(pos, nil, slices.Clone(rs))
+		e.shiftMarks(pos, 0, len(rs))
+	}
 	e.buf.Insert(pos, rs)
+	e.rev++
 }
 
 func (e *Editor) deleteRange(start, end int) {
+	start = clamp(start, 0, e.RuneLen())
+	end = clamp(end, start, e.RuneLen())
+	if end > start {
+		e.notePatch(start, e.buf.Slice(start, end), nil)
+		e.shiftMarks(start, end-start, 0)
+	}
 	e.buf.Delete(start, end)
+	e.rev++
 }
 
 func CaretLineAt(lines []string, caret int) int {
@@ -778,6 +1457,15 @@ func matchAtFold(hay, needle []rune, i int, hf, nf func(rune) rune) bool {
 // Util
 // ======================
 
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// isWordRune is isWordRune extended by the editor's extra word characters.
+func (e *Editor) isWordRune(r rune) bool {
+	return isWordRune(r) || strings.ContainsRune(e.wordChars, r)
+}
+
 func clamp(v, lo, hi int) int {
 	if v < lo {
 		return lo
@@ -1,6 +1,10 @@
 package editor
 
-import "testing"
+import (
+	"slices"
+	"strings"
+	"testing"
+)
 
 // Tests are written scenario-first using a small fixture DSL:
 //   run(t, "buffer", caretPos, func(f *fixture) {
@@ -102,6 +106,152 @@ func TestDeleteWordAtCaretEdgeCases(t *testing.T) {
 	})
 }
 
+func TestWordCharsJoinHyphenatedWords(t *testing.T) {
+	run(t, "foo-bar baz", 5, func(f *fixture) {
+		// By default the hyphen splits the word.
+		f.ed.DeleteWordAtCaret()
+		f.expectBuffer("foo- baz")
+		f.expectCaret(4)
+	})
+
+	run(t, "foo-bar baz", 5, func(f *fixture) {
+		f.ed.SetWordChars("-")
+		if !f.ed.DeleteWordAtCaret() {
+			f.t.Fatal("expected delete to succeed")
+		}
+		f.expectBuffer(" baz")
+		f.expectCaret(0)
+	})
+
+	run(t, "a foo-bar", 9, func(f *fixture) {
+		// At the end, the whole hyphenated word to the left goes.
+		f.ed.SetWordChars("-")
+		f.ed.DeleteWordAtCaret()
+		f.expectBuffer("a ")
+	})
+
+	run(t, "x foo-bar y", 4, func(f *fixture) {
+		f.ed.SetWordChars("-")
+		if !f.ed.SelectWordAtCaret() {
+			f.t.Fatal("expected a word at the caret")
+		}
+		if a, b := f.ed.Sel.Normalised(); a != 2 || b != 9 {
+			f.t.Fatalf("selection=[%d,%d), want [2,9)", a, b)
+		}
+	})
+}
+
+func TestSubwordStops(t *testing.T) {
+	stops := func(ed *Editor, from int, dir Dir) []int {
+		var out []int
+		for pos := from; ; {
+			next := ed.SubwordFrom(pos, dir)
+			if next == pos {
+				return out
+			}
+			out = append(out, next)
+			pos = next
+		}
+	}
+	cases := []struct {
+		text      string
+		fwd, back []int
+	}{
+		{"parseHTTPResponse", []int{5, 9, 17}, []int{9, 5, 0}},
+		{"max_line_len", []int{3, 8, 12}, []int{9, 4, 0}},
+		{"_leading__Under", []int{8, 15}, []int{10, 1, 0}},
+		{"utf8Decode x", []int{3, 4, 10, 12}, []int{11, 4, 3, 0}},
+		{"Hello, World", []int{5, 6, 12}, []int{7, 5, 0}},
+		{"ID ok", []int{2, 5}, []int{3, 0}},
+	}
+	for _, tc := range cases {
+		ed := NewEditor(tc.text)
+		if got := stops(ed, 0, DirFwd); !slices.Equal(got, tc.fwd) {
+			t.Fatalf("%q forward stops=%v, want %v", tc.text, got, tc.fwd)
+		}
+		if got := stops(ed, ed.RuneLen(), DirBack); !slices.Equal(got, tc.back) {
+			t.Fatalf("%q backward stops=%v, want %v", tc.text, got, tc.back)
+		}
+	}
+}
+
+func TestSubwordStopsAtExtraWordChars(t *testing.T) {
+	ed := NewEditor("foo-barBaz")
+	ed.SetWordChars("-")
+	if got := ed.SubwordFrom(0, DirFwd); got != 3 {
+		t.Fatalf("first stop=%d, want 3", got)
+	}
+	if got := ed.SubwordFrom(3, DirFwd); got != 7 {
+		t.Fatalf("second stop=%d, want 7", got)
+	}
+}
+
+func TestDeleteSubword(t *testing.T) {
+	run(t, "parseHTTPResponse", 17, func(f *fixture) {
+		if !f.ed.DeleteSubword(DirBack) {
+			f.t.Fatal("expected a subword to delete")
+		}
+		f.expectBuffer("parseHTTP")
+		f.expectCaret(9)
+		f.ed.Undo()
+		f.expectBuffer("parseHTTPResponse")
+	})
+
+	run(t, "max_line_len", 3, func(f *fixture) {
+		f.ed.DeleteSubword(DirFwd)
+		f.expectBuffer("max_len")
+		f.expectCaret(3)
+	})
+
+	run(t, "abc", 0, func(f *fixture) {
+		if f.ed.DeleteSubword(DirBack) {
+			f.t.Fatal("nothing to delete before the start")
+		}
+	})
+}
+
+func TestApplyTextKeepsCaretOnReindentedLine(t *testing.T) {
+	before := "func f() {\n  if x {\n      y()\n  }\n}\n"
+	after := "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n"
+	caret := strings.Index(before, "y()") + 1 // on "("
+	run(t, before, caret, func(f *fixture) {
+		mark := f.ed.AddMark(strings.Index(before, "}\n}"))
+		if !f.ed.ApplyText([]rune(after)) {
+			f.t.Fatal("expected a change")
+		}
+		f.expectBuffer(after)
+		f.expectCaret(strings.Index(after, "y()") + 1)
+		if got, _ := f.ed.MarkPos(mark); got != strings.Index(after, "}\n}") {
+			f.t.Fatalf("mark=%d, want it on the closing brace", got)
+		}
+		f.ed.Undo()
+		f.expectBuffer(before)
+		f.expectCaret(caret)
+	})
+
+	run(t, "a\nb\n", 2, func(f *fixture) {
+		f.ed.Sel = Sel{Active: true, A: 2, B: 3}
+		f.ed.ApplyText([]rune("a\n\nb\nc\n"))
+		f.expectBuffer("a\n\nb\nc\n")
+		if a, b := f.ed.Sel.Normalised(); a != 3 || b != 4 {
+			f.t.Fatalf("selection=[%d,%d), want it still on b", a, b)
+		}
+		if f.ed.ApplyText([]rune("a\n\nb\nc\n")) {
+			f.t.Fatal("same text should not change the buffer")
+		}
+	})
+}
+
+func TestDiffLinesFindsScatteredChanges(t *testing.T) {
+	a := []string{"a", "b", "c", "d", "e"}
+	b := []string{"a", "B", "c", "d", "x", "e"}
+	got := diffLines(a, b)
+	want := []lineHunk{{1, 2, 1, 2}, {4, 4, 4, 5}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("hunks=%v, want %v", got, want)
+	}
+}
+
 func TestLeap_AnchoredAtOrigin_Forward(t *testing.T) {
 	// Leap refinements are anchored at the origin caret; this confirms a forward
 	// leap moves from position 0 to the first "hello" while committing the query.
@@ -215,6 +365,117 @@ func TestSelecting_UpdatesSelectionOnLeapSearch(t *testing.T) {
 	})
 }
 
+func TestLeapStartSelecting_ExtendsSelectionToEachMatch(t *testing.T) {
+	// Leap-and-select anchors at the origin, so every refinement of the query
+	// moves the selection end to the new match.
+	run(t, "go gopher gopls", 0, func(f *fixture) {
+		f.ed.LeapStartSelecting(DirFwd)
+		f.expectLeapActive(true)
+
+		f.ed.LeapAppend("go")
+		f.expectSelection(true, 0, 0) // match at origin
+
+		f.ed.LeapAppend("p")
+		f.expectCaret(3)
+		f.expectSelection(true, 0, 3)
+
+		f.ed.LeapAppend("l")
+		f.expectCaret(10)
+		f.expectSelection(true, 0, 10)
+
+		f.commit()
+		f.expectSelection(true, 0, 10)
+	})
+}
+
+func TestLeapStartSelecting_BackwardAndCancel(t *testing.T) {
+	// A backward leap-and-select grows the selection towards the start; cancel
+	// restores the origin and drops the selection it created.
+	run(t, "aa hello bb hello cc", 20, func(f *fixture) {
+		f.ed.LeapStartSelecting(DirBack)
+		f.ed.LeapAppend("hello")
+		f.expectSelection(true, 12, 20)
+
+		f.ed.LeapAppend(" b")
+		f.expectSelection(true, 3, 20)
+
+		f.cancel()
+		f.expectCaret(20)
+		f.expectSelection(false, 0, 0)
+	})
+}
+
+func TestLeapHistory_KeepsDistinctCommitsAndCyclesWithWrap(t *testing.T) {
+	// Two distinct commits are both retrievable (most recent first), repeated
+	// commits of the same query are stored once, and indexes wrap around.
+	run(t, "alpha beta alpha beta", 0, func(f *fixture) {
+		f.leap(DirFwd, "beta")
+		f.commit()
+		f.leap(DirFwd, "alpha")
+		f.commit()
+		f.leap(DirFwd, "alpha")
+		f.commit()
+
+		hist := f.ed.LeapHistory()
+		if len(hist) != 2 || hist[0] != "alpha" || hist[1] != "beta" {
+			f.t.Fatalf("history: got %q", hist)
+		}
+
+		f.ed.Caret = 0
+		if q, ok := f.ed.LeapAgainFromHistory(1, DirFwd); !ok || q != "beta" {
+			f.t.Fatalf("index 1: got %q ok=%v", q, ok)
+		}
+		f.expectCaret(6)
+
+		if q, _ := f.ed.LeapAgainFromHistory(2, DirFwd); q != "alpha" {
+			f.t.Fatalf("index 2 should wrap to the newest entry, got %q", q)
+		}
+		f.expectCaret(11)
+
+		if q, _ := f.ed.LeapAgainFromHistory(-1, DirBack); q != "beta" {
+			f.t.Fatalf("index -1 should wrap to the oldest entry, got %q", q)
+		}
+		f.expectCaret(6)
+	})
+}
+
+func TestLeapAgainFromHistory_EmptyHistory(t *testing.T) {
+	run(t, "abc", 1, func(f *fixture) {
+		if _, ok := f.ed.LeapAgainFromHistory(0, DirFwd); ok {
+			f.t.Fatalf("expected no history")
+		}
+		f.expectCaret(1)
+	})
+}
+
+func TestJumpToChar_ForwardThenBackward(t *testing.T) {
+	// Repeated jumps step through successive occurrences and stop (without
+	// wrapping) at the last one; reversing walks back the same way.
+	run(t, "banana", 0, func(f *fixture) {
+		for _, want := range []int{1, 3, 5} {
+			if !f.ed.JumpToChar('a', DirFwd) {
+				f.t.Fatalf("expected forward jump to %d", want)
+			}
+			f.expectCaret(want)
+		}
+		if f.ed.JumpToChar('a', DirFwd) {
+			f.t.Fatalf("expected no further forward match")
+		}
+		f.expectCaret(5)
+
+		for _, want := range []int{3, 1} {
+			if !f.ed.JumpToChar('a', DirBack) {
+				f.t.Fatalf("expected backward jump to %d", want)
+			}
+			f.expectCaret(want)
+		}
+		if f.ed.JumpToChar('a', DirBack) {
+			f.t.Fatalf("expected no further backward match")
+		}
+		f.expectCaret(1)
+	})
+}
+
 func TestMoveCaretLineUpDown(t *testing.T) {
 	// Up/Down moves by whole lines while clamping column and respects selection extension.
 	run(t, "abc\ndef\ng", 4, func(f *fixture) {
@@ -342,6 +603,201 @@ func TestUndoNoHistoryIsSafe(t *testing.T) {
 	})
 }
 
+func TestUndoRedoRoundTripsInsertsAndDeletes(t *testing.T) {
+	run(t, "hello world", 5, func(f *fixture) {
+		f.ed.InsertText(",")
+		f.ed.DeleteWordAtCaret() // removes the space after the comma
+		f.selectRange(0, 5)
+		f.ed.InsertText("howdy")
+		f.ed.Caret = f.ed.RuneLen()
+		f.ed.InsertText("!\n")
+		f.expectBuffer("howdy,world!\n")
+
+		f.ed.Undo()
+		f.expectBuffer("howdy,world")
+		f.ed.Undo()
+		f.expectBuffer("hello,world")
+		f.expectSelection(true, 0, 5)
+		f.ed.Undo()
+		f.expectBuffer("hello, world")
+		f.ed.Undo()
+		f.expectBuffer("hello world")
+		f.expectCaret(5)
+
+		for range 4 {
+			if !f.ed.Redo() {
+				f.t.Fatalf("redo should succeed")
+			}
+		}
+		f.expectBuffer("howdy,world!\n")
+		if f.ed.Redo() {
+			f.t.Fatalf("nothing left to redo")
+		}
+
+		// A new edit drops the redo history.
+		f.ed.Undo()
+		f.ed.InsertText("?")
+		if f.ed.Redo() {
+			f.t.Fatalf("redo after a new edit")
+		}
+	})
+	// Reloading after undoing everything drops the redo history too.
+	run(t, "ab", 2, func(f *fixture) {
+		f.ed.InsertText("c")
+		f.ed.Undo()
+		f.ed.SetRunes([]rune("xy"))
+		if f.ed.Redo() {
+			f.t.Fatalf("redo after a reload")
+		}
+		f.expectBuffer("xy")
+	})
+}
+
+func TestUndoStoresPatchesNotSnapshots(t *testing.T) {
+	big := strings.Repeat("x", 1<<16)
+	run(t, big, 100, func(f *fixture) {
+		f.ed.InsertText("ab")
+		f.ed.BackspaceOrDeleteSelection(true)
+		// Replacing the whole buffer text keeps only the changed middle; like
+		// any unrecorded change it joins the open step.
+		rs := []rune(f.ed.String())
+		rs[200] = 'y'
+		f.ed.SetRunes(rs)
+		for _, step := range f.ed.undo {
+			for _, p := range step.patches {
+				if len(p.old)+len(p.new) > 2 {
+					f.t.Fatalf("patch at %d holds %d runes", p.pos, len(p.old)+len(p.new))
+				}
+			}
+		}
+		f.ed.Undo()
+		f.ed.Undo()
+		f.expectBuffer(big)
+	})
+}
+
+func TestReplaceRange(t *testing.T) {
+	// Replacing at the start shifts a caret after the range.
+	run(t, "hello world", 8, func(f *fixture) {
+		f.ed.ReplaceRange(0, 5, "howdy there")
+		f.expectBuffer("howdy there world")
+		f.expectCaret(14)
+	})
+	// Replacing at the end leaves the caret after the new text.
+	run(t, "hello world", 11, func(f *fixture) {
+		f.ed.ReplaceRange(6, 99, "gophers")
+		f.expectBuffer("hello gophers")
+		f.expectCaret(13)
+		f.ed.Undo()
+		f.expectBuffer("hello world")
+		f.expectCaret(11)
+	})
+	// An empty range inserts; a reversed range is normalised.
+	run(t, "ac", 0, func(f *fixture) {
+		f.selectRange(0, 2)
+		f.ed.ReplaceRange(1, 1, "b")
+		f.expectBuffer("abc")
+		f.expectSelection(true, 0, 3)
+		f.ed.ReplaceRange(3, 0, "")
+		f.expectBuffer("")
+		f.expectSelection(false, 0, 0)
+	})
+}
+
+func TestMarksFollowEdits(t *testing.T) {
+	run(t, "one\ntwo\nthree", 0, func(f *fixture) {
+		mark := f.ed.AddMark(4) // start of "two"
+		pos := func() int {
+			f.t.Helper()
+			p, ok := f.ed.MarkPos(mark)
+			if !ok {
+				f.t.Fatalf("mark %d lost", mark)
+			}
+			return p
+		}
+		f.ed.InsertText("zero\n")
+		if got := pos(); got != 9 {
+			f.t.Fatalf("after inserting a line above: mark at %d, want 9", got)
+		}
+		f.ed.Caret = 9
+		f.ed.InsertText(">")
+		if got := pos(); got != 10 {
+			f.t.Fatalf("inserting at the mark should push it: %d", got)
+		}
+		f.ed.ReplaceRange(12, 14, "") // inside "two" after the mark
+		if got := pos(); got != 10 {
+			f.t.Fatalf("edits after the mark moved it: %d", got)
+		}
+		f.ed.Undo()
+		f.ed.Undo()
+		if got := pos(); got != 9 {
+			f.t.Fatalf("undo should shift the mark back: %d", got)
+		}
+		f.ed.ReplaceRange(2, 11, "")
+		if got := pos(); got != 2 {
+			f.t.Fatalf("deleting around the mark collapses it: %d", got)
+		}
+		f.ed.RemoveMark(mark)
+		if _, ok := f.ed.MarkPos(mark); ok {
+			f.t.Fatalf("removed mark still reported")
+		}
+	})
+}
+
+func TestLineOffsets(t *testing.T) {
+	cases := []struct {
+		buf         string
+		starts, end []int
+	}{
+		{"héllo\nab\nlast", []int{0, 6, 9}, []int{5, 8, 13}},
+		// A trailing newline leaves an empty last line at the buffer end.
+		{"héllo\nab\n", []int{0, 6, 9}, []int{5, 8, 9}},
+	}
+	for _, c := range cases {
+		lines := SplitLines([]rune(c.buf))
+		for i := range lines {
+			if got := LineStartOffset(lines, i); got != c.starts[i] {
+				t.Fatalf("%q: LineStartOffset(%d) = %d, want %d", c.buf, i, got, c.starts[i])
+			}
+			if got := LineEndOffset(lines, i); got != c.end[i] {
+				t.Fatalf("%q: LineEndOffset(%d) = %d, want %d", c.buf, i, got, c.end[i])
+			}
+		}
+		// Indexes past either end clamp to the first and last lines.
+		if LineStartOffset(lines, -3) != 0 || LineEndOffset(lines, 99) != c.end[len(c.end)-1] {
+			t.Fatalf("%q: out-of-range lines should clamp", c.buf)
+		}
+	}
+	if LineStartOffset(nil, 2) != 0 || LineEndOffset(nil, 2) != 0 {
+		t.Fatalf("no lines should give offset 0")
+	}
+}
+
+func TestMoveCaretLineKeepsGoalColumn(t *testing.T) {
+	run(t, "longline\nx\nlongline", 6, func(f *fixture) {
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.expectCaret(10) // end of "x"
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.expectCaret(17) // column 6 again
+		f.ed.MoveCaretLine(f.ed.Lines(), -2, false)
+		f.expectCaret(6)
+	})
+	// A horizontal move resets the goal.
+	run(t, "longline\nx\nlongline", 6, func(f *fixture) {
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.ed.MoveCaret(-1, false)
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.expectCaret(11)
+	})
+	// So does an edit at the caret.
+	run(t, "longline\nx\nlongline", 6, func(f *fixture) {
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.ed.InsertText("y")
+		f.ed.MoveCaretLine(f.ed.Lines(), 1, false)
+		f.expectCaret(14)
+	})
+}
+
 func TestMoveCaretPageClampsWithinBuffer(t *testing.T) {
 	buf := "short\nline\n"
 	run(t, buf, 0, func(f *fixture) {
@@ -363,10 +819,291 @@ func TestCaretToBufferEdgeSelectionExtends(t *testing.T) {
 	})
 }
 
+func TestSelectWordAtCaret_MiddleWord(t *testing.T) {
+	run(t, "alpha beta gamma", 7, func(f *fixture) {
+		if !f.ed.SelectWordAtCaret() {
+			f.t.Fatal("expected a word under the caret")
+		}
+		f.expectSelection(true, 6, 10)
+		f.expectCaret(10)
+	})
+
+	// Caret just past a word still selects that word.
+	run(t, "alpha beta gamma", 5, func(f *fixture) {
+		f.ed.SelectWordAtCaret()
+		f.expectSelection(true, 0, 5)
+	})
+}
+
+func TestWrapSelection(t *testing.T) {
+	// No selection wraps the word at the caret.
+	run(t, "make it bold now", 9, func(f *fixture) {
+		if !f.ed.WrapSelection("**", "**") {
+			f.t.Fatalf("expected a word to wrap")
+		}
+		f.expectBuffer("make it **bold** now")
+		f.expectSelection(true, 10, 14)
+		f.expectCaret(14)
+		f.ed.Undo()
+		f.expectBuffer("make it bold now")
+	})
+	// A selection is wrapped as is.
+	run(t, "x = a + b", 0, func(f *fixture) {
+		f.selectRange(4, 9)
+		f.ed.WrapSelection("(", ")")
+		f.expectBuffer("x = (a + b)")
+		f.expectSelection(true, 5, 10)
+	})
+	run(t, "  ", 1, func(f *fixture) {
+		if f.ed.WrapSelection("(", ")") {
+			f.t.Fatalf("nothing to wrap between spaces")
+		}
+		f.expectBuffer("  ")
+	})
+}
+
+func TestExpandSelection_WordThenLineThenBuffer(t *testing.T) {
+	run(t, "one\nalpha beta gamma\nthree", 12, func(f *fixture) {
+		f.ed.ExpandSelection()
+		f.expectSelection(true, 10, 14) // "beta"
+
+		f.ed.ExpandSelection()
+		f.expectSelection(true, 4, 21) // "alpha beta gamma\n"
+
+		f.ed.ExpandSelection()
+		f.expectSelection(true, 0, f.ed.RuneLen())
+	})
+}
+
+func TestExpandSelection_CopiesWord(t *testing.T) {
+	run(t, "alpha beta gamma", 7, func(f *fixture) {
+		clip := &stubClipboard{}
+		f.ed.SetClipboard(clip)
+		f.ed.ExpandSelection()
+		f.ed.CopySelection()
+		if clip.text != "beta" {
+			f.t.Fatalf("clipboard: want %q, got %q", "beta", clip.text)
+		}
+	})
+}
+
+func TestCutLine_MiddleAndLastLine(t *testing.T) {
+	run(t, "one\ntwo\nthree", 5, func(f *fixture) {
+		clip := &stubClipboard{}
+		f.ed.SetClipboard(clip)
+		if !f.ed.CutLine() {
+			f.t.Fatalf("CutLine should cut the middle line")
+		}
+		f.expectBuffer("one\nthree")
+		f.expectCaret(4)
+		if clip.text != "two\n" {
+			f.t.Fatalf("clipboard: want %q, got %q", "two\n", clip.text)
+		}
+		f.ed.Undo()
+		f.expectBuffer("one\ntwo\nthree")
+
+		f.ed.Caret = f.ed.RuneLen() - 1
+		f.ed.CutLine()
+		f.expectBuffer("one\ntwo")
+		f.expectCaret(4)
+		if clip.text != "three\n" {
+			f.t.Fatalf("clipboard: want %q, got %q", "three\n", clip.text)
+		}
+	})
+	run(t, "", 0, func(f *fixture) {
+		f.ed.SetClipboard(&stubClipboard{})
+		if f.ed.CutLine() {
+			f.t.Fatalf("an empty buffer has no line to cut")
+		}
+	})
+}
+
+func TestPasteLines_BelowAndAbove(t *testing.T) {
+	run(t, "one\ntwo\nthree", 5, func(f *fixture) {
+		clip := &stubClipboard{text: "a\nb"}
+		f.ed.SetClipboard(clip)
+		if !f.ed.PasteLines(false) {
+			f.t.Fatalf("PasteLines should paste")
+		}
+		f.expectBuffer("one\ntwo\na\nb\nthree")
+		f.expectCaret(8)
+		f.ed.Undo()
+		f.expectBuffer("one\ntwo\nthree")
+
+		clip.text = "a\nb\n"
+		f.ed.Caret = 5
+		f.ed.PasteLines(true)
+		f.expectBuffer("one\na\nb\ntwo\nthree")
+		f.expectCaret(4)
+
+		f.ed.Caret = f.ed.RuneLen()
+		f.ed.PasteLines(false)
+		f.expectBuffer("one\na\nb\ntwo\nthree\na\nb")
+		f.expectCaret(18)
+	})
+	run(t, "x", 0, func(f *fixture) {
+		f.ed.SetClipboard(&stubClipboard{})
+		if f.ed.PasteLines(false) {
+			f.t.Fatalf("an empty clipboard pastes nothing")
+		}
+		f.expectBuffer("x")
+	})
+}
+
+func TestLinesCacheInvalidatedByEdits(t *testing.T) {
+	run(t, "one\ntwo", 3, func(f *fixture) {
+		first := f.ed.Lines()
+		if len(first) != 2 {
+			f.t.Fatalf("lines: want 2, got %d", len(first))
+		}
+		if again := f.ed.Lines(); &again[0] != &first[0] {
+			f.t.Fatalf("unchanged buffer should reuse cached lines")
+		}
+		rev := f.ed.Revision()
+		f.ed.MoveCaret(1, false)
+		if f.ed.Revision() != rev {
+			f.t.Fatalf("caret movement should not change the revision")
+		}
+		f.ed.InsertText("\n")
+		if got := f.ed.Lines(); len(got) != 3 || got[1] != "" {
+			f.t.Fatalf("lines after insert: %q", got)
+		}
+		f.ed.BackspaceOrDeleteSelection(true)
+		if got := f.ed.Lines(); len(got) != 2 {
+			f.t.Fatalf("lines after backspace: %q", got)
+		}
+		f.ed.SetRunes([]rune("a\nb\nc\nd"))
+		if got := f.ed.Lines(); len(got) != 4 {
+			f.t.Fatalf("lines after SetRunes: %q", got)
+		}
+	})
+}
+
+func TestUniqueLinesAdjacentAndGlobal(t *testing.T) {
+	// Adjacent mode collapses runs only; the later "a" survives.
+	run(t, "a\na\nb\nb\nb\na\n", 0, func(f *fixture) {
+		if n := f.ed.UniqueLines(false); n != 3 {
+			f.t.Fatalf("adjacent: removed %d, want 3", n)
+		}
+		f.expectBuffer("a\nb\na\n")
+		f.ed.Undo()
+		f.expectBuffer("a\na\nb\nb\nb\na\n")
+	})
+
+	// Global mode keeps the first copy of each line; the caret on a removed
+	// line lands on a valid line start.
+	run(t, "a\nb\na\nc\nb\n", len("a\nb\na\nc\nb"), func(f *fixture) {
+		if n := f.ed.UniqueLines(true); n != 2 {
+			f.t.Fatalf("global: removed %d, want 2", n)
+		}
+		f.expectBuffer("a\nb\nc\n")
+		f.expectCaret(len("a\nb\n"))
+	})
+
+	// Only the selected lines are considered; a selection ending at a line
+	// start leaves that line alone.
+	run(t, "x\nx\ny\ny\nx\n", 0, func(f *fixture) {
+		f.ed.Sel = Sel{Active: true, A: len("x\n"), B: len("x\nx\ny\ny\n")}
+		if n := f.ed.UniqueLines(true); n != 1 {
+			f.t.Fatalf("selection: removed %d, want 1", n)
+		}
+		f.expectBuffer("x\nx\ny\nx\n")
+		f.expectSelection(false, 0, 0)
+	})
+
+	run(t, "a\nb\n", 2, func(f *fixture) {
+		if n := f.ed.UniqueLines(true); n != 0 {
+			f.t.Fatalf("no duplicates: removed %d", n)
+		}
+		f.expectBuffer("a\nb\n")
+		f.expectCaret(2)
+	})
+}
+
+func TestAlignLinesOnDelimiter(t *testing.T) {
+	src := "a = 1\nlonger = 2\nmid  = 3\n"
+	run(t, src, 0, func(f *fixture) {
+		f.ed.Sel = Sel{Active: true, A: 0, B: len(src)}
+		if n := f.ed.AlignLines("="); n != 2 {
+			f.t.Fatalf("aligned %d lines, want 2", n)
+		}
+		f.expectBuffer("a      = 1\nlonger = 2\nmid    = 3\n")
+		f.ed.Undo()
+		f.expectBuffer(src)
+	})
+
+	// Without a selection the block of delimiter lines around the caret is
+	// aligned; other lines are untouched.
+	run(t, "x:1\nlong:2\n\nz:3\n", 1, func(f *fixture) {
+		if n := f.ed.AlignLines(":"); n != 1 {
+			f.t.Fatalf("aligned %d lines, want 1", n)
+		}
+		f.expectBuffer("x   :1\nlong:2\n\nz:3\n")
+	})
+}
+
+func TestChangeCaseTransforms(t *testing.T) {
+	// A selection spanning several words keeps covering the result.
+	run(t, "say hello wide world", 0, func(f *fixture) {
+		f.ed.Sel = Sel{Active: true, A: 4, B: 20}
+		f.ed.ChangeCase(CaseUpper)
+		f.expectBuffer("say HELLO WIDE WORLD")
+		f.expectSelection(true, 4, 20)
+		f.ed.ChangeCase(CaseLower)
+		f.expectBuffer("say hello wide world")
+		f.ed.ChangeCase(CaseTitle)
+		f.expectBuffer("say Hello Wide World")
+		f.expectSelection(true, 4, 20)
+		f.ed.Undo()
+		f.expectBuffer("say hello wide world")
+	})
+
+	// Without a selection the word under the caret is changed and selected.
+	run(t, "one twO three", 5, func(f *fixture) {
+		if !f.ed.ChangeCase(CaseUpper) {
+			f.t.Fatalf("expected a change")
+		}
+		f.expectBuffer("one TWO three")
+		f.expectSelection(true, 4, 7)
+	})
+
+	run(t, "don't 'quote' snake_case", 0, func(f *fixture) {
+		f.ed.Sel = Sel{Active: true, A: 0, B: f.ed.RuneLen()}
+		f.ed.ChangeCase(CaseTitle)
+		f.expectBuffer("Don't 'Quote' Snake_case")
+	})
+
+	// Extra word characters join words, as for word deletion.
+	run(t, "KEBAB-CASE name", 0, func(f *fixture) {
+		f.ed.SetWordChars("-")
+		f.ed.Sel = Sel{Active: true, A: 0, B: f.ed.RuneLen()}
+		f.ed.ChangeCase(CaseTitle)
+		f.expectBuffer("Kebab-case Name")
+	})
+
+	run(t, "a  b", 2, func(f *fixture) {
+		if f.ed.ChangeCase(CaseUpper) {
+			f.t.Fatalf("no word under caret should not change anything")
+		}
+		f.expectBuffer("a  b")
+	})
+}
+
 // ========
 // Helpers
 // ========
 
+type stubClipboard struct {
+	text string
+}
+
+func (c *stubClipboard) GetText() (string, error) { return c.text, nil }
+
+func (c *stubClipboard) SetText(s string) error {
+	c.text = s
+	return nil
+}
+
 type fixture struct {
 	t  *testing.T
 	ed *Editor
@@ -0,0 +1,125 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// subwordClass groups runes for subword segmentation.
+type subwordClass int
+
+const (
+	subSpace subwordClass = iota
+	subSep                // underscore and extra word characters
+	subPunct
+	subUpper
+	subLower // lower-case and caseless letters
+	subDigit
+)
+
+func (e *Editor) subwordClassOf(r rune) subwordClass {
+	switch {
+	case unicode.IsSpace(r):
+		return subSpace
+	case r == '_' || strings.ContainsRune(e.wordChars, r):
+		return subSep
+	case unicode.IsUpper(r):
+		return subUpper
+	case unicode.IsLetter(r):
+		return subLower
+	case unicode.IsDigit(r):
+		return subDigit
+	}
+	return subPunct
+}
+
+// subwordBoundary reports whether a subword starts at i, between the runes
+// at i-1 and i of one run: lower to upper (parse|HTTP), the last capital of
+// an acronym before a lower-case letter (HTTP|Response), and letters to
+// digits or punctuation and back.
+func subwordBoundary(class func(int) subwordClass, n, i int) bool {
+	a, b := class(i-1), class(i)
+	if a != b {
+		return !(a == subUpper && b == subLower)
+	}
+	return a == subUpper && i+1 < n && class(i+1) == subLower
+}
+
+// SubwordFrom returns where a subword move from pos in dir stops: past any
+// whitespace and separators (_ and the extra word characters), then to the
+// end (forward) or start (backward) of the next subword.
+func (e *Editor) SubwordFrom(pos int, dir Dir) int {
+	n := e.RuneLen()
+	pos = clamp(pos, 0, n)
+	class := func(i int) subwordClass {
+		r, _ := e.buf.RuneAt(i)
+		return e.subwordClassOf(r)
+	}
+	skip := func(i int) bool {
+		c := class(i)
+		return c == subSpace || c == subSep
+	}
+	i := pos
+	if dir == DirBack {
+		for i > 0 && skip(i-1) {
+			i--
+		}
+		if i == 0 {
+			return 0
+		}
+		i--
+		for i > 0 && !skip(i-1) && !subwordBoundary(class, n, i) {
+			i--
+		}
+		return i
+	}
+	for i < n && skip(i) {
+		i++
+	}
+	if i == n {
+		return n
+	}
+	i++
+	for i < n && !skip(i) && !subwordBoundary(class, n, i) {
+		i++
+	}
+	return i
+}
+
+// MoveSubword moves the caret to the next subword boundary in dir.
+func (e *Editor) MoveSubword(dir Dir, extendSelection bool) {
+	if e == nil {
+		return
+	}
+	e.MoveCaret(e.SubwordFrom(e.Caret, dir)-e.Caret, extendSelection)
+}
+
+// DeleteSubword deletes from the caret to the next subword boundary in dir,
+// or the selection when one is active, as one undo step. It reports whether
+// anything was deleted.
+func (e *Editor) DeleteSubword(dir Dir) bool {
+	if e == nil {
+		return false
+	}
+	if e.Sel.Active {
+		if a, b := e.Sel.Normalised(); a == b {
+			e.Sel.Active = false
+		} else {
+			e.recordUndo()
+			e.deleteSelection()
+			return true
+		}
+	}
+	a, b := e.Caret, e.SubwordFrom(e.Caret, dir)
+	if a > b {
+		a, b = b, a
+	}
+	if a == b {
+		return false
+	}
+	e.recordUndo()
+	e.deleteRange(a, b)
+	e.Caret = a
+	e.dirty = true
+	return true
+}
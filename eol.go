@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+
+	"gc/editor"
+)
+
+// finalNewline describes how a buffer ends.
+type finalNewline int
+
+const (
+	finalNewlineOK      finalNewline = iota // empty, or exactly one newline
+	finalNewlineMissing                     // the last line has no newline
+	finalNewlineExtra                       // blank lines after the last text
+)
+
+// finalNewlineOf classifies the end of ed's text, reading back only over the
+// trailing blank lines. Spaces and tabs count as blank.
+func finalNewlineOf(ed *editor.Editor) finalNewline {
+	n := ed.RuneLen()
+	if n == 0 {
+		return finalNewlineOK
+	}
+	if r, _ := ed.RuneAt(n - 1); r != '\n' {
+		return finalNewlineMissing
+	}
+	newlines := 0
+	for i := n - 1; i >= 0; i-- {
+		r, _ := ed.RuneAt(i)
+		if r == '\n' {
+			newlines++
+		} else if r != ' ' && r != '\t' {
+			break
+		}
+	}
+	if newlines > 1 {
+		return finalNewlineExtra
+	}
+	return finalNewlineOK
+}
+
+// finalNewlineStatus is the status-line note for a file buffer that does not
+// end in exactly one newline.
+func finalNewlineStatus(slot *bufferSlot) string {
+	if slot.path == "" || slot.picker || slot.tailView || strings.HasPrefix(slot.path, "[") {
+		return ""
+	}
+	switch finalNewlineOf(slot.ed) {
+	case finalNewlineMissing:
+		return "no final newline"
+	case finalNewlineExtra:
+		return "blank lines at end"
+	}
+	return ""
+}
+
+// normalizeFinalNewline ends the buffer with exactly one newline, dropping
+// trailing blank lines, as one undo step. It reports whether it changed.
+func normalizeFinalNewline(app *appState) bool {
+	if finalNewlineOf(app.ed) == finalNewlineOK {
+		return false
+	}
+	n := app.ed.RuneLen()
+	end := n
+	for end > 0 {
+		r, _ := app.ed.RuneAt(end - 1)
+		if r != '\n' && r != ' ' && r != '\t' {
+			break
+		}
+		end--
+	}
+	// Keep trailing spaces on the last text line; only whole blank lines go.
+	for end < n {
+		r, _ := app.ed.RuneAt(end)
+		if r == '\n' {
+			break
+		}
+		end++
+	}
+	text := "\n"
+	if end == 0 {
+		text = ""
+	}
+	caret := min(app.ed.Caret, end)
+	app.ed.ReplaceRange(end, n, text)
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = caret
+	app.markDirty()
+	return true
+}
+
+// fileFormatStatus is the encoding and line-ending segment of the status line
+// for a file buffer, e.g. "utf-8 | LF" or "utf-8 bom | CRLF".
+func fileFormatStatus(slot *bufferSlot) string {
+	if slot.path == "" || slot.picker || strings.HasPrefix(slot.path, "[") {
+		return ""
+	}
+	enc, eol := "utf-8", "LF"
+	if slot.bom {
+		enc += " bom"
+	}
+	if slot.crlf {
+		eol = "CRLF"
+	}
+	return enc + " | " + eol
+}
+
+// toggleLineEndings switches the active buffer between LF and CRLF endings on
+// save. The text in the editor always uses LF, so only the buffer is marked
+// modified.
+func toggleLineEndings(app *appState) {
+	slot := &app.buffers[app.bufIdx]
+	slot.crlf = !slot.crlf
+	slot.dirty = true
+	app.lastEvent = "Line endings: LF (saved on next write)"
+	if slot.crlf {
+		app.lastEvent = "Line endings: CRLF (saved on next write)"
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"gc/editor"
+)
+
+// foldRange is a foldable block. Folded, lines start+1..end are hidden and
+// the start line is drawn with tail after it. anchor is the rune offset that
+// identifies the block (the opening brace for Go, the heading line start for
+// Markdown).
+type foldRange struct {
+	start, end int
+	anchor     int
+	tail       string
+}
+
+// foldRanges lists the foldable blocks of a buffer in start-line order.
+func foldRanges(kind syntaxKind, lines []string) []foldRange {
+	switch kind {
+	case syntaxGo:
+		return goFoldRanges(lines)
+	case syntaxMarkdown:
+		return markdownFoldRanges(lines)
+	}
+	return nil
+}
+
+// goFoldRanges finds the brace blocks that span lines, ignoring braces in
+// comments, strings and rune literals. Of several blocks opened on one line
+// only the outermost is kept.
+func goFoldRanges(lines []string) []foldRange {
+	type open struct{ line, pos int }
+	var (
+		stack   []open
+		out     []foldRange
+		byStart = map[int]int{}
+		inBlock bool
+		inRaw   bool
+		off     int
+	)
+	for ln, line := range lines {
+		rs := []rune(line)
+		for i := 0; i < len(rs); i++ {
+			r := rs[i]
+			next := rune(0)
+			if i+1 < len(rs) {
+				next = rs[i+1]
+			}
+			switch {
+			case inBlock:
+				if r == '*' && next == '/' {
+					inBlock = false
+					i++
+				}
+			case inRaw:
+				inRaw = r != '`'
+			case r == '/' && next == '/':
+				i = len(rs)
+			case r == '/' && next == '*':
+				inBlock = true
+				i++
+			case r == '`':
+				inRaw = true
+			case r == '"' || r == '\'':
+				for i++; i < len(rs) && rs[i] != r; i++ {
+					if rs[i] == '\\' {
+						i++
+					}
+				}
+			case r == '{':
+				stack = append(stack, open{ln, off + i})
+			case r == '}' && len(stack) > 0:
+				o := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if ln == o.line {
+					break
+				}
+				fr := foldRange{start: o.line, end: ln, anchor: o.pos, tail: strings.TrimRight(string(rs[i:]), " \t")}
+				// Blocks opened on one line nest, so a later close is the
+				// outer block.
+				if j, ok := byStart[o.line]; ok {
+					out[j] = fr
+				} else {
+					byStart[o.line] = len(out)
+					out = append(out, fr)
+				}
+			}
+		}
+		off += len(rs) + 1
+	}
+	slices.SortFunc(out, func(a, b foldRange) int { return a.start - b.start })
+	return out
+}
+
+// closedFolds returns the folded blocks of buffer idx, whose syntax is kind.
+// A fold whose block no longer parses (its brace was edited away) or that now
+// hides the buffer's caret is opened.
+func closedFolds(app *appState, idx int, kind syntaxKind, lines []string) []foldRange {
+	if idx < 0 || idx >= len(app.buffers) || len(app.buffers[idx].folds) == 0 {
+		return nil
+	}
+	slot := &app.buffers[idx]
+	if slot.foldAnchors == nil || slot.foldTextRev != slot.textRev || slot.foldMode != kind {
+		slot.foldAnchors = map[int]foldRange{}
+		for _, r := range foldRanges(kind, lines) {
+			slot.foldAnchors[r.anchor] = r
+		}
+		slot.foldTextRev, slot.foldMode = slot.textRev, kind
+	}
+	byAnchor := slot.foldAnchors
+	cLine := editor.CaretLineAt(lines, slot.ed.Caret)
+	var out []foldRange
+	kept := slot.folds[:0]
+	for _, id := range slot.folds {
+		pos, _ := slot.ed.MarkPos(id)
+		r, ok := byAnchor[pos]
+		if !ok || (cLine > r.start && cLine <= r.end) {
+			slot.ed.RemoveMark(id)
+			continue
+		}
+		kept = append(kept, id)
+		out = append(out, r)
+	}
+	slot.folds = kept
+	slices.SortFunc(out, func(a, b foldRange) int { return a.start - b.start })
+	return out
+}
+
+// toggleFold unfolds the folded block whose summary line holds the caret, or
+// folds the innermost block (or Markdown section) around the caret.
+func toggleFold(app *appState) error {
+	slot := &app.buffers[app.bufIdx]
+	lines := app.ed.Lines()
+	cLine := editor.CaretLineAt(lines, app.ed.Caret)
+	kind := bufferSyntaxKind(app, app.currentPath, app.ed.Runes())
+	for _, r := range closedFolds(app, app.bufIdx, kind, lines) {
+		if r.start == cLine {
+			i := slices.IndexFunc(slot.folds, func(id int) bool {
+				pos, _ := app.ed.MarkPos(id)
+				return pos == r.anchor
+			})
+			app.ed.RemoveMark(slot.folds[i])
+			slot.folds = slices.Delete(slot.folds, i, i+1)
+			app.lastEvent = fmt.Sprintf("Unfolded lines %d-%d", r.start+1, r.end+1)
+			return nil
+		}
+	}
+	if kind != syntaxGo && kind != syntaxMarkdown {
+		return fmt.Errorf("folding needs a Go or Markdown buffer")
+	}
+	var block *foldRange
+	for _, r := range foldRanges(kind, lines) {
+		if r.start <= cLine && cLine <= r.end {
+			block = &r
+		}
+	}
+	if block == nil {
+		return fmt.Errorf("no block at the caret")
+	}
+	slot.folds = append(slot.folds, app.ed.AddMark(block.anchor))
+	if cLine != block.start {
+		app.ed.Sel = editor.Sel{}
+		app.ed.Caret = block.anchor
+	}
+	app.lastEvent = fmt.Sprintf("Folded lines %d-%d", block.start+1, block.end+1)
+	return nil
+}
+
+// foldView maps display rows to buffer lines when some lines are folded away.
+// The zero rows slice means every line is shown.
+type foldView struct {
+	n       int
+	rows    []int
+	summary map[int]string
+}
+
+func newFoldView(closed []foldRange, n int) foldView {
+	v := foldView{n: n}
+	if len(closed) == 0 {
+		return v
+	}
+	byStart := map[int]foldRange{}
+	for _, r := range closed {
+		byStart[r.start] = r
+	}
+	v.summary = map[int]string{}
+	for ln := 0; ln < n; ln++ {
+		v.rows = append(v.rows, ln)
+		r, ok := byStart[ln]
+		if !ok {
+			continue
+		}
+		// A fold ending on another fold's first line (`} else {`) chains
+		// both summaries onto one row.
+		var tail strings.Builder
+		for ok {
+			tail.WriteString(" …")
+			if r.tail != "" {
+				tail.WriteString(" " + r.tail)
+			}
+			ln = r.end
+			r, ok = byStart[ln]
+		}
+		v.summary[v.rows[len(v.rows)-1]] = tail.String()
+	}
+	return v
+}
+
+// len is the number of display rows.
+func (v foldView) len() int {
+	if v.rows == nil {
+		return v.n
+	}
+	return len(v.rows)
+}
+
+// row is the display row of a line; a hidden line maps to the next shown one.
+func (v foldView) row(line int) int {
+	if v.rows == nil {
+		return line
+	}
+	return sort.SearchInts(v.rows, line)
+}
+
+// lineAt is the buffer line drawn on a display row, or n past the end.
+func (v foldView) lineAt(row int) int {
+	if v.rows == nil {
+		return row
+	}
+	if row < 0 || row >= len(v.rows) {
+		return v.n
+	}
+	return v.rows[row]
+}
+
+// foldLineDelta adjusts a vertical move of delta lines in the active buffer
+// to count shown lines only, so Up/Down step over folded blocks.
+func foldLineDelta(app *appState, lines []string, delta int) int {
+	closed := closedFolds(app, app.bufIdx, bufferSyntaxKind(app, app.currentPath, app.ed.Runes()), lines)
+	if len(closed) == 0 {
+		return delta
+	}
+	v := newFoldView(closed, len(lines))
+	cLine := editor.CaretLineAt(lines, app.ed.Caret)
+	return v.lineAt(clamp(v.row(cLine)+delta, 0, v.len()-1)) - cLine
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// walkFilter holds the optional skip rules for the file walkers
+// (findMatches, listFiles, pickerLines). The zero value skips only dot
+// entries and vendor.
+type walkFilter struct {
+	// gitignore also skips paths matched by the nearest .gitignore.
+	gitignore bool
+	// skipNames are further directory names to skip, e.g. node_modules.
+	skipNames []string
+}
+
+// walkFilter returns the skip rules selected by the app's options.
+func (app *appState) walkFilter() walkFilter {
+	return walkFilter{gitignore: !app.noGitignore, skipNames: app.ignoreNames}
+}
+
+// skipName reports directory names that are never walked: hidden ones,
+// vendor, and the configured extras.
+func (f walkFilter) skipName(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor" || slices.Contains(f.skipNames, name)
+}
+
+// ignorer loads the rules that apply below root, or nil when there are none.
+func (f walkFilter) ignorer(root string) *gitignore {
+	if !f.gitignore {
+		return nil
+	}
+	return loadGitignore(root)
+}
+
+// gitignore is the common subset of .gitignore rules: globs ("*", "?",
+// "[...]", "**"), "!" negation, a trailing "/" for directories only, and a
+// leading or inner "/" anchoring the pattern to the file's directory.
+type gitignore struct {
+	base  string
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// loadGitignore reads the nearest .gitignore at or above dir, stopping at the
+// repository top (a directory holding .git). It returns nil if none is found.
+func loadGitignore(dir string) *gitignore {
+	dir = filepath.Clean(dir)
+	for {
+		if f, err := os.Open(filepath.Join(dir, ".gitignore")); err == nil {
+			defer f.Close()
+			g := &gitignore{base: dir}
+			sc := bufio.NewScanner(f)
+			for sc.Scan() {
+				if r, ok := parseIgnoreRule(sc.Text()); ok {
+					g.rules = append(g.rules, r)
+				}
+			}
+			return g
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// parseIgnoreRule reads one .gitignore line; blank lines and comments give
+// ok == false.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
+
+// ignored reports whether the file or directory at p is ignored; the last
+// matching rule decides. A nil gitignore ignores nothing.
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	rel, err := filepath.Rel(g.base, p)
+	if err != nil || rel == "." || outsideRoot(g.base, p) != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches a slash-separated pattern against path segments;
+// "**" stands for any number of segments.
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -52,6 +52,7 @@ func (h *syntaxHighlighter) lineStyleForKind(path, src string, lines []string, k
 	tsSpecsOnce.Do(initTreeSitterSpecs)
 	spec := tsSpecs[kind]
 	lineStyles := buildTreeSitterLineStyles(spec, src, lines)
+	markCommentMarkers(lines, lineStyles)
 
 	h.lastPath = path
 	h.lastSource = src
@@ -15,6 +15,10 @@ const (
 	styleHeading
 	styleLink
 	stylePunctuation
+	// styleTodo emphasizes TODO/FIXME/XXX/NOTE inside comments.
+	styleTodo
+	// styleError marks stderr and failed exit lines in run buffers.
+	styleError
 )
 
 type syntaxKind int
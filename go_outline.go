@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+
+	"gc/editor"
+)
+
+// goOutlineFromSource lists the top-level declarations of Go source, the
+// same way gopls titles them ("func f", "func (*T).M", "type T", "var v",
+// "const c"), in line order. It parses what it can of a broken file.
+func goOutlineFromSource(src string) []outlineItem {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+	var out []outlineItem
+	add := func(kw string, pos token.Pos, name string) {
+		out = append(out, outlineItem{line: fset.Position(pos).Line - 1, level: 1, title: kw + " " + name})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = "(" + goReceiverType(d.Recv.List[0].Type) + ")." + name
+			}
+			add("func", d.Name.Pos(), name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					add("type", sp.Name.Pos(), sp.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range sp.Names {
+						if n.Name != "_" {
+							add(strings.ToLower(d.Tok.String()), n.Pos(), n.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b outlineItem) int { return a.line - b.line })
+	return out
+}
+
+// goReceiverType renders a method receiver type without type parameters:
+// "T" or "*T".
+func goReceiverType(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.StarExpr:
+		return "*" + goReceiverType(t.X)
+	case *ast.IndexExpr:
+		return goReceiverType(t.X)
+	case *ast.IndexListExpr:
+		return goReceiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// goOutline lists the declarations of the active Go buffer from gopls,
+// falling back to parsing the buffer when gopls is off or fails.
+func goOutline(app *appState) []outlineItem {
+	src := string(app.ed.Runes())
+	if !app.noGopls {
+		items, err := goDocumentSymbols(app.gopls, app.currentPath, src)
+		if err == nil {
+			return items
+		}
+		goplsFailed(app, "Symbol outline")
+	}
+	return goOutlineFromSource(src)
+}
+
+// openGoOutline lists the top-level declarations of the active Go buffer
+// with the one at or above the caret selected.
+func openGoOutline(app *appState) error {
+	items := goOutline(app)
+	if len(items) == 0 {
+		return fmt.Errorf("no declarations")
+	}
+	cLine := editor.CaretLineAt(app.ed.Lines(), app.ed.Caret)
+	sel := 0
+	for i, it := range items {
+		if it.line <= cLine {
+			sel = i
+		}
+	}
+	app.outline = outlineState{active: true, title: "Symbols", items: items, selected: sel}
+	app.lastEvent = fmt.Sprintf("%d symbols: Up/Down choose, Enter jumps, Esc closes", len(items))
+	return nil
+}
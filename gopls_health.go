@@ -0,0 +1,77 @@
+package main
+
+import "fmt"
+
+// goplsStatus is the gopls segment of the status line in Go buffers.
+func goplsStatus(app *appState) string {
+	if app.noGopls {
+		return "gopls: off"
+	}
+	return "gopls: ok"
+}
+
+// goplsFailed turns gopls features off after a failed request and says how to
+// bring them back; what names the feature that hit the failure.
+func goplsFailed(app *appState, what string) {
+	app.noGopls = true
+	app.lastEvent = what + " disabled (gopls unavailable; Esc+Shift+Y retries)"
+}
+
+// goplsFailedOn is goplsFailed for a request started on client. A request
+// still in flight when gopls was restarted fails on the old client; that
+// failure says nothing about the new one and is ignored.
+func goplsFailedOn(app *appState, client *goplsClient, what string) {
+	if client != app.gopls {
+		return
+	}
+	goplsFailed(app, what)
+}
+
+// goplsRestartInterrupt reports whether a gopls client started by
+// retryGopls came up.
+type goplsRestartInterrupt struct {
+	client *goplsClient
+	err    error
+}
+
+// startGopls launches and initializes a gopls client.
+var startGopls = func(client *goplsClient) error {
+	return client.start()
+}
+
+// retryGopls replaces the gopls client after a failure. With an interrupt
+// channel the old client is shut down and the new one started off the UI
+// thread, and the result comes back as a goplsRestartInterrupt; gopls
+// features stay off until then.
+func retryGopls(app *appState) error {
+	old, client := app.gopls, newGoplsClient()
+	app.gopls = client
+	app.noGopls = true
+	start := startGopls
+	if app.requestInterrupt == nil {
+		old.close()
+		return applyGoplsRestart(app, goplsRestartInterrupt{client: client, err: start(client)})
+	}
+	post := app.requestInterrupt
+	go func() {
+		old.close()
+		post(goplsRestartInterrupt{client: client, err: start(client)})
+	}()
+	app.lastEvent = "gopls: restarting"
+	return nil
+}
+
+// applyGoplsRestart turns gopls features back on when the restarted client
+// came up. A result for a client replaced by a later retry is dropped.
+func applyGoplsRestart(app *appState, res goplsRestartInterrupt) error {
+	if res.client != app.gopls {
+		return nil
+	}
+	if res.err != nil {
+		app.noGopls = true
+		return fmt.Errorf("gopls still unavailable: %v", res.err)
+	}
+	app.noGopls = false
+	app.lastEvent = "gopls: ok (restarted)"
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// indentGuideColor is the faint line drawn at each indentation level.
+var indentGuideColor = tcell.NewRGBColor(64, 64, 76)
+
+// indentLevel is the number of whole indent steps of width step (in columns)
+// in line's leading whitespace, tabs expanded to tabWidth.
+func indentLevel(line string, step int) int {
+	if step <= 0 {
+		return 0
+	}
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width = (width/tabWidth + 1) * tabWidth
+		default:
+			return width / step
+		}
+	}
+	return width / step
+}
+
+// indentGuideLevels returns the guide count for each of lines first..last.
+// A blank line has no indentation of its own, so it takes the smaller level
+// of the nearest non-blank lines around it and a block's guides run through
+// its blank lines unbroken.
+func indentGuideLevels(lines []string, first, last, step int) []int {
+	last = min(last, len(lines)-1)
+	if first > last || step <= 0 {
+		return nil
+	}
+	blank := func(ln int) bool { return strings.TrimSpace(lines[ln]) == "" }
+	out := make([]int, last-first+1)
+	for ln := first; ln <= last; ln++ {
+		if !blank(ln) {
+			out[ln-first] = indentLevel(lines[ln], step)
+			continue
+		}
+		above, below := ln-1, ln+1
+		for above >= 0 && blank(above) {
+			above--
+		}
+		for below < len(lines) && blank(below) {
+			below++
+		}
+		if above < 0 || below >= len(lines) {
+			continue
+		}
+		out[ln-first] = min(indentLevel(lines[above], step), indentLevel(lines[below], step))
+	}
+	return out
+}
+
+// indentGuideStep is the guide spacing in columns for buffer idx of the given
+// kind, or 0 when it gets no guides: only code buffers do, unless
+// guides=off. Space-indented buffers use their detected step.
+func indentGuideStep(app *appState, idx int, kind syntaxKind) int {
+	if app.noIndentGuides || idx < 0 || idx >= len(app.buffers) {
+		return 0
+	}
+	switch kind {
+	case syntaxGo, syntaxC, syntaxMiranda:
+	default:
+		return 0
+	}
+	if w := app.buffers[idx].indentWidth; w > 0 {
+		return w
+	}
+	return tabWidth
+}
+
+// drawIndentGuides draws level guides on row from text x, clipped at maxX.
+// Guides only replace blank cells, so text and whitespace markers stay on
+// top, and each cell keeps its background.
+func drawIndentGuides(s tcell.Screen, x, maxX, row, level, step int) {
+	for l := range level {
+		cx := x + l*step
+		if cx >= maxX {
+			return
+		}
+		str, st, _ := s.Get(cx, row)
+		if str != "" && str != " " {
+			continue
+		}
+		s.SetContent(cx, row, '│', nil, st.Foreground(indentGuideColor))
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import "strings"
+
+// detectIndent guesses a buffer's indentation unit from its leading
+// whitespace. It returns 0 for tab indentation (also the default when nothing
+// is indented) or the width of one space-indent step.
+func detectIndent(buf []rune) int {
+	tabLines, spaceLines := 0, 0
+	steps := map[int]int{}
+	prev := 0
+	for line := range strings.SplitSeq(string(buf), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] == '\t' {
+			tabLines++
+			prev = 0
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if n > 0 {
+			spaceLines++
+		}
+		if d := n - prev; d >= 2 && d <= 8 {
+			steps[d]++
+		}
+		prev = n
+	}
+	if spaceLines <= tabLines {
+		return 0
+	}
+	best, bestN := 4, 0
+	for d, n := range steps {
+		if n > bestN || (n == bestN && d < best) {
+			best, bestN = d, n
+		}
+	}
+	return best
+}
+
+// indentUnit is the text inserted for one indent step in the active buffer.
+func indentUnit(app *appState) string {
+	if app == nil || len(app.buffers) == 0 || app.buffers[app.bufIdx].indentWidth == 0 {
+		return "\t"
+	}
+	return strings.Repeat(" ", app.buffers[app.bufIdx].indentWidth)
+}
+
+// doubleSpaceIndents reports whether two quick spaces should become an indent
+// step in the active buffer. Only code buffers use it, so prose and pasted
+// text keep literal spaces.
+func doubleSpaceIndents(app *appState) bool {
+	if app == nil || app.noDoubleSpace || app.ed == nil {
+		return false
+	}
+	switch bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) {
+	case syntaxGo, syntaxC, syntaxMiranda:
+		return true
+	}
+	return false
+}
+
+// pasteReindents reports whether multi-line pastes are re-indented in the
+// active buffer: code buffers only, unless pasteindent=off.
+func pasteReindents(app *appState) bool {
+	if app == nil || app.noPasteIndent || app.ed == nil {
+		return false
+	}
+	switch bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) {
+	case syntaxGo, syntaxC, syntaxMiranda:
+		return true
+	}
+	return false
+}
+
+// reindentPaste shifts a multi-line block to the indentation of the line it
+// is pasted into at pos: the leading whitespace all its non-blank lines share
+// is removed, and every line after the first, which continues the caret
+// line, gets that line's indentation instead. A first line without leading
+// whitespace was copied from mid-line and does not count towards the shared
+// indent. Blank lines become empty.
+func reindentPaste(buf []rune, pos int, txt string) string {
+	lines := strings.Split(txt, "\n")
+	if len(lines) < 2 {
+		return txt
+	}
+	common, first := "", true
+	for i, ln := range lines {
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		lead := ln[:len(ln)-len(strings.TrimLeft(ln, " \t"))]
+		if i == 0 && lead == "" {
+			continue
+		}
+		if first {
+			common, first = lead, false
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(lead) && common[n] == lead[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	start := min(pos, len(buf))
+	for start > 0 && buf[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < len(buf) && (buf[end] == ' ' || buf[end] == '\t') {
+		end++
+	}
+	indent := string(buf[start:end])
+	for i, ln := range lines {
+		switch {
+		case strings.TrimSpace(ln) == "":
+			lines[i] = ""
+		case i == 0:
+			lines[i] = strings.TrimPrefix(ln, common)
+		default:
+			lines[i] = indent + strings.TrimPrefix(ln, common)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pasteClipboard pastes at the caret, re-indenting multi-line code blocks
+// when pasteReindents allows, as one undo step.
+func pasteClipboard(app *appState) {
+	txt, ok := app.ed.ClipboardText()
+	if !ok {
+		return
+	}
+	if pasteReindents(app) {
+		pos := app.ed.Caret
+		if app.ed.Sel.Active {
+			pos, _ = app.ed.Sel.Normalised()
+		}
+		txt = reindentPaste(app.ed.Runes(), pos, txt)
+	}
+	app.ed.InsertText(txt)
+}
+
+// caretInIndent reports whether only tabs and spaces precede the caret on its
+// line, i.e. Tab should indent rather than complete.
+func caretInIndent(app *appState) bool {
+	if app == nil || app.ed == nil {
+		return false
+	}
+	for p := app.ed.Caret - 1; p >= 0; p-- {
+		r, ok := app.ed.RuneAt(p)
+		if !ok || r == '\n' {
+			return true
+		}
+		if r != ' ' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
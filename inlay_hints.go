@@ -0,0 +1,155 @@
+package main
+
+import (
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"gc/editor"
+)
+
+// inlayHintDebounce delays inlay hint requests until typing pauses.
+const inlayHintDebounce = 300 * time.Millisecond
+
+// inlayHint is an annotation gopls asks to show before rune column col of a
+// line, such as a parameter name at a call site or an inferred type.
+type inlayHint struct {
+	line, col int
+	label     string
+}
+
+// inlayHintState caches the hints of one buffer text revision and tracks the
+// request in flight.
+type inlayHintState struct {
+	ed      *editor.Editor
+	textRev int
+	byLine  map[int][]inlayHint
+	// token numbers requests; reqEd/reqRev is the text being asked about.
+	token  int64
+	reqEd  *editor.Editor
+	reqRev int
+}
+
+// inlayHintsInterrupt carries a finished inlay hint request to the UI loop.
+type inlayHintsInterrupt struct {
+	token   int64
+	ed      *editor.Editor
+	textRev int
+	hints   []inlayHint
+	err     error
+	client  *goplsClient // gopls client the request was issued on
+}
+
+// inlayHintsByLine groups hints by line, each line's hints in column order
+// (hints at one column keep their order).
+func inlayHintsByLine(hints []inlayHint) map[int][]inlayHint {
+	out := map[int][]inlayHint{}
+	for _, h := range hints {
+		out[h.line] = append(out[h.line], h)
+	}
+	for _, hs := range out {
+		slices.SortStableFunc(hs, func(a, b inlayHint) int { return a.col - b.col })
+	}
+	return out
+}
+
+// inlayHintVisualCol maps rune column col of line to its visual column once
+// the line's hints are drawn: hints at or before col push it right by their
+// width.
+func inlayHintVisualCol(line string, hints []inlayHint, col int) int {
+	vc := visualColForRuneCol(line, col, tabWidth)
+	for _, h := range hints {
+		if h.col <= col {
+			vc += utf8.RuneCountInString(h.label)
+		}
+	}
+	return vc
+}
+
+// inlayHintStyle dims hint text over the line's background.
+func inlayHintStyle(line tcell.Style) tcell.Style {
+	return line.Foreground(tcell.ColorGray).Italic(true)
+}
+
+// drawInlayHints inserts the hints into a drawn line at x, shifting the
+// cells after each hint right and clipping at maxX.
+func drawInlayHints(s tcell.Screen, x, maxX, row int, line string, hints []inlayHint, st tcell.Style) {
+	n := utf8.RuneCountInString(line)
+	// From the last hint back, so earlier positions are not yet shifted.
+	for i := len(hints) - 1; i >= 0; i-- {
+		h := hints[i]
+		at := x + visualColForRuneCol(line, min(max(h.col, 0), n), tabWidth)
+		w := utf8.RuneCountInString(h.label)
+		for cx := maxX - 1; cx >= at+w; cx-- {
+			str, cst, _ := s.Get(cx-w, row)
+			r := ' '
+			if str != "" {
+				r = []rune(str)[0]
+			}
+			s.SetContent(cx, row, r, nil, cst)
+		}
+		for j, r := range []rune(h.label) {
+			if at+j < maxX {
+				_, cst, _ := s.Get(at+j, row)
+				s.SetContent(at+j, row, r, nil, inlayHintStyle(cst))
+			}
+		}
+	}
+}
+
+// paneInlayHints returns the cached hints of buffer idx when they are for
+// its current text and the option is on.
+func paneInlayHints(app *appState, idx int) map[int][]inlayHint {
+	st := &app.inlay
+	if !app.inlayHints || st.ed == nil || idx < 0 || idx >= len(app.buffers) {
+		return nil
+	}
+	if slot := &app.buffers[idx]; st.ed != slot.ed || st.textRev != slot.textRev {
+		return nil
+	}
+	return st.byLine
+}
+
+// scheduleInlayHints asks gopls, after a pause, for the hints of the active
+// Go buffer when the cached ones are for other text and none are on the way.
+func scheduleInlayHints(app *appState) {
+	if !app.inlayHints || app.noGopls || app.requestInterrupt == nil || len(app.buffers) == 0 {
+		return
+	}
+	slot := &app.buffers[app.bufIdx]
+	buf := app.ed.Runes()
+	if bufferSyntaxKind(app, app.currentPath, buf) != syntaxGo {
+		return
+	}
+	st := &app.inlay
+	if (st.ed == app.ed && st.textRev == slot.textRev) || (st.reqEd == app.ed && st.reqRev == slot.textRev) {
+		return
+	}
+	st.token++
+	st.reqEd, st.reqRev = app.ed, slot.textRev
+	token, ed, rev := st.token, app.ed, slot.textRev
+	path, content := app.currentPath, string(buf)
+	post := app.requestInterrupt
+	fetch := goInlayHints
+	client := app.gopls
+	time.AfterFunc(inlayHintDebounce, func() {
+		hints, err := fetch(client, path, content)
+		post(inlayHintsInterrupt{token: token, ed: ed, textRev: rev, hints: hints, err: err, client: client})
+	})
+}
+
+// applyInlayHints stores the hints of the latest request.
+func applyInlayHints(app *appState, res inlayHintsInterrupt) {
+	st := &app.inlay
+	if res.token != st.token {
+		return
+	}
+	st.reqEd = nil
+	if res.err != nil {
+		goplsFailedOn(app, res.client, "Inlay hints")
+		return
+	}
+	st.ed, st.textRev, st.byLine = res.ed, res.textRev, inlayHintsByLine(res.hints)
+}
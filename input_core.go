@@ -1,9 +1,11 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -82,6 +84,16 @@ const (
 	keyT
 	keyY
 	keyZ
+	keyBackslash
+	keySemicolon
+	keyQuote
+	keyParen
+	keyDollar
+	keyBang
+	keyHash
+	keyBacktick
+	keyBracketL
+	keyBracketR
 )
 
 type keyEvent struct {
@@ -91,19 +103,114 @@ type keyEvent struct {
 	mods   modMask
 }
 
+// dispatchKeyEvent routes a key to the active prompt, the open prompt, or the
+// editor, recording it when a macro is being recorded.
+func dispatchKeyEvent(app *appState, e keyEvent) bool {
+	rec := app.macroRecording
+	prefixWas := app.cmdPrefixActive
+	var keep bool
+	switch {
+	case app.inputActive:
+		keep = handleInputKey(app, e)
+	case app.open.Active:
+		keep = handleOpenKeyEvent(app, e)
+	default:
+		keep = handleKeyEvent(app, e)
+	}
+	noteQueryMatch(app)
+	recordMacroKey(app, rec, prefixWas, e)
+	return keep
+}
+
+// dispatchTextEvent routes typed text like dispatchKeyEvent.
+func dispatchTextEvent(app *appState, text string, mods modMask) bool {
+	rec := app.macroRecording && !app.suppressTextOnce
+	var keep bool
+	switch {
+	case app.inputActive:
+		keep = handleInputText(app, text)
+	case app.open.Active:
+		keep = handleOpenTextEvent(app, text)
+	default:
+		keep = handleTextEvent(app, text, mods)
+	}
+	noteQueryMatch(app)
+	if rec && app.macroRecording {
+		app.macro = append(app.macro, macroEvent{text: text, mods: mods})
+	}
+	return keep
+}
+
 func handleKeyEvent(app *appState, e keyEvent) bool {
 	ed := app.ed
 	app.blinkAt = time.Now()
 	app.lastMods = e.mods
 	prefixed := false
+	if e.down && e.key != keyReturn && e.key != keyKpEnter {
+		app.insertRunOpen = false
+	}
+
+	if app.palette.active {
+		if e.down {
+			return handlePaletteKey(app, e)
+		}
+		return true
+	}
+	if app.outline.active {
+		if e.down {
+			return handleOutlineKey(app, e)
+		}
+		return true
+	}
+	if app.codeActions.active {
+		if e.down {
+			return handleCodeActionKey(app, e)
+		}
+		return true
+	}
+	if app.sidebar.focused {
+		if e.down {
+			return handleSidebarKey(app, e)
+		}
+		return true
+	}
 
+	count := 1
+	if e.down && app.pendingCount > 0 {
+		if _, ok := countDigit(e); ok {
+			// The digit's text event extends the count.
+			return true
+		}
+		if e.key == keyEscape {
+			app.pendingCount = 0
+			app.lastEvent = "Count cancelled"
+			return true
+		}
+		count = takeCount(app)
+		if cmd, ok := stepNumberKey(e); ok {
+			app.suppressTextOnce = true
+			app.RunCommand(cmd, strconv.Itoa(count))
+			return true
+		}
+	}
+
+	if handleSymbolInfoSearchKey(app, e, app.cmdPrefixActive) {
+		return true
+	}
 	if e.down && e.repeat == 0 && e.key == keyEscape && strings.TrimSpace(app.symbolInfoPopup) != "" {
 		app.symbolInfoPopup = ""
 		app.symbolInfoScroll = 0
+		resetSymbolInfoSearch(app)
 		app.cmdPrefixActive = false
 		app.lastEvent = "Closed symbol info"
 		return true
 	}
+	if e.down && e.repeat == 0 && e.key == keyEscape && app.sigHelpActive {
+		closeSignatureHelp(app)
+		app.cmdPrefixActive = false
+		app.lastEvent = "Closed signature help"
+		return true
+	}
 
 	if e.down && e.repeat == 0 && app.cmdPrefixActive {
 		app.cmdPrefixActive = false
@@ -131,7 +238,11 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 		}
 		if e.key == keySlash {
 			app.suppressTextOnce = false
-			startSearchMode(app)
+			app.RunCommand(CmdSearch, "")
+			return true
+		}
+		if d, ok := countDigit(e); ok && d > 0 {
+			addCountDigit(app, d)
 			return true
 		}
 		e.mods |= modCtrl
@@ -219,11 +330,25 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 	}
 	if e.down && e.repeat == 0 && app.lessMode && e.key == keySpace {
 		app.suppressTextOnce = true
-		lines := editor.SplitLines(ed.Runes())
-		ed.MoveCaretPage(lines, 20, editor.DirFwd, false)
+		lines := ed.Lines()
+		ed.MoveCaretPage(lines, pageLines(app), editor.DirFwd, false)
 		app.lastEvent = "Less mode: paged"
 		return true
 	}
+	if e.down && e.repeat == 0 && app.jumpCharArmed {
+		if e.key == keyEscape {
+			exitJumpCharMode(app)
+			app.lastEvent = "Jump mode off"
+			return true
+		}
+		if (e.mods & (modCtrl | modLAlt | modRAlt)) == 0 {
+			if _, ok := keyToRune(e.key, e.mods); ok {
+				// The matching text event decides whether to jump or exit.
+				return true
+			}
+		}
+		exitJumpCharMode(app)
+	}
 	if e.down && e.repeat == 0 && e.key == keyEscape && !ed.Leap.Active {
 		app.cmdPrefixActive = true
 		app.escHelpVisible = false
@@ -245,6 +370,17 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 
 	if e.down && app.symbolInfoPopup != "" {
 		switch e.key {
+		case keyC:
+			if (e.mods&modCtrl) == 0 || prefixed {
+				break
+			}
+			// The popup text is copied in full, not as wrapped on screen.
+			if ed.CopyText(app.symbolInfoPopup) {
+				app.lastEvent = "Copied symbol info"
+			} else {
+				app.lastEvent = "COPY ERR: no clipboard"
+			}
+			return true
 		case keyUp:
 			app.symbolInfoScroll = max(0, app.symbolInfoScroll-1)
 			return true
@@ -271,6 +407,15 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 			if (e.mods&modShift) != 0 && (e.mods&modCtrl) == 0 {
 				app.switchBuffer(-1)
 				app.lastEvent = fmt.Sprintf("Switched to buffer %d/%d", app.bufIdx+1, len(app.buffers))
+				checkExternalChange(app)
+				return true
+			}
+			if readOnlyBlocked(app) {
+				return true
+			}
+			if caretInIndent(app) {
+				ed.InsertText(indentUnit(app))
+				app.markDirty()
 				return true
 			}
 			if tryManualCompletion(app) {
@@ -281,6 +426,10 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 
 		ctrlHeld := (e.mods & modCtrl) != 0
 		if ctrlHeld {
+			if cmd, ok := app.boundCommand(e, prefixed); ok {
+				app.RunCommand(cmd, "")
+				return true
+			}
 			switch e.key {
 			case keyQ:
 				if (e.mods & modShift) != 0 {
@@ -288,6 +437,9 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 						app.lastEvent = "Use Esc+Shift+Q to quit all"
 						return true
 					}
+					for _, b := range app.buffers {
+						removeSwap(b.path)
+					}
 					app.lastEvent = "Quit (discard all buffers)"
 					return false
 				}
@@ -298,56 +450,30 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 				}
 				app.lastEvent = fmt.Sprintf("Closed buffer, now %d/%d", app.bufIdx+1, remaining)
 				return true
-			case keyB:
-				app.addBuffer()
-				app.lastEvent = fmt.Sprintf("New buffer %d/%d", app.bufIdx+1, len(app.buffers))
-				return true
+			// Bound commands (save, run, write, ...) were dispatched above;
+			// the cases below only explain Ctrl forms of Esc commands.
 			case keyW:
-				if prefixed {
-					promptSaveAs(app)
-					return true
+				if !prefixed {
+					app.lastEvent = "Use Esc+W to write"
 				}
-				app.lastEvent = "Use Esc+W to write"
 				return true
 			case keyF:
 				if !prefixed {
 					app.lastEvent = "Use Esc+F for format/fix/reload"
-					return true
-				}
-				if err := formatFixReloadCurrent(app); err != nil {
-					app.lastEvent = fmt.Sprintf("FMT/FIX ERR: %v", err)
-				} else {
-					app.lastEvent = fmt.Sprintf("Saved, fmt/fix, reloaded %s", app.currentPath)
 				}
 				return true
 			case keyS:
-				if (e.mods & modShift) != 0 {
-					if !prefixed {
-						app.lastEvent = "Use Esc+Shift+S to save dirty buffers"
-						return true
-					}
-					if err := saveAll(app); err != nil {
-						app.lastEvent = fmt.Sprintf("SAVE ALL ERR: %v", err)
-					} else {
-						app.lastEvent = "Saved dirty buffers"
-					}
-					return true
-				}
-				if err := saveCurrent(app); err != nil {
-					app.lastEvent = fmt.Sprintf("SAVE ERR: %v", err)
-				} else {
-					app.lastEvent = fmt.Sprintf("Saved %s", app.currentPath)
+				if !prefixed && (e.mods&modShift) != 0 {
+					app.lastEvent = "Use Esc+Shift+S to save dirty buffers"
 				}
 				return true
 			case keyR:
-				if err := runCurrentPackage(app); err != nil {
-					app.lastEvent = fmt.Sprintf("RUN ERR: %v", err)
-				} else {
-					app.lastEvent = "Running: go run ."
+				if !prefixed && (e.mods&modShift) != 0 {
+					app.lastEvent = "Use Esc+Shift+R to toggle read-only"
 				}
 				return true
 			case keyA:
-				lines := editor.SplitLines(ed.Runes())
+				lines := ed.Lines()
 				if (e.mods & modShift) != 0 {
 					ed.CaretToBufferEdge(lines, false, true)
 				} else {
@@ -355,22 +481,55 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 				}
 				return true
 			case keyE:
-				lines := editor.SplitLines(ed.Runes())
+				lines := ed.Lines()
 				if (e.mods & modShift) != 0 {
 					ed.CaretToBufferEdge(lines, true, true)
 				} else {
 					ed.CaretToLineEdge(lines, true, false)
 				}
 				return true
+			case keyJ:
+				if prefixed && (e.mods&modShift) != 0 {
+					ed.LeapStartSelecting(editor.DirFwd)
+					app.lastEvent = "Leap select forward: type to extend, Enter to keep"
+				}
+				return true
 			case keyK:
-				ed.KillToLineEnd(editor.SplitLines(ed.Runes()))
+				if prefixed && (e.mods&modShift) != 0 {
+					ed.LeapStartSelecting(editor.DirBack)
+					app.lastEvent = "Leap select back: type to extend, Enter to keep"
+					return true
+				}
+				if readOnlyBlocked(app) {
+					return true
+				}
+				ed.KillToLineEnd(ed.Lines())
 				app.markDirty()
+				noteEdit(app, repeatKillLine)
 				return true
 			case keyU:
+				if readOnlyBlocked(app) {
+					return true
+				}
+				if prefixed && (e.mods&modShift) != 0 {
+					cycleCase(app)
+					return true
+				}
 				ed.Undo()
 				app.lastEvent = "Undo"
 				app.markDirty()
 				return true
+			case keyY:
+				if readOnlyBlocked(app) {
+					return true
+				}
+				if !ed.Redo() {
+					app.lastEvent = "Nothing to redo"
+					return true
+				}
+				app.lastEvent = "Redo"
+				app.markDirty()
+				return true
 			case keyI:
 				if !prefixed {
 					app.lastEvent = "Use Esc+I for symbol info"
@@ -383,31 +542,18 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 					app.symbolInfoPopup = showSymbolInfo(app)
 					app.symbolInfoScroll = 0
 				}
+				resetSymbolInfoSearch(app)
 				return true
 			case keyM:
 				if !prefixed {
 					app.lastEvent = "Use Esc+M to cycle language mode"
-					return true
-				}
-				mode := cycleBufferMode(app)
-				app.lastEvent = "Mode: " + mode
-				return true
-			case keySlash:
-				if (e.mods & modShift) != 0 {
-					app.addBuffer()
-					app.ed.SetRunes([]rune(helpText()))
-					app.touchActiveBufferText()
-					app.currentPath = ""
-					app.buffers[app.bufIdx].path = ""
-					app.lastEvent = "Opened shortcuts buffer"
-					return true
 				}
-				toggleComment(ed)
-				app.lastEvent = "Toggled comment"
-				app.markDirty()
 				return true
 			case keyDelete:
 				if prefixed && (e.mods&modShift) != 0 {
+					if readOnlyBlocked(app) {
+						return true
+					}
 					ed.SetRunes(nil)
 					ed.Caret = 0
 					ed.Sel = editor.Sel{}
@@ -417,6 +563,12 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 					return true
 				}
 			case keyO:
+				if (e.mods & modShift) != 0 {
+					if !prefixed {
+						app.lastEvent = "Use Esc+Shift+O to set an option"
+					}
+					return true
+				}
 				listRoot := app.openRoot
 				if listRoot == "" {
 					if cwd, err := os.Getwd(); err == nil {
@@ -426,7 +578,7 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 				if len(app.buffers) > 0 && app.buffers[app.bufIdx].picker {
 					listRoot = filepath.Dir(listRoot)
 				}
-				list, err := pickerLines(listRoot, 500)
+				list, err := pickerListing(app, listRoot)
 				if err != nil {
 					app.lastEvent = fmt.Sprintf("OPEN ERR: %v", err)
 					return true
@@ -448,29 +600,150 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 				app.lastEvent = fmt.Sprintf("OPEN: file picker (%d files). Leap to a line, Ctrl+L to load", len(list))
 				return true
 			case keyL:
+				if ok, err := followMarkdownLink(app); ok {
+					if err != nil && !promptOpenOutsideRoot(app, err) {
+						app.lastEvent = fmt.Sprintf("LOAD ERR: %v", err)
+					}
+					return true
+				}
 				if err := loadFileAtCaret(app); err != nil {
-					app.lastEvent = fmt.Sprintf("LOAD ERR: %v", err)
+					if !promptOpenOutsideRoot(app, err) {
+						app.lastEvent = fmt.Sprintf("LOAD ERR: %v", err)
+					}
 				} else {
-					app.lastEvent = fmt.Sprintf("Opened %s", app.currentPath)
+					app.lastEvent = openedStatus(app)
+				}
+				return true
+			case keyP:
+				if !prefixed {
+					app.lastEvent = "Use Esc+p to switch split pane"
+					return true
+				}
+				if (e.mods & modShift) != 0 {
+					return true
+				}
+				if !app.switchSplitFocus() {
+					app.lastEvent = "Split view is off (Esc+Shift+V)"
+					return true
+				}
+				app.lastEvent = fmt.Sprintf("Focused pane: buffer %d/%d", app.bufIdx+1, len(app.buffers))
+				return true
+			case keyD:
+				if !prefixed {
+					app.lastEvent = "Use Esc+d for the diagnostics summary"
+					return true
+				}
+				if (e.mods & modShift) != 0 {
+					uniqueLines(app, false)
+				}
+				return true
+			case keyG:
+				if !prefixed {
+					app.lastEvent = "Use Esc+g to toggle the test file"
+					return true
+				}
+				if (e.mods & modShift) != 0 {
+					uniqueLines(app, true)
+				}
+				return true
+			case keyH:
+				if !prefixed {
+					app.lastEvent = "Use Esc+h to cycle leap history"
+					return true
+				}
+				q, ok := ed.LeapAgainFromHistory(app.leapHistoryPos, editor.DirFwd)
+				if !ok {
+					app.lastEvent = "Leap history empty"
+					return true
+				}
+				n := len(ed.LeapHistory())
+				app.lastEvent = fmt.Sprintf("Leap history %d/%d: %q", app.leapHistoryPos%n+1, n, q)
+				app.leapHistoryPos = (app.leapHistoryPos + 1) % n
+				return true
+			case keyT:
+				if !prefixed {
+					app.lastEvent = "Use Esc+t / Esc+Shift+T to jump to a character"
+					return true
+				}
+				dir := editor.DirFwd
+				if (e.mods & modShift) != 0 {
+					dir = editor.DirBack
 				}
+				startJumpCharMode(app, dir)
 				return true
 			case keyComma:
-				lines := editor.SplitLines(ed.Runes())
-				ed.MoveCaretPage(lines, 20, editor.DirBack, (e.mods&modShift) != 0)
+				lines := ed.Lines()
+				ed.MoveCaretPage(lines, pageLines(app)*count, editor.DirBack, (e.mods&modShift) != 0)
 				return true
 			case keyPeriod:
-				lines := editor.SplitLines(ed.Runes())
-				ed.MoveCaretPage(lines, 20, editor.DirFwd, (e.mods&modShift) != 0)
+				lines := ed.Lines()
+				ed.MoveCaretPage(lines, pageLines(app)*count, editor.DirFwd, (e.mods&modShift) != 0)
+				return true
+			case keySemicolon:
+				return true
+			case keyZ:
+				if !prefixed {
+					app.lastEvent = "Use Esc+z to record a macro"
+					return true
+				}
+				if (e.mods & modShift) != 0 {
+					promptMacroReplay(app)
+				} else {
+					toggleMacroRecording(app)
+				}
+				return true
+			case keyBackslash:
+				if !prefixed {
+					return true
+				}
+				if !readOnlyBlocked(app) {
+					promptAlign(app)
+				}
+				return true
+			case keyEquals:
+				if !prefixed {
+					return true
+				}
+				ed.ExpandSelection()
+				app.lastEvent = "Expanded selection"
 				return true
 			case keyC:
+				if prefixed && (e.mods&modShift) != 0 {
+					return true
+				}
+				if !ed.Sel.Active {
+					if ed.CopyLine() {
+						app.lastEvent = "Copied line"
+					}
+					return true
+				}
 				ed.CopySelection()
 				return true
 			case keyX:
+				if readOnlyBlocked(app) {
+					return true
+				}
+				if !ed.Sel.Active {
+					if ed.CutLine() {
+						app.markDirty()
+						app.lastEvent = "Cut line"
+					}
+					return true
+				}
 				ed.CutSelection()
 				app.markDirty()
 				return true
 			case keyV:
-				ed.PasteClipboard()
+				if (e.mods & modShift) != 0 {
+					if !prefixed {
+						app.lastEvent = "Use Esc+Shift+V to toggle split view"
+					}
+					return true
+				}
+				if readOnlyBlocked(app) {
+					return true
+				}
+				pasteClipboard(app)
 				app.markDirty()
 				return true
 			}
@@ -486,7 +759,11 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 			ed.LeapBackspace()
 			return true
 		case keyReturn, keyKpEnter:
+			if ed.Caret != ed.Leap.OriginCaret {
+				recordJumpAt(app, ed.Leap.OriginCaret)
+			}
 			ed.LeapEndCommit()
+			app.leapHistoryPos = 0
 			return true
 		}
 
@@ -498,7 +775,70 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 	}
 
 	if !ed.Leap.Active && e.down {
-		lines := editor.SplitLines(ed.Runes())
+		lines := ed.Lines()
+		// Esc+<move> selects like Shift+<move>, for terminals that do not
+		// report Shift on arrow keys.
+		extend := (e.mods&modShift) != 0 || prefixed
+		if (e.mods&modCtrl) != 0 && !prefixed {
+			// Ctrl+Up/Down and Ctrl+PageUp/PageDown scroll, leaving the caret.
+			switch e.key {
+			case keyUp:
+				scrollView(app, -count)
+				return true
+			case keyDown:
+				scrollView(app, count)
+				return true
+			case keyPageUp:
+				scrollView(app, -pageLines(app)*count)
+				return true
+			case keyPageDown:
+				scrollView(app, pageLines(app)*count)
+				return true
+			}
+		}
+		if (e.mods&(modLAlt|modRAlt)) != 0 && (e.mods&modCtrl) == 0 && !prefixed {
+			// Alt+Left/Right move by camelCase and snake_case subwords,
+			// Alt+Backspace/Delete delete one.
+			switch e.key {
+			case keyLeft, keyRight:
+				dir := editor.DirFwd
+				if e.key == keyLeft {
+					dir = editor.DirBack
+				}
+				for range count {
+					ed.MoveSubword(dir, extend)
+				}
+				return true
+			case keyBackspace, keyDelete:
+				if readOnlyBlocked(app) || checkExternalChange(app) {
+					return true
+				}
+				dir := editor.DirFwd
+				if e.key == keyBackspace {
+					dir = editor.DirBack
+				}
+				changed := false
+				for range count {
+					if ed.DeleteSubword(dir) {
+						changed = true
+					}
+				}
+				if changed {
+					app.markDirty()
+				}
+				return true
+			}
+		}
+		if e.key == keyDelete && e.mods == 0 && len(app.buffers) > 0 && app.buffers[app.bufIdx].picker {
+			app.RunCommand(CmdDeleteFile, "")
+			return true
+		}
+		switch e.key {
+		case keyBackspace, keyDelete, keyReturn, keyKpEnter:
+			if readOnlyBlocked(app) || checkExternalChange(app) {
+				return true
+			}
+		}
 		switch e.key {
 		case keyBackspace:
 			ed.BackspaceOrDeleteSelection(true)
@@ -507,38 +847,45 @@ func handleKeyEvent(app *appState, e keyEvent) bool {
 			if (e.mods & modShift) != 0 {
 				if ed.DeleteLineAtCaret() {
 					app.markDirty()
+					noteEdit(app, repeatDeleteLine)
 				}
 			} else {
 				// Delete intentionally ignores active selection and targets the word at caret.
 				ed.Sel.Active = false
 				if ed.DeleteWordAtCaret() {
 					app.markDirty()
+					noteEdit(app, repeatDeleteWord)
 				}
 			}
 		case keyLeft:
-			ed.MoveCaret(-1, (e.mods&modShift) != 0)
+			ed.MoveCaret(-count, extend)
 		case keyRight:
-			ed.MoveCaret(1, (e.mods&modShift) != 0)
+			ed.MoveCaret(count, extend)
 		case keyUp:
-			if (e.mods & modShift) != 0 {
-				ed.MoveCaretLineByLine(lines, -1)
+			if extend {
+				for range count {
+					ed.MoveCaretLineByLine(lines, -1)
+				}
 			} else {
-				ed.MoveCaretLine(lines, -1, false)
+				ed.MoveCaretLine(lines, foldLineDelta(app, lines, -count), false)
 			}
 		case keyDown:
-			if (e.mods & modShift) != 0 {
-				ed.MoveCaretLineByLine(lines, 1)
+			if extend {
+				for range count {
+					ed.MoveCaretLineByLine(lines, 1)
+				}
 			} else {
-				ed.MoveCaretLine(lines, 1, false)
+				ed.MoveCaretLine(lines, foldLineDelta(app, lines, count), false)
 			}
 		case keyPageDown:
-			ed.MoveCaretPage(lines, 20, editor.DirFwd, (e.mods&modShift) != 0)
+			ed.MoveCaretPage(lines, pageLines(app)*count, editor.DirFwd, extend)
 		case keyPageUp:
-			ed.MoveCaretPage(lines, 20, editor.DirBack, (e.mods&modShift) != 0)
+			ed.MoveCaretPage(lines, pageLines(app)*count, editor.DirBack, extend)
 		case keyReturn, keyKpEnter:
 			if e.repeat == 0 {
 				ed.InsertText("\n")
 				app.markDirty()
+				noteInsert(app, "\n")
 			}
 		}
 	}
@@ -550,11 +897,32 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 		app.suppressTextOnce = false
 		return true
 	}
+	if app.palette.active {
+		return handlePaletteText(app, text)
+	}
+	if app.outline.active || app.codeActions.active {
+		return true
+	}
+	if app.sidebar.focused {
+		return handleSidebarText(app, text)
+	}
+	if app.symbolInfoSearch && app.symbolInfoPopup != "" {
+		handleSymbolInfoSearchText(app, text)
+		return true
+	}
 	app.blinkAt = time.Now()
 	app.lastEvent = fmt.Sprintf("TEXTINPUT %q mods=%s", text, modsString(mods))
 	if debug {
 		fmt.Println(app.lastEvent)
 	}
+	if app.pendingCount > 0 {
+		if len(text) == 1 && text[0] >= '0' && text[0] <= '9' {
+			addCountDigit(app, int(text[0]-'0'))
+			return true
+		}
+		// Typing drops the count.
+		app.pendingCount = 0
+	}
 
 	if text == "" || !utf8.ValidString(text) {
 		return true
@@ -562,6 +930,9 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 	if app.completionPopup.active {
 		closeCompletionPopup(app)
 	}
+	if app.jumpCharArmed && handleJumpCharText(app, text) {
+		return true
+	}
 	if app.searchActive {
 		if !app.searchPatternDone {
 			if text == "/" {
@@ -607,8 +978,11 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 		ed.LeapAppend(text)
 		return true
 	}
-	if text == " " {
-		lines := editor.SplitLines(ed.Runes())
+	if readOnlyBlocked(app) || checkExternalChange(app) {
+		return true
+	}
+	if text == " " && doubleSpaceIndents(app) {
+		lines := ed.Lines()
 		lineIdx := editor.CaretLineAt(lines, ed.Caret)
 		double := app.lastSpaceLn == lineIdx && time.Since(app.lastSpaceAt) < 2*time.Second
 		app.lastSpaceLn = lineIdx
@@ -620,7 +994,7 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 		}
 		if double {
 			ed.BackspaceOrDeleteSelection(true)
-			lines = editor.SplitLines(ed.Runes())
+			lines = ed.Lines()
 			col := editor.CaretColAt(lines, ed.Caret)
 			lineStart := max(ed.Caret-col, 0)
 			indentEnd := lineStart
@@ -632,7 +1006,7 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 				indentEnd++
 			}
 			ed.Caret = indentEnd
-			ed.InsertText("\t")
+			ed.InsertText(indentUnit(app))
 			app.lastSpaceLn = lineIdx
 			return true
 		}
@@ -641,9 +1015,80 @@ func handleTextEvent(app *appState, text string, mods modMask) bool {
 	}
 	ed.InsertText(text)
 	app.markDirty()
+	noteInsert(app, text)
+	// New text supersedes any completion request still waiting on its debounce.
+	app.completionSeq.Add(1)
+	switch text {
+	case ".":
+		autoCompleteSelector(app)
+	case "(", ",":
+		requestSignatureHelp(app)
+	case ")":
+		closeSignatureHelp(app)
+	}
 	return true
 }
 
+var caseNames = [...]string{editor.CaseUpper: "UPPER", editor.CaseLower: "lower", editor.CaseTitle: "Title"}
+
+// cycleCase changes the selection (or word under the caret) to upper case;
+// pressing it again straight away moves on to lower, then title case.
+func cycleCase(app *appState) {
+	next := editor.CaseUpper
+	if app.caseCycleActive && app.caseCycleRev == app.ed.Revision() {
+		next = (app.caseCycleLast + 1) % editor.Case(len(caseNames))
+	}
+	if app.ed.ChangeCase(next) {
+		app.markDirty()
+	} else if !app.ed.Sel.Active {
+		app.lastEvent = "No word under caret"
+		return
+	}
+	app.caseCycleActive = true
+	app.caseCycleLast = next
+	app.caseCycleRev = app.ed.Revision()
+	app.lastEvent = "Case: " + caseNames[next] + " (Esc+Shift+U again for " + caseNames[(next+1)%editor.Case(len(caseNames))] + ")"
+}
+
+func promptAlign(app *appState) {
+	app.inputActive = true
+	app.inputPrompt = "Align on: "
+	app.inputValue = ""
+	app.inputKind = "align"
+	app.lastEvent = "Align selected lines on a delimiter (e.g. = : |), Enter to apply, Esc to cancel"
+}
+
+// alignLines pads the selected lines (or the block around the caret) so the
+// first delim on each lines up.
+func alignLines(app *appState, delim string) {
+	if delim == "" {
+		app.lastEvent = "Align cancelled (empty delimiter)"
+		return
+	}
+	n := app.ed.AlignLines(delim)
+	if n == 0 {
+		app.lastEvent = fmt.Sprintf("Nothing to align on %q", delim)
+		return
+	}
+	app.markDirty()
+	app.lastEvent = fmt.Sprintf("Aligned %s on %q", plural(n, "line"), delim)
+}
+
+// uniqueLines drops duplicate lines from the selected lines (or the buffer):
+// adjacent repeats only, or every repeat when global is set.
+func uniqueLines(app *appState, global bool) {
+	if readOnlyBlocked(app) {
+		return
+	}
+	n := app.ed.UniqueLines(global)
+	if n == 0 {
+		app.lastEvent = "No duplicate lines"
+		return
+	}
+	app.markDirty()
+	app.lastEvent = fmt.Sprintf("Removed %s", plural(n, "duplicate line"))
+}
+
 func searchHasActiveMatch(app *appState) bool {
 	if app == nil || !app.searchActive {
 		return false
@@ -690,6 +1135,7 @@ func updateSearchMatch(app *appState) {
 	pos, ok := editor.FindInDir(app.ed.Runes(), app.searchQuery, app.searchOrigin, editor.DirFwd, true)
 	if !ok {
 		app.searchLastMatch = -1
+		app.ed.Caret = app.searchOrigin
 		app.ed.Sel.Active = false
 		app.lastEvent = fmt.Sprintf("Search: no match for %q", string(app.searchQuery))
 		return
@@ -744,11 +1190,90 @@ func applySearchMatch(app *appState, pos int) {
 	app.ed.Sel.B = end
 }
 
+// readOnlyBlocked reports whether the active buffer refuses edits, explaining
+// why in the status line.
+func readOnlyBlocked(app *appState) bool {
+	if app == nil || len(app.buffers) == 0 || !app.buffers[app.bufIdx].readOnly {
+		return false
+	}
+	app.lastEvent = "Buffer is read-only (Esc+Shift+R toggles)"
+	return true
+}
+
+func toggleReadOnly(app *appState) {
+	if app == nil || len(app.buffers) == 0 {
+		return
+	}
+	slot := &app.buffers[app.bufIdx]
+	slot.readOnly = !slot.readOnly
+	app.touchActiveBuffer()
+	if slot.readOnly {
+		app.lastEvent = "Read-only on"
+	} else {
+		app.lastEvent = "Read-only off"
+	}
+}
+
+// leapAgain repeats the last committed leap query from the caret in dir,
+// wrapping at the buffer ends, and reports the query in the status line.
+func leapAgain(app *appState, dir editor.Dir) error {
+	q := string(app.ed.Leap.LastCommit)
+	if q == "" {
+		return fmt.Errorf("no committed leap to repeat")
+	}
+	from := app.ed.Caret
+	app.ed.LeapAgain(dir)
+	if app.ed.Caret == from {
+		app.lastEvent = fmt.Sprintf("Leap again: no other %q", q)
+		return nil
+	}
+	app.lastEvent = fmt.Sprintf("Leap again: %q", q)
+	return nil
+}
+
+func startJumpCharMode(app *appState, dir editor.Dir) {
+	if app == nil || app.ed == nil {
+		return
+	}
+	app.jumpCharArmed = true
+	app.jumpCharDir = dir
+	app.jumpCharRune = 0
+	if dir == editor.DirBack {
+		app.lastEvent = "Jump back to char: type a character, repeat it to continue"
+	} else {
+		app.lastEvent = "Jump to char: type a character, repeat it to continue"
+	}
+}
+
+func exitJumpCharMode(app *appState) {
+	app.jumpCharArmed = false
+	app.jumpCharRune = 0
+}
+
+// handleJumpCharText jumps to the typed rune while jump mode is armed. Once a
+// target is chosen only that rune repeats; anything else exits the mode and is
+// left for normal handling (reported by returning false).
+func handleJumpCharText(app *appState, text string) bool {
+	rs := []rune(text)
+	if len(rs) != 1 || (app.jumpCharRune != 0 && rs[0] != app.jumpCharRune) {
+		exitJumpCharMode(app)
+		return false
+	}
+	r := rs[0]
+	app.jumpCharRune = r
+	if app.ed.JumpToChar(r, app.jumpCharDir) {
+		app.lastEvent = fmt.Sprintf("Jumped to %q", r)
+	} else {
+		app.lastEvent = fmt.Sprintf("No further %q", r)
+	}
+	return true
+}
+
 func startLineHighlightMode(app *appState) {
 	if app == nil || app.ed == nil {
 		return
 	}
-	lines := editor.SplitLines(app.ed.Runes())
+	lines := app.ed.Lines()
 	if len(lines) == 0 {
 		return
 	}
@@ -764,7 +1289,7 @@ func extendLineHighlightMode(app *appState, delta int) {
 	if app == nil || app.ed == nil {
 		return
 	}
-	lines := editor.SplitLines(app.ed.Runes())
+	lines := app.ed.Lines()
 	if len(lines) == 0 {
 		return
 	}
@@ -782,33 +1307,19 @@ func applyLineHighlightSelection(app *appState, lines []string) {
 	}
 	from := min(app.lineHighlightAnchorLine, app.lineHighlightToLine)
 	to := max(app.lineHighlightAnchorLine, app.lineHighlightToLine)
-	selA := lineStartForSelection(lines, from)
+	selA := editor.LineStartOffset(lines, from)
 	selB := lineEndExclusiveForSelection(lines, to, app.ed.RuneLen())
 	app.ed.Sel.Active = true
 	app.ed.Sel.A = selA
 	app.ed.Sel.B = selB
-	app.ed.Caret = lineStartForSelection(lines, app.lineHighlightToLine)
-}
-
-func lineStartForSelection(lines []string, lineIdx int) int {
-	if lineIdx < 0 {
-		lineIdx = 0
-	}
-	if lineIdx >= len(lines) {
-		lineIdx = len(lines) - 1
-	}
-	pos := 0
-	for i := 0; i < lineIdx; i++ {
-		pos += utf8.RuneCountInString(lines[i]) + 1
-	}
-	return pos
+	app.ed.Caret = editor.LineStartOffset(lines, app.lineHighlightToLine)
 }
 
 func lineEndExclusiveForSelection(lines []string, lineIdx int, bufLen int) int {
 	if lineIdx >= len(lines)-1 {
 		return bufLen
 	}
-	return lineStartForSelection(lines, lineIdx+1)
+	return editor.LineStartOffset(lines, lineIdx+1)
 }
 
 func handleOpenKeyEvent(app *appState, e keyEvent) bool {
@@ -823,27 +1334,34 @@ func handleOpenKeyEvent(app *appState, e keyEvent) bool {
 	case keyBackspace:
 		if len(app.open.Query) > 0 {
 			rs := []rune(app.open.Query)
-			app.open.Query = string(rs[:len(rs)-1])
-			app.open.Matches = findMatches(app.openRoot, app.open.Query, 50)
+			setOpenQuery(app, string(rs[:len(rs)-1]))
+		}
+		return true
+	case keyTab:
+		if !app.open.More {
+			app.lastEvent = fmt.Sprintf("OPEN: all %d matches shown", len(app.open.Matches))
+			return true
 		}
+		app.open.Limit += findLimit(app)
+		refreshOpenMatches(app)
+		app.lastEvent = openMatchesStatus(app)
 		return true
 	case keyReturn, keyKpEnter:
-		app.open.Matches = findMatches(app.openRoot, app.open.Query, 50)
-		if len(app.open.Matches) == 1 {
+		refreshOpenMatches(app)
+		if len(app.open.Matches) == 1 && !app.open.More {
 			if err := openPath(app, app.open.Matches[0]); err != nil {
 				app.lastEvent = fmt.Sprintf("OPEN ERR: %v", err)
 			} else {
-				app.lastEvent = fmt.Sprintf("Opened %s", app.currentPath)
+				app.lastEvent = openedStatus(app)
 			}
 			app.open.Active = false
 		} else {
-			app.lastEvent = fmt.Sprintf("OPEN: %d matches; refine", len(app.open.Matches))
+			app.lastEvent = openMatchesStatus(app)
 		}
 		return true
 	default:
 		if r, ok := keyToRune(e.key, e.mods); ok {
-			app.open.Query += string(r)
-			app.open.Matches = findMatches(app.openRoot, app.open.Query, 50)
+			setOpenQuery(app, app.open.Query+string(r))
 		}
 		return true
 	}
@@ -851,23 +1369,51 @@ func handleOpenKeyEvent(app *appState, e keyEvent) bool {
 
 func handleOpenTextEvent(app *appState, text string) bool {
 	if text != "" && utf8.ValidString(text) {
-		app.open.Query += text
-		app.open.Matches = findMatches(app.openRoot, app.open.Query, 50)
+		setOpenQuery(app, app.open.Query+text)
 	}
 	return true
 }
 
+// setOpenQuery changes the open prompt's query and lists its first page of
+// matches.
+func setOpenQuery(app *appState, q string) {
+	app.open.Query = q
+	app.open.Limit = findLimit(app)
+	refreshOpenMatches(app)
+}
+
+func refreshOpenMatches(app *appState) {
+	if app.open.Limit <= 0 {
+		app.open.Limit = findLimit(app)
+	}
+	app.open.Matches, app.open.More = findMatches(app.openRoot, app.open.Query, app.open.Limit, app.walkFilter())
+}
+
+func openMatchesStatus(app *appState) string {
+	if app.open.More {
+		return fmt.Sprintf("OPEN: %d+ matches; refine, or Tab for more", len(app.open.Matches))
+	}
+	return fmt.Sprintf("OPEN: %d matches; refine", len(app.open.Matches))
+}
+
 func handleInputKey(app *appState, e keyEvent) bool {
 	if !e.down || e.repeat != 0 {
 		return true
 	}
 	switch e.key {
 	case keyEscape:
+		kind := app.inputKind
 		app.inputActive = false
 		app.inputValue = ""
 		app.inputPrompt = ""
 		app.inputKind = ""
 		app.lastEvent = "Input cancelled"
+		switch kind {
+		case "reload":
+			keepBufferOverDisk(app)
+		case "recover":
+			applySwapRecovery(app, false)
+		}
 		return true
 	case keyBackspace:
 		if len(app.inputValue) > 0 {
@@ -875,6 +1421,11 @@ func handleInputKey(app *appState, e keyEvent) bool {
 			app.inputValue = string(rs[:len(rs)-1])
 		}
 		return true
+	case keyTab:
+		if app.inputKind == "save" {
+			completeSavePath(app)
+		}
+		return true
 	case keyReturn, keyKpEnter:
 		switch app.inputKind {
 		case "save":
@@ -885,13 +1436,7 @@ func handleInputKey(app *appState, e keyEvent) bool {
 			}
 			path := name
 			if !filepath.IsAbs(path) {
-				root := app.openRoot
-				if root == "" {
-					if cwd, err := os.Getwd(); err == nil {
-						root = cwd
-					}
-				}
-				path = filepath.Join(root, name)
+				path = filepath.Join(saveRoot(app), name)
 			}
 			app.currentPath = path
 			if app.bufIdx >= 0 && app.bufIdx < len(app.buffers) {
@@ -904,7 +1449,129 @@ func handleInputKey(app *appState, e keyEvent) bool {
 			if err := saveCurrent(app); err != nil {
 				app.lastEvent = fmt.Sprintf("SAVE ERR: %v", err)
 			} else {
-				app.lastEvent = fmt.Sprintf("Saved %s", app.currentPath)
+				app.lastEvent = fmt.Sprintf("Saved %s", displayPath(app, app.currentPath))
+			}
+		case "set":
+			spec := app.inputValue
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			desc, err := applyOption(app, spec)
+			if err != nil {
+				app.lastEvent = fmt.Sprintf("SET ERR: %v", err)
+			} else {
+				app.lastEvent = "Set " + desc
+			}
+		case "align":
+			delim := strings.TrimSpace(app.inputValue)
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			alignLines(app, delim)
+		case "macro":
+			spec := app.inputValue
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			n, err := parseReplayCount(spec)
+			if err != nil {
+				app.lastEvent = fmt.Sprintf("MACRO ERR: %v", err)
+				return true
+			}
+			return replayMacro(app, n)
+		case "wrap":
+			value := app.inputValue
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			if strings.TrimSpace(value) == "" {
+				app.lastEvent = "WRAP ERR: delimiter required"
+				return true
+			}
+			app.RunCommand(CmdWrap, value)
+			return true
+		case "bookmark", "gotobookmark":
+			kind, value := app.inputKind, app.inputValue
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			if kind == "bookmark" {
+				app.RunCommand(CmdBookmark, cmp.Or(strings.TrimSpace(value), nextBookmarkName(app)))
+			} else if strings.TrimSpace(value) == "" {
+				app.lastEvent = "BOOKMARK ERR: name required"
+			} else {
+				app.RunCommand(CmdGotoBookmark, value)
+			}
+		case "rename", "deletefile":
+			kind, value, path := app.inputKind, app.inputValue, app.pickerOpPath
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			app.pickerOpPath = ""
+			var err error
+			if kind == "rename" {
+				err = renamePickerEntry(app, path, value)
+			} else if answer := strings.ToLower(strings.TrimSpace(value)); answer == "y" || answer == "yes" {
+				err = deletePickerEntry(app, path)
+			} else {
+				app.lastEvent = "Kept " + filepath.Base(path)
+			}
+			if err != nil {
+				app.lastEvent = fmt.Sprintf("FILE ERR: %v", err)
+			}
+		case "closeothers":
+			answer := strings.ToLower(strings.TrimSpace(app.inputValue))
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			if answer != "y" && answer != "yes" {
+				app.lastEvent = "Kept other buffers"
+				return true
+			}
+			app.lastEvent = fmt.Sprintf("Closed %s", plural(app.closeOtherBuffers(), "other buffer"))
+		case "recover":
+			answer := strings.ToLower(strings.TrimSpace(app.inputValue))
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			applySwapRecovery(app, answer == "y" || answer == "yes")
+		case "openoutside":
+			answer := strings.ToLower(strings.TrimSpace(app.inputValue))
+			path := app.outsidePath
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			app.outsidePath = ""
+			if answer != "y" && answer != "yes" && answer != "r" {
+				app.lastEvent = "Not opened"
+				return true
+			}
+			if err := openOutsideRoot(app, path, answer == "r"); err != nil {
+				app.lastEvent = fmt.Sprintf("LOAD ERR: %v", err)
+			}
+		case "reload":
+			answer := strings.ToLower(strings.TrimSpace(app.inputValue))
+			app.inputActive = false
+			app.inputValue = ""
+			app.inputPrompt = ""
+			app.inputKind = ""
+			if answer != "y" && answer != "yes" {
+				keepBufferOverDisk(app)
+				return true
+			}
+			if err := reloadCurrentFromDisk(app); err != nil {
+				app.lastEvent = fmt.Sprintf("RELOAD ERR: %v", err)
+			} else {
+				app.lastEvent = fmt.Sprintf("Reloaded %s", displayPath(app, app.currentPath))
 			}
 		default:
 			app.inputActive = false
@@ -1124,6 +1791,44 @@ func keyToRune(k keyCode, mods modMask) (rune, bool) {
 			return '?', true
 		}
 		return '/', true
+	case keyBackslash:
+		if shift {
+			return '|', true
+		}
+		return '\\', true
+	case keySemicolon:
+		if shift {
+			return ':', true
+		}
+		return ';', true
+	case keyQuote:
+		if shift {
+			return '"', true
+		}
+		return '\'', true
+	case keyParen:
+		return '(', true
+	case keyDollar:
+		return '$', true
+	case keyBang:
+		return '!', true
+	case keyHash:
+		return '#', true
+	case keyBacktick:
+		if shift {
+			return '~', true
+		}
+		return '`', true
+	case keyBracketL:
+		if shift {
+			return '{', true
+		}
+		return '[', true
+	case keyBracketR:
+		if shift {
+			return '}', true
+		}
+		return ']', true
 	}
 	return 0, false
 }
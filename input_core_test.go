@@ -3,8 +3,10 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"gc/editor"
 )
@@ -497,6 +499,10 @@ func TestCtrlSlashTogglesCommentAndDoesNotStartSearch(t *testing.T) {
 	if got := app.ed.String(); got != "//line\n" {
 		t.Fatalf("ctrl+/ should toggle comment, got %q", got)
 	}
+	app.ed.Undo()
+	if got := app.ed.String(); got != "line\n" {
+		t.Fatalf("comment toggle should be one undo step, got %q", got)
+	}
 }
 
 func TestSearchModeBackspaceCancelsAndDeletesSelection(t *testing.T) {
@@ -618,3 +624,842 @@ func BenchmarkHandleKeyEventMoveRight(b *testing.B) {
 		_ = handleKeyEvent(&app, ev)
 	}
 }
+
+func TestEscEqualsExpandsSelectionForCopy(t *testing.T) {
+	clip := &memoryClipboard{}
+	app := appState{clipboard: clip}
+	app.initBuffers(editor.NewEditor("alpha beta gamma"))
+	app.ed.SetClipboard(clip)
+	app.ed.Caret = 7
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEquals})
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || a != 6 || b != 10 {
+		t.Fatalf("Esc+= should select word, got active=%v (%d,%d)", app.ed.Sel.Active, a, b)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if got, _ := clip.GetText(); got != "beta" {
+		t.Fatalf("copy after expand: got %q", got)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEquals})
+	if a, b := app.ed.Sel.Normalised(); a != 0 || b != app.ed.RuneLen() {
+		t.Fatalf("second Esc+= should select the line, got (%d,%d)", a, b)
+	}
+}
+
+func TestEscShiftJLeapSelectsForward(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("one two three two"))
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyJ, mods: modShift})
+	handleTextEvent(&app, "J", modShift)
+	if !app.ed.Leap.Active || !app.ed.Leap.Selecting {
+		t.Fatalf("Esc+Shift+J should start a selecting leap")
+	}
+	handleTextEvent(&app, "th", 0)
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || a != 0 || b != 8 {
+		t.Fatalf("leap select: got active=%v (%d,%d)", app.ed.Sel.Active, a, b)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.ed.Leap.Active {
+		t.Fatalf("Enter should commit the leap")
+	}
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || a != 0 || b != 8 {
+		t.Fatalf("commit should keep selection, got active=%v (%d,%d)", app.ed.Sel.Active, a, b)
+	}
+}
+
+func TestEscKStillKillsAndEscShiftKLeapSelectsBack(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("abc def"))
+	app.ed.Caret = 7
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyK, mods: modShift})
+	if !app.ed.Leap.Active || app.ed.Leap.Dir != editor.DirBack || !app.ed.Leap.Selecting {
+		t.Fatalf("Esc+Shift+K should start a backward selecting leap")
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	if app.ed.Leap.Active || app.ed.Caret != 7 {
+		t.Fatalf("Esc should cancel the leap back to origin, caret=%d", app.ed.Caret)
+	}
+
+	app.ed.Caret = 3
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyK})
+	if got := app.ed.String(); got != "abc" {
+		t.Fatalf("Esc+k should still kill to line end, got %q", got)
+	}
+}
+
+func TestEscTJumpsToRepeatedCharAndOtherTextExits(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("banana"))
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyT})
+	handleTextEvent(&app, "t", 0)
+	if !app.jumpCharArmed {
+		t.Fatalf("Esc+t should arm jump mode")
+	}
+	for _, want := range []int{1, 3, 5} {
+		handleTextEvent(&app, "a", 0)
+		if app.ed.Caret != want {
+			t.Fatalf("jump to a: want caret %d, got %d", want, app.ed.Caret)
+		}
+	}
+	handleTextEvent(&app, "x", 0)
+	if app.jumpCharArmed {
+		t.Fatalf("a different rune should exit jump mode")
+	}
+	if got := app.ed.String(); got != "bananxa" {
+		t.Fatalf("exit rune should be inserted normally, got %q", got)
+	}
+}
+
+func TestEscShiftTJumpsBackwardAndArrowExits(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("banana"))
+	app.ed.Caret = 5
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyT, mods: modShift})
+	handleTextEvent(&app, "T", modShift)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyA})
+	handleTextEvent(&app, "a", 0)
+	if app.ed.Caret != 3 {
+		t.Fatalf("backward jump: want caret 3, got %d", app.ed.Caret)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyLeft})
+	if app.jumpCharArmed || app.ed.Caret != 2 {
+		t.Fatalf("arrow should exit jump mode and move, armed=%v caret=%d", app.jumpCharArmed, app.ed.Caret)
+	}
+}
+
+func TestEscHCyclesLeapHistory(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha beta alpha beta"))
+	for _, q := range []string{"beta", "alpha"} {
+		app.ed.LeapStart(editor.DirFwd)
+		handleTextEvent(&app, q, 0)
+		handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	}
+	app.ed.Caret = 0
+
+	want := []struct {
+		caret int
+		query string
+	}{{11, "alpha"}, {17, "beta"}, {0, "alpha"}}
+	for _, w := range want {
+		handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(&app, keyEvent{down: true, key: keyH})
+		if app.ed.Caret != w.caret || !strings.Contains(app.lastEvent, w.query) {
+			t.Fatalf("Esc+h: want caret %d for %q, got %d (%s)", w.caret, w.query, app.ed.Caret, app.lastEvent)
+		}
+	}
+}
+
+func TestReadOnlyBufferIgnoresTypingAndBlocksSave(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "ro.txt")
+	if err := os.WriteFile(path, []byte("keep"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("keep"))
+	app.currentPath = path
+	app.buffers[0].path = path
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyR, mods: modShift})
+	if !app.buffers[0].readOnly {
+		t.Fatalf("Esc+Shift+R should make the buffer read-only")
+	}
+
+	handleTextEvent(&app, "x", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyBackspace})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if got := app.ed.String(); got != "keep" || app.buffers[0].dirty {
+		t.Fatalf("read-only buffer changed: %q dirty=%v", got, app.buffers[0].dirty)
+	}
+	if !strings.Contains(app.lastEvent, "read-only") {
+		t.Fatalf("expected read-only status, got %q", app.lastEvent)
+	}
+
+	app.ed.SetRunes([]rune("changed"))
+	if err := saveCurrent(&app); err == nil {
+		t.Fatalf("save should be blocked for read-only buffer")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep" {
+		t.Fatalf("file should be untouched, got %q", string(data))
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyR, mods: modShift})
+	if app.buffers[0].readOnly {
+		t.Fatalf("second Esc+Shift+R should clear read-only")
+	}
+	if err := saveCurrent(&app); err != nil {
+		t.Fatalf("save after toggling off: %v", err)
+	}
+}
+
+func TestPickerRunAndHelpBuffersAreReadOnly(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor(""))
+	app.addPickerBuffer([]string{"..", "a.go"})
+	if !app.buffers[app.bufIdx].readOnly {
+		t.Fatalf("picker buffer should be read-only")
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keySlash, mods: modCtrl | modShift})
+	if app.bufIdx != 2 || !app.buffers[app.bufIdx].readOnly {
+		t.Fatalf("shortcuts buffer should be read-only")
+	}
+
+	orig := startRun
+	defer func() { startRun = orig }()
+	startRun = func(runCommand, func(string), func(error)) error { return nil }
+	if err := runCurrentPackage(&app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.buffers[app.bufIdx].readOnly {
+		t.Fatalf("run buffer should be read-only")
+	}
+}
+
+func TestExternalChangePromptsReloadAndClampsCaret(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(path, []byte("a much longer original line"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	app.ed.Caret = app.ed.RuneLen()
+
+	if err := os.WriteFile(path, []byte("short"), 0644); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	handleTextEvent(&app, "x", 0)
+	if !app.inputActive || app.inputKind != "reload" {
+		t.Fatalf("edit after external change should prompt for reload")
+	}
+	if strings.Contains(app.ed.String(), "x") {
+		t.Fatalf("edit should not be applied while the reload prompt is open")
+	}
+	handleInputText(&app, "y")
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+
+	if got := app.ed.String(); got != "short" {
+		t.Fatalf("reload: got %q", got)
+	}
+	if app.ed.Caret != len("short") {
+		t.Fatalf("caret should clamp to reloaded length, got %d", app.ed.Caret)
+	}
+	if checkExternalChange(&app) {
+		t.Fatalf("no prompt expected after reload")
+	}
+}
+
+func TestExternalChangeDeclineKeepsUnsavedEdits(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(path, []byte("disk"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	app.ed.SetRunes([]rune("mine"))
+	app.markDirty()
+
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if !checkExternalChange(&app) || !strings.Contains(app.inputPrompt, "discard") {
+		t.Fatalf("dirty buffer should get a discard-confirmation prompt, got %q", app.inputPrompt)
+	}
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	if got := app.ed.String(); got != "mine" || !app.buffers[0].dirty {
+		t.Fatalf("declining should keep edits, got %q dirty=%v", got, app.buffers[0].dirty)
+	}
+	if checkExternalChange(&app) {
+		t.Fatalf("declined change should not prompt again")
+	}
+}
+
+type recordingClipboard struct {
+	text       string
+	sets, gets int
+}
+
+func (c *recordingClipboard) GetText() (string, error) { c.gets++; return c.text, nil }
+
+func (c *recordingClipboard) SetText(s string) error { c.sets++; c.text = s; return nil }
+
+func TestCopyCutPasteUseInjectedClipboard(t *testing.T) {
+	clip := &recordingClipboard{}
+	app := appState{clipboard: clip}
+	app.initBuffers(editor.NewEditor("alpha beta"))
+	app.ed.SetClipboard(clip)
+	app.ed.Sel = editor.Sel{Active: true, A: 0, B: 5}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.sets != 1 || clip.text != "alpha" {
+		t.Fatalf("Ctrl+C should SetText the selection, got sets=%d text=%q", clip.sets, clip.text)
+	}
+	app.ed.Sel = editor.Sel{Active: true, A: 6, B: 10}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyX, mods: modCtrl})
+	if clip.sets != 2 || clip.text != "beta" || string(app.ed.Runes()) != "alpha " {
+		t.Fatalf("Ctrl+X should SetText and delete, got sets=%d text=%q buf=%q", clip.sets, clip.text, string(app.ed.Runes()))
+	}
+	clip.text = "gamma"
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modCtrl})
+	if clip.gets != 1 || string(app.ed.Runes()) != "alpha gamma" {
+		t.Fatalf("Ctrl+V should GetText and insert, got gets=%d buf=%q", clip.gets, string(app.ed.Runes()))
+	}
+
+	// New buffers share the injected clipboard.
+	app.addBuffer()
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modCtrl})
+	if clip.gets != 2 || string(app.ed.Runes()) != "gamma" {
+		t.Fatalf("paste in a new buffer should use the same clipboard, got %q", string(app.ed.Runes()))
+	}
+}
+
+func TestPasteReindentsCodeBlocks(t *testing.T) {
+	clip := &recordingClipboard{text: "  a := 1\n  if a > 0 {\n    b()\n\n  }"}
+	src := "package main\n\nfunc f() {\n\tfor {\n\t\t\n\t}\n}\n"
+	app := appState{clipboard: clip}
+	app.initBuffers(editor.NewEditor(src))
+	app.ed.SetClipboard(clip)
+	app.currentPath = "f.go"
+	app.ed.Caret = strings.Index(src, "\t\t\n") + 2
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modCtrl})
+	want := "package main\n\nfunc f() {\n\tfor {\n\t\ta := 1\n\t\tif a > 0 {\n\t\t  b()\n\n\t\t}\n\t}\n}\n"
+	if got := app.ed.String(); got != want {
+		t.Fatalf("re-indented paste:\n got %q\nwant %q", got, want)
+	}
+	app.ed.Undo()
+	if got := app.ed.String(); got != src {
+		t.Fatalf("one undo should remove the paste, got %q", got)
+	}
+
+	if _, err := applyOption(&app, "pasteindent=off"); err != nil {
+		t.Fatal(err)
+	}
+	app.ed.Caret = strings.Index(src, "\t\t\n") + 2
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modCtrl})
+	if got := app.ed.String(); !strings.Contains(got, "\t\t  a := 1\n  if a > 0 {\n") {
+		t.Fatalf("pasteindent=off should paste as copied, got %q", got)
+	}
+
+	if got := reindentPaste([]rune("x"), 1, "one line"); got != "one line" {
+		t.Fatalf("single lines are pasted unchanged, got %q", got)
+	}
+
+	// A block copied from its first non-blank character keeps its inner
+	// levels relative to the later lines.
+	if got := reindentPaste([]rune("\t\t"), 2, "if x {\n\t\tfoo()\n\t}"); got != "if x {\n\t\t\tfoo()\n\t\t}" {
+		t.Fatalf("mid-line block: got %q", got)
+	}
+}
+
+func TestCtrlCWithoutSelectionCopiesLine(t *testing.T) {
+	clip := &recordingClipboard{}
+	app := appState{clipboard: clip}
+	src := "one\ntwo three\nlast"
+	app.initBuffers(editor.NewEditor(src))
+	app.ed.SetClipboard(clip)
+	app.ed.Caret = 6
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.text != "two three\n" || app.ed.String() != src || app.ed.Caret != 6 || app.ed.Sel.Active {
+		t.Fatalf("copy line: clip %q, buf %q, caret %d, sel %v", clip.text, app.ed.String(), app.ed.Caret, app.ed.Sel.Active)
+	}
+	if app.buffers[0].dirty {
+		t.Fatalf("copying a line should not modify the buffer")
+	}
+	app.ed.Caret = len(src)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.text != "last\n" {
+		t.Fatalf("copy last line: clip %q", clip.text)
+	}
+	app.ed.Sel = editor.Sel{Active: true, A: 4, B: 7}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.text != "two" {
+		t.Fatalf("a selection still copies just the range: clip %q", clip.text)
+	}
+}
+
+func TestDetectClipboardCommands(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	have := func(names ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			if slices.Contains(names, name) {
+				return "/usr/bin/" + name, nil
+			}
+			return "", os.ErrNotExist
+		}
+	}
+	tests := []struct {
+		name   string
+		goos   string
+		vars   map[string]string
+		tools  []string
+		want   string
+		wantOK bool
+	}{
+		{"mac", "darwin", nil, []string{"pbcopy", "pbpaste"}, "pbcopy", true},
+		{"wayland", "linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "wl-paste", "xclip"}, "wl-copy", true},
+		{"x11 xclip", "linux", map[string]string{"DISPLAY": ":0"}, []string{"xclip"}, "xclip", true},
+		{"x11 xsel", "linux", map[string]string{"DISPLAY": ":0"}, []string{"xsel"}, "xsel", true},
+		{"no display", "linux", nil, []string{"xclip", "wl-copy", "wl-paste"}, "", false},
+		{"no tools", "linux", map[string]string{"DISPLAY": ":0"}, nil, "", false},
+	}
+	for _, tc := range tests {
+		cmds, ok := detectClipboardCommands(tc.goos, env(tc.vars), have(tc.tools...))
+		if ok != tc.wantOK || (ok && cmds.copy[0] != tc.want) {
+			t.Fatalf("%s: got %v ok=%v, want %q ok=%v", tc.name, cmds.copy, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestSystemClipboardRunsToolsAndFallsBack(t *testing.T) {
+	oldRun := runClipboardCommand
+	defer func() { runClipboardCommand = oldRun }()
+	var calls []string
+	failPaste := false
+	system := ""
+	runClipboardCommand = func(argv []string, stdin string, paste bool) (string, error) {
+		calls = append(calls, strings.Join(argv, " "))
+		if paste {
+			if failPaste {
+				return "", os.ErrNotExist
+			}
+			return system, nil
+		}
+		system = stdin
+		return "", nil
+	}
+	clip := &systemClipboard{cmds: clipboardCommands{copy: []string{"xclip", "-in"}, paste: []string{"xclip", "-out"}}}
+	if err := clip.SetText("shared"); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if got, _ := clip.GetText(); got != "shared" || system != "shared" {
+		t.Fatalf("GetText=%q system=%q", got, system)
+	}
+	if strings.Join(calls, "|") != "xclip -in|xclip -out" {
+		t.Fatalf("unexpected tool calls %v", calls)
+	}
+	failPaste = true
+	system = "other"
+	if got, _ := clip.GetText(); got != "shared" {
+		t.Fatalf("failed paste tool should fall back to the last copy, got %q", got)
+	}
+}
+
+func TestEscShiftDAndGRemoveDuplicateLines(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("a\na\nb\na\n"))
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyD, mods: modShift})
+	if got := app.ed.String(); got != "a\nb\na\n" || !app.buffers[0].dirty || app.lastEvent != "Removed 1 duplicate line" {
+		t.Fatalf("Esc+Shift+D: buf=%q dirty=%v status=%q", got, app.buffers[0].dirty, app.lastEvent)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyG, mods: modShift})
+	if got := app.ed.String(); got != "a\nb\n" {
+		t.Fatalf("Esc+Shift+G: buf=%q", got)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyG, mods: modShift})
+	if app.lastEvent != "No duplicate lines" {
+		t.Fatalf("status = %q", app.lastEvent)
+	}
+}
+
+func TestEscPipeAlignsSelectedLines(t *testing.T) {
+	src := "a = 1\nlonger = 2\nmid = 3\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.ed.Sel = editor.Sel{Active: true, A: 0, B: len(src)}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyBackslash, mods: modShift})
+	if !app.inputActive || app.inputKind != "align" {
+		t.Fatalf("Esc+| should open the align prompt")
+	}
+	handleInputText(&app, "=")
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	if got := app.ed.String(); got != "a      = 1\nlonger = 2\nmid    = 3\n" {
+		t.Fatalf("aligned buffer = %q", got)
+	}
+	if !app.buffers[0].dirty || app.lastEvent != `Aligned 2 lines on "="` {
+		t.Fatalf("dirty=%v status=%q", app.buffers[0].dirty, app.lastEvent)
+	}
+}
+
+func TestEscShiftUCyclesCase(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("make it Loud"))
+	app.ed.Sel = editor.Sel{Active: true, A: 5, B: 12}
+	press := func() {
+		handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(&app, keyEvent{down: true, key: keyU, mods: modShift})
+	}
+	for _, want := range []string{"make IT LOUD", "make it loud", "make It Loud", "make IT LOUD"} {
+		press()
+		if got := app.ed.String(); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if !app.buffers[0].dirty {
+		t.Fatalf("case change should mark the buffer dirty")
+	}
+
+	// Any other edit restarts the cycle at upper case.
+	app.ed.Caret = 0
+	app.ed.Sel = editor.Sel{}
+	app.suppressTextOnce = false // no TUI text event follows the prefixed key here
+	handleTextEvent(&app, "x", 0)
+	app.ed.Sel = editor.Sel{Active: true, A: 0, B: 5}
+	press()
+	if got := app.ed.String(); got != "XMAKE IT LOUD" {
+		t.Fatalf("cycle should restart after an edit, got %q", got)
+	}
+}
+
+func TestMacroRecordAndReplay(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("abc"))
+	key := func(k keyCode, mods modMask) {
+		t.Helper()
+		if !dispatchKeyEvent(&app, keyEvent{down: true, key: k, mods: mods}) {
+			t.Fatalf("key %v should not quit", k)
+		}
+	}
+	text := func(s string) {
+		t.Helper()
+		dispatchTextEvent(&app, s, 0)
+	}
+
+	key(keyEscape, 0)
+	key(keyZ, 0)
+	text("z") // echo of the command key
+	if !app.macroRecording {
+		t.Fatalf("Esc+z should start recording")
+	}
+	text("x")
+	key(keyRight, 0)
+	key(keyEscape, 0)
+	key(keyZ, 0)
+	text("z")
+	if app.macroRecording || len(app.macro) != 2 {
+		t.Fatalf("Esc+z should stop with 2 events, got recording=%v %+v", app.macroRecording, app.macro)
+	}
+	if got := app.ed.String(); got != "xabc" || app.ed.Caret != 2 {
+		t.Fatalf("recording pass: %q caret %d", got, app.ed.Caret)
+	}
+
+	key(keyEscape, 0)
+	key(keyZ, modShift)
+	if !app.inputActive || app.inputKind != "macro" {
+		t.Fatalf("Esc+Shift+Z should prompt for a count")
+	}
+	text("3")
+	key(keyReturn, 0)
+	if got := app.ed.String(); got != "xaxbxcx" {
+		t.Fatalf("replayed 3 times: got %q", got)
+	}
+	if app.lastEvent != "Replayed macro 3 times" || app.macroReplaying {
+		t.Fatalf("status %q replaying=%v", app.lastEvent, app.macroReplaying)
+	}
+
+	// Replaying is refused while recording, so a macro cannot replay itself.
+	key(keyEscape, 0)
+	key(keyZ, 0)
+	key(keyEscape, 0)
+	key(keyZ, modShift)
+	if app.inputActive || app.lastEvent != "Stop recording (Esc+z) before replaying" {
+		t.Fatalf("replay during recording: input=%v status %q", app.inputActive, app.lastEvent)
+	}
+	app.macro = []macroEvent{{key: keyEvent{down: true, key: keyEscape}}, {key: keyEvent{down: true, key: keyZ, mods: modShift}}}
+	app.macroRecording = false
+	if !replayMacro(&app, 1) || app.inputActive {
+		t.Fatalf("a macro that replays itself should be refused, input=%v", app.inputActive)
+	}
+
+	if _, err := parseReplayCount("0"); err == nil {
+		t.Fatalf("zero replays should be refused")
+	}
+	if n, err := parseReplayCount(" "); err != nil || n != 1 {
+		t.Fatalf("empty count should mean once, got %d %v", n, err)
+	}
+}
+
+func TestRepeatLastEdit(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha beta gamma"))
+	repeat := func() {
+		t.Helper()
+		handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(&app, keyEvent{down: true, key: keyY})
+		app.suppressTextOnce = false
+	}
+
+	repeat()
+	if app.lastEvent != "Nothing to repeat" {
+		t.Fatalf("repeat with no edit: %q", app.lastEvent)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDelete})
+	if got := app.ed.String(); got != " beta gamma" {
+		t.Fatalf("delete word: %q", got)
+	}
+	// Navigation does not replace the repeat target.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyRight})
+	repeat()
+	if got := app.ed.String(); got != "  gamma" || app.lastEvent != "Repeated delete word" {
+		t.Fatalf("repeat should delete the next word, got %q (%s)", got, app.lastEvent)
+	}
+
+	// A typed run, including Enter, repeats as one insert.
+	app.ed.Caret = app.ed.RuneLen()
+	handleTextEvent(&app, "!", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	handleTextEvent(&app, "ok", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyLeft})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyRight})
+	repeat()
+	if got := app.ed.String(); got != "  gamma!\nok!\nok" {
+		t.Fatalf("repeat insert run: %q", got)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keySlash, mods: modCtrl})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyUp})
+	repeat()
+	if got := app.ed.String(); got != "  gamma!\n//ok!\n//ok" {
+		t.Fatalf("repeat comment toggle: %q", got)
+	}
+
+	app.buffers[0].readOnly = true
+	repeat()
+	if got := app.ed.String(); got != "  gamma!\n//ok!\n//ok" {
+		t.Fatalf("repeat must not edit a read-only buffer: %q", got)
+	}
+}
+
+func TestCountPrefixRepeatsMoves(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("l0\nl1\nl2\nl3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\nl11\nl12\nl13"))
+	line := func() int { return editor.CaretLineAt(app.ed.Lines(), app.ed.Caret) }
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: key3})
+	handleTextEvent(&app, "3", 0) // echo of the prefixed digit
+	if app.pendingCount != 3 {
+		t.Fatalf("pending count = %d, want 3", app.pendingCount)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	if got := line(); got != 3 {
+		t.Fatalf("Esc+3 Down moved to line %d, want 3", got)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	if got := line(); got != 4 {
+		t.Fatalf("count should reset after the move, line %d", got)
+	}
+
+	// Further digits extend the count.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: key1})
+	app.suppressTextOnce = false
+	handleTextEvent(&app, "0", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	if got := line(); got != 13 {
+		t.Fatalf("Esc+1 0 Down moved to line %d, want 13", got)
+	}
+
+	// Esc cancels the count and does not arm the prefix.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: key4})
+	app.suppressTextOnce = false
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	if app.pendingCount != 0 || app.cmdPrefixActive {
+		t.Fatalf("Esc should cancel the count: count=%d prefix=%v", app.pendingCount, app.cmdPrefixActive)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyUp})
+	if got := line(); got != 12 {
+		t.Fatalf("cancelled count still applied: line %d", got)
+	}
+}
+
+func TestEscArrowExtendsSelection(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("one\ntwo\nthree\nfour"))
+	escDown := func() {
+		t.Helper()
+		handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	}
+
+	escDown()
+	a, b := app.ed.Sel.Normalised()
+	if ok := app.ed.Sel.Active; !ok || a != 0 || b != len("one\ntwo\n") {
+		t.Fatalf("Esc+Down selection = [%d,%d) active=%v, want first two lines", a, b, ok)
+	}
+	escDown()
+	a, b = app.ed.Sel.Normalised()
+	if !app.ed.Sel.Active || a != 0 || b != len("one\ntwo\nthree\n") {
+		t.Fatalf("second Esc+Down should grow by a line, got [%d,%d)", a, b)
+	}
+
+	// Plain Down still moves without selecting.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	if app.ed.Sel.Active {
+		t.Fatalf("plain Down should clear the selection")
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyLeft})
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || b-a != 1 {
+		t.Fatalf("Esc+Left should select one character, got [%d,%d) active=%v", a, b, app.ed.Sel.Active)
+	}
+}
+
+func TestPageMovesUseVisibleHeight(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor(strings.Repeat("line\n", 40)))
+	line := func() int { return editor.CaretLineAt(app.ed.Lines(), app.ed.Caret) }
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown})
+	if got := line(); got != defaultPageLines {
+		t.Fatalf("before any frame PageDown should move %d lines, got %d", defaultPageLines, got)
+	}
+	app.visibleLines = 10
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageUp})
+	if got := line(); got != 10 {
+		t.Fatalf("PageUp with 10 visible lines: line %d, want 10", got)
+	}
+	app.lessMode = true
+	handleKeyEvent(&app, keyEvent{down: true, key: keySpace})
+	if got := line(); got != 20 {
+		t.Fatalf("less-mode Space with 10 visible lines: line %d, want 20", got)
+	}
+}
+
+func TestIncrementDecrementNumberAtCaret(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("item9 x\nwait 007s\nfoo-3"))
+
+	if err := app.RunCommand(CmdIncrement, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.ed.String(); got != "item10 x\nwait 007s\nfoo-3" {
+		t.Fatalf("increment: %q", got)
+	}
+	if app.ed.Caret != 5 {
+		t.Fatalf("caret = %d, want 5 (last digit)", app.ed.Caret)
+	}
+	app.ed.Undo()
+	if got := app.ed.String(); got != "item9 x\nwait 007s\nfoo-3" {
+		t.Fatalf("increment should be one undo step: %q", got)
+	}
+
+	// The number after the caret; zero padding keeps its width.
+	app.ed.Caret = len("item9 x\n")
+	if err := app.RunCommand(CmdDecrement, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.ed.String(); got != "item9 x\nwait 006s\nfoo-3" {
+		t.Fatalf("decrement: %q", got)
+	}
+
+	// A count typed as Esc+<digits> steps by that much with + or -.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: key1})
+	app.suppressTextOnce = false
+	handleTextEvent(&app, "0", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyMinus})
+	handleTextEvent(&app, "-", 0)
+	if got := app.ed.String(); got != "item9 x\nwait -004s\nfoo-3" {
+		t.Fatalf("Esc+1 0 -: %q", got)
+	}
+
+	// A '-' after a word character is not a sign.
+	app.ed.Caret = len("item9 x\nwait -004s\n")
+	if err := app.RunCommand(CmdIncrement, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.ed.String(); got != "item9 x\nwait -004s\nfoo-5" {
+		t.Fatalf("foo-3 + 2: %q", got)
+	}
+
+	app.ed.Caret = len("item9 x")
+	if err := app.RunCommand(CmdIncrement, ""); err == nil || !strings.Contains(app.lastEvent, "NUMBER ERR") {
+		t.Fatalf("no number should be an error, status %q", app.lastEvent)
+	}
+}
+
+func TestAltArrowsMoveAndDeleteBySubword(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("x := parseHTTPResponse"))
+	app.ed.Caret = app.ed.RuneLen()
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyLeft, mods: modLAlt})
+	if app.ed.Caret != 14 {
+		t.Fatalf("Alt+Left caret=%d, want 14", app.ed.Caret)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyLeft, mods: modLAlt | modShift})
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || a != 10 || b != 14 {
+		t.Fatalf("Alt+Shift+Left should select HTTP, got [%d,%d) active=%v", a, b, app.ed.Sel.Active)
+	}
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = 10
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDelete, mods: modLAlt})
+	if got := app.ed.String(); got != "x := parseResponse" {
+		t.Fatalf("Alt+Delete: %q", got)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyBackspace, mods: modLAlt})
+	if got := app.ed.String(); got != "x := Response" || !app.buffers[0].dirty {
+		t.Fatalf("Alt+Backspace: %q dirty=%v", got, app.buffers[0].dirty)
+	}
+}
+
+func TestCtrlCInSymbolInfoPopupCopiesItsText(t *testing.T) {
+	clip := &recordingClipboard{}
+	app := appState{clipboard: clip}
+	app.initBuffers(editor.NewEditor("fmt.Println(x)"))
+	app.ed.SetClipboard(clip)
+	app.symbolInfoPopup = "Go symbol: Println\n\nHover:\nfunc Println(a ...any) (n int, err error)"
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.sets != 1 || clip.text != app.symbolInfoPopup {
+		t.Fatalf("Ctrl+C should copy the popup text, got sets=%d text=%q", clip.sets, clip.text)
+	}
+	if app.lastEvent != "Copied symbol info" || app.symbolInfoPopup == "" {
+		t.Fatalf("popup should stay open after copying: %q", app.lastEvent)
+	}
+
+	app.symbolInfoPopup = ""
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modCtrl})
+	if clip.text != "fmt.Println(x)\n" {
+		t.Fatalf("without the popup Ctrl+C copies the line again, got %q", clip.text)
+	}
+}
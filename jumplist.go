@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"gc/editor"
+)
+
+// jumpPos is a caret position remembered before a large move (leap, a
+// path:line load, a bookmark jump).
+type jumpPos struct {
+	ed   *editor.Editor
+	path string
+	pos  int
+}
+
+// maxJumps bounds the jump list.
+const maxJumps = 100
+
+func currentJump(app *appState) jumpPos {
+	return jumpPos{ed: app.ed, path: app.currentPath, pos: app.ed.Caret}
+}
+
+// sameJump treats positions on the same line of the same buffer as one.
+func sameJump(a, b jumpPos) bool {
+	if a.ed != b.ed {
+		return false
+	}
+	lines := a.ed.Lines()
+	return editor.CaretLineAt(lines, a.pos) == editor.CaretLineAt(lines, b.pos)
+}
+
+// recordJump remembers the caret before a large move. Entries ahead of the
+// current place in the list (after jumping back) are dropped.
+func recordJump(app *appState) {
+	recordJumpAt(app, app.ed.Caret)
+}
+
+func recordJumpAt(app *appState, pos int) {
+	j := currentJump(app)
+	j.pos = pos
+	pushJump(app, j)
+}
+
+// pushJump adds j to the jump list. Moves that can fail take currentJump
+// first and push it only once the move succeeded.
+func pushJump(app *appState, j jumpPos) {
+	app.jumps = app.jumps[:min(app.jumpIdx, len(app.jumps))]
+	if n := len(app.jumps); n > 0 && sameJump(app.jumps[n-1], j) {
+		app.jumps[n-1] = j
+	} else {
+		app.jumps = append(app.jumps, j)
+	}
+	if len(app.jumps) > maxJumps {
+		app.jumps = slices.Delete(app.jumps, 0, len(app.jumps)-maxJumps)
+	}
+	app.jumpIdx = len(app.jumps)
+}
+
+// jumpBack returns to the previous jump position. Leaving the newest end of
+// the list records the caret there first, so jumpForward can come back.
+func jumpBack(app *appState) error {
+	if app.jumpIdx >= len(app.jumps) {
+		cur := currentJump(app)
+		if n := len(app.jumps); n > 0 && sameJump(app.jumps[n-1], cur) {
+			app.jumpIdx = n - 1
+		} else {
+			app.jumps = append(app.jumps, cur)
+			app.jumpIdx = len(app.jumps) - 1
+		}
+	}
+	if app.jumpIdx == 0 {
+		return fmt.Errorf("no earlier jump")
+	}
+	app.jumpIdx--
+	return goToJump(app, app.jumps[app.jumpIdx])
+}
+
+func jumpForward(app *appState) error {
+	if app.jumpIdx+1 >= len(app.jumps) {
+		return fmt.Errorf("no later jump")
+	}
+	app.jumpIdx++
+	return goToJump(app, app.jumps[app.jumpIdx])
+}
+
+func goToJump(app *appState, j jumpPos) error {
+	if err := showEditor(app, j.ed, j.path, false); err != nil {
+		return err
+	}
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = clamp(j.pos, 0, app.ed.RuneLen())
+	app.lastEvent = fmt.Sprintf("Jump %d/%d", app.jumpIdx+1, len(app.jumps))
+	return nil
+}
+
+// showEditor activates the buffer holding ed, else the buffer for path, else
+// opens path in a new buffer, dropping it again when the load fails. Paths
+// outside the open root are refused unless allowOutside is set.
+func showEditor(app *appState, ed *editor.Editor, path string, allowOutside bool) error {
+	idx := slices.IndexFunc(app.buffers, func(s bufferSlot) bool { return s.ed == ed })
+	if idx < 0 && path != "" {
+		idx = slices.IndexFunc(app.buffers, func(s bufferSlot) bool {
+			return s.path != "" && filepath.Clean(s.path) == filepath.Clean(path)
+		})
+	}
+	if idx < 0 {
+		if path == "" {
+			return fmt.Errorf("buffer was closed")
+		}
+		prev := app.bufIdx
+		app.addBuffer()
+		if err := loadPath(app, path, allowOutside); err != nil {
+			app.closeBuffer()
+			app.bufIdx = prev
+			app.syncActiveBuffer()
+			return err
+		}
+		app.lastEvent = openedStatus(app)
+		return nil
+	}
+	app.bufIdx = idx
+	app.syncActiveBuffer()
+	if app.currentPath != "" {
+		app.lastEvent = "Switched to " + filepath.Base(app.currentPath)
+	} else {
+		app.lastEvent = fmt.Sprintf("Switched to buffer %d/%d", app.bufIdx+1, len(app.buffers))
+	}
+	return nil
+}
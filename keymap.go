@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keyBinding is a key chord that can run a command: an Esc-prefixed key
+// (prefix set) or a Ctrl chord. mods holds only modShift and modCtrl.
+type keyBinding struct {
+	prefix bool
+	key    keyCode
+	mods   modMask
+}
+
+// keymap routes key chords to named commands.
+type keymap map[keyBinding]Command
+
+// defaultKeys holds the built-in bindings, taken from the command registry.
+var defaultKeys = defaultKeymap()
+
+// reservedBinding reports Esc chords that enter a mode before commands are
+// looked up (less mode, close buffer, line highlight, search); they cannot be
+// rebound.
+func reservedBinding(b keyBinding) bool {
+	if !b.prefix || b.mods != 0 {
+		return false
+	}
+	switch b.key {
+	case keySpace, keyEscape, keyX, keySlash:
+		return true
+	}
+	return false
+}
+
+// parseKeyBinding reads chords such as "Ctrl+S", "Esc+Shift+V", "Esc+:" or
+// "Ctrl+?". Letters ignore case; shifted symbols imply Shift.
+func parseKeyBinding(spec string) (keyBinding, error) {
+	var b keyBinding
+	parts := strings.Split(strings.TrimSpace(spec), "+")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		last := i == len(parts)-1
+		switch {
+		case !last && strings.EqualFold(p, "Esc") && i == 0:
+			b.prefix = true
+		case !last && strings.EqualFold(p, "Ctrl"):
+			b.mods |= modCtrl
+		case !last && strings.EqualFold(p, "Shift"):
+			b.mods |= modShift
+		case !last:
+			return keyBinding{}, fmt.Errorf("bad modifier %q in %q", p, spec)
+		default:
+			k, shift, ok := parseKeyName(p)
+			if !ok {
+				return keyBinding{}, fmt.Errorf("unknown key %q in %q", p, spec)
+			}
+			b.key = k
+			if shift {
+				b.mods |= modShift
+			}
+		}
+	}
+	if b.prefix == (b.mods&modCtrl != 0) {
+		return keyBinding{}, fmt.Errorf("%q: want Esc+<key> or Ctrl+<key>", spec)
+	}
+	if reservedBinding(b) {
+		return keyBinding{}, fmt.Errorf("%q is reserved", spec)
+	}
+	return b, nil
+}
+
+// parseKeyName maps a single rune or a key name (as printed by keyName) to a
+// key code, reporting whether the rune implies Shift.
+func parseKeyName(s string) (keyCode, bool, bool) {
+	if r, size := utf8.DecodeRuneInString(s); size == len(s) && r != utf8.RuneError {
+		k, ok := runeToKeyCode(r)
+		return k, ok && !unicode.IsLetter(r) && inferShiftFromRune(r), ok
+	}
+	for k := keyUp; k <= keyBracketR; k++ {
+		if name := keyName(k); name != "Key" && strings.EqualFold(name, s) {
+			return k, false, true
+		}
+	}
+	return keyUnknown, false, false
+}
+
+// defaultKeymap binds every registered command to the keys it lists.
+func defaultKeymap() keymap {
+	km := keymap{}
+	for _, s := range commandSpecs {
+		if b, err := parseKeyBinding(s.keys); err == nil {
+			km[b] = s.cmd
+		}
+	}
+	return km
+}
+
+// loadKeymap applies "<keys> = <command>" lines from r to a copy of base.
+// Blank lines and "#" comments are skipped; the command "none" unbinds the
+// keys.
+func loadKeymap(r io.Reader, base keymap) (keymap, error) {
+	km := make(keymap, len(base))
+	maps.Copy(km, base)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys, name, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want <keys> = <command>", n)
+		}
+		// "Esc+=" binds the equals key.
+		if strings.HasSuffix(strings.TrimSpace(keys), "+") && strings.HasPrefix(name, "=") {
+			keys, name = keys+"=", name[1:]
+		}
+		b, err := parseKeyBinding(keys)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, "none") {
+			delete(km, b)
+			continue
+		}
+		cmd, ok := commandByName(name)
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown command %q", n, name)
+		}
+		km[b] = cmd
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// keymapPath is the user key binding file, e.g. ~/.config/gocat/keys.
+func keymapPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gocat", "keys")
+}
+
+// loadUserKeymap returns the default bindings updated from the user's key
+// file. A missing file is not an error; a bad one leaves the defaults.
+func loadUserKeymap(path string) (keymap, error) {
+	if path == "" {
+		return defaultKeys, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return defaultKeys, nil
+	}
+	if err != nil {
+		return defaultKeys, err
+	}
+	defer f.Close()
+	km, err := loadKeymap(f, defaultKeys)
+	if err != nil {
+		return defaultKeys, fmt.Errorf("%s: %v", path, err)
+	}
+	return km, nil
+}
+
+// boundCommand looks up the command bound to a key. Esc acts as a Ctrl
+// prefix, so an Esc chord without its own binding falls back to the Ctrl
+// binding of the same key (Esc+s saves like Ctrl+S).
+func (app *appState) boundCommand(e keyEvent, prefixed bool) (Command, bool) {
+	km := app.keymap
+	if km == nil {
+		km = defaultKeys
+	}
+	mods := e.mods & (modShift | modCtrl)
+	if prefixed {
+		if cmd, ok := km[keyBinding{prefix: true, key: e.key, mods: mods &^ modCtrl}]; ok {
+			return cmd, true
+		}
+		mods |= modCtrl
+	}
+	cmd, ok := km[keyBinding{key: e.key, mods: mods}]
+	return cmd, ok
+}
@@ -10,9 +10,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type completionItem struct {
@@ -34,6 +38,8 @@ type lspCompletionItem struct {
 }
 
 type goplsClient struct {
+	// mu serialises requests; completions may run off the UI goroutine.
+	mu      sync.Mutex
 	cmd     *exec.Cmd
 	in      io.WriteCloser
 	out     *bufio.Reader
@@ -41,11 +47,18 @@ type goplsClient struct {
 	inited  bool
 	opened  map[string]int
 	rootURI string
+	// diags holds the diagnostics gopls last published per URI, kept raw so
+	// code action requests can hand them back unchanged.
+	diags map[string][]json.RawMessage
+	// utf16 is set unless gopls agreed to count columns in runes: LSP
+	// positions default to UTF-16 code units.
+	utf16 bool
 }
 
 func newGoplsClient() *goplsClient {
 	return &goplsClient{
 		opened: make(map[string]int),
+		diags:  make(map[string][]json.RawMessage),
 	}
 }
 
@@ -79,6 +92,19 @@ func (c *goplsClient) ensureStarted() error {
 	return nil
 }
 
+// start launches and initializes gopls now instead of on the first request.
+func (c *goplsClient) start() error {
+	if c == nil {
+		return fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return err
+	}
+	return c.ensureInitialized()
+}
+
 func (c *goplsClient) ensureInitialized() error {
 	if c.inited {
 		return nil
@@ -87,18 +113,41 @@ func (c *goplsClient) ensureInitialized() error {
 		"processId": os.Getpid(),
 		"rootUri":   c.rootURI,
 		"capabilities": map[string]any{
+			"general": map[string]any{
+				"positionEncodings": []string{"utf-32", "utf-16"},
+			},
 			"textDocument": map[string]any{
 				"completion": map[string]any{
 					"completionItem": map[string]any{
 						"snippetSupport": false,
 					},
 				},
+				"documentSymbol": map[string]any{
+					"hierarchicalDocumentSymbolSupport": true,
+				},
+				"inlayHint": map[string]any{},
+			},
+		},
+		"initializationOptions": map[string]any{
+			"hints": map[string]any{
+				"parameterNames":         true,
+				"assignVariableTypes":    true,
+				"rangeVariableTypes":     true,
+				"functionTypeParameters": true,
 			},
 		},
 	}
-	if _, err := c.request("initialize", params); err != nil {
+	raw, err := c.request("initialize", params)
+	if err != nil {
 		return err
 	}
+	var res struct {
+		Capabilities struct {
+			PositionEncoding string `json:"positionEncoding"`
+		} `json:"capabilities"`
+	}
+	_ = json.Unmarshal(raw, &res)
+	c.utf16 = res.Capabilities.PositionEncoding != "utf-32"
 	if err := c.notify("initialized", map[string]any{}); err != nil {
 		return err
 	}
@@ -107,6 +156,11 @@ func (c *goplsClient) ensureInitialized() error {
 }
 
 func (c *goplsClient) complete(path string, content string, line int, col int) ([]completionItem, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if err := c.ensureStarted(); err != nil {
 		return nil, err
 	}
@@ -119,10 +173,7 @@ func (c *goplsClient) complete(path string, content string, line int, col int) (
 	}
 	params := map[string]any{
 		"textDocument": map[string]any{"uri": uri},
-		"position": map[string]any{
-			"line":      line,
-			"character": col,
-		},
+		"position":     c.position(content, line, col),
 	}
 	raw, err := c.request("textDocument/completion", params)
 	if err != nil {
@@ -132,6 +183,11 @@ func (c *goplsClient) complete(path string, content string, line int, col int) (
 }
 
 func (c *goplsClient) hover(path string, content string, line int, col int) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if err := c.ensureStarted(); err != nil {
 		return "", err
 	}
@@ -144,10 +200,7 @@ func (c *goplsClient) hover(path string, content string, line int, col int) (str
 	}
 	params := map[string]any{
 		"textDocument": map[string]any{"uri": uri},
-		"position": map[string]any{
-			"line":      line,
-			"character": col,
-		},
+		"position":     c.position(content, line, col),
 	}
 	raw, err := c.request("textDocument/hover", params)
 	if err != nil {
@@ -156,6 +209,206 @@ func (c *goplsClient) hover(path string, content string, line int, col int) (str
 	return parseHoverText(raw), nil
 }
 
+// documentSymbols lists the top-level declarations of the file as outline
+// items.
+func (c *goplsClient) documentSymbols(path string, content string) ([]outlineItem, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureInitialized(); err != nil {
+		return nil, err
+	}
+	uri := completionURI(path)
+	if err := c.syncDocument(uri, content); err != nil {
+		return nil, err
+	}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+	}
+	raw, err := c.request("textDocument/documentSymbol", params)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocumentSymbols(raw), nil
+}
+
+// organizeImports asks for the source.organizeImports code action and
+// returns its edits to the file.
+func (c *goplsClient) organizeImports(path string, content string) ([]textEdit, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureInitialized(); err != nil {
+		return nil, err
+	}
+	uri := completionURI(path)
+	if err := c.syncDocument(uri, content); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(content, "\n")
+	last := lines[len(lines)-1]
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range": map[string]any{
+			"start": map[string]any{"line": 0, "character": 0},
+			"end":   map[string]any{"line": len(lines) - 1, "character": c.lspChar(last, utf8.RuneCountInString(last))},
+		},
+		"context": map[string]any{
+			"diagnostics": []any{},
+			"only":        []string{"source.organizeImports"},
+		},
+	}
+	raw, err := c.request("textDocument/codeAction", params)
+	if err != nil {
+		return nil, err
+	}
+	var actions []struct {
+		Kind string          `json:"kind"`
+		Edit json.RawMessage `json:"edit"`
+	}
+	if err := json.Unmarshal(raw, &actions); err != nil {
+		return nil, err
+	}
+	var edits []textEdit
+	for _, a := range actions {
+		if a.Kind == "source.organizeImports" && len(a.Edit) > 0 {
+			edits = append(edits, parseWorkspaceEdit(a.Edit, uri)...)
+		}
+	}
+	c.editsToRuneCols(lines, edits)
+	return edits, nil
+}
+
+// codeActions asks for the quick fixes on a line, passing gopls the
+// diagnostics it published for the line plus msg (a syntax error found
+// locally, if any). Only fixes that edit just this file are returned.
+func (c *goplsClient) codeActions(path string, content string, line int, msg string) ([]codeAction, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureInitialized(); err != nil {
+		return nil, err
+	}
+	uri := completionURI(path)
+	if err := c.syncDocument(uri, content); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(content, "\n")
+	if line < 0 || line >= len(lines) {
+		return nil, fmt.Errorf("line %d out of range", line+1)
+	}
+	lineRange := map[string]any{
+		"start": map[string]any{"line": line, "character": 0},
+		"end":   map[string]any{"line": line, "character": c.lspChar(lines[line], utf8.RuneCountInString(lines[line]))},
+	}
+	diags := []any{}
+	for _, d := range c.lineDiagnostics(uri, line) {
+		diags = append(diags, d)
+	}
+	if msg != "" {
+		diags = append(diags, map[string]any{"range": lineRange, "severity": 1, "source": "syntax", "message": msg})
+	}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        lineRange,
+		"context": map[string]any{
+			"diagnostics": diags,
+			"only":        []string{"quickfix"},
+		},
+	}
+	raw, err := c.request("textDocument/codeAction", params)
+	if err != nil {
+		return nil, err
+	}
+	actions := parseCodeActions(raw, uri)
+	for _, a := range actions {
+		c.editsToRuneCols(lines, a.edits)
+	}
+	return actions, nil
+}
+
+// inlayHints returns the inlay hints gopls has for the whole file.
+func (c *goplsClient) inlayHints(path string, content string) ([]inlayHint, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureInitialized(); err != nil {
+		return nil, err
+	}
+	uri := completionURI(path)
+	if err := c.syncDocument(uri, content); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(content, "\n")
+	last := lines[len(lines)-1]
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range": map[string]any{
+			"start": map[string]any{"line": 0, "character": 0},
+			"end":   map[string]any{"line": len(lines) - 1, "character": c.lspChar(last, utf8.RuneCountInString(last))},
+		},
+	}
+	raw, err := c.request("textDocument/inlayHint", params)
+	if err != nil {
+		return nil, err
+	}
+	hints := parseInlayHints(raw)
+	for i, h := range hints {
+		if h.line >= 0 && h.line < len(lines) {
+			hints[i].col = c.runeCol(lines[h.line], h.col)
+		}
+	}
+	return hints, nil
+}
+
+func (c *goplsClient) signatureHelp(path string, content string, line int, col int) (signatureHelp, bool, error) {
+	if c == nil {
+		return signatureHelp{}, false, fmt.Errorf("nil gopls client")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ensureStarted(); err != nil {
+		return signatureHelp{}, false, err
+	}
+	if err := c.ensureInitialized(); err != nil {
+		return signatureHelp{}, false, err
+	}
+	uri := completionURI(path)
+	if err := c.syncDocument(uri, content); err != nil {
+		return signatureHelp{}, false, err
+	}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     c.position(content, line, col),
+	}
+	raw, err := c.request("textDocument/signatureHelp", params)
+	if err != nil {
+		return signatureHelp{}, false, err
+	}
+	help, ok := parseSignatureHelp(raw)
+	return help, ok, nil
+}
+
 func (c *goplsClient) syncDocument(uri, content string) error {
 	ver := c.opened[uri]
 	if ver == 0 {
@@ -191,7 +444,12 @@ func (c *goplsClient) syncDocument(uri, content string) error {
 }
 
 func (c *goplsClient) close() {
-	if c == nil || c.cmd == nil {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil {
 		return
 	}
 	_, _ = c.request("shutdown", nil)
@@ -221,6 +479,8 @@ func (c *goplsClient) request(method string, params any) (json.RawMessage, error
 		}
 		var envelope struct {
 			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
 			Result json.RawMessage `json:"result"`
 			Error  *struct {
 				Message string `json:"message"`
@@ -229,6 +489,10 @@ func (c *goplsClient) request(method string, params any) (json.RawMessage, error
 		if err := json.Unmarshal(raw, &envelope); err != nil {
 			continue
 		}
+		if envelope.Method == "textDocument/publishDiagnostics" {
+			c.storeDiagnostics(envelope.Params)
+			continue
+		}
 		if len(envelope.ID) == 0 {
 			continue
 		}
@@ -243,6 +507,39 @@ func (c *goplsClient) request(method string, params any) (json.RawMessage, error
 	}
 }
 
+// storeDiagnostics records a publishDiagnostics notification.
+func (c *goplsClient) storeDiagnostics(params json.RawMessage) {
+	var p struct {
+		URI         string            `json:"uri"`
+		Diagnostics []json.RawMessage `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return
+	}
+	if c.diags == nil {
+		c.diags = make(map[string][]json.RawMessage)
+	}
+	c.diags[p.URI] = p.Diagnostics
+}
+
+// lineDiagnostics returns the published diagnostics of uri whose range
+// covers line.
+func (c *goplsClient) lineDiagnostics(uri string, line int) []json.RawMessage {
+	var out []json.RawMessage
+	for _, raw := range c.diags[uri] {
+		var d struct {
+			Range struct {
+				Start lspPosition `json:"start"`
+				End   lspPosition `json:"end"`
+			} `json:"range"`
+		}
+		if json.Unmarshal(raw, &d) == nil && d.Range.Start.Line <= line && line <= d.Range.End.Line {
+			out = append(out, raw)
+		}
+	}
+	return out
+}
+
 func (c *goplsClient) notify(method string, params any) error {
 	msg := map[string]any{
 		"jsonrpc": "2.0",
@@ -396,6 +693,397 @@ func parseMarkupText(raw json.RawMessage) string {
 	return ""
 }
 
+// textEdit replaces the text between two LSP positions (line and rune
+// column) with NewText.
+type textEdit struct {
+	Range struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	} `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// position is the LSP position of rune column col on line of content.
+func (c *goplsClient) position(content string, line, col int) map[string]any {
+	lines := strings.Split(content, "\n")
+	if line >= 0 && line < len(lines) {
+		col = c.lspChar(lines[line], col)
+	}
+	return map[string]any{"line": line, "character": col}
+}
+
+// lspChar converts rune column col of line to the client's position
+// encoding.
+func (c *goplsClient) lspChar(line string, col int) int {
+	if !c.utf16 {
+		return col
+	}
+	return utf16Col(line, col)
+}
+
+// runeCol converts a character offset on line from the client's position
+// encoding to a rune column.
+func (c *goplsClient) runeCol(line string, ch int) int {
+	if !c.utf16 {
+		return ch
+	}
+	return runeColFromUTF16(line, ch)
+}
+
+// editsToRuneCols rewrites the columns of edits to lines in place, so
+// applyTextEdits can treat them as rune columns.
+func (c *goplsClient) editsToRuneCols(lines []string, edits []textEdit) {
+	conv := func(p *lspPosition) {
+		if p.Line >= 0 && p.Line < len(lines) {
+			p.Character = c.runeCol(lines[p.Line], p.Character)
+		}
+	}
+	for i := range edits {
+		conv(&edits[i].Range.Start)
+		conv(&edits[i].Range.End)
+	}
+}
+
+// utf16Col returns the number of UTF-16 code units in the first col runes of
+// line.
+func utf16Col(line string, col int) int {
+	n := 0
+	for _, r := range line {
+		if col <= 0 {
+			break
+		}
+		n += utf16.RuneLen(r)
+		col--
+	}
+	return n + max(col, 0)
+}
+
+// runeColFromUTF16 returns the rune column at UTF-16 offset units of line.
+// An offset inside a surrogate pair rounds down to its rune.
+func runeColFromUTF16(line string, units int) int {
+	col := 0
+	for _, r := range line {
+		n := utf16.RuneLen(r)
+		if units < n {
+			return col
+		}
+		units -= n
+		col++
+	}
+	return col + max(units, 0)
+}
+
+// parseWorkspaceEdit returns the edits a WorkspaceEdit makes to uri, from
+// either its changes map or its documentChanges list.
+func parseWorkspaceEdit(raw json.RawMessage, uri string) []textEdit {
+	var ws struct {
+		Changes         map[string][]textEdit `json:"changes"`
+		DocumentChanges []struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []textEdit `json:"edits"`
+		} `json:"documentChanges"`
+	}
+	if err := json.Unmarshal(raw, &ws); err != nil {
+		return nil
+	}
+	out := ws.Changes[uri]
+	for _, dc := range ws.DocumentChanges {
+		if dc.TextDocument.URI == uri {
+			out = append(out, dc.Edits...)
+		}
+	}
+	return out
+}
+
+// codeAction is a fix offered by gopls: its title and its edits to the file.
+type codeAction struct {
+	title string
+	edits []textEdit
+}
+
+// parseCodeActions keeps the code actions of a textDocument/codeAction result
+// that carry an edit touching only uri; command-only actions and edits to
+// other files are dropped.
+func parseCodeActions(raw json.RawMessage, uri string) []codeAction {
+	var actions []struct {
+		Title string          `json:"title"`
+		Edit  json.RawMessage `json:"edit"`
+	}
+	if err := json.Unmarshal(raw, &actions); err != nil {
+		return nil
+	}
+	var out []codeAction
+	for _, a := range actions {
+		if len(a.Edit) == 0 || !workspaceEditOnlyTouches(a.Edit, uri) {
+			continue
+		}
+		if edits := parseWorkspaceEdit(a.Edit, uri); len(edits) > 0 {
+			out = append(out, codeAction{title: a.Title, edits: edits})
+		}
+	}
+	return out
+}
+
+// workspaceEditOnlyTouches reports whether a WorkspaceEdit only changes the
+// text of uri, without file creates, renames or deletes.
+func workspaceEditOnlyTouches(raw json.RawMessage, uri string) bool {
+	var ws struct {
+		Changes         map[string]json.RawMessage `json:"changes"`
+		DocumentChanges []struct {
+			Kind         string `json:"kind"`
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		} `json:"documentChanges"`
+	}
+	if err := json.Unmarshal(raw, &ws); err != nil {
+		return false
+	}
+	for u := range ws.Changes {
+		if u != uri {
+			return false
+		}
+	}
+	for _, dc := range ws.DocumentChanges {
+		if dc.Kind != "" || dc.TextDocument.URI != uri {
+			return false
+		}
+	}
+	return true
+}
+
+// applyTextEdits returns src with the edits applied. Edit columns count
+// runes; goplsClient converts them from LSP positions. The edits must not
+// overlap; they are applied from the end so earlier positions stay valid,
+// and inserts at one position keep their order.
+func applyTextEdits(src []rune, edits []textEdit) ([]rune, error) {
+	starts := []int{0}
+	for i, r := range src {
+		if r == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	offset := func(p lspPosition) (int, error) {
+		if p.Line < 0 || p.Line >= len(starts) {
+			return 0, fmt.Errorf("edit line %d out of range", p.Line+1)
+		}
+		end := len(src)
+		if p.Line+1 < len(starts) {
+			end = starts[p.Line+1] - 1
+		}
+		return min(starts[p.Line]+max(p.Character, 0), end), nil
+	}
+	type span struct {
+		a, b int
+		text string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		a, err := offset(e.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		b, err := offset(e.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if b < a {
+			return nil, fmt.Errorf("edit ends before it starts")
+		}
+		spans = append(spans, span{a, b, e.NewText})
+	}
+	slices.SortStableFunc(spans, func(x, y span) int { return x.a - y.a })
+	out := slices.Clone(src)
+	next := len(out)
+	for i := len(spans) - 1; i >= 0; i-- {
+		sp := spans[i]
+		if sp.b > next {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+		out = slices.Concat(out[:sp.a], []rune(sp.text), out[sp.b:])
+		next = sp.a
+	}
+	return out, nil
+}
+
+// parseInlayHints reads an inlayHint result. Labels may be a string or a
+// list of parts; padding becomes spaces around the label.
+func parseInlayHints(raw json.RawMessage) []inlayHint {
+	var hints []struct {
+		Position     lspPosition     `json:"position"`
+		Label        json.RawMessage `json:"label"`
+		PaddingLeft  bool            `json:"paddingLeft"`
+		PaddingRight bool            `json:"paddingRight"`
+	}
+	if err := json.Unmarshal(raw, &hints); err != nil {
+		return nil
+	}
+	var out []inlayHint
+	for _, h := range hints {
+		var label string
+		if err := json.Unmarshal(h.Label, &label); err != nil {
+			var parts []struct {
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal(h.Label, &parts); err != nil {
+				continue
+			}
+			for _, p := range parts {
+				label += p.Value
+			}
+		}
+		if label == "" {
+			continue
+		}
+		if h.PaddingLeft {
+			label = " " + label
+		}
+		if h.PaddingRight {
+			label += " "
+		}
+		out = append(out, inlayHint{line: h.Position.Line, col: h.Position.Character, label: label})
+	}
+	return out
+}
+
+// lspSymbolKeyword maps the LSP symbol kinds of top-level Go declarations to
+// the keyword that declares them.
+var lspSymbolKeyword = map[int]string{
+	5:  "type",  // Class
+	6:  "func",  // Method
+	10: "type",  // Enum
+	11: "type",  // Interface
+	12: "func",  // Function
+	13: "var",   // Variable
+	14: "const", // Constant
+	23: "type",  // Struct
+	26: "type",  // TypeParameter
+}
+
+// parseDocumentSymbols reads a documentSymbol result, either hierarchical
+// DocumentSymbols (children are skipped) or flat SymbolInformation, into
+// outline items titled "keyword name", in line order.
+func parseDocumentSymbols(raw json.RawMessage) []outlineItem {
+	type lspRange struct {
+		Start struct {
+			Line int `json:"line"`
+		} `json:"start"`
+	}
+	var syms []struct {
+		Name           string    `json:"name"`
+		Kind           int       `json:"kind"`
+		ContainerName  string    `json:"containerName"`
+		SelectionRange *lspRange `json:"selectionRange"`
+		Range          *lspRange `json:"range"`
+		Location       *struct {
+			Range lspRange `json:"range"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(raw, &syms); err != nil {
+		return nil
+	}
+	var out []outlineItem
+	for _, sym := range syms {
+		kw, ok := lspSymbolKeyword[sym.Kind]
+		if !ok || sym.ContainerName != "" {
+			continue
+		}
+		var line int
+		switch {
+		case sym.SelectionRange != nil:
+			line = sym.SelectionRange.Start.Line
+		case sym.Range != nil:
+			line = sym.Range.Start.Line
+		case sym.Location != nil:
+			line = sym.Location.Range.Start.Line
+		}
+		out = append(out, outlineItem{line: line, level: 1, title: kw + " " + sym.Name})
+	}
+	slices.SortStableFunc(out, func(a, b outlineItem) int { return a.line - b.line })
+	return out
+}
+
+// signatureHelp is the active signature of a call being typed. Params holds
+// each parameter's rune range within Label.
+type signatureHelp struct {
+	Label  string
+	Params [][2]int
+	Active int
+	Doc    string
+}
+
+// activeRange returns the rune range of the active parameter in Label.
+func (h signatureHelp) activeRange() (int, int, bool) {
+	if h.Active < 0 || h.Active >= len(h.Params) {
+		return 0, 0, false
+	}
+	return h.Params[h.Active][0], h.Params[h.Active][1], true
+}
+
+// parseSignatureHelp decodes a textDocument/signatureHelp result. It reports
+// false for a null result or one without signatures (caret not in a call).
+func parseSignatureHelp(raw json.RawMessage) (signatureHelp, bool) {
+	var payload struct {
+		Signatures []struct {
+			Label         string          `json:"label"`
+			Documentation json.RawMessage `json:"documentation"`
+			Parameters    []struct {
+				Label json.RawMessage `json:"label"`
+			} `json:"parameters"`
+			ActiveParameter *int `json:"activeParameter"`
+		} `json:"signatures"`
+		ActiveSignature int  `json:"activeSignature"`
+		ActiveParameter *int `json:"activeParameter"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || len(payload.Signatures) == 0 {
+		return signatureHelp{}, false
+	}
+	idx := payload.ActiveSignature
+	if idx < 0 || idx >= len(payload.Signatures) {
+		idx = 0
+	}
+	sig := payload.Signatures[idx]
+	help := signatureHelp{Label: sig.Label, Doc: parseMarkupText(sig.Documentation)}
+	label := []rune(sig.Label)
+	from := 0
+	for _, p := range sig.Parameters {
+		var name string
+		var offs [2]int
+		switch {
+		case json.Unmarshal(p.Label, &name) == nil:
+			// String labels are located in order so repeated names resolve
+			// to successive parameters.
+			i := strings.Index(string(label[from:]), name)
+			if i < 0 {
+				help.Params = append(help.Params, [2]int{0, 0})
+				continue
+			}
+			start := from + utf8.RuneCountInString(string(label[from:])[:i])
+			offs = [2]int{start, start + utf8.RuneCountInString(name)}
+		case json.Unmarshal(p.Label, &offs) == nil:
+			offs[0] = min(max(offs[0], 0), len(label))
+			offs[1] = min(max(offs[1], offs[0]), len(label))
+		}
+		help.Params = append(help.Params, offs)
+		from = offs[1]
+	}
+	help.Active = 0
+	if payload.ActiveParameter != nil {
+		help.Active = *payload.ActiveParameter
+	}
+	if sig.ActiveParameter != nil {
+		help.Active = *sig.ActiveParameter
+	}
+	return help, true
+}
+
 func stripSnippet(s string) string {
 	if s == "" {
 		return s
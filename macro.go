@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// macroEvent is one recorded input: a key, or typed text when text is set.
+type macroEvent struct {
+	key  keyEvent
+	text string
+	mods modMask
+}
+
+// maxMacroReplays bounds the replay count accepted by the prompt.
+const maxMacroReplays = 1000
+
+// recordMacroKey appends a dispatched key to the macro when recording was on
+// before and after it. rec and prefixWas are the recording and Esc-prefix
+// states from before the key was handled.
+func recordMacroKey(app *appState, rec, prefixWas bool, e keyEvent) {
+	if !rec || !app.macroRecording {
+		return
+	}
+	if !prefixWas && app.cmdPrefixActive {
+		// This Esc may begin the Esc+z that stops recording.
+		app.macroPrefixAt = len(app.macro)
+	}
+	app.macro = append(app.macro, macroEvent{key: e})
+}
+
+// toggleMacroRecording starts a new macro or stops the current one. The Esc
+// of the stopping Esc+z is dropped from the recording.
+func toggleMacroRecording(app *appState) {
+	if app.macroReplaying {
+		app.lastEvent = "Cannot record during macro replay"
+		return
+	}
+	if app.macroRecording {
+		app.macroRecording = false
+		app.macro = app.macro[:min(app.macroPrefixAt, len(app.macro))]
+		app.lastEvent = fmt.Sprintf("Recorded macro (%s); Esc+Shift+Z replays", plural(len(app.macro), "event"))
+		return
+	}
+	app.macroRecording = true
+	app.macro = nil
+	app.macroPrefixAt = 0
+	app.lastEvent = "Recording macro (Esc+z stops)"
+}
+
+func promptMacroReplay(app *appState) {
+	switch {
+	case app.macroReplaying:
+		app.lastEvent = "Macro is already replaying"
+		return
+	case app.macroRecording:
+		app.lastEvent = "Stop recording (Esc+z) before replaying"
+		return
+	case len(app.macro) == 0:
+		app.lastEvent = "No macro recorded (Esc+z records)"
+		return
+	}
+	app.inputActive = true
+	app.inputPrompt = "Replay times: "
+	app.inputValue = ""
+	app.inputKind = "macro"
+	app.lastEvent = "Replay macro: count (Enter = once), Esc to cancel"
+}
+
+// parseReplayCount reads the replay prompt; empty means once.
+func parseReplayCount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxMacroReplays {
+		return 0, fmt.Errorf("want a count from 1 to %d", maxMacroReplays)
+	}
+	return n, nil
+}
+
+// replayMacro re-dispatches the recorded events n times. It reports false if
+// a replayed key quits the editor.
+func replayMacro(app *appState, n int) bool {
+	if app.macroReplaying || app.macroRecording {
+		return true
+	}
+	events := slices.Clone(app.macro)
+	// Any pending echo suppression belongs to the key that asked for the
+	// replay, not to the first replayed text.
+	app.suppressTextOnce = false
+	app.macroReplaying = true
+	defer func() { app.macroReplaying = false }()
+	for range n {
+		for _, ev := range events {
+			if ev.text != "" {
+				if !dispatchTextEvent(app, ev.text, ev.mods) {
+					return false
+				}
+				continue
+			}
+			if !dispatchKeyEvent(app, ev.key) {
+				return false
+			}
+			// A command key's text echo was not recorded, so nothing is
+			// left to suppress.
+			app.suppressTextOnce = false
+		}
+	}
+	app.lastEvent = fmt.Sprintf("Replayed macro %s", plural(n, "time"))
+	return true
+}
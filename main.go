@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"go/parser"
@@ -11,9 +12,13 @@ import (
 	"os/exec"
 	pathpkg "path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 
 	"gc/editor"
@@ -28,10 +33,29 @@ type bufferSlot struct {
 	// picker buffers are temporary file-list views
 	picker     bool
 	pickerRoot string
-	dirty      bool
-	rev        int
-	textRev    int
-	mode       syntaxKind
+	// readOnly buffers refuse edits and saves (picker/run/help by default).
+	readOnly bool
+	dirty    bool
+	// modTime is the file mtime at last load/save, for external-change checks.
+	modTime time.Time
+	// swapEdits counts edits since the swap file was last written.
+	swapEdits int
+	// indentWidth is the space-indent unit detected on load (0 = tabs).
+	indentWidth int
+	// tailView buffers hold only the end of a file too large to load.
+	tailView bool
+	// bom records a UTF-8 byte order mark stripped on load, re-emitted on save.
+	bom bool
+	// crlf buffers hold LF text that is saved with CRLF line endings.
+	crlf bool
+	// scrollLine is the first visible line, saved while the buffer is not
+	// active. The goal column lives on the Editor itself.
+	scrollLine int
+	// folds are the marks anchoring the buffer's folded blocks.
+	folds   []int
+	rev     int
+	textRev int
+	mode    syntaxKind
 	// Per-buffer cached render data keyed by textRev/mode/path.
 	cachedTextRev    int
 	cachedMode       syntaxKind
@@ -45,6 +69,10 @@ type bufferSlot struct {
 	syntaxErrMode    syntaxKind
 	syntaxErrLines   map[int]struct{}
 	syntaxErrMsgs    map[int]string
+	// Foldable blocks by anchor offset, cached by textRev/mode.
+	foldTextRev int
+	foldMode    syntaxKind
+	foldAnchors map[int]foldRange
 }
 
 type renderCache struct {
@@ -75,12 +103,33 @@ type appState struct {
 	bufIdx           int
 	currentPath      string
 	scrollLine       int
+	visibleLines     int // text rows in the last drawn frame
+	screenW, screenH int // size of the last drawn frame
+	scrollPin        scrollPin
+	closed           []closedBuffer // most recently closed last
 	symbolInfoPopup  string
 	symbolInfoScroll int
+	// symbolInfoQuery is the popup search; symbolInfoSearch is set while it
+	// is typed. symbolInfoMatch indexes the current match.
+	symbolInfoQuery  []rune
+	symbolInfoSearch bool
+	symbolInfoMatch  int
 	syntaxHL         *syntaxHighlighter
 	syntaxCheck      *goSyntaxChecker
 	gopls            *goplsClient
 	noGopls          bool
+	// completionSeq numbers async completion requests; only the latest one's
+	// results are shown. completionReq describes that request.
+	completionSeq atomic.Int64
+	completionReq completionRequest
+	// sigHelp is the signature popup shown while typing call arguments;
+	// sigHelpSeq/sigHelpReq track its async request like completion's.
+	sigHelp       signatureHelp
+	sigHelpActive bool
+	sigHelpSeq    atomic.Int64
+	sigHelpReq    completionRequest
+	// autoComplete opens selector completion after typing `.` in Go buffers.
+	autoComplete     bool
 	clipboard        editor.Clipboard
 	cmdPrefixActive  bool
 	suppressTextOnce bool
@@ -104,9 +153,102 @@ type appState struct {
 	searchPatternDone bool
 	searchOrigin      int
 	searchLastMatch   int
-	completionPopup   completionPopupState
-	render            renderCache
-	startupFast       bool
+	// Buffer indexes waiting for the swap-recovery prompt; the first is the
+	// one being asked about.
+	swapPrompts []int
+	// outsidePath is the file awaiting the open-outside-root confirmation.
+	outsidePath string
+	// Jump-to-char state: armed until a different key is pressed.
+	jumpCharArmed bool
+	jumpCharDir   editor.Dir
+	jumpCharRune  rune
+	// Next leap history index recalled by Esc+h.
+	leapHistoryPos int
+	// Split view: the focused pane is always the active buffer; the other pane
+	// shows splitOther with its own scroll offset.
+	splitActive      bool
+	splitFocusRight  bool
+	splitOther       int
+	splitOtherScroll int
+	// Display options set through the Esc+Shift+O prompt.
+	lineNumbers    lineNumberMode
+	pathMode       pathMode
+	showWhitespace bool
+	// rulerCol is the 1-based column marked by the ruler (0 = off).
+	rulerCol int
+	// lineLimit marks lines wider than this many visual columns (0 = off).
+	lineLimit int
+	// noBlink keeps a steady caret; blinkPeriod > 0 makes gc blink the caret
+	// itself instead of leaving it to the terminal.
+	noBlink      bool
+	blinkPeriod  time.Duration
+	blinkPending bool
+	// noMatchAt is when the leap or search query stopped matching (zero
+	// while it matches); the input line flashes briefly from then.
+	noMatchAt time.Time
+	// curLineColor is the caret line's background, a color name or #rrggbb
+	// ("" = not highlighted).
+	curLineColor string
+	// Esc+Shift+U case cycle: the case applied last, valid while the buffer
+	// revision is unchanged.
+	caseCycleActive bool
+	caseCycleLast   editor.Case
+	caseCycleRev    uint64
+	// noDoubleSpace turns off the double-space indent in code buffers.
+	noDoubleSpace bool
+	// noPasteIndent keeps pasted code blocks' indentation as copied.
+	noPasteIndent bool
+	// noIndentGuides hides the indentation guides in code buffers.
+	noIndentGuides bool
+	// noOccurrences turns off highlighting the identifier at the caret;
+	// occur tracks where the caret came to rest.
+	noOccurrences bool
+	occur         occurrenceState
+	// wordChars are extra runes word operations treat as word characters.
+	wordChars string
+	// fmtDiff applies Esc+F formatting as minimal edits instead of a reload.
+	fmtDiff bool
+	// organizeImports organizes a Go buffer's imports before Esc+F saves it.
+	organizeImports bool
+	// inlayHints shows gopls inlay hints in Go buffers; inlay caches them.
+	inlayHints bool
+	inlay      inlayHintState
+	// pickerDetails annotates picker entries with size and modification time.
+	pickerDetails bool
+	// spellCheck underlines unknown words in prose buffers.
+	spellCheck bool
+	spell      spellChecker
+	// pickerOpPath is the picker entry a rename/delete prompt acts on.
+	pickerOpPath string
+	// noGitignore stops the file walkers from honouring .gitignore.
+	noGitignore bool
+	// ignoreNames are extra directory names the file walkers skip.
+	ignoreNames []string
+	// findLimit is the open prompt's match page size (0 = defaultFindLimit).
+	findLimit       int
+	completionPopup completionPopupState
+	codeActions     codeActionState
+	statusLog       statusLog
+	palette         paletteState
+	// snippets maps a snippet name to its body; nil means the built-ins.
+	snippets  map[string]string
+	outline   outlineState
+	bookmarks map[string]bookmark
+	// jumps is the jump list; jumpIdx is the entry last jumped to, or
+	// len(jumps) when not navigating it.
+	jumps          []jumpPos
+	jumpIdx        int
+	sidebar        sidebarState
+	keymap         keymap
+	macro          []macroEvent
+	macroRecording bool
+	macroReplaying bool
+	macroPrefixAt  int
+	lastEdit       repeatEdit
+	insertRunOpen  bool
+	pendingCount   int
+	render         renderCache
+	startupFast    bool
 }
 
 type completionPopupState struct {
@@ -127,33 +269,105 @@ type completionDetailInterrupt struct {
 	Token int
 }
 
+// completionRequest records where an async selector completion was asked
+// for, so a late response can be checked against the current buffer.
+type completionRequest struct {
+	token   int64
+	bufIdx  int
+	textRev int
+	caret   int
+	prefix  string
+	start   int
+	end     int
+	client  *goplsClient // gopls client the request was issued on
+}
+
+type completionResultInterrupt struct {
+	Token int64
+	Items []completionItem
+	Err   error
+}
+
+type signatureHelpInterrupt struct {
+	Token int64
+	Help  signatureHelp
+	OK    bool
+	Err   error
+}
+
+// completionDebounce delays async completion requests so a burst of Tab
+// presses reaches gopls once.
+const completionDebounce = 120 * time.Millisecond
+
 type helpEntry struct {
 	action string
 	keys   string
 }
 
 var helpEntries = []helpEntry{
-	{"Leap forward / backward", "Unbound in TUI mode"},
-	{"Leap Again", "N/A in TUI mode"},
+	{"Leap forward / backward", "Esc+j / Esc+` (type, Enter keeps)"},
+	{"Leap Again forward / backward", "Esc+n / Esc+Shift+N"},
+	{"Leap select forward / backward", "Esc+Shift+J / Esc+Shift+K"},
+	{"Cycle leap history", "Esc+h"},
+	{"Jump to character forward / backward", "Esc+t / Esc+Shift+T"},
 	{"New buffer / cycle buffers", "Ctrl+B / Shift+Tab"},
+	{"Toggle split view / switch pane", "Esc+Shift+V / Esc+p"},
+	{"Toggle foo.go / foo_test.go", "Esc+g"},
+	{"Diagnostics summary buffer", "Esc+d"},
+	{"Word / line / char count", "Esc+Shift+C (selection or buffer)"},
+	{"Remove duplicate lines (adjacent / all)", "Esc+Shift+D / Esc+Shift+G"},
+	{"Align lines on a delimiter", "Esc+| (prompts for the delimiter)"},
+	{"Change case (UPPER / lower / Title)", "Esc+Shift+U (repeat to cycle)"},
+	{"Renumber ordered list (Markdown)", "Esc+Shift+M"},
+	{"Markdown preview buffer", "Esc+Shift+P"},
 	{"File picker / load line path", "Ctrl+O / Ctrl+L"},
+	{"Rename / delete picker entry", "Esc+Shift+W / Delete (asks first)"},
 	{"Write as / save all", "Esc+W / Esc+Shift+S"},
 	{"Save + fmt/fix + reload", "Esc+F"},
 	{"Run package (go run .)", "Ctrl+R"},
 	{"Close buffer / quit", "Ctrl+Q / Esc+Shift+Q"},
-	{"Undo", "Ctrl+U"},
+	{"Undo / redo", "Ctrl+U / Ctrl+Y"},
+	{"Toggle read-only", "Esc+Shift+R"},
+	{"Set option (name=value)", "Esc+Shift+O"},
+	{"Command palette", "Esc+: (type to filter, Enter runs)"},
+	{"Record macro / replay", "Esc+z / Esc+Shift+Z (prompts for a count)"},
+	{"Repeat last edit", "Esc+y"},
+	{"Count prefix", "Esc+<digit> then a move (Esc+5 Down)"},
+	{"Select without Shift", "Esc+arrow / Esc+PageUp / Esc+PageDown"},
+	{"Scroll view (caret stays)", "Ctrl+Up / Ctrl+Down / Ctrl+PageUp / Ctrl+PageDown"},
+	{"Close other buffers", "Esc+Shift+B (asks about unsaved ones)"},
+	{"Reopen last closed file", "Esc+Shift+L"},
+	{"File sidebar", "Esc+Shift+F (Esc back to editor, q hides)"},
+	{"Bookmarks", "Esc+\" sets (name or next number) / Esc+' jumps"},
+	{"Jump back / forward", "Esc+- / Esc+_"},
+	{"Fold / unfold Go block or Markdown section", "Esc+Shift+H"},
+	{"Markdown heading / Go symbol outline", "Esc+Shift+I (Enter jumps)"},
+	{"Quick fixes for the caret line (gopls)", "Esc+a (Enter applies)"},
+	{"Recent status messages", "Esc+Shift+E"},
+	{"Wrap selection or word", "Esc+( then the opening delimiter"},
+	{"Increment / decrement number", "Esc+Shift+= / Esc+Shift+X (Esc+<count> then + / -)"},
+	{"End with one newline", "Esc+$"},
+	{"Switch line endings LF / CRLF", "Esc+;"},
+	{"Accept word for spell check", "Esc+! (with spell=on)"},
+	{"List TODO / FIXME comments", "Esc+# (Enter jumps)"},
 	{"Comment / uncomment", "Ctrl+/ (selection or current line)"},
 	{"Line start / end", "Ctrl+A / Ctrl+E (Shift = select)"},
 	{"Buffer start / end", "Ctrl+Shift+A / Ctrl+Shift+E"},
 	{"Kill to EOL", "Ctrl+K"},
 	{"Copy / Cut / Paste", "Ctrl+C / Ctrl+X / Ctrl+V"},
+	{"Paste as lines below / above", "Esc+} / Esc+{"},
+	{"Restart gopls after a failure", "Esc+Shift+Y"},
+	{"Expand selection (word / line / buffer)", "Esc+="},
 	{"Symbol info under cursor (Go)", "Esc+I"},
+	{"Search symbol info popup", "Ctrl+F in the popup (Tab next, Esc clears)"},
+	{"Copy symbol info popup text", "Ctrl+C in the popup"},
 	{"Cycle language mode", "Esc+M"},
 	{"Search mode", "Esc+/ then type pattern; / locks; Tab/Shift+Tab navigate; x enters line highlight mode"},
 	{"Line highlight mode", "Esc+X (or x from locked search), then x to extend by line; Esc exits"},
 	{"Autocomplete (Go mode)", "Tab"},
 	{"Less mode", "Esc+Space (Space page, Esc exit)"},
 	{"Navigation", "Arrows, PageUp/Down, Ctrl+, Ctrl+. (Shift = select)"},
+	{"Subword move / delete", "Alt+Left / Alt+Right (Shift = select) / Alt+Backspace / Alt+Delete"},
 	{"Delete buffer contents", "Esc+Shift+Delete"},
 	{"Escape", "Closes symbol info popup or exits less mode; otherwise command prefix (Esc then Esc closes current buffer)"},
 	{"Help buffer", "Ctrl+Shift+/ (Ctrl+?)"},
@@ -163,10 +377,25 @@ type openPrompt struct {
 	Active  bool
 	Query   string
 	Matches []string
+	// Limit is the match count asked for; More reports matches beyond it.
+	Limit int
+	More  bool
+}
+
+// defaultFindLimit is the open prompt's page of matches.
+const defaultFindLimit = 50
+
+// findLimit is the configured page size of the open prompt.
+func findLimit(app *appState) int {
+	if app.findLimit > 0 {
+		return app.findLimit
+	}
+	return defaultFindLimit
 }
 
 func (app *appState) initBuffers(ed *editor.Editor) {
 	app.buffers = []bufferSlot{{ed: ed, rev: 1, textRev: 1}}
+	ed.SetWordChars(app.wordChars)
 	app.bufIdx = 0
 	app.ed = ed
 	app.currentPath = ""
@@ -185,6 +414,17 @@ func (app *appState) syncActiveBuffer() {
 	}
 	app.bufIdx = clamp(app.bufIdx, 0, len(app.buffers)-1)
 	b := app.buffers[app.bufIdx]
+	if b.ed != app.ed {
+		// Park the outgoing buffer's scroll (if it is still open) and bring
+		// back the incoming one's.
+		for i := range app.buffers {
+			if app.ed != nil && app.buffers[i].ed == app.ed {
+				app.buffers[i].scrollLine = app.scrollLine
+				break
+			}
+		}
+		app.scrollLine = b.scrollLine
+	}
 	app.ed = b.ed
 	app.currentPath = b.path
 }
@@ -194,6 +434,7 @@ func (app *appState) addBuffer() {
 	if app.clipboard != nil {
 		nb.ed.SetClipboard(app.clipboard)
 	}
+	nb.ed.SetWordChars(app.wordChars)
 	app.buffers = append(app.buffers, nb)
 	app.bufIdx = len(app.buffers) - 1
 	app.syncActiveBuffer()
@@ -204,6 +445,7 @@ func (app *appState) addPickerBuffer(lines []string) {
 		ed:         editor.NewEditor(strings.Join(lines, "\n")),
 		picker:     true,
 		pickerRoot: app.openRoot,
+		readOnly:   true,
 		rev:        1,
 		textRev:    1,
 		mode:       syntaxNone,
@@ -228,6 +470,10 @@ func (app *appState) markDirty() {
 	app.buffers[app.bufIdx].syntaxErrMode = syntaxNone
 	app.buffers[app.bufIdx].syntaxErrLines = nil
 	app.buffers[app.bufIdx].syntaxErrMsgs = nil
+	app.buffers[app.bufIdx].swapEdits++
+	if app.buffers[app.bufIdx].swapEdits >= swapEveryEdits {
+		_ = writeSwap(&app.buffers[app.bufIdx])
+	}
 }
 
 func (app *appState) touchBuffer(idx int) {
@@ -267,10 +513,53 @@ func (app *appState) switchBuffer(delta int) {
 	app.syncActiveBuffer()
 }
 
+// toggleSplit turns the two-pane view on (showing the next buffer beside the
+// active one) or off.
+func (app *appState) toggleSplit() {
+	if app == nil {
+		return
+	}
+	if app.splitActive {
+		app.splitActive = false
+		app.splitFocusRight = false
+		return
+	}
+	app.splitActive = true
+	app.splitFocusRight = false
+	app.splitOther = app.bufIdx
+	if n := len(app.buffers); n > 1 {
+		app.splitOther = (app.bufIdx + 1) % n
+	}
+	app.splitOtherScroll = 0
+}
+
+// switchSplitFocus moves input focus to the other pane, swapping which buffer
+// is active and which scroll offset belongs to the focused pane.
+func (app *appState) switchSplitFocus() bool {
+	if app == nil || !app.splitActive || len(app.buffers) == 0 {
+		return false
+	}
+	other := clamp(app.splitOther, 0, len(app.buffers)-1)
+	app.splitOther = app.bufIdx
+	app.bufIdx = other
+	otherScroll := app.splitOtherScroll
+	app.splitOtherScroll = app.scrollLine
+	app.splitFocusRight = !app.splitFocusRight
+	app.syncActiveBuffer()
+	// The pane keeps its own offset even when both show the same buffer.
+	app.scrollLine = otherScroll
+	return true
+}
+
 func (app *appState) closeBuffer() int {
 	if app == nil || len(app.buffers) == 0 {
 		return 0
 	}
+	app.rememberClosed()
+	removeSwap(app.buffers[app.bufIdx].path)
+	if app.splitOther > app.bufIdx {
+		app.splitOther--
+	}
 	app.buffers = append(app.buffers[:app.bufIdx], app.buffers[app.bufIdx+1:]...)
 	if app.bufIdx >= len(app.buffers) {
 		app.bufIdx = len(app.buffers) - 1
@@ -280,10 +569,55 @@ func (app *appState) closeBuffer() int {
 	return len(app.buffers)
 }
 
+// otherDirtyBuffers counts unsaved buffers other than the active one.
+func (app *appState) otherDirtyBuffers() int {
+	n := 0
+	for i, b := range app.buffers {
+		if i != app.bufIdx && b.dirty {
+			n++
+		}
+	}
+	return n
+}
+
+// closeOtherBuffers closes every buffer except the active one through
+// closeBuffer, discarding unsaved edits, and returns how many it closed.
+func (app *appState) closeOtherBuffers() int {
+	if app == nil || len(app.buffers) == 0 {
+		return 0
+	}
+	keep := app.buffers[app.bufIdx].ed
+	closed := 0
+	for len(app.buffers) > 1 {
+		app.bufIdx = 0
+		if app.buffers[0].ed == keep {
+			app.bufIdx = 1
+		}
+		app.closeBuffer()
+		closed++
+	}
+	return closed
+}
+
+// promptCloseOthers asks before closeOtherBuffers discards unsaved buffers.
+func promptCloseOthers(app *appState, dirty int) {
+	app.inputActive = true
+	app.inputValue = ""
+	app.inputKind = "closeothers"
+	app.inputPrompt = fmt.Sprintf("Close other buffers, discarding %s? (y/N): ", plural(dirty, "unsaved buffer"))
+	app.lastEvent = "Unsaved buffers: y closes them anyway, Enter/Esc keeps them"
+}
+
 func saveCurrent(app *appState) error {
 	if app == nil || app.ed == nil || len(app.buffers) == 0 {
 		return fmt.Errorf("no editor to save")
 	}
+	if app.buffers[app.bufIdx].readOnly {
+		return fmt.Errorf("buffer is read-only")
+	}
+	if app.buffers[app.bufIdx].tailView {
+		return fmt.Errorf("buffer holds only the tail of %s", filepath.Base(app.currentPath))
+	}
 	path := app.currentPath
 	if path == "" {
 		promptSaveAs(app)
@@ -292,11 +626,21 @@ func saveCurrent(app *appState) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	if err := os.WriteFile(path, []byte(app.ed.String()), 0644); err != nil {
+	text := app.ed.String()
+	if app.buffers[app.bufIdx].crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	data := []byte(text)
+	if app.buffers[app.bufIdx].bom {
+		data = append([]byte(utf8BOM), data...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
 		return err
 	}
 	app.buffers[app.bufIdx].path = path
 	app.buffers[app.bufIdx].dirty = false
+	app.buffers[app.bufIdx].modTime = fileModTime(path)
+	removeSwap(path)
 	app.touchActiveBuffer()
 	return nil
 }
@@ -309,7 +653,7 @@ func promptSaveAs(app *appState) {
 	app.inputPrompt = "Save as: "
 	app.inputValue = ""
 	app.inputKind = "save"
-	app.lastEvent = "Save: enter filename in input line, Enter to confirm, Esc to cancel"
+	app.lastEvent = "Save: enter filename in input line, Tab completes, Enter to confirm, Esc to cancel"
 }
 
 func saveAll(app *appState) error {
@@ -321,7 +665,7 @@ func saveAll(app *appState) error {
 	for i := range app.buffers {
 		app.bufIdx = i
 		app.syncActiveBuffer()
-		if !app.buffers[i].dirty {
+		if !app.buffers[i].dirty || app.buffers[i].readOnly {
 			continue
 		}
 		if err := saveCurrent(app); err != nil {
@@ -340,18 +684,55 @@ func saveAll(app *appState) error {
 }
 
 var runFmtFix = goFmtAndFix
-var startGoRun = startGoRunProcess
-var completeGoCompletions = func(app *appState, path string, content string, line int, col int) ([]completionItem, error) {
-	if app == nil || app.gopls == nil {
+var startRun = startRunProcess
+
+// The gopls fetchers take the client to ask rather than the app: debounced
+// requests capture app.gopls on the UI goroutine, which may replace it.
+var completeGoCompletions = func(client *goplsClient, path string, content string, line int, col int) ([]completionItem, error) {
+	if client == nil {
+		return nil, fmt.Errorf("gopls unavailable")
+	}
+	return client.complete(path, content, line, col)
+}
+
+var goSignatureHelp = func(client *goplsClient, path string, content string, line int, col int) (signatureHelp, bool, error) {
+	if client == nil {
+		return signatureHelp{}, false, fmt.Errorf("gopls unavailable")
+	}
+	return client.signatureHelp(path, content, line, col)
+}
+
+var goDocumentSymbols = func(client *goplsClient, path string, content string) ([]outlineItem, error) {
+	if client == nil {
+		return nil, fmt.Errorf("gopls unavailable")
+	}
+	return client.documentSymbols(path, content)
+}
+
+var goCodeActions = func(client *goplsClient, path string, content string, line int, msg string) ([]codeAction, error) {
+	if client == nil {
+		return nil, fmt.Errorf("gopls unavailable")
+	}
+	return client.codeActions(path, content, line, msg)
+}
+
+var goInlayHints = func(client *goplsClient, path string, content string) ([]inlayHint, error) {
+	if client == nil {
 		return nil, fmt.Errorf("gopls unavailable")
 	}
-	return app.gopls.complete(path, content, line, col)
+	return client.inlayHints(path, content)
 }
 
 func formatFixReloadCurrent(app *appState) error {
 	if app == nil || app.ed == nil || len(app.buffers) == 0 {
 		return fmt.Errorf("no active buffer")
 	}
+	var importsErr error
+	if app.organizeImports && !app.buffers[app.bufIdx].tailView && bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) == syntaxGo {
+		if err := organizeCurrentImports(app); err != nil {
+			importsErr = fmt.Errorf("imports: %v", err)
+		}
+	}
 	if err := saveCurrent(app); err != nil {
 		return err
 	}
@@ -359,7 +740,16 @@ func formatFixReloadCurrent(app *appState) error {
 		return fmt.Errorf("no path")
 	}
 	opErr := runFmtFix(app.currentPath)
-	reloadErr := reloadCurrentFromDisk(app)
+	if importsErr != nil && opErr != nil {
+		opErr = fmt.Errorf("%v; %v", importsErr, opErr)
+	} else if importsErr != nil {
+		opErr = importsErr
+	}
+	reload := reloadCurrentFromDisk
+	if app.fmtDiff && !app.buffers[app.bufIdx].tailView {
+		reload = applyCurrentFromDisk
+	}
+	reloadErr := reload(app)
 	if opErr != nil && reloadErr != nil {
 		return fmt.Errorf("%v; reload: %v", opErr, reloadErr)
 	}
@@ -384,14 +774,19 @@ func runCurrentPackage(app *appState) error {
 		}
 		dir = cwd
 	}
-	title := fmt.Sprintf("[run] %s", filepath.Base(dir))
+	rc, err := loadRunCommand(dir, app.openRoot)
+	if err != nil {
+		return err
+	}
+	title := runBufferPrefix + filepath.Base(dir)
 	app.addBuffer()
 	runIdx := app.bufIdx
 	app.buffers[app.bufIdx].path = title
 	app.buffers[app.bufIdx].dirty = false
+	app.buffers[app.bufIdx].readOnly = true
 	app.currentPath = title
 	runEd := app.ed
-	runEd.SetRunes([]rune(fmt.Sprintf("$ (cd %s && go run .)\n\n", dir)))
+	runEd.SetRunes([]rune(fmt.Sprintf("$ (cd %s && %s)\n\n", rc.dir, rc)))
 	runEd.Caret = runEd.RuneLen()
 	runEd.Sel = editor.Sel{}
 	app.touchBufferText(runIdx)
@@ -401,21 +796,25 @@ func runCurrentPackage(app *appState) error {
 		app.touchBufferText(runIdx)
 	}
 	onDone := func(err error) {
-		if err != nil {
-			appendOut(fmt.Sprintf("\n[exit] %v\n", err))
-			return
-		}
-		appendOut("\n[exit] ok\n")
+		appendOut("\n" + runExitFooter(err) + "\n")
+	}
+	if err := startRun(rc, appendOut, onDone); err != nil {
+		return err
 	}
-	return startGoRun(dir, appendOut, onDone)
+	app.lastEvent = "Running: " + rc.String()
+	return nil
 }
 
-func startGoRunProcess(dir string, onOut func(string), onDone func(error)) error {
-	if strings.TrimSpace(dir) == "" {
+// startRunProcess starts rc and streams its stdout and stderr lines to onOut
+// until it exits, then reports the result to onDone.
+func startRunProcess(rc runCommand, onOut func(string), onDone func(error)) error {
+	if strings.TrimSpace(rc.dir) == "" {
 		return fmt.Errorf("no run directory")
 	}
-	cmd := exec.Command("go", "run", ".")
-	cmd.Dir = dir
+	if len(rc.argv) == 0 {
+		return fmt.Errorf("no run command")
+	}
+	cmd := rc.execCmd()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
@@ -497,52 +896,387 @@ func reloadCurrentFromDisk(app *appState) error {
 	if strings.TrimSpace(path) == "" {
 		return fmt.Errorf("no path")
 	}
-	buf, err := readFileRunes(path)
+	var buf []rune
+	var err error
+	if app.buffers[app.bufIdx].tailView {
+		buf, err = readFileTail(path, tailViewBytes)
+	} else {
+		buf, err = readFileRunes(path)
+	}
 	if err != nil {
 		return err
 	}
+	buf, app.buffers[app.bufIdx].bom = stripBOM(buf)
+	buf, app.buffers[app.bufIdx].crlf = stripCRLF(buf)
 	app.ed.SetRunes(buf)
 	app.ed.Caret = clamp(app.ed.Caret, 0, app.ed.RuneLen())
 	app.ed.Sel = editor.Sel{}
 	app.ed.Leap = editor.LeapState{LastFoundPos: -1}
 	app.buffers[app.bufIdx].dirty = false
 	app.buffers[app.bufIdx].path = path
+	app.buffers[app.bufIdx].modTime = fileModTime(path)
+	app.buffers[app.bufIdx].indentWidth = detectIndent(buf)
+	removeSwap(path)
 	app.touchActiveBufferText()
 	return nil
 }
 
+// applyCurrentFromDisk brings the active buffer in line with its file by
+// editing only what differs, as one undo step, so the caret and selection
+// stay on their text. formatFixReloadCurrent uses it when fmtdiff is on.
+func applyCurrentFromDisk(app *appState) error {
+	if app == nil || app.ed == nil {
+		return fmt.Errorf("no active buffer")
+	}
+	path := app.currentPath
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("no path")
+	}
+	buf, err := readFileRunes(path)
+	if err != nil {
+		return err
+	}
+	slot := &app.buffers[app.bufIdx]
+	buf, slot.bom = stripBOM(buf)
+	buf, slot.crlf = stripCRLF(buf)
+	app.ed.ApplyText(buf)
+	app.ed.Leap = editor.LeapState{LastFoundPos: -1}
+	slot.dirty = false
+	slot.modTime = fileModTime(path)
+	slot.indentWidth = detectIndent(buf)
+	removeSwap(path)
+	app.touchActiveBufferText()
+	return nil
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// checkExternalChange prompts to reload the active buffer when its file has a
+// newer mtime than the one recorded at load/save. It reports whether a prompt
+// was opened.
+func checkExternalChange(app *appState) bool {
+	if app == nil || app.inputActive || len(app.buffers) == 0 {
+		return false
+	}
+	slot := &app.buffers[app.bufIdx]
+	if slot.path == "" || slot.modTime.IsZero() {
+		return false
+	}
+	mt := fileModTime(slot.path)
+	if mt.IsZero() || !mt.After(slot.modTime) {
+		return false
+	}
+	app.inputActive = true
+	app.inputValue = ""
+	app.inputKind = "reload"
+	if slot.dirty {
+		app.inputPrompt = "File changed on disk; reload and discard edits? (y/N): "
+	} else {
+		app.inputPrompt = "File changed on disk; reload? (y/N): "
+	}
+	app.lastEvent = fmt.Sprintf("%s changed on disk", filepath.Base(slot.path))
+	return true
+}
+
+// keepBufferOverDisk dismisses a reload prompt, remembering the disk mtime so
+// the same change is not reported again.
+func keepBufferOverDisk(app *appState) {
+	if app == nil || len(app.buffers) == 0 {
+		return
+	}
+	slot := &app.buffers[app.bufIdx]
+	slot.modTime = fileModTime(slot.path)
+	app.lastEvent = "Kept buffer contents"
+}
+
 func openPath(app *appState, path string) error {
+	return loadPath(app, path, false)
+}
+
+// loadPath reads path into the active buffer. A path outside the open root
+// is refused unless allowOutside is set (the user confirmed it).
+func loadPath(app *appState, path string, allowOutside bool) error {
 	if app == nil || app.ed == nil || len(app.buffers) == 0 {
 		return fmt.Errorf("no active buffer")
 	}
-	buf, err := readFileRunes(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	mode := largeFileMode(info.Size())
+	var buf []rune
+	if mode == loadTail {
+		buf, err = readFileTail(path, tailViewBytes)
+	} else {
+		buf, err = readFileRunes(path)
+	}
 	if err != nil {
 		return err
 	}
-	if app.openRoot != "" {
-		if rel, err := filepath.Rel(app.openRoot, path); err != nil || strings.HasPrefix(rel, "..") {
-			return fmt.Errorf("refusing to open outside %s", app.openRoot)
+	if !allowOutside {
+		if err := outsideRoot(app.openRoot, path); err != nil {
+			return err
 		}
 	}
 	app.currentPath = path
 	app.buffers[app.bufIdx].path = path
 	app.buffers[app.bufIdx].dirty = false
+	app.buffers[app.bufIdx].modTime = fileModTime(path)
+	buf, app.buffers[app.bufIdx].bom = stripBOM(buf)
+	buf, app.buffers[app.bufIdx].crlf = stripCRLF(buf)
+	app.buffers[app.bufIdx].indentWidth = detectIndent(buf)
+	app.buffers[app.bufIdx].readOnly = mode != loadFull
+	app.buffers[app.bufIdx].tailView = mode == loadTail
 	app.ed.SetRunes(buf)
 	app.ed.Caret = 0
 	app.ed.Sel = editor.Sel{}
 	app.ed.Leap = editor.LeapState{LastFoundPos: -1}
 	app.touchActiveBufferText()
+	if mode != loadFull {
+		return nil
+	}
+	if _, ok := recoverSwap(path); ok && (!app.inputActive || app.inputKind == "recover") {
+		app.swapPrompts = append(app.swapPrompts, app.bufIdx)
+		if !app.inputActive {
+			promptSwapRecovery(app)
+		}
+	}
 	return nil
 }
 
+// fileLoadMode is how openPath loads a file of a given size.
+type fileLoadMode int
+
+const (
+	loadFull     fileLoadMode = iota
+	loadReadOnly              // whole file, read-only
+	loadTail                  // last tailViewBytes only, read-only
+)
+
+// Size thresholds for the large-file guard. Editing keeps the whole file in
+// a rune buffer, so big files are opened read-only and huge ones as a tail.
+const (
+	largeFileBytes = 32 << 20
+	hugeFileBytes  = 256 << 20
+	tailViewBytes  = 1 << 20
+)
+
+func largeFileMode(size int64) fileLoadMode {
+	switch {
+	case size > hugeFileBytes:
+		return loadTail
+	case size > largeFileBytes:
+		return loadReadOnly
+	}
+	return loadFull
+}
+
+// readFileTail reads roughly the last n bytes of path, starting at the first
+// complete line.
+func readFileTail(path string, n int64) ([]rune, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off := max(info.Size()-n, 0)
+	data := make([]byte, info.Size()-off)
+	if _, err := f.ReadAt(data, off); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if off > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	if err := checkTextContent(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return bytesToRunes(data), nil
+}
+
+// openedStatus describes the active buffer after a successful open, warning
+// when the large-file guard limited it.
+func openedStatus(app *appState) string {
+	msg := fmt.Sprintf("Opened %s", app.currentPath)
+	if len(app.buffers) == 0 {
+		return msg
+	}
+	slot := app.buffers[app.bufIdx]
+	switch {
+	case slot.tailView:
+		return msg + fmt.Sprintf(" (huge file: last %d KiB only, read-only)", tailViewBytes>>10)
+	case slot.readOnly && fileSize(slot.path) > largeFileBytes:
+		return msg + " (large file: read-only)"
+	}
+	return msg
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// swapEveryEdits is how many edits a dirty buffer may accumulate before its
+// swap file is rewritten.
+const swapEveryEdits = 40
+
+// swapPath returns the hidden sibling swap file for path.
+func swapPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".gocat-swap")
+}
+
+// writeSwap saves a dirty, file-backed buffer to its swap file.
+func writeSwap(slot *bufferSlot) error {
+	if slot == nil || slot.ed == nil || slot.path == "" || !slot.dirty || slot.readOnly || slot.picker {
+		return nil
+	}
+	slot.swapEdits = 0
+	return os.WriteFile(swapPath(slot.path), []byte(slot.ed.String()), 0600)
+}
+
+// recoverSwap returns the swap contents for path when a swap file exists and is
+// newer than the file itself (or the file is missing).
+func recoverSwap(path string) ([]rune, bool) {
+	if path == "" {
+		return nil, false
+	}
+	info, err := os.Stat(swapPath(path))
+	if err != nil {
+		return nil, false
+	}
+	if mt := fileModTime(path); !mt.IsZero() && !info.ModTime().After(mt) {
+		return nil, false
+	}
+	buf, err := readFileRunes(swapPath(path))
+	if err != nil {
+		return nil, false
+	}
+	return buf, true
+}
+
+func removeSwap(path string) {
+	if path == "" {
+		return
+	}
+	_ = os.Remove(swapPath(path))
+}
+
+// promptSwapRecovery asks about the first buffer queued in swapPrompts.
+func promptSwapRecovery(app *appState) {
+	idx := app.swapPrompts[0]
+	app.inputActive = true
+	app.inputValue = ""
+	app.inputKind = "recover"
+	app.inputPrompt = "Swap file newer than " + filepath.Base(app.buffers[idx].path) + "; recover it? (y/N): "
+}
+
+// applySwapRecovery answers the startup recovery prompt for the buffer it was
+// raised for: "y" loads the swap contents as unsaved edits, anything else
+// discards the swap file. The next queued buffer is asked about after.
+func applySwapRecovery(app *appState, accept bool) {
+	if app == nil || len(app.swapPrompts) == 0 {
+		return
+	}
+	idx := app.swapPrompts[0]
+	app.swapPrompts = app.swapPrompts[1:]
+	defer func() {
+		if len(app.swapPrompts) > 0 {
+			promptSwapRecovery(app)
+		}
+	}()
+	if idx < 0 || idx >= len(app.buffers) {
+		return
+	}
+	slot := &app.buffers[idx]
+	buf, ok := recoverSwap(slot.path)
+	if !accept || !ok {
+		removeSwap(slot.path)
+		app.lastEvent = "Discarded swap file"
+		return
+	}
+	slot.ed.SetRunes(buf)
+	slot.ed.Caret = clamp(slot.ed.Caret, 0, slot.ed.RuneLen())
+	slot.ed.Sel = editor.Sel{}
+	app.touchBufferText(idx)
+	slot.dirty = true
+	app.lastEvent = fmt.Sprintf("Recovered %s from swap (unsaved)", filepath.Base(slot.path))
+}
+
 func readFileRunes(path string) ([]rune, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTextContent(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
 	return bytesToRunes(data), nil
 }
 
+var errNotText = errors.New("not a text file")
+
+// checkTextContent rejects data that would not round-trip through a rune
+// buffer: NUL bytes (binary files) or invalid UTF-8 (e.g. Latin-1).
+func checkTextContent(data []byte) error {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return fmt.Errorf("%w: NUL byte at offset %d", errNotText, i)
+	}
+	if !utf8.Valid(data) {
+		return fmt.Errorf("%w: invalid UTF-8", errNotText)
+	}
+	return nil
+}
+
+const utf8BOM = "\uFEFF"
+
+// stripBOM removes a leading UTF-8 byte order mark and reports whether one was
+// present.
+func stripBOM(buf []rune) ([]rune, bool) {
+	if len(buf) > 0 && buf[0] == '\uFEFF' {
+		return buf[1:], true
+	}
+	return buf, false
+}
+
+// stripCRLF turns CRLF line endings into LF when every line ends in CRLF and
+// reports whether it did. Files with LF or mixed endings are left alone.
+func stripCRLF(buf []rune) ([]rune, bool) {
+	n := 0
+	for i, r := range buf {
+		if r == '\n' {
+			if i == 0 || buf[i-1] != '\r' {
+				return buf, false
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return buf, false
+	}
+	out := make([]rune, 0, len(buf)-n)
+	for i, r := range buf {
+		if r == '\r' && i+1 < len(buf) && buf[i+1] == '\n' {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, true
+}
+
 func bytesToRunes(data []byte) []rune {
 	if len(data) == 0 {
 		return nil
@@ -567,7 +1301,7 @@ func loadFileAtCaret(app *appState) error {
 		return fmt.Errorf("no active buffer")
 	}
 	slot := &app.buffers[app.bufIdx]
-	lines := editor.SplitLines(app.ed.Runes())
+	lines := app.ed.Lines()
 	lineIdx := editor.CaretLineAt(lines, app.ed.Caret)
 	if lineIdx < 0 || lineIdx >= len(lines) {
 		return fmt.Errorf("no line under caret")
@@ -586,10 +1320,13 @@ func loadFileAtCaret(app *appState) error {
 	if slot.picker && slot.pickerRoot != "" {
 		root = slot.pickerRoot
 	}
+	if slot.picker {
+		line = pickerEntryName(line)
+	}
 
 	if slot.picker && line == ".." {
 		up := filepath.Dir(root)
-		list, err := pickerLines(up, 500)
+		list, err := pickerListing(app, up)
 		if err != nil {
 			return err
 		}
@@ -604,7 +1341,7 @@ func loadFileAtCaret(app *appState) error {
 
 	if slot.picker && strings.HasSuffix(line, "/") {
 		next := filepath.Join(root, strings.TrimSuffix(line, "/"))
-		list, err := pickerLines(next, 500)
+		list, err := pickerListing(app, next)
 		if err != nil {
 			return err
 		}
@@ -616,71 +1353,180 @@ func loadFileAtCaret(app *appState) error {
 		app.ed = slot.ed
 		return nil
 	}
+	return openListedPath(app, root, line)
+}
 
+// openListedPath opens a path listed relative to root (a picker or sidebar
+// entry, or "path:line:" output), switching to its buffer when it is already
+// open. Paths outside root are refused.
+func openListedPath(app *appState, root, line string) error {
+	from := currentJump(app)
+	// "path:line:" (compiler and diagnostics output) also moves to the line.
+	target := 0
+	if p, ln, ok := splitPathLine(line); ok {
+		line, target = p, ln
+	}
 	full := line
 	if !filepath.IsAbs(full) {
 		full = filepath.Join(root, line)
 	}
 	full = filepath.Clean(full)
-	if root != "" {
-		if rel, err := filepath.Rel(root, full); err != nil || strings.HasPrefix(rel, "..") {
-			return fmt.Errorf("refusing to open outside %s", root)
-		}
-	}
 
 	for i, b := range app.buffers {
-		if filepath.Clean(b.path) == filepath.Clean(full) {
+		if filepath.Clean(b.path) == full {
 			app.bufIdx = i
 			app.syncActiveBuffer()
+			pushJump(app, from)
+			gotoLine(app, target)
 			return nil
 		}
 	}
 
+	if err := outsideRoot(root, full); err != nil {
+		return err
+	}
+
 	app.addBuffer()
 	app.openRoot = filepath.Dir(full)
-	return openPath(app, full)
+	if err := openPath(app, full); err != nil {
+		return err
+	}
+	pushJump(app, from)
+	gotoLine(app, target)
+	return nil
 }
 
-func findMatches(root, query string, limit int) []string {
-	if query == "" {
+// splitPathLine parses "path:line:" or "path:line:col: msg" into the path and
+// 1-based line.
+func splitPathLine(s string) (string, int, bool) {
+	path, rest, ok := strings.Cut(s, ":")
+	if !ok || path == "" {
+		return "", 0, false
+	}
+	num, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return path, n, true
+}
+
+// gotoLine puts the caret at the start of 1-based line n (0 leaves it).
+func gotoLine(app *appState, n int) {
+	if app == nil || app.ed == nil || n < 1 {
+		return
+	}
+	app.ed.Caret = 0
+	app.ed.MoveCaretLine(app.ed.Lines(), n-1, false)
+}
+
+// testCompanionPath maps foo.go to foo_test.go and back. It reports false
+// for paths that are not Go source files.
+func testCompanionPath(path string) (string, bool) {
+	if path == "" || !strings.HasSuffix(path, ".go") {
+		return "", false
+	}
+	base := strings.TrimSuffix(path, ".go")
+	if filepath.Base(base) == "_test" {
+		return "", false
+	}
+	if src, ok := strings.CutSuffix(base, "_test"); ok {
+		return src + ".go", true
+	}
+	return base + "_test.go", true
+}
+
+// openTestCompanion switches to the active Go file's test companion (or back
+// from a test to its source), opening it in a new buffer if needed. A missing
+// companion gets an empty buffer that is created on first save.
+func openTestCompanion(app *appState) error {
+	if app == nil || len(app.buffers) == 0 {
+		return fmt.Errorf("no active buffer")
+	}
+	cur := app.buffers[app.bufIdx].path
+	companion, ok := testCompanionPath(cur)
+	if !ok {
+		if cur == "" {
+			return fmt.Errorf("buffer has no file")
+		}
+		return fmt.Errorf("no Go companion for %s", filepath.Base(cur))
+	}
+	companion = filepath.Clean(companion)
+	loaded := slices.ContainsFunc(app.buffers, func(b bufferSlot) bool {
+		return b.path != "" && filepath.Clean(b.path) == companion
+	})
+	if _, err := os.Stat(companion); !loaded && errors.Is(err, os.ErrNotExist) {
+		if err := outsideRoot(app.openRoot, companion); err != nil {
+			return err
+		}
+		recordJump(app)
+		app.addBuffer()
+		app.currentPath = companion
+		app.buffers[app.bufIdx].path = companion
+		app.touchActiveBufferText()
+		app.lastEvent = fmt.Sprintf("Buffer for %s (file will be created on save)", filepath.Base(companion))
 		return nil
 	}
+	from := currentJump(app)
+	if err := showEditor(app, nil, companion, false); err != nil {
+		return err
+	}
+	pushJump(app, from)
+	return nil
+}
+
+// findMatches walks root for files whose name contains query (ignoring
+// case), stopping after limit matches. more reports that at least one further
+// match exists.
+func findMatches(root, query string, limit int, filter walkFilter) (matches []string, more bool) {
+	if query == "" {
+		return nil, false
+	}
 	lq := strings.ToLower(query)
-	matches := make([]string, 0, 8)
+	matches = make([]string, 0, 8)
 	errStop := fmt.Errorf("stop")
+	ignore := filter.ignorer(root)
 
 	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
-		if len(matches) >= limit {
-			return errStop
-		}
 		if d.IsDir() {
 			base := d.Name()
-			if strings.HasPrefix(base, ".") || base == "vendor" {
-				if path == root {
-					return nil
-				}
+			if path == root {
+				return nil
+			}
+			if filter.skipName(base) || ignore.ignored(path, true) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
+		if ignore.ignored(path, false) {
+			return nil
+		}
 		if strings.Contains(strings.ToLower(d.Name()), lq) {
+			if len(matches) >= limit {
+				more = true
+				return errStop
+			}
 			matches = append(matches, path)
 		}
 		return nil
 	})
-	return matches
+	return matches, more
 }
 
-func listFiles(root string, limit int) ([]string, error) {
+func listFiles(root string, limit int, filter walkFilter) ([]string, error) {
 	if root == "" {
 		return nil, fmt.Errorf("no root")
 	}
 	root = filepath.Clean(root)
 	files := make([]string, 0, 16)
 	errStop := fmt.Errorf("stop")
+	ignore := filter.ignorer(root)
 
 	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -691,14 +1537,17 @@ func listFiles(root string, limit int) ([]string, error) {
 		}
 		if d.IsDir() {
 			base := d.Name()
-			if strings.HasPrefix(base, ".") || base == "vendor" {
-				if path == root {
-					return nil
-				}
+			if path == root {
+				return nil
+			}
+			if filter.skipName(base) || ignore.ignored(path, true) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
+		if ignore.ignored(path, false) {
+			return nil
+		}
 		rel, err := filepath.Rel(root, path)
 		if err != nil {
 			return nil
@@ -713,7 +1562,7 @@ func listFiles(root string, limit int) ([]string, error) {
 	return files, nil
 }
 
-func pickerLines(root string, limit int) ([]string, error) {
+func pickerLines(root string, limit int, filter walkFilter) ([]string, error) {
 	if root == "" {
 		return nil, fmt.Errorf("no root")
 	}
@@ -725,12 +1574,13 @@ func pickerLines(root string, limit int) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	ignore := filter.ignorer(root)
 	for _, de := range dirEntries {
 		if len(entries) >= limit {
 			break
 		}
 		name := de.Name()
-		if strings.HasPrefix(name, ".") || name == "vendor" {
+		if filter.skipName(name) || ignore.ignored(filepath.Join(root, name), de.IsDir()) {
 			continue
 		}
 		if de.IsDir() {
@@ -763,14 +1613,14 @@ func loadStartupFiles(app *appState, args []string) {
 			app.ed.SetRunes(nil)
 			app.buffers[app.bufIdx].dirty = false
 			app.touchActiveBufferText()
-			app.lastEvent = fmt.Sprintf("Buffer for %s (file will be created on save)", abs)
+			app.lastEvent = fmt.Sprintf("Buffer for %s (file will be created on save)", displayPath(app, abs))
 			continue
 		}
 		if err := openPath(app, abs); err != nil {
 			app.lastEvent = fmt.Sprintf("OPEN ERR: %v", err)
 			continue
 		}
-		app.lastEvent = fmt.Sprintf("Opened %s", app.currentPath)
+		app.lastEvent = openedStatus(app)
 	}
 }
 
@@ -800,12 +1650,19 @@ func bufferLabel(app *appState) string {
 		return "buf 0/0"
 	}
 	name := app.currentPath
-	if name == "" {
+	switch {
+	case name == "":
 		name = "<untitled>"
-	} else {
+	case app.pathMode == pathsFull:
 		name = filepath.Base(name)
+	default:
+		name = displayPath(app, name)
 	}
-	return fmt.Sprintf("buf %d/%d [%s]", app.bufIdx+1, total, name)
+	label := fmt.Sprintf("buf %d/%d [%s]", app.bufIdx+1, total, name)
+	if app.buffers[app.bufIdx].readOnly {
+		label += " [RO]"
+	}
+	return label
 }
 
 func helpText() string {
@@ -873,7 +1730,10 @@ func toggleComment(ed *editor.Editor) {
 		return oldPos + cum[ln] + deltas[ln]
 	}
 
-	ed.SetRunes([]rune(strings.Join(lines, "\n")))
+	caret := ed.Caret
+	blockStart := editor.LineStartOffset(oldLines, startLine)
+	blockEnd := editor.LineEndOffset(oldLines, endLine)
+	ed.ReplaceRange(blockStart, blockEnd, strings.Join(lines[startLine:endLine+1], "\n"))
 	if origSel.Active {
 		ed.Sel.Active = true
 		ed.Sel.A = adjustPos(selA)
@@ -881,8 +1741,45 @@ func toggleComment(ed *editor.Editor) {
 	} else {
 		ed.Sel.Active = false
 	}
-	ed.Caret = adjustPos(ed.Caret)
-	ed.Caret = clamp(ed.Caret, 0, ed.RuneLen())
+	ed.Caret = clamp(adjustPos(caret), 0, ed.RuneLen())
+}
+
+// defaultPageLines is the page size used before the first frame is drawn.
+const defaultPageLines = 20
+
+// pageLines is how far a page move goes: one screenful of text rows.
+func pageLines(app *appState) int {
+	if app.visibleLines > 0 {
+		return app.visibleLines
+	}
+	return defaultPageLines
+}
+
+// scrollPin holds the view where scrollView left it for as long as the caret
+// and text of ed are unchanged.
+type scrollPin struct {
+	ed    *editor.Editor
+	caret int
+	rev   uint64
+}
+
+// scrollView moves the view by delta lines without moving the caret.
+func scrollView(app *appState, delta int) {
+	total := len(app.ed.Lines())
+	app.scrollLine = clamp(app.scrollLine+delta, 0, max(0, total-pageLines(app)))
+	app.scrollPin = scrollPin{ed: app.ed, caret: app.ed.Caret, rev: app.ed.Revision()}
+}
+
+// followCaret keeps the caret in view, except while the view is pinned by
+// scrollView; moving the caret or editing releases the pin.
+func followCaret(app *appState, caretLine, totalLines, visibleLines int) {
+	pin := app.scrollPin
+	if pin.ed != nil && pin.ed == app.ed && pin.caret == app.ed.Caret && pin.rev == app.ed.Revision() {
+		app.scrollLine = clamp(app.scrollLine, 0, max(0, totalLines-visibleLines))
+		return
+	}
+	app.scrollPin = scrollPin{}
+	ensureCaretVisible(app, caretLine, totalLines, visibleLines)
 }
 
 func ensureCaretVisible(app *appState, caretLine, totalLines, visibleLines int) {
@@ -1033,6 +1930,9 @@ func tryManualCompletion(app *appState) bool {
 	if app.completionPopup.active {
 		return completionPopupApplySelection(app)
 	}
+	if trySnippetExpansion(app, buf) {
+		return true
+	}
 	if tryImportedPackageNameExpansion(app, buf) {
 		return true
 	}
@@ -1057,10 +1957,9 @@ func tryManualCompletion(app *appState) bool {
 	}
 	items := []completionItem(nil)
 	if !app.noGopls {
-		got, err := completeGoCompletions(app, app.currentPath, string(buf), line, col)
+		got, err := completeGoCompletions(app.gopls, app.currentPath, string(buf), line, col)
 		if err != nil {
-			app.noGopls = true
-			app.lastEvent = "Autocomplete disabled (gopls unavailable)"
+			goplsFailed(app, "Autocomplete")
 		} else {
 			items = got
 		}
@@ -1126,15 +2025,17 @@ func trySelectorCompletionPopup(app *appState, buf []rune, prefix string, start
 	if line < 0 || col < 0 {
 		return false
 	}
-	items := []completionItem(nil)
-	if !app.noGopls {
-		got, err := completeGoCompletions(app, app.currentPath, string(buf), line, col)
-		if err != nil {
-			app.noGopls = true
-			app.lastEvent = "Autocomplete disabled (gopls unavailable)"
-			return false
-		}
-		items = got
+	if app.noGopls {
+		return false
+	}
+	if app.requestInterrupt != nil {
+		requestCompletionAsync(app, string(buf), line, col, prefix, start, end)
+		return true
+	}
+	items, err := completeGoCompletions(app.gopls, app.currentPath, string(buf), line, col)
+	if err != nil {
+		goplsFailed(app, "Autocomplete")
+		return false
 	}
 	if len(items) == 0 {
 		return false
@@ -1143,6 +2044,157 @@ func trySelectorCompletionPopup(app *appState, buf []rune, prefix string, start
 	return true
 }
 
+// requestCompletionAsync asks gopls for selector completions off the UI
+// thread. The request waits completionDebounce first and is skipped if a newer
+// one has been issued; the result comes back as a completionResultInterrupt.
+func requestCompletionAsync(app *appState, content string, line, col int, prefix string, start, end int) {
+	token := app.completionSeq.Add(1)
+	app.completionReq = completionRequest{
+		token:   token,
+		bufIdx:  app.bufIdx,
+		textRev: app.buffers[app.bufIdx].textRev,
+		caret:   app.ed.Caret,
+		prefix:  prefix,
+		start:   start,
+		end:     end,
+		client:  app.gopls,
+	}
+	path, client := app.currentPath, app.gopls
+	post := app.requestInterrupt
+	complete := completeGoCompletions
+	time.AfterFunc(completionDebounce, func() {
+		if app.completionSeq.Load() != token {
+			return
+		}
+		items, err := complete(client, path, content, line, col)
+		post(completionResultInterrupt{Token: token, Items: items, Err: err})
+	})
+}
+
+// autoCompleteSelector starts a debounced selector completion right after a
+// `.` is typed in a Go buffer when the autocomplete option is on. Typing
+// anything else before the debounce elapses supersedes the request.
+func autoCompleteSelector(app *appState) bool {
+	if app == nil || !app.autoComplete || app.noGopls || app.requestInterrupt == nil || app.ed == nil {
+		return false
+	}
+	buf := app.ed.Runes()
+	if bufferSyntaxKind(app, app.currentPath, buf) != syntaxGo {
+		return false
+	}
+	prefix, start, end, ok := selectorCompletionPrefix(buf, app.ed.Caret)
+	if !ok {
+		return false
+	}
+	lines := app.ed.Lines()
+	line := editor.CaretLineAt(lines, app.ed.Caret)
+	col := editor.CaretColAt(lines, app.ed.Caret)
+	requestCompletionAsync(app, string(buf), line, col, prefix, start, end)
+	return true
+}
+
+// applyCompletionResult opens the popup for an async completion response,
+// dropping it if a newer request superseded it or the buffer moved on.
+func applyCompletionResult(app *appState, res completionResultInterrupt) {
+	req := app.completionReq
+	if res.Token != app.completionSeq.Load() || res.Token != req.token {
+		return
+	}
+	if req.bufIdx != app.bufIdx || req.bufIdx >= len(app.buffers) ||
+		app.buffers[req.bufIdx].textRev != req.textRev || app.ed.Caret != req.caret {
+		return
+	}
+	if res.Err != nil {
+		goplsFailedOn(app, req.client, "Autocomplete")
+		return
+	}
+	if len(res.Items) == 0 {
+		app.lastEvent = "No completions for " + req.prefix
+		return
+	}
+	openCompletionPopup(app, "Completions for "+req.prefix, res.Items, req.start, req.end)
+}
+
+// requestSignatureHelp asks gopls for the signature of the call around the
+// caret, after `(` or `,` is typed in a Go buffer. With an interrupt channel
+// the request is debounced and answered as a signatureHelpInterrupt.
+func requestSignatureHelp(app *appState) bool {
+	if app == nil || app.noGopls || app.ed == nil || len(app.buffers) == 0 {
+		return false
+	}
+	buf := app.ed.Runes()
+	if bufferSyntaxKind(app, app.currentPath, buf) != syntaxGo {
+		return false
+	}
+	lines := app.ed.Lines()
+	line := editor.CaretLineAt(lines, app.ed.Caret)
+	col := editor.CaretColAt(lines, app.ed.Caret)
+	if line < 0 || col < 0 {
+		return false
+	}
+	token := app.sigHelpSeq.Add(1)
+	app.sigHelpReq = completionRequest{
+		token:   token,
+		bufIdx:  app.bufIdx,
+		textRev: app.buffers[app.bufIdx].textRev,
+		caret:   app.ed.Caret,
+		client:  app.gopls,
+	}
+	path, client := app.currentPath, app.gopls
+	content := string(buf)
+	help := goSignatureHelp
+	if app.requestInterrupt == nil {
+		h, ok, err := help(client, path, content, line, col)
+		applySignatureHelpResult(app, signatureHelpInterrupt{Token: token, Help: h, OK: ok, Err: err})
+		return true
+	}
+	post := app.requestInterrupt
+	time.AfterFunc(completionDebounce, func() {
+		if app.sigHelpSeq.Load() != token {
+			return
+		}
+		h, ok, err := help(client, path, content, line, col)
+		post(signatureHelpInterrupt{Token: token, Help: h, OK: ok, Err: err})
+	})
+	return true
+}
+
+// applySignatureHelpResult shows or hides the signature popup for a gopls
+// response, dropping it if the buffer moved on since the request.
+func applySignatureHelpResult(app *appState, res signatureHelpInterrupt) {
+	req := app.sigHelpReq
+	if res.Token != app.sigHelpSeq.Load() || res.Token != req.token {
+		return
+	}
+	if req.bufIdx != app.bufIdx || req.bufIdx >= len(app.buffers) ||
+		app.buffers[req.bufIdx].textRev != req.textRev || app.ed.Caret != req.caret {
+		return
+	}
+	if res.Err != nil {
+		goplsFailedOn(app, req.client, "Signature help")
+		closeSignatureHelp(app)
+		return
+	}
+	if !res.OK || strings.TrimSpace(res.Help.Label) == "" {
+		closeSignatureHelp(app)
+		return
+	}
+	app.sigHelp = res.Help
+	app.sigHelpActive = true
+	if start, end, ok := res.Help.activeRange(); ok {
+		app.lastEvent = "Signature: " + string([]rune(res.Help.Label)[start:end])
+	}
+}
+
+func closeSignatureHelp(app *appState) {
+	if app == nil {
+		return
+	}
+	app.sigHelpSeq.Add(1)
+	app.sigHelp = signatureHelp{}
+	app.sigHelpActive = false
+}
+
 func openCompletionPopup(app *appState, title string, items []completionItem, replaceStart, replaceEnd int) {
 	if app == nil {
 		return
@@ -1189,16 +2241,11 @@ func completionPopupApplySelection(app *appState) bool {
 	if insert == "" {
 		insert = item.Label
 	}
-	cur := app.ed.Runes()
-	start := clamp(app.completionPopup.replaceStart, 0, len(cur))
-	end := clamp(app.completionPopup.replaceEnd, start, len(cur))
-	ins := []rune(insert)
-	next := make([]rune, 0, len(cur)-(end-start)+len(ins))
-	next = append(next, cur[:start]...)
-	next = append(next, ins...)
-	next = append(next, cur[end:]...)
-	app.ed.SetRunes(next)
-	app.ed.Caret = start + len(ins)
+	n := app.ed.RuneLen()
+	start := clamp(app.completionPopup.replaceStart, 0, n)
+	end := clamp(app.completionPopup.replaceEnd, start, n)
+	app.ed.ReplaceRange(start, end, insert)
+	app.ed.Caret = start + utf8.RuneCountInString(insert)
 	closeCompletionPopup(app)
 	app.markDirty()
 	app.lastEvent = "Completed"
@@ -1283,14 +2330,8 @@ func isSimpleIdentRune(r rune) bool {
 }
 
 func applyCompletionText(app *appState, prefixStart int, insertText string) {
-	insert := []rune(insertText)
-	cur := app.ed.Runes()
-	next := make([]rune, 0, len(cur)-(app.ed.Caret-prefixStart)+len(insert))
-	next = append(next, cur[:prefixStart]...)
-	next = append(next, insert...)
-	next = append(next, cur[app.ed.Caret:]...)
-	app.ed.SetRunes(next)
-	app.ed.Caret = prefixStart + len(insert)
+	app.ed.ReplaceRange(prefixStart, app.ed.Caret, insertText)
+	app.ed.Caret = prefixStart + utf8.RuneCountInString(insertText)
 	app.markDirty()
 }
 
@@ -1376,6 +2417,17 @@ func visualColForRuneCol(line string, runeCol, width int) int {
 	return vis
 }
 
+// visualLen is the on-screen width of line with tabs expanded.
+func visualLen(line string) int {
+	return visualColForRuneCol(line, utf8.RuneCountInString(line), tabWidth)
+}
+
+// exceedsLineLimit reports whether line is wider than limit visual columns;
+// a limit of 0 disables the check.
+func exceedsLineLimit(line string, limit int) bool {
+	return limit > 0 && visualLen(line) > limit
+}
+
 func maxInt(a, b int) int {
 	if a > b {
 		return a
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gc/editor"
 )
@@ -47,6 +53,66 @@ func TestAddAndSwitchBuffers(t *testing.T) {
 	}
 }
 
+func TestSwitchBuffersRestoresScroll(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor(strings.Repeat("a\n", 100)))
+	app.scrollLine = 30
+	app.addBuffer()
+	if app.scrollLine != 0 {
+		t.Fatalf("new buffer should start at the top, scroll %d", app.scrollLine)
+	}
+	app.scrollLine = 5
+
+	app.switchBuffer(-1)
+	if app.scrollLine != 30 {
+		t.Fatalf("back to A: scroll %d, want 30", app.scrollLine)
+	}
+	app.switchBuffer(1)
+	if app.scrollLine != 5 {
+		t.Fatalf("back to B: scroll %d, want 5", app.scrollLine)
+	}
+}
+
+func TestReopenClosedBuffer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("alpha\nbeta\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := appState{openRoot: dir}
+	app.initBuffers(editor.NewEditor(""))
+	app.addBuffer()
+	if err := openPath(&app, path); err != nil {
+		t.Fatal(err)
+	}
+	app.ed.Caret = 8
+	app.addBuffer() // untitled buffers are not remembered
+	app.closeBuffer()
+	app.closeBuffer() // notes.txt is active again
+
+	if err := app.RunCommand(CmdReopen, ""); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if app.currentPath != path || app.ed.Caret != 8 || app.ed.String() != "alpha\nbeta\n" {
+		t.Fatalf("reopened path=%q caret=%d text=%q", app.currentPath, app.ed.Caret, app.ed.String())
+	}
+	if err := app.RunCommand(CmdReopen, ""); err == nil {
+		t.Fatalf("history should be empty after reopening the only closed file")
+	}
+
+	// A file gone from disk fails without leaving an empty buffer behind.
+	app.closeBuffer()
+	os.Remove(path)
+	app.ed.InsertText("x\ny") // a caret line no jump has recorded yet
+	first, jumps := app.ed, len(app.jumps)
+	if err := app.RunCommand(CmdReopen, ""); err == nil {
+		t.Fatalf("reopening a deleted file should fail")
+	}
+	if len(app.buffers) != 1 || app.ed != first || len(app.jumps) != jumps {
+		t.Fatalf("failed reopen left %d buffers, %d jumps (want %d)", len(app.buffers), len(app.jumps), jumps)
+	}
+}
+
 func TestCloseBufferCountsAndSwitches(t *testing.T) {
 	first := editor.NewEditor("first")
 	second := editor.NewEditor("second")
@@ -71,3 +137,669 @@ func TestCloseBufferCountsAndSwitches(t *testing.T) {
 		t.Fatalf("expected no active editor after closing all buffers")
 	}
 }
+
+func TestWriteSwapForDirtyBufferAndRecoverOnLoad(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "note.txt")
+	if err := os.WriteFile(path, []byte("saved"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := writeSwap(&app.buffers[0]); err != nil {
+		t.Fatalf("clean writeSwap: %v", err)
+	}
+	if _, err := os.Stat(swapPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("clean buffer should not get a swap file")
+	}
+
+	app.ed.SetRunes([]rune("unsaved work"))
+	app.markDirty()
+	if err := writeSwap(&app.buffers[0]); err != nil {
+		t.Fatalf("writeSwap: %v", err)
+	}
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(swapPath(path), later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if got, ok := recoverSwap(path); !ok || string(got) != "unsaved work" {
+		t.Fatalf("recoverSwap=%q ok=%v", string(got), ok)
+	}
+
+	// Simulate a restart: a fresh app loads the file and is offered the swap.
+	next := appState{openRoot: root}
+	next.initBuffers(editor.NewEditor(""))
+	if err := openPath(&next, path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if !next.inputActive || next.inputKind != "recover" {
+		t.Fatalf("load should offer swap recovery")
+	}
+	handleInputText(&next, "y")
+	handleInputKey(&next, keyEvent{down: true, key: keyReturn})
+	if got := next.ed.String(); got != "unsaved work" || !next.buffers[0].dirty {
+		t.Fatalf("recovered buffer=%q dirty=%v", got, next.buffers[0].dirty)
+	}
+
+	if err := saveCurrent(&next); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(swapPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("clean save should delete the swap file")
+	}
+}
+
+func TestStartupAsksAboutEverySwapFile(t *testing.T) {
+	root := t.TempDir()
+	later := time.Now().Add(2 * time.Second)
+	var paths []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte("saved "+name), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.WriteFile(swapPath(path), []byte("swap "+name), 0644); err != nil {
+			t.Fatalf("write swap: %v", err)
+		}
+		if err := os.Chtimes(swapPath(path), later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor(""))
+	loadStartupFiles(&app, paths)
+	answer := func(s string) {
+		t.Helper()
+		if !app.inputActive || app.inputKind != "recover" {
+			t.Fatalf("expected a recovery prompt, got %q", app.inputPrompt)
+		}
+		handleInputText(&app, s)
+		handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	}
+	if !strings.Contains(app.inputPrompt, "a.txt") {
+		t.Fatalf("first prompt should name a.txt: %q", app.inputPrompt)
+	}
+	answer("n")
+	if !strings.Contains(app.inputPrompt, "b.txt") {
+		t.Fatalf("second prompt should name b.txt: %q", app.inputPrompt)
+	}
+	answer("y")
+	if app.inputActive {
+		t.Fatalf("no prompt should remain: %q", app.inputPrompt)
+	}
+	if got := app.buffers[0].ed.String(); got != "saved a.txt" || app.buffers[0].dirty {
+		t.Fatalf("declined buffer=%q dirty=%v", got, app.buffers[0].dirty)
+	}
+	if _, err := os.Stat(swapPath(paths[0])); !os.IsNotExist(err) {
+		t.Fatalf("declined swap file should be removed")
+	}
+	if got := app.buffers[1].ed.String(); got != "swap b.txt" || !app.buffers[1].dirty {
+		t.Fatalf("recovered buffer=%q dirty=%v", got, app.buffers[1].dirty)
+	}
+}
+
+func TestRecoverSwapIgnoresStaleSwapAndMarkDirtyWritesPeriodically(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(swapPath(path), []byte("old"), 0600); err != nil {
+		t.Fatalf("write swap: %v", err)
+	}
+	earlier := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(swapPath(path), earlier, earlier); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, ok := recoverSwap(path); ok {
+		t.Fatalf("swap older than the file should be ignored")
+	}
+
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	app.buffers[0].path = path
+	app.currentPath = path
+	removeSwap(path)
+	for range swapEveryEdits {
+		app.ed.InsertText("x")
+		app.markDirty()
+	}
+	if data, err := os.ReadFile(swapPath(path)); err != nil || len(data) != swapEveryEdits {
+		t.Fatalf("swap should be written after %d edits: %q %v", swapEveryEdits, data, err)
+	}
+	app.closeBuffer()
+	if _, err := os.Stat(swapPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("closing the buffer should delete the swap file")
+	}
+}
+
+func TestDetectIndent(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want int
+	}{
+		{"tabs", "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n", 0},
+		{"two spaces", "a:\n  b:\n    c: 1\n  d: 2\n", 2},
+		{"four spaces", "def f():\n    if x:\n        y()\n    return\n", 4},
+		{"flat", "one\ntwo\n", 0},
+	}
+	for _, tc := range cases {
+		if got := detectIndent([]rune(tc.src)); got != tc.want {
+			t.Fatalf("%s: detectIndent = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTabInsertsDetectedSpaceIndent(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "conf.yaml")
+	if err := os.WriteFile(path, []byte("a:\n  b: 1\n  c: 2\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if app.buffers[0].indentWidth != 2 {
+		t.Fatalf("indentWidth = %d, want 2", app.buffers[0].indentWidth)
+	}
+	app.ed.Caret = 3 // start of "  b: 1"
+	handleKeyEvent(&app, keyEvent{down: true, key: keyTab})
+	if got := app.ed.String(); got != "a:\n    b: 1\n  c: 2\n" {
+		t.Fatalf("tab should insert two spaces, got %q", got)
+	}
+	if !app.buffers[0].dirty {
+		t.Fatalf("indenting should mark the buffer dirty")
+	}
+
+	app.ed.Caret = 0 // before "a:", still at line start
+	app.buffers[0].indentWidth = 0
+	handleKeyEvent(&app, keyEvent{down: true, key: keyTab})
+	if got := app.ed.String(); got != "\ta:\n    b: 1\n  c: 2\n" {
+		t.Fatalf("tab-indented buffer should insert a tab, got %q", got)
+	}
+}
+
+func TestDoubleSpaceUsesIndentUnit(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("x"))
+	app.currentPath = "a.go"
+	app.buffers[0].indentWidth = 4
+	app.ed.Caret = 0
+	handleTextEvent(&app, " ", 0)
+	handleTextEvent(&app, " ", 0)
+	if got := app.ed.String(); got != "    x" {
+		t.Fatalf("double space should insert the 4-space unit, got %q", got)
+	}
+}
+
+func TestDoubleSpaceIndentOnlyInCodeBuffersWhenEnabled(t *testing.T) {
+	typeTwoSpaces := func(path string, opt string) string {
+		app := appState{}
+		app.initBuffers(editor.NewEditor("x"))
+		app.currentPath = path
+		if opt != "" {
+			if _, err := applyOption(&app, opt); err != nil {
+				t.Fatalf("applyOption(%q): %v", opt, err)
+			}
+		}
+		app.ed.Caret = 0
+		handleTextEvent(&app, " ", 0)
+		handleTextEvent(&app, " ", 0)
+		return app.ed.String()
+	}
+	if got := typeTwoSpaces("a.go", ""); got != "\tx" {
+		t.Fatalf("Go buffer should keep the double-space indent, got %q", got)
+	}
+	if got := typeTwoSpaces("a.c", "doublespace=on"); got != "\tx" {
+		t.Fatalf("C buffer with doublespace=on should indent, got %q", got)
+	}
+	if got := typeTwoSpaces("a.go", "doublespace=off"); got != "  x" {
+		t.Fatalf("doublespace=off should insert literal spaces, got %q", got)
+	}
+	if got := typeTwoSpaces("notes.txt", ""); got != "  x" {
+		t.Fatalf("text buffer should insert literal spaces, got %q", got)
+	}
+	if got := typeTwoSpaces("README.md", ""); got != "  x" {
+		t.Fatalf("markdown buffer should insert literal spaces, got %q", got)
+	}
+}
+
+func TestTestCompanionPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"foo.go", "foo_test.go", true},
+		{"dir/foo_test.go", "dir/foo.go", true},
+		{"notes.txt", "", false},
+		{"dir/_test.go", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		got, ok := testCompanionPath(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("testCompanionPath(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestEscGTogglesTestCompanion(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "foo.go")
+	test := filepath.Join(root, "foo_test.go")
+	for path, body := range map[string]string{src: "package foo\n", test: "package foo\n\nimport \"testing\"\n"} {
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	toggle := func(app *appState) {
+		handleKeyEvent(app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(app, keyEvent{down: true, key: keyG})
+	}
+
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, src); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	toggle(&app)
+	if app.currentPath != test || len(app.buffers) != 2 || !strings.Contains(app.ed.String(), "testing") {
+		t.Fatalf("Esc+g from foo.go should open foo_test.go, got %q (%d buffers)", app.currentPath, len(app.buffers))
+	}
+	toggle(&app)
+	if app.currentPath != src || len(app.buffers) != 2 {
+		t.Fatalf("Esc+g from foo_test.go should switch back to foo.go, got %q (%d buffers)", app.currentPath, len(app.buffers))
+	}
+	if err := app.RunCommand(CmdJumpBack, ""); err != nil || app.currentPath != test {
+		t.Fatalf("jump back after Esc+g: %q, err %v", app.currentPath, err)
+	}
+
+	// A missing companion opens an empty buffer that is created on save.
+	bar := filepath.Join(root, "bar.go")
+	if err := os.WriteFile(bar, []byte("package foo\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app.addBuffer()
+	if err := openPath(&app, bar); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	toggle(&app)
+	barTest := filepath.Join(root, "bar_test.go")
+	if app.currentPath != barTest || app.ed.String() != "" || !strings.Contains(app.lastEvent, "created on save") {
+		t.Fatalf("missing companion should get a new empty buffer, got %q (%q)", app.currentPath, app.lastEvent)
+	}
+	if _, err := os.Stat(barTest); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("companion should not be created before save, stat err=%v", err)
+	}
+
+	// Non-Go buffers have no companion.
+	notes := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(notes, []byte("hi\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app.addBuffer()
+	if err := openPath(&app, notes); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	n := len(app.buffers)
+	toggle(&app)
+	if len(app.buffers) != n || app.currentPath != notes || !strings.Contains(app.lastEvent, "no Go companion") {
+		t.Fatalf("non-Go buffer should report no companion, got %q (%q)", app.currentPath, app.lastEvent)
+	}
+
+	// A companion that cannot be read records no jump.
+	baz := filepath.Join(root, "baz.go")
+	if err := os.WriteFile(baz, []byte("package foo\n\nvar x = 1\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "baz_test.go"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	app.addBuffer()
+	if err := openPath(&app, baz); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	app.ed.Caret = app.ed.RuneLen()
+	jumps := len(app.jumps)
+	if err := openTestCompanion(&app); err == nil || len(app.jumps) != jumps {
+		t.Fatalf("unreadable companion: err=%v jumps=%d, want %d", err, len(app.jumps), jumps)
+	}
+}
+
+func TestBOMStrippedOnLoadAndRestoredOnSave(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "win.txt")
+	if err := os.WriteFile(path, []byte("\xef\xbb\xbfhello\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := app.ed.String(); got != "hello\n" {
+		t.Fatalf("buffer should start without BOM, got %q", got)
+	}
+	app.ed.Caret = 5
+	app.ed.InsertText("!")
+	app.markDirty()
+	if err := saveCurrent(&app); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != "\xef\xbb\xbfhello!\n" {
+		t.Fatalf("saved file should keep its BOM, got %q", data)
+	}
+
+	plain := filepath.Join(root, "plain.txt")
+	if err := os.WriteFile(plain, []byte("hi"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := openPath(&app, plain); err != nil {
+		t.Fatalf("open plain: %v", err)
+	}
+	app.markDirty()
+	if err := saveCurrent(&app); err != nil {
+		t.Fatalf("save plain: %v", err)
+	}
+	if data, _ := os.ReadFile(plain); string(data) != "hi" {
+		t.Fatalf("file without BOM should not gain one, got %q", data)
+	}
+}
+
+func TestLineEndingsDetectedAndConverted(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "dos.txt")
+	if err := os.WriteFile(path, []byte("a\r\nb\r\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(&app, path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := app.ed.String(); got != "a\nb\n" {
+		t.Fatalf("CRLF should load as LF, got %q", got)
+	}
+	if got := fileFormatStatus(&app.buffers[app.bufIdx]); got != "utf-8 | CRLF" {
+		t.Fatalf("status %q", got)
+	}
+	if err := app.RunCommand(CmdLineEndings, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !app.buffers[app.bufIdx].dirty {
+		t.Fatalf("converting endings should mark the buffer modified")
+	}
+	if err := saveCurrent(&app); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a\nb\n" {
+		t.Fatalf("converted to LF, saved %q", data)
+	}
+
+	app.RunCommand(CmdLineEndings, "")
+	if got := fileFormatStatus(&app.buffers[app.bufIdx]); got != "utf-8 | CRLF" {
+		t.Fatalf("status after converting back %q", got)
+	}
+	if err := saveCurrent(&app); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a\r\nb\r\n" {
+		t.Fatalf("converted to CRLF, saved %q", data)
+	}
+
+	// Mixed endings are not converted on load.
+	mixed := filepath.Join(root, "mixed.txt")
+	if err := os.WriteFile(mixed, []byte("a\r\nb\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := openPath(&app, mixed); err != nil {
+		t.Fatalf("open mixed: %v", err)
+	}
+	if got := app.ed.String(); got != "a\r\nb\n" || app.buffers[app.bufIdx].crlf {
+		t.Fatalf("mixed endings loaded as %q crlf=%v", got, app.buffers[app.bufIdx].crlf)
+	}
+}
+
+func TestOpenPathRejectsBinaryAndInvalidUTF8(t *testing.T) {
+	root := t.TempDir()
+	bin := filepath.Join(root, "a.out")
+	latin1 := filepath.Join(root, "latin1.txt")
+	good := filepath.Join(root, "good.txt")
+	for path, data := range map[string]string{
+		bin:    "ELF\x00\x01\x02",
+		latin1: "caf\xe9",
+		good:   "café ☕",
+	} {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("keep"))
+	for _, path := range []string{bin, latin1} {
+		err := openPath(&app, path)
+		if !errors.Is(err, errNotText) {
+			t.Fatalf("open %s: err = %v, want errNotText", filepath.Base(path), err)
+		}
+		if app.ed.String() != "keep" || app.currentPath != "" {
+			t.Fatalf("rejected open should leave the buffer untouched, got %q at %q", app.ed.String(), app.currentPath)
+		}
+	}
+	if err := openPath(&app, good); err != nil {
+		t.Fatalf("open valid UTF-8: %v", err)
+	}
+	if got := app.ed.String(); got != "café ☕" {
+		t.Fatalf("valid file content = %q", got)
+	}
+}
+
+func TestLargeFileMode(t *testing.T) {
+	cases := []struct {
+		size int64
+		want fileLoadMode
+	}{
+		{0, loadFull},
+		{largeFileBytes, loadFull},
+		{largeFileBytes + 1, loadReadOnly},
+		{hugeFileBytes, loadReadOnly},
+		{hugeFileBytes + 1, loadTail},
+	}
+	for _, tc := range cases {
+		if got := largeFileMode(tc.size); got != tc.want {
+			t.Fatalf("largeFileMode(%d) = %d, want %d", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestReadFileTailStartsAtLineBoundary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, []byte("first line\nsecond\nthird\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := readFileTail(path, 10)
+	if err != nil {
+		t.Fatalf("readFileTail: %v", err)
+	}
+	if string(got) != "third\n" {
+		t.Fatalf("tail = %q, want %q", string(got), "third\n")
+	}
+	whole, err := readFileTail(path, 1<<10)
+	if err != nil || string(whole) != "first line\nsecond\nthird\n" {
+		t.Fatalf("tail larger than file = %q, %v", string(whole), err)
+	}
+}
+
+func TestTextStatsForBufferAndSelection(t *testing.T) {
+	src := "# Title\n\nFirst paragraph has five words.\n\nSecond one, shorter.\n"
+	if got := countText([]rune(src)); got != (textStats{words: 10, lines: 5, chars: len(src)}) {
+		t.Fatalf("buffer stats = %+v", got)
+	}
+	if got := countText(nil); got != (textStats{}) {
+		t.Fatalf("empty stats = %+v", got)
+	}
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modShift})
+	if app.lastEvent != fmt.Sprintf("Buffer: 10 words, 5 lines, %d chars", len(src)) {
+		t.Fatalf("buffer report = %q", app.lastEvent)
+	}
+
+	start := strings.Index(src, "paragraph")
+	app.ed.Sel = editor.Sel{Active: true, A: start + len("paragraph has"), B: start}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyC, mods: modShift})
+	if app.lastEvent != "Selection: 2 words, 1 line, 13 chars" {
+		t.Fatalf("selection report = %q", app.lastEvent)
+	}
+}
+
+func TestBookmarksFollowEditsAcrossBuffers(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha\nbeta\ngamma\n"))
+	first := app.ed
+	first.Caret = 6 // "beta"
+	app.RunCommand(CmdBookmark, "")
+	if app.inputKind != "bookmark" {
+		t.Fatalf("bookmark should prompt, kind=%q", app.inputKind)
+	}
+	dispatchKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if _, ok := app.bookmarks["1"]; !ok {
+		t.Fatalf("empty name should give bookmark 1: %v", bookmarkNames(&app))
+	}
+	if got := bookmarkLines(&app, first, first.Lines()); len(got) != 1 {
+		t.Fatalf("gutter lines = %v", got)
+	}
+
+	first.Caret = 0
+	first.InsertText("intro\n")
+	app.addBuffer()
+	app.ed.InsertText("other")
+
+	app.RunCommand(CmdGotoBookmark, "")
+	app.inputValue = "1"
+	dispatchKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.ed != first || app.ed.Caret != 12 {
+		t.Fatalf("jump: same editor=%v caret=%d (%s)", app.ed == first, app.ed.Caret, app.lastEvent)
+	}
+	if err := app.RunCommand(CmdGotoBookmark, "missing"); err == nil {
+		t.Fatalf("unknown bookmark should fail")
+	}
+}
+
+func TestJumpListReturnsAcrossLeapsAndBuffers(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha\nbeta\ngamma\n"))
+	first := app.ed
+	first.LeapStart(editor.DirFwd)
+	first.LeapAppend("gam")
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if first.Caret != 11 || len(app.jumps) != 1 {
+		t.Fatalf("leap: caret=%d jumps=%d", first.Caret, len(app.jumps))
+	}
+
+	app.addBuffer()
+	app.ed.InsertText("other")
+	second := app.ed
+	recordJump(&app)
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+
+	// Esc+- goes back through the list; the tip is recorded on the way.
+	for _, want := range []struct {
+		ed    *editor.Editor
+		caret int
+	}{{second, 5}, {first, 0}} {
+		handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+		handleKeyEvent(&app, keyEvent{down: true, key: keyMinus})
+		if app.ed != want.ed || app.ed.Caret != want.caret {
+			t.Fatalf("back: caret=%d (%s)", app.ed.Caret, app.lastEvent)
+		}
+	}
+	if err := app.RunCommand(CmdJumpBack, ""); err == nil {
+		t.Fatalf("jump back past the start should fail")
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyMinus, mods: modShift})
+	if app.ed != second {
+		t.Fatalf("forward should return to the second buffer (%s)", app.lastEvent)
+	}
+
+	// A new jump drops the forward entries.
+	recordJump(&app)
+	if err := app.RunCommand(CmdJumpForward, ""); err == nil {
+		t.Fatalf("forward after a new jump should fail, at %d/%d", app.jumpIdx, len(app.jumps))
+	}
+
+	// A refused open records no jump.
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	n := len(app.jumps)
+	app.openRoot = t.TempDir()
+	if err := openListedPath(&app, app.openRoot, "../elsewhere.go"); err == nil || len(app.jumps) != n {
+		t.Fatalf("refused open: err=%v jumps=%d, want %d", err, len(app.jumps), n)
+	}
+}
+
+func TestFinalNewlineDetectionAndNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want finalNewline
+	}{
+		{"", finalNewlineOK},
+		{"a\n", finalNewlineOK},
+		{"a", finalNewlineMissing},
+		{"a\n  ", finalNewlineMissing},
+		{"a\n\n", finalNewlineExtra},
+		{"a\n \t\n", finalNewlineExtra},
+	} {
+		if got := finalNewlineOf(editor.NewEditor(tc.text)); got != tc.want {
+			t.Fatalf("finalNewlineOf(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("x := 1  \n\n  \n\n"))
+	app.buffers[0].path = "/tmp/x.go"
+	if got := finalNewlineStatus(&app.buffers[0]); got != "blank lines at end" {
+		t.Fatalf("status %q", got)
+	}
+	app.ed.Caret = app.ed.RuneLen()
+	if err := app.RunCommand(CmdFinalNewline, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.ed.String(); got != "x := 1  \n" {
+		t.Fatalf("normalized %q", got)
+	}
+	if got := finalNewlineStatus(&app.buffers[0]); got != "" {
+		t.Fatalf("status after normalize %q", got)
+	}
+	out := bufferSlot{ed: editor.NewEditor("building"), path: "[run] go run ."}
+	if got := finalNewlineStatus(&out) + fileFormatStatus(&out); got != "" {
+		t.Fatalf("pseudo-buffers should have no file status, got %q", got)
+	}
+	app.ed.Undo()
+	if got := app.ed.String(); got != "x := 1  \n\n  \n\n" {
+		t.Fatalf("normalize should be one undo step: %q", got)
+	}
+
+	app.ed.SetRunes([]rune("x := 1"))
+	app.RunCommand(CmdFinalNewline, "")
+	if got := app.ed.String(); got != "x := 1\n" {
+		t.Fatalf("missing newline not added: %q", got)
+	}
+}
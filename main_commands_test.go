@@ -0,0 +1,272 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gc/editor"
+)
+
+func TestRunCommandByName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	app := appState{openRoot: dir}
+	app.initBuffers(editor.NewEditor("x := 1\nfoo(x)\n"))
+	app.currentPath = path
+	app.buffers[0].path = path
+
+	run := func(name, arg string) error {
+		t.Helper()
+		cmd, ok := commandByName(name)
+		if !ok {
+			t.Fatalf("command %q not registered", name)
+		}
+		return app.RunCommand(cmd, arg)
+	}
+
+	if err := run("toggle-comment", ""); err != nil {
+		t.Fatalf("toggle-comment: %v", err)
+	}
+	if got := app.ed.String(); got != "//x := 1\nfoo(x)\n" || !app.buffers[0].dirty {
+		t.Fatalf("after toggle-comment: %q dirty=%v", got, app.buffers[0].dirty)
+	}
+
+	if err := run("search", "foo"); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if !app.searchActive || !app.searchPatternDone || app.ed.Caret != 9 || app.ed.Sel.B != 12 {
+		t.Fatalf("search state: active=%v locked=%v caret=%d sel=%+v", app.searchActive, app.searchPatternDone, app.ed.Caret, app.ed.Sel)
+	}
+	if err := run("Search", "nope"); err == nil || app.searchActive {
+		t.Fatalf("missing pattern should fail and leave search: err=%v active=%v", err, app.searchActive)
+	}
+
+	if err := run("save", ""); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "//x := 1\nfoo(x)\n" || app.buffers[0].dirty {
+		t.Fatalf("saved %q err=%v dirty=%v", data, err, app.buffers[0].dirty)
+	}
+
+	if err := run("new-buffer", ""); err != nil || len(app.buffers) != 2 || app.bufIdx != 1 {
+		t.Fatalf("new-buffer: err=%v buffers=%d active=%d", err, len(app.buffers), app.bufIdx)
+	}
+	app.buffers[1].readOnly = true
+	if err := app.RunCommand(CmdToggleComment, ""); err == nil || app.ed.String() != "" {
+		t.Fatalf("toggle-comment in a read-only buffer should fail, got err=%v text=%q", err, app.ed.String())
+	}
+
+	if _, ok := commandByName("no-such-command"); ok {
+		t.Fatalf("unknown names should not resolve")
+	}
+	if err := app.RunCommand(Command(99), ""); err == nil {
+		t.Fatalf("unknown command should fail")
+	}
+}
+
+func TestFuzzyScorePrefersWordStartsAndRuns(t *testing.T) {
+	if _, ok := fuzzyScore("xyz", "save"); ok {
+		t.Fatalf("missing runes should not match")
+	}
+	if _, ok := fuzzyScore("sva", "save"); ok {
+		t.Fatalf("out-of-order runes should not match")
+	}
+	run, _ := fuzzyScore("sav", "save-all")
+	gap, _ := fuzzyScore("sav", "shortcuts view")
+	if run <= gap {
+		t.Fatalf("consecutive match %d should beat scattered match %d", run, gap)
+	}
+	start, _ := fuzzyScore("tc", "toggle-comment")
+	inner, _ := fuzzyScore("tc", "test companion")
+	if start != inner {
+		t.Fatalf("word-start matches should score alike: %d vs %d", start, inner)
+	}
+}
+
+func TestCommandPaletteFiltersAndRuns(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("x := 1\n"))
+	app.currentPath = "a.go"
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keySemicolon, mods: modShift})
+	handleTextEvent(&app, ":", modShift)
+	if !app.palette.active || len(app.palette.query) != 0 || len(app.palette.matches) != len(commandSpecs) {
+		t.Fatalf("palette should open listing every command, got active=%v query=%q matches=%d", app.palette.active, string(app.palette.query), len(app.palette.matches))
+	}
+
+	for _, r := range "tgcm" {
+		handleKeyEvent(&app, keyEvent{down: true, key: keyUnknown})
+		handleTextEvent(&app, string(r), 0)
+	}
+	if len(app.palette.matches) == 0 || app.palette.matches[0].cmd != CmdToggleComment {
+		t.Fatalf("query %q should rank toggle-comment first, got %v", string(app.palette.query), app.palette.matches)
+	}
+	if len(app.palette.matches) >= len(commandSpecs) {
+		t.Fatalf("query should narrow the list, got %d matches", len(app.palette.matches))
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyBackspace})
+	if string(app.palette.query) != "tgc" {
+		t.Fatalf("backspace should trim the query, got %q", string(app.palette.query))
+	}
+	handleTextEvent(&app, "m", 0)
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.palette.active {
+		t.Fatalf("Enter should close the palette")
+	}
+	if got := app.ed.String(); got != "//x := 1\n" || app.lastEvent != "Toggled comment" {
+		t.Fatalf("Enter should run toggle-comment, got %q (%s)", got, app.lastEvent)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keySemicolon, mods: modShift})
+	handleTextEvent(&app, ":", modShift)
+	handleTextEvent(&app, "qqq", 0)
+	if len(app.palette.matches) != 0 {
+		t.Fatalf("nonsense query should match nothing, got %v", app.palette.matches)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.palette.active || app.lastEvent != `No command matches "qqq"` || app.ed.String() != "//x := 1\n" {
+		t.Fatalf("Enter without a match: active=%v status=%q", app.palette.active, app.lastEvent)
+	}
+
+	openCommandPalette(&app)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	if app.palette.active || app.cmdPrefixActive {
+		t.Fatalf("Esc should close the palette without arming the prefix")
+	}
+}
+
+func TestDefaultKeymapCoversRegisteredCommands(t *testing.T) {
+	for _, s := range commandSpecs {
+		b, err := parseKeyBinding(s.keys)
+		if s.cmd == CmdSearch {
+			if err == nil {
+				t.Fatalf("Esc+/ enters search mode and should be reserved")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if defaultKeys[b] != s.cmd {
+			t.Fatalf("%s (%s) not in the default keymap", s.name, s.keys)
+		}
+	}
+	for _, bad := range []string{"S", "Esc+Ctrl+S", "Ctrl+Hyper+S", "Ctrl+NoSuchKey", "Esc+Space"} {
+		if _, err := parseKeyBinding(bad); err == nil {
+			t.Fatalf("%q should not parse", bad)
+		}
+	}
+	if b, err := parseKeyBinding("esc+:"); err != nil || b != (keyBinding{prefix: true, key: keySemicolon, mods: modShift}) {
+		t.Fatalf("esc+: parsed as %+v, %v", b, err)
+	}
+	if b, err := parseKeyBinding("Ctrl+PageDown"); err != nil || b != (keyBinding{key: keyPageDown, mods: modCtrl}) {
+		t.Fatalf("Ctrl+PageDown parsed as %+v, %v", b, err)
+	}
+}
+
+func TestLoadedKeymapRoutesRemappedKeys(t *testing.T) {
+	conf := `# move new-buffer off Ctrl+B
+Ctrl+N = new-buffer
+Ctrl+B = none
+
+Esc+Shift+K = toggle-comment
+Ctrl+S = count
+`
+	km, err := loadKeymap(strings.NewReader(conf), defaultKeys)
+	if err != nil {
+		t.Fatalf("loadKeymap: %v", err)
+	}
+	if defaultKeys[keyBinding{key: keyB, mods: modCtrl}] != CmdNewBuffer {
+		t.Fatalf("loading must not modify the defaults")
+	}
+
+	app := appState{keymap: km}
+	app.initBuffers(editor.NewEditor("one two\n"))
+	app.currentPath = "a.go"
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyB, mods: modCtrl})
+	if len(app.buffers) != 1 {
+		t.Fatalf("unbound Ctrl+B should not open a buffer")
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyN, mods: modCtrl})
+	if len(app.buffers) != 2 || app.bufIdx != 1 {
+		t.Fatalf("Ctrl+N should open a buffer, have %d", len(app.buffers))
+	}
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyS, mods: modCtrl})
+	if app.lastEvent != "Buffer: 2 words, 1 line, 8 chars" || app.buffers[0].dirty {
+		t.Fatalf("Ctrl+S should report counts, got %q", app.lastEvent)
+	}
+	// Esc+s still follows the remapped Ctrl+S binding.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyS})
+	if app.lastEvent != "Buffer: 2 words, 1 line, 8 chars" {
+		t.Fatalf("Esc+s should follow Ctrl+S, got %q", app.lastEvent)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyK, mods: modShift})
+	if got := app.ed.String(); got != "//one two\n" {
+		t.Fatalf("Esc+Shift+K should toggle the comment, got %q", got)
+	}
+
+	for _, bad := range []string{"Ctrl+S save", "Ctrl+S = no-such-command", "Esc+x = save"} {
+		if _, err := loadKeymap(strings.NewReader(bad), defaultKeys); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("%q should fail with a line number, got %v", bad, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if km, err := loadUserKeymap(filepath.Join(dir, "missing")); err != nil || len(km) != len(defaultKeys) {
+		t.Fatalf("missing key file should give the defaults, got %d bindings, %v", len(km), err)
+	}
+	path := filepath.Join(dir, "keys")
+	if err := os.WriteFile(path, []byte("Ctrl+S = bogus\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if km, err := loadUserKeymap(path); err == nil || len(km) != len(defaultKeys) {
+		t.Fatalf("bad key file should report an error and keep the defaults, got %v", err)
+	}
+}
+
+func TestCloseOtherBuffers(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("one"))
+	app.addBuffer()
+	app.addBuffer()
+	app.switchBuffer(-1)
+	keep := app.ed
+
+	if err := app.RunCommand(CmdCloseOthers, ""); err != nil {
+		t.Fatalf("close-others: %v", err)
+	}
+	if len(app.buffers) != 1 || app.ed != keep || app.bufIdx != 0 {
+		t.Fatalf("want only the active buffer left, got %d buffers (active kept: %v)", len(app.buffers), app.ed == keep)
+	}
+
+	// An unsaved buffer blocks until the prompt is answered with y.
+	app.addBuffer()
+	app.markDirty()
+	app.switchBuffer(1)
+	app.RunCommand(CmdCloseOthers, "")
+	if !app.inputActive || len(app.buffers) != 2 {
+		t.Fatalf("dirty buffer should prompt first: input=%v buffers=%d", app.inputActive, len(app.buffers))
+	}
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	if len(app.buffers) != 2 {
+		t.Fatalf("Enter without y should keep the buffers, got %d", len(app.buffers))
+	}
+	app.RunCommand(CmdCloseOthers, "")
+	handleInputText(&app, "y")
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	if len(app.buffers) != 1 || app.ed != keep {
+		t.Fatalf("y should close the dirty buffer, got %d buffers", len(app.buffers))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -24,7 +25,7 @@ func TestFindMatchesAndOpenPath(t *testing.T) {
 		t.Fatalf("write ignored: %v", err)
 	}
 
-	matches := findMatches(root, "alp", 10)
+	matches, _ := findMatches(root, "alp", 10, walkFilter{})
 	if len(matches) != 1 || matches[0] != path {
 		t.Fatalf("matches = %v, want [%s]", matches, path)
 	}
@@ -53,7 +54,7 @@ func TestFilePickerListsAndLoads(t *testing.T) {
 		t.Fatalf("write b: %v", err)
 	}
 
-	files, err := listFiles(root, 10)
+	files, err := listFiles(root, 10, walkFilter{})
 	if err != nil {
 		t.Fatalf("listFiles: %v", err)
 	}
@@ -82,6 +83,62 @@ func TestFilePickerListsAndLoads(t *testing.T) {
 	}
 }
 
+func TestPickerDetailsStillLoad(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "my notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "b.txt"), []byte(strings.Repeat("x", 1536)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := &appState{openRoot: root, pickerDetails: true}
+	app.initBuffers(editor.NewEditor(""))
+	list, err := pickerListing(app, root)
+	if err != nil {
+		t.Fatalf("pickerListing: %v", err)
+	}
+	if len(list) != 3 || list[0] != ".." || !strings.HasPrefix(list[1], "my notes.txt  ") || !strings.HasPrefix(list[2], "sub/  ") {
+		t.Fatalf("annotated listing = %q", list)
+	}
+	if !strings.Contains(list[2], "      -  ") {
+		t.Fatalf("directory size should be '-': %q", list[2])
+	}
+	for i, want := range []string{"..", "my notes.txt", "sub/"} {
+		if got := pickerEntryName(list[i]); got != want {
+			t.Fatalf("pickerEntryName(%q) = %q, want %q", list[i], got, want)
+		}
+	}
+
+	app.addPickerBuffer(list)
+	app.ed.Caret = len([]rune(list[0])) + len([]rune(list[1])) + 2
+	if err := loadFileAtCaret(app); err != nil {
+		t.Fatalf("enter sub/: %v", err)
+	}
+	lines := app.ed.Lines()
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "b.txt  ") || !strings.Contains(lines[1], "1.5K") {
+		t.Fatalf("sub listing = %q", lines)
+	}
+	app.ed.Caret = len([]rune(lines[0])) + 1
+	if err := loadFileAtCaret(app); err != nil {
+		t.Fatalf("load b.txt: %v", err)
+	}
+	if app.currentPath != filepath.Join(sub, "b.txt") {
+		t.Fatalf("currentPath = %q", app.currentPath)
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0B", 1023: "1023B", 1536: "1.5K", 20 << 10: "20K", 3 << 20: "3.0M"} {
+		if got := formatFileSize(n); got != want {
+			t.Fatalf("formatFileSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
 func TestOpenPathRejectsOutsideRoot(t *testing.T) {
 	root := t.TempDir()
 	app := &appState{openRoot: root}
@@ -93,6 +150,104 @@ func TestOpenPathRejectsOutsideRoot(t *testing.T) {
 	}
 }
 
+func TestCtrlLConfirmsOpeningOutsideRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "proj")
+	other := filepath.Join(base, "other")
+	for _, d := range []string{root, other} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	notes := filepath.Join(other, "notes.md")
+	todo := filepath.Join(other, "todo.md")
+	for _, p := range []string{notes, todo} {
+		if err := os.WriteFile(p, []byte("# "+filepath.Base(p)+"\n"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	readme := filepath.Join(root, "README.md")
+	src := "See [notes](../other/notes.md) and [todo](../other/todo.md).\n"
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = readme
+	app.buffers[0].path = readme
+
+	follow := func(at, answer string) {
+		t.Helper()
+		app.bufIdx = 0
+		app.syncActiveBuffer()
+		app.ed.Caret = strings.Index(src, at)
+		handleKeyEvent(app, keyEvent{down: true, key: keyL, mods: modCtrl})
+		if !app.inputActive || app.inputKind != "openoutside" {
+			t.Fatalf("expected a confirmation prompt, got %q", app.lastEvent)
+		}
+		dispatchTextEvent(app, answer, 0)
+		dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	}
+
+	follow("notes]", "")
+	if len(app.buffers) != 1 || app.lastEvent != "Not opened" {
+		t.Fatalf("declined: %d buffers, status %q", len(app.buffers), app.lastEvent)
+	}
+	follow("notes]", "y")
+	if len(app.buffers) != 2 || app.currentPath != notes || app.openRoot != root {
+		t.Fatalf("confirmed: %d buffers, path %q, root %q (%s)", len(app.buffers), app.currentPath, app.openRoot, app.lastEvent)
+	}
+	follow("todo]", "r")
+	if len(app.buffers) != 3 || app.currentPath != todo || app.openRoot != other {
+		t.Fatalf("confirmed with root: %d buffers, path %q, root %q (%s)", len(app.buffers), app.currentPath, app.openRoot, app.lastEvent)
+	}
+
+	// A confirmed file that cannot be opened records no jump.
+	jumps := len(app.jumps)
+	if err := openOutsideRoot(app, filepath.Join(base, "gone", "x.md"), false); err == nil || len(app.jumps) != jumps {
+		t.Fatalf("failed open: err=%v jumps=%d, want %d", err, len(app.jumps), jumps)
+	}
+}
+
+func TestOutsideRootMatchesWholePathElements(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "proj")
+	for _, p := range []string{filepath.Join(root, "..foo"), filepath.Join(root, "a", "..b"), root} {
+		if err := outsideRoot(root, p); err != nil {
+			t.Fatalf("%s should be inside %s: %v", p, root, err)
+		}
+	}
+	for _, p := range []string{filepath.Dir(root), filepath.Join(root, "..", "other"), root + "2"} {
+		if outsideRoot(root, p) == nil {
+			t.Fatalf("%s should be outside %s", p, root)
+		}
+	}
+}
+
+func TestSidebarConfirmsOpeningOutsideRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "proj")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(base, "notes.txt")
+	if err := os.WriteFile(outside, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("scratch"))
+	if err := app.RunCommand(CmdSidebar, ""); err != nil {
+		t.Fatalf("sidebar: %v", err)
+	}
+	handleKeyEvent(app, keyEvent{down: true, key: keyBackspace})
+	app.sidebar.selected = slices.Index(app.sidebar.entries, "notes.txt")
+	handleKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	if !app.inputActive || app.inputKind != "openoutside" || app.sidebar.focused {
+		t.Fatalf("expected a confirmation prompt with the editor focused, got %q", app.lastEvent)
+	}
+	dispatchTextEvent(app, "y", 0)
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	if app.currentPath != outside || app.ed.String() != "notes" || app.openRoot != root {
+		t.Fatalf("confirmed: path %q, root %q (%s)", app.currentPath, app.openRoot, app.lastEvent)
+	}
+}
+
 func TestSaveCurrentDefaultsToLeapTxt(t *testing.T) {
 	root := t.TempDir()
 	app := &appState{openRoot: root}
@@ -116,6 +271,43 @@ func TestSaveCurrentDefaultsToLeapTxt(t *testing.T) {
 	}
 }
 
+func TestSaveAsTabCompletesPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"docs/notes", "dist"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("hi"))
+	promptSaveAs(app)
+	tab := func() { dispatchKeyEvent(app, keyEvent{down: true, key: keyTab}) }
+
+	dispatchTextEvent(app, "d", 0)
+	tab()
+	if app.inputValue != "d" || !strings.Contains(app.lastEvent, "dist/") || !strings.Contains(app.lastEvent, "docs/") {
+		t.Fatalf("ambiguous: input %q, status %q", app.inputValue, app.lastEvent)
+	}
+	dispatchTextEvent(app, "o", 0)
+	tab()
+	tab()
+	if app.inputValue != "docs/notes/" {
+		t.Fatalf("completed directories: %q (%s)", app.inputValue, app.lastEvent)
+	}
+	dispatchTextEvent(app, "todo.md", 0)
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	want := filepath.Join(root, "docs", "notes", "todo.md")
+	if data, err := os.ReadFile(want); err != nil || string(data) != "hi" {
+		t.Fatalf("save to completed path: %q %v (%s)", data, err, app.lastEvent)
+	}
+
+	app.inputActive, app.inputKind, app.inputValue = true, "save", "dcs"
+	tab()
+	if app.inputValue != "docs/" {
+		t.Fatalf("fuzzy completion: %q (%s)", app.inputValue, app.lastEvent)
+	}
+}
+
 func TestFilterArgsToFilesSkipsDirs(t *testing.T) {
 	root := t.TempDir()
 	file := filepath.Join(root, "a.txt")
@@ -195,3 +387,339 @@ func TestOpenLongFileAndLeapAround(t *testing.T) {
 		t.Fatalf("expected caret at start after wrap; got %d", app.ed.Caret)
 	}
 }
+
+func TestCtrlLFollowsMarkdownLinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	intro := filepath.Join(root, "docs", "intro.md")
+	if err := os.WriteFile(intro, []byte("# Intro\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readme := filepath.Join(root, "README.md")
+	src := "See [the intro](docs/intro.md#setup) or [the site](https://example.com/gc).\n"
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = readme
+	app.buffers[0].path = readme
+
+	app.ed.Caret = strings.Index(src, "intro]")
+	handleKeyEvent(app, keyEvent{down: true, key: keyL, mods: modCtrl})
+	if len(app.buffers) != 2 || app.currentPath != intro || app.ed.String() != "# Intro\n" {
+		t.Fatalf("follow relative link: %d buffers, path %q, text %q (%s)", len(app.buffers), app.currentPath, app.ed.String(), app.lastEvent)
+	}
+	if err := app.RunCommand(CmdJumpBack, ""); err != nil || app.currentPath != readme {
+		t.Fatalf("jump back after following a link: path %q, err %v", app.currentPath, err)
+	}
+
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	app.ed.Caret = strings.Index(src, "example")
+	handleKeyEvent(app, keyEvent{down: true, key: keyL, mods: modCtrl})
+	if app.bufIdx != 0 || len(app.buffers) != 2 || app.lastEvent != "Link: https://example.com/gc" {
+		t.Fatalf("external link: active %d, %d buffers, status %q", app.bufIdx, len(app.buffers), app.lastEvent)
+	}
+
+	// A link to a missing file fails without recording a jump.
+	app.ed.SetRunes([]rune(src + "\n[gone](docs/gone.md)\n"))
+	app.touchActiveBufferText()
+	app.ed.Caret = app.ed.RuneLen() - len("gone.md)\n")
+	jumps := len(app.jumps)
+	if ok, err := followMarkdownLink(app); !ok || err == nil || len(app.jumps) != jumps {
+		t.Fatalf("broken link: ok=%v err=%v jumps=%d, want %d", ok, err, len(app.jumps), jumps)
+	}
+
+	if _, ok := markdownLinkAt("plain [x](y) text", 1); ok {
+		t.Fatalf("caret outside a link should not match")
+	}
+	if target, ok := markdownLinkAt("ü [x](y)", 4); !ok || target != "y" {
+		t.Fatalf("rune column inside link: %q %v", target, ok)
+	}
+}
+
+func TestSidebarNavigatesAndOpens(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("aaa"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "b.txt"), []byte("bbb"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("scratch"))
+	key := func(k keyCode) { handleKeyEvent(app, keyEvent{down: true, key: k}) }
+
+	if err := app.RunCommand(CmdSidebar, ""); err != nil {
+		t.Fatalf("sidebar: %v", err)
+	}
+	sb := &app.sidebar
+	if !sb.visible || !sb.focused || strings.Join(sb.entries, ",") != "..,a.txt,sub/" {
+		t.Fatalf("opened sidebar = %+v", *sb)
+	}
+
+	key(keyDown)
+	key(keyDown)
+	key(keyReturn)
+	if sb.root != sub || strings.Join(sb.entries, ",") != "..,b.txt" || sb.selected != 0 {
+		t.Fatalf("after entering sub/: root=%q entries=%v selected=%d", sb.root, sb.entries, sb.selected)
+	}
+	handleTextEvent(app, "x", 0)
+	if app.ed.String() != "scratch" {
+		t.Fatalf("text typed into the focused sidebar reached the buffer: %q", app.ed.String())
+	}
+
+	key(keyDown)
+	key(keyReturn)
+	if app.currentPath != filepath.Join(sub, "b.txt") || app.ed.String() != "bbb" {
+		t.Fatalf("open from sidebar: path=%q text=%q", app.currentPath, app.ed.String())
+	}
+	if !sb.visible || sb.focused {
+		t.Fatalf("opening a file should keep the sidebar and focus the editor: %+v", *sb)
+	}
+
+	if err := app.RunCommand(CmdSidebar, ""); err != nil {
+		t.Fatalf("refocus: %v", err)
+	}
+	key(keyBackspace)
+	if sb.root != root || sb.entries[sb.selected] != "sub/" {
+		t.Fatalf("going up: root=%q selected=%d entries=%v", sb.root, sb.selected, sb.entries)
+	}
+	key(keyEscape)
+	if !sb.visible || sb.focused {
+		t.Fatalf("Esc should return focus and keep the sidebar: %+v", *sb)
+	}
+	app.RunCommand(CmdSidebar, "")
+	handleTextEvent(app, "q", 0)
+	if sb.visible || sb.focused {
+		t.Fatalf("q should hide the sidebar: %+v", *sb)
+	}
+}
+
+func TestPickerRenameUpdatesBufferPath(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old.txt")
+	if err := os.WriteFile(old, []byte("body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	if err := openPath(app, old); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := pickerLines(root, 500, walkFilter{})
+	app.addPickerBuffer(list)
+	app.ed.Caret = len("..") + 1 // old.txt
+
+	if err := app.RunCommand(CmdRenameFile, ""); err != nil {
+		t.Fatalf("rename-file: %v", err)
+	}
+	if app.inputKind != "rename" || app.inputValue != "old.txt" {
+		t.Fatalf("prompt kind=%q value=%q", app.inputKind, app.inputValue)
+	}
+	app.inputValue = "new.txt"
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+
+	renamed := filepath.Join(root, "new.txt")
+	if _, err := os.Stat(renamed); err != nil {
+		t.Fatalf("renamed file: %v (%s)", err, app.lastEvent)
+	}
+	if app.buffers[0].path != renamed {
+		t.Fatalf("open buffer path = %q, want %q", app.buffers[0].path, renamed)
+	}
+	if got := app.ed.String(); got != "..\nnew.txt" {
+		t.Fatalf("refreshed listing = %q", got)
+	}
+}
+
+func TestPickerDeleteConfirmsAndStaysInRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "gone.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(filepath.Dir(root), filepath.Base(root)+"-outside.txt")
+	if err := os.WriteFile(outside, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(outside) })
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	list, _ := pickerLines(root, 500, walkFilter{})
+	app.addPickerBuffer(list)
+	app.ed.Caret = len("..") + 1
+
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyDelete})
+	if app.inputKind != "deletefile" {
+		t.Fatalf("Delete in picker should prompt, got kind=%q (%s)", app.inputKind, app.lastEvent)
+	}
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	if _, err := os.Stat(filepath.Join(root, "gone.txt")); err != nil {
+		t.Fatalf("Enter without y should keep the file: %v", err)
+	}
+
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyDelete})
+	app.inputValue = "y"
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyReturn})
+	if _, err := os.Stat(filepath.Join(root, "gone.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file should be deleted: %v", err)
+	}
+	if got := app.ed.String(); got != ".." {
+		t.Fatalf("listing after delete = %q", got)
+	}
+
+	app.buffers[app.bufIdx].ed.SetRunes([]rune("..\n../" + filepath.Base(outside)))
+	app.ed.Caret = len("..") + 1
+	if err := app.RunCommand(CmdDeleteFile, ""); err == nil || app.inputActive {
+		t.Fatalf("delete outside the root should be refused: err=%v", err)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("outside file touched: %v", err)
+	}
+}
+
+func TestWalkersHonourGitignore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":        "# build output\nbuild/\n*.log\n!keep.log\n/docs/*.tmp\n",
+		"main.go":           "package main",
+		"run.log":           "x",
+		"keep.log":          "x",
+		"build/out.go":      "x",
+		"src/build/gen.go":  "x",
+		"src/trace.log":     "x",
+		"src/app.go":        "x",
+		"docs/a.tmp":        "x",
+		"docs/guide.md":     "x",
+		"src/docs/b.tmp":    "x",
+		"builder/notes.txt": "x",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	on := walkFilter{gitignore: true}
+
+	got, err := listFiles(root, 100, on)
+	if err != nil {
+		t.Fatalf("listFiles: %v", err)
+	}
+	want := ".gitignore,builder/notes.txt,docs/guide.md,keep.log,main.go,src/app.go,src/docs/b.tmp"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("listFiles with .gitignore = %v\nwant %s", got, want)
+	}
+	if all, _ := listFiles(root, 100, walkFilter{}); len(all) != len(files) {
+		t.Fatalf("listFiles without the option should list every file: %v", all)
+	}
+
+	if m, _ := findMatches(root, ".log", 10, on); len(m) != 1 || filepath.Base(m[0]) != "keep.log" {
+		t.Fatalf("findMatches(.log) = %v", m)
+	}
+	entries, err := pickerLines(root, 100, on)
+	if err != nil {
+		t.Fatalf("pickerLines: %v", err)
+	}
+	if strings.Join(entries, ",") != "..,builder/,docs/,keep.log,main.go,src/" {
+		t.Fatalf("pickerLines = %v", entries)
+	}
+	sub, _ := pickerLines(filepath.Join(root, "src"), 100, on)
+	if strings.Join(sub, ",") != "..,app.go,docs/" {
+		t.Fatalf("pickerLines below root should use the parent .gitignore: %v", sub)
+	}
+}
+
+func TestWalkersWithoutGitignoreKeepDefaults(t *testing.T) {
+	root := t.TempDir()
+	// A .git directory stops the search for a .gitignore further up.
+	for _, d := range []string{".git", "vendor", "pkg"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg", "x.log"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := pickerLines(root, 100, walkFilter{gitignore: true})
+	if err != nil || strings.Join(entries, ",") != "..,pkg/" {
+		t.Fatalf("pickerLines = %v, %v", entries, err)
+	}
+	if m, _ := findMatches(root, "x.log", 10, walkFilter{gitignore: true}); len(m) != 1 {
+		t.Fatalf("findMatches = %v", m)
+	}
+}
+
+func TestWalkersSkipConfiguredNames(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"node_modules/lib/index.js", "web/app.js", "vendor/dep.js"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := &appState{openRoot: root}
+	if _, err := applyOption(app, "ignore=node_modules"); err != nil {
+		t.Fatal(err)
+	}
+	f := app.walkFilter()
+
+	files, err := listFiles(root, 10, f)
+	if err != nil || strings.Join(files, ",") != "web/app.js" {
+		t.Fatalf("listFiles = %v, %v", files, err)
+	}
+	if m, _ := findMatches(root, ".js", 10, f); len(m) != 1 || m[0] != filepath.Join(root, "web", "app.js") {
+		t.Fatalf("findMatches = %v", m)
+	}
+	entries, err := pickerLines(root, 10, f)
+	if err != nil || strings.Join(entries, ",") != "..,web/" {
+		t.Fatalf("pickerLines = %v, %v", entries, err)
+	}
+	if all, _ := listFiles(root, 10, walkFilter{}); len(all) != 2 {
+		t.Fatalf("without the option node_modules is walked: %v", all)
+	}
+}
+
+func TestFindMatchesReportsTruncation(t *testing.T) {
+	root := t.TempDir()
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("note%d.txt", i)), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m, more := findMatches(root, "note", 3, walkFilter{}); len(m) != 3 || !more {
+		t.Fatalf("limit 3 of 5: %d matches, more=%v", len(m), more)
+	}
+	if m, more := findMatches(root, "note", 5, walkFilter{}); len(m) != 5 || more {
+		t.Fatalf("limit 5 of 5: %d matches, more=%v", len(m), more)
+	}
+	if m, more := findMatches(root, "note1", 3, walkFilter{}); len(m) != 1 || more {
+		t.Fatalf("single match: %d matches, more=%v", len(m), more)
+	}
+
+	app := &appState{openRoot: root, findLimit: 2}
+	app.initBuffers(editor.NewEditor(""))
+	app.open.Active = true
+	dispatchTextEvent(app, "note", 0)
+	if len(app.open.Matches) != 2 || !app.open.More {
+		t.Fatalf("first page: %v more=%v", app.open.Matches, app.open.More)
+	}
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyTab})
+	dispatchKeyEvent(app, keyEvent{down: true, key: keyTab})
+	if len(app.open.Matches) != 5 || app.open.More {
+		t.Fatalf("after paging: %v more=%v", app.open.Matches, app.open.More)
+	}
+	dispatchTextEvent(app, "1", 0)
+	if app.open.Limit != 2 || len(app.open.Matches) != 1 || app.open.More {
+		t.Fatalf("refining resets the page: limit=%d matches=%v", app.open.Limit, app.open.Matches)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gc/editor"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestGutterLabelRelativeAroundCaret(t *testing.T) {
+	cases := []struct {
+		ln   int
+		want string
+	}{
+		{7, "   3 "},
+		{9, "   1 "},
+		{10, "  11 "}, // caret line keeps its absolute number
+		{11, "   1 "},
+		{14, "   4 "},
+	}
+	for _, tc := range cases {
+		if got := gutterLabel(lineNumbersRelative, tc.ln, 10); got != tc.want {
+			t.Fatalf("relative label for line %d = %q, want %q", tc.ln, got, tc.want)
+		}
+	}
+	if got := gutterLabel(lineNumbersAbsolute, 7, 10); got != "   8 " {
+		t.Fatalf("absolute label = %q", got)
+	}
+	if got := gutterLabel(lineNumbersOff, 7, 10); got != "" {
+		t.Fatalf("hidden gutter label = %q", got)
+	}
+}
+
+func TestApplyOptionNumbers(t *testing.T) {
+	app := &appState{}
+	for spec, want := range map[string]lineNumberMode{
+		"numbers=rel":      lineNumbersRelative,
+		" numbers = OFF ":  lineNumbersOff,
+		"nu=abs":           lineNumbersAbsolute,
+		"numbers=relative": lineNumbersRelative,
+	} {
+		if _, err := applyOption(app, spec); err != nil {
+			t.Fatalf("applyOption(%q): %v", spec, err)
+		}
+		if app.lineNumbers != want {
+			t.Fatalf("applyOption(%q) mode=%v, want %v", spec, app.lineNumbers, want)
+		}
+	}
+	if _, err := applyOption(app, "numbers=sideways"); err == nil {
+		t.Fatalf("invalid value should error")
+	}
+	if _, err := applyOption(app, "bogus=1"); err == nil {
+		t.Fatalf("unknown option should error")
+	}
+}
+
+func TestEscShiftOSetsOptionFromInputLine(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("abc"))
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyO, mods: modShift})
+	if !app.inputActive || app.inputKind != "set" {
+		t.Fatalf("Esc+Shift+O should open the Set prompt")
+	}
+	handleInputText(&app, "numbers=off")
+	handleInputKey(&app, keyEvent{down: true, key: keyReturn})
+	if app.lineNumbers != lineNumbersOff || gutterWidth(&app) != 0 {
+		t.Fatalf("numbers=off should hide the gutter")
+	}
+}
+
+func TestDrawTUIHiddenGutterReclaimsWidth(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+
+	app := appState{lineNumbers: lineNumbersOff}
+	app.initBuffers(editor.NewEditor("xyz"))
+	drawTUI(s, &app)
+	if str, _, _ := s.Get(0, 0); str != "x" {
+		t.Fatalf("text should start at column 0 without a gutter, got %q", str)
+	}
+}
+
+func TestDrawTUIHiddenGutterKeepsAColumnForMarkers(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+
+	app := appState{lineNumbers: lineNumbersOff, lineLimit: 8, syntaxHL: newGoHighlighter(), syntaxCheck: newGoSyntaxChecker()}
+	app.initBuffers(editor.NewEditor("package main\nfunc main() {\n"))
+	app.currentPath = "bad.go"
+	app.buffers[0].path = "bad.go"
+	drawTUI(s, &app)
+	for row, want := range []string{">", "!"} {
+		if str, _, _ := s.Get(0, row); str != want {
+			t.Fatalf("row %d marker = %q, want %q", row, str, want)
+		}
+		if str, _, _ := s.Get(1, row); str != "p" && str != "f" {
+			t.Fatalf("row %d text should start after the marker column, got %q", row, str)
+		}
+	}
+
+	// A Go buffer keeps the column whether or not a line has an error, so
+	// fixing the error does not shift the text.
+	app.lineLimit = 0
+	app.ed.SetRunes([]rune("package main\nfunc main() {}\n"))
+	app.touchActiveBufferText()
+	drawTUI(s, &app)
+	if str, _, _ := s.Get(1, 0); str != "p" {
+		t.Fatalf("text should stay after the marker column without errors, got %q", str)
+	}
+}
+
+func TestRulerCellX(t *testing.T) {
+	p := tuiPane{x: 0, w: 100, gutterW: 5}
+	if x, ok := rulerCellX(p, 80); !ok || x != 84 {
+		t.Fatalf("ruler 80 with gutter: x=%d ok=%v", x, ok)
+	}
+	p.gutterW = 0
+	if x, ok := rulerCellX(p, 1); !ok || x != 0 {
+		t.Fatalf("ruler 1 without gutter: x=%d ok=%v", x, ok)
+	}
+	right := tuiPane{x: 41, w: 40, gutterW: 5}
+	if x, ok := rulerCellX(right, 20); !ok || x != 65 {
+		t.Fatalf("ruler in right pane: x=%d ok=%v", x, ok)
+	}
+	if _, ok := rulerCellX(right, 80); ok {
+		t.Fatalf("ruler beyond pane width should not be drawn")
+	}
+	if _, ok := rulerCellX(p, 0); ok {
+		t.Fatalf("ruler 0 means off")
+	}
+}
+
+func TestApplyOptionRuler(t *testing.T) {
+	app := &appState{}
+	if desc, err := applyOption(app, "ruler=100"); err != nil || app.rulerCol != 100 || desc != "ruler=100" {
+		t.Fatalf("ruler=100: col=%d desc=%q err=%v", app.rulerCol, desc, err)
+	}
+	if _, err := applyOption(app, "ruler=off"); err != nil || app.rulerCol != 0 {
+		t.Fatalf("ruler=off: col=%d err=%v", app.rulerCol, err)
+	}
+	if _, err := applyOption(app, "ruler=wide"); err == nil {
+		t.Fatalf("non-numeric ruler should error")
+	}
+}
+
+func TestApplyOptionWordChars(t *testing.T) {
+	app := &appState{}
+	app.initBuffers(editor.NewEditor("foo-bar"))
+	if desc, err := applyOption(app, "wordchars=-"); err != nil || desc != "wordchars=-" {
+		t.Fatalf("wordchars=-: desc=%q err=%v", desc, err)
+	}
+	app.addBuffer()
+	app.ed.InsertText("foo-bar")
+	app.ed.Caret = 1
+	app.ed.DeleteWordAtCaret()
+	if got := string(app.ed.Runes()); got != "" {
+		t.Fatalf("new buffers should use the word characters, got %q", got)
+	}
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	app.ed.Caret = 1
+	app.ed.DeleteWordAtCaret()
+	if got := string(app.ed.Runes()); got != "" {
+		t.Fatalf("open buffers should use the word characters, got %q", got)
+	}
+	if desc, err := applyOption(app, "wordchars=default"); err != nil || desc != "wordchars=default" || app.wordChars != "" {
+		t.Fatalf("wordchars=default: desc=%q err=%v chars=%q", desc, err, app.wordChars)
+	}
+	if _, err := applyOption(app, "wordchars=- ."); err == nil {
+		t.Fatalf("whitespace word characters should error")
+	}
+}
+
+func TestExceedsLineLimitCountsTabsVisually(t *testing.T) {
+	if got := visualLen("\tabc"); got != tabWidth+3 {
+		t.Fatalf("visualLen(tab+abc) = %d, want %d", got, tabWidth+3)
+	}
+	line := "\t" + strings.Repeat("x", 6)
+	limit := tabWidth + 6
+	if exceedsLineLimit(line, limit) {
+		t.Fatalf("line exactly at limit %d should not be marked", limit)
+	}
+	if !exceedsLineLimit(line, limit-1) {
+		t.Fatalf("tab should count at visual width, line over limit %d", limit-1)
+	}
+	if exceedsLineLimit(strings.Repeat("x", 500), 0) {
+		t.Fatalf("limit 0 disables the check")
+	}
+}
+
+func TestApplyOptionLimitFollowsRuler(t *testing.T) {
+	app := &appState{}
+	if desc, err := applyOption(app, "limit"); err != nil || app.lineLimit != 80 || desc != "limit=80" {
+		t.Fatalf("bare limit: limit=%d desc=%q err=%v", app.lineLimit, desc, err)
+	}
+	app.rulerCol = 100
+	if _, err := applyOption(app, "limit"); err != nil || app.lineLimit != 100 {
+		t.Fatalf("bare limit should follow the ruler: limit=%d err=%v", app.lineLimit, err)
+	}
+	if _, err := applyOption(app, "limit=off"); err != nil || app.lineLimit != 0 {
+		t.Fatalf("limit=off: limit=%d err=%v", app.lineLimit, err)
+	}
+	if _, err := applyOption(app, "limit=-3"); err == nil {
+		t.Fatalf("negative limit should error")
+	}
+}
+
+func TestDrawTUIMarksLongLines(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+	app := appState{lineLimit: 5}
+	app.initBuffers(editor.NewEditor("short\ntoo long\n"))
+	drawTUI(s, &app)
+	if str, _, _ := s.Get(4, 0); str == ">" {
+		t.Fatalf("line at the limit should not be marked")
+	}
+	if str, _, _ := s.Get(4, 1); str != ">" {
+		t.Fatalf("long line should show '>' in the gutter, got %q", str)
+	}
+}
+
+func TestLoadConfigAppliesOptions(t *testing.T) {
+	app := &appState{}
+	cfg := "# editor defaults\n\nignore = node_modules, Target ,node_modules\nnumbers=rel\n"
+	if err := loadConfig(app, strings.NewReader(cfg)); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if strings.Join(app.ignoreNames, ",") != "node_modules,Target" || app.lineNumbers != lineNumbersRelative {
+		t.Fatalf("ignore=%v numbers=%v", app.ignoreNames, app.lineNumbers)
+	}
+	err := loadConfig(app, strings.NewReader("ruler=40\nbogus=1\nruler=90\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("bad line error = %v", err)
+	}
+	if app.rulerCol != 40 {
+		t.Fatalf("lines before the error should apply, ruler=%d", app.rulerCol)
+	}
+	if got, err := applyOption(app, "ignore="); err != nil || got != "ignore=none" || app.ignoreNames != nil {
+		t.Fatalf("clearing ignore: %q %v %v", got, err, app.ignoreNames)
+	}
+}
+
+func TestFormatPathCollapsesHomeAndRoot(t *testing.T) {
+	home := "/home/ann"
+	root := "/home/ann/src/gc"
+	cases := []struct {
+		mode pathMode
+		p    string
+		want string
+	}{
+		{pathsFull, "/home/ann/src/gc/main.go", "/home/ann/src/gc/main.go"},
+		{pathsHome, "/home/ann/src/gc/main.go", "~/src/gc/main.go"},
+		{pathsHome, "/home/ann", "~"},
+		{pathsHome, "/home/annex/x.go", "/home/annex/x.go"},
+		{pathsHome, "/etc/hosts", "/etc/hosts"},
+		{pathsRelative, "/home/ann/src/gc/editor/editor.go", "editor/editor.go"},
+		{pathsRelative, "/home/ann/notes.md", "~/notes.md"},
+		{pathsRelative, "/tmp/x.go", "/tmp/x.go"},
+	}
+	for _, tc := range cases {
+		if got := formatPath(tc.mode, tc.p, root, home); got != tc.want {
+			t.Fatalf("%s %q = %q, want %q", pathModeName(tc.mode), tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestPathsOptionChangesBufferLabel(t *testing.T) {
+	root := t.TempDir()
+	app := &appState{openRoot: root}
+	app.initBuffers(editor.NewEditor(""))
+	app.currentPath = root + "/sub/a.go"
+	app.buffers[0].path = app.currentPath
+	if got := bufferLabel(app); !strings.Contains(got, "[a.go]") {
+		t.Fatalf("default label = %q", got)
+	}
+	if msg, err := applyOption(app, "paths=rel"); err != nil || msg != "paths=relative" {
+		t.Fatalf("paths=rel: %q %v", msg, err)
+	}
+	if got := bufferLabel(app); !strings.Contains(got, "[sub/a.go]") {
+		t.Fatalf("relative label = %q", got)
+	}
+	if _, err := applyOption(app, "paths=short"); err == nil {
+		t.Fatalf("unknown paths mode should fail")
+	}
+}
+
+func TestCurlineRejectsTheSelectionColor(t *testing.T) {
+	app := appState{}
+	if isSelectionColor(defaultCurLineColor) {
+		t.Fatalf("the default caret line color must differ from the selection color")
+	}
+	for _, c := range []string{"darkslateblue", "#483d8b"} {
+		if _, err := applyOption(&app, "curline="+c); err == nil {
+			t.Fatalf("curline=%s would hide selections on the caret line", c)
+		}
+	}
+	if app.curLineColor != "" {
+		t.Fatalf("a rejected color must not be applied: %q", app.curLineColor)
+	}
+}
+
+func TestBlinkOptionAndCaretPhase(t *testing.T) {
+	app := appState{}
+	t0 := time.Now()
+	app.blinkAt = t0
+	if !caretBlinkOn(&app, t0.Add(900*time.Millisecond)) {
+		t.Fatalf("without a period the terminal blinks; gc always shows the caret")
+	}
+
+	if got, err := applyOption(&app, "blink=1000"); err != nil || got != "blink=1000" {
+		t.Fatalf("blink=1000: %q %v", got, err)
+	}
+	for _, tc := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{600 * time.Millisecond, true},
+		{700 * time.Millisecond, false},
+		{999 * time.Millisecond, false},
+		{1100 * time.Millisecond, true},
+	} {
+		if got := caretBlinkOn(&app, t0.Add(tc.at)); got != tc.want {
+			t.Fatalf("caret at %v = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+	if d := nextBlinkChange(&app, t0.Add(600*time.Millisecond)); d != 50*time.Millisecond {
+		t.Fatalf("next change = %v, want 50ms", d)
+	}
+
+	if got, err := applyOption(&app, "blink=off"); err != nil || got != "blink=off" {
+		t.Fatalf("blink=off: %q %v", got, err)
+	}
+	if !caretBlinkOn(&app, t0.Add(700*time.Millisecond)) {
+		t.Fatalf("blink=off must keep the caret on")
+	}
+	if _, err := applyOption(&app, "blink=5"); err == nil {
+		t.Fatalf("a 5ms period should be rejected")
+	}
+}
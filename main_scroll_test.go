@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"gc/editor"
+)
 
 func TestEnsureCaretVisibleScrollsDown(t *testing.T) {
 	var app appState
@@ -78,3 +83,33 @@ func TestEnsureCaretVisibleCaretBeyondEnd(t *testing.T) {
 		t.Fatalf("caret beyond end should clamp to max start, want %d got %d", want, app.scrollLine)
 	}
 }
+
+func TestScrollViewLeavesCaretUntilNextEdit(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor(strings.Repeat("line\n", 50)))
+	app.visibleLines = 10
+	follow := func() {
+		followCaret(&app, editor.CaretLineAt(app.ed.Lines(), app.ed.Caret), len(app.ed.Lines()), app.visibleLines)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown, mods: modCtrl})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown, mods: modCtrl})
+	follow()
+	if app.scrollLine != 11 || app.ed.Caret != 0 {
+		t.Fatalf("scroll = %d caret = %d, want 11 and 0", app.scrollLine, app.ed.Caret)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown, mods: modCtrl})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown, mods: modCtrl})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown, mods: modCtrl})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyPageDown, mods: modCtrl})
+	if app.scrollLine != 41 {
+		t.Fatalf("scrolling should clamp at the last page, got %d", app.scrollLine)
+	}
+
+	// Typing releases the pin and brings the caret back into view.
+	handleTextEvent(&app, "x", 0)
+	follow()
+	if app.scrollLine != 0 {
+		t.Fatalf("editing should scroll back to the caret, got %d", app.scrollLine)
+	}
+}
@@ -3,12 +3,18 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"gc/editor"
+
+	"github.com/gdamore/tcell/v2"
 )
 
 func TestDetectSyntaxByPath(t *testing.T) {
@@ -296,7 +302,7 @@ func TestSelectorCompletionPopupAndApply(t *testing.T) {
 
 	oldComplete := completeGoCompletions
 	defer func() { completeGoCompletions = oldComplete }()
-	completeGoCompletions = func(_ *appState, _ string, _ string, _ int, _ int) ([]completionItem, error) {
+	completeGoCompletions = func(_ *goplsClient, _ string, _ string, _ int, _ int) ([]completionItem, error) {
 		return []completionItem{
 			{Label: "Print", Insert: "Print", Detail: "func Print(a ...any) (n int, err error)"},
 			{Label: "Println", Insert: "Println", Detail: "func Println(a ...any) (n int, err error)"},
@@ -470,6 +476,138 @@ func TestWrapPopupTextAndSingleLine(t *testing.T) {
 	}
 }
 
+func TestPopupMatchesInWrappedLinesAndRevealScroll(t *testing.T) {
+	lines := wrapPopupText("Reader reads bytes.\n\nUse a bufio.Reader to read lines; a Reader may return EOF.", 20)
+	got := popupMatches(lines, []rune("reader"))
+	if len(got) != 3 {
+		t.Fatalf("matches=%v in %q, want 3", got, lines)
+	}
+	for _, m := range got {
+		if s := string([]rune(lines[m.line])[m.col : m.col+6]); !strings.EqualFold(s, "reader") {
+			t.Fatalf("match %v covers %q", m, s)
+		}
+	}
+	if got[0] != (popupMatch{0, 0}) || got[2].line <= got[1].line {
+		t.Fatalf("matches should be in reading order: %v", got)
+	}
+	if popupMatches(lines, []rune("missing")) != nil {
+		t.Fatal("no matches expected")
+	}
+
+	cases := []struct{ scroll, line, rows, want int }{
+		{0, 3, 5, 0}, // already visible
+		{0, 9, 5, 5}, // below: last row
+		{8, 2, 5, 2}, // above: first row
+		{4, 8, 5, 4}, // bottom edge stays
+		{4, 9, 5, 5}, // one past the bottom
+		{0, 7, 0, 7}, // no rows
+	}
+	for _, tc := range cases {
+		if got := popupRevealScroll(tc.scroll, tc.line, tc.rows); got != tc.want {
+			t.Fatalf("popupRevealScroll(%d, %d, %d)=%d, want %d", tc.scroll, tc.line, tc.rows, got, tc.want)
+		}
+	}
+}
+
+func TestSymbolInfoPopupSearchScrollsToMatch(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(80, 20)
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("package main\n"))
+	var doc []string
+	for i := range 30 {
+		doc = append(doc, fmt.Sprintf("line %d of the hover text", i))
+	}
+	doc = append(doc, "the Needle is here", "and a second needle")
+	app.symbolInfoPopup = strings.Join(doc, "\n")
+	drawTUI(s, &app)
+
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl))
+	if !app.symbolInfoSearch {
+		t.Fatal("Ctrl+F should start the popup search")
+	}
+	for _, r := range "needle" {
+		handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	if got := app.ed.String(); got != "package main\n" {
+		t.Fatalf("popup search text should not reach the buffer: %q", got)
+	}
+	drawTUI(s, &app)
+	if app.symbolInfoScroll != 30-11 {
+		t.Fatalf("scroll=%d, want the first match on the last visible row (19)", app.symbolInfoScroll)
+	}
+	var rows []string
+	for y := range 20 {
+		rows = append(rows, screenRowText(s, y, 80))
+	}
+	if screen := strings.Join(rows, "\n"); !strings.Contains(screen, "/needle  1/2") {
+		t.Fatalf("footer should count matches:\n%s", screen)
+	}
+
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyTab, 0, 0))
+	drawTUI(s, &app)
+	if app.symbolInfoMatch != 1 || app.symbolInfoScroll != 31-11 {
+		t.Fatalf("Tab should go to the second match: match=%d scroll=%d", app.symbolInfoMatch, app.symbolInfoScroll)
+	}
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	if len(app.symbolInfoQuery) != 0 || app.symbolInfoPopup == "" {
+		t.Fatal("first Esc should clear the search and keep the popup")
+	}
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	if app.symbolInfoPopup != "" {
+		t.Fatal("second Esc should close the popup")
+	}
+}
+
+func TestSymbolInfoPopupSearchHighlightsAfterTabs(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(80, 20)
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("package main\n"))
+	app.symbolInfoPopup = "\tneedle()"
+	drawTUI(s, &app)
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl))
+	for _, r := range "needle" {
+		handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	app.symbolInfoMatch = 5
+	drawTUI(s, &app)
+	if app.symbolInfoMatch != 5 {
+		t.Fatalf("drawing should not change the match index, got %d", app.symbolInfoMatch)
+	}
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyTab, 0, 0))
+	if app.symbolInfoMatch != 0 {
+		t.Fatalf("Tab should wrap the match index, got %d", app.symbolInfoMatch)
+	}
+
+	// The popup text starts at column 5 of row 4; the tab takes tabWidth cells.
+	drawTUI(s, &app)
+	underlined := func(st tcell.Style) bool {
+		_, _, attrs := st.Decompose()
+		return attrs&tcell.AttrUnderline != 0
+	}
+	for c, want := range "needle" {
+		str, st, _ := s.Get(5+tabWidth+c, 4)
+		if str != string(want) || !underlined(st) {
+			t.Fatalf("cell %d = %q underline=%v, want underlined %q", c, str, underlined(st), want)
+		}
+	}
+	if _, st, _ := s.Get(5+tabWidth+len("needle"), 4); underlined(st) {
+		t.Fatal("the highlight should stop after the match")
+	}
+}
+
 func TestFormatHoverMarkdown(t *testing.T) {
 	in := "# Signature\n\n- `Println` writes output\nSee [fmt](https://pkg.go.dev/fmt).\n\n```go\nfmt.Println(x)\n```"
 	got := formatHoverMarkdown(in)
@@ -576,11 +714,11 @@ func TestRunCurrentPackageOpensBufferAndStreamsOutput(t *testing.T) {
 	app.currentPath = path
 	app.buffers[0].path = path
 
-	oldRun := startGoRun
-	defer func() { startGoRun = oldRun }()
-	startGoRun = func(runDir string, onOut func(string), onDone func(error)) error {
-		if runDir != dir {
-			t.Fatalf("runDir=%q, want %q", runDir, dir)
+	oldRun := startRun
+	defer func() { startRun = oldRun }()
+	startRun = func(rc runCommand, onOut func(string), onDone func(error)) error {
+		if rc.dir != dir {
+			t.Fatalf("runDir=%q, want %q", rc.dir, dir)
 		}
 		onOut("hello\n")
 		onDone(errors.New("boom"))
@@ -605,6 +743,144 @@ func TestRunCurrentPackageOpensBufferAndStreamsOutput(t *testing.T) {
 	}
 }
 
+func TestRunUsesProjectRunConfig(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "cmd", "tool")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cfg := "# project run command\ncommand = go test -run 'Test A' ./...\n"
+	if err := os.WriteFile(filepath.Join(root, runConfigName), []byte(cfg), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("package main\n"))
+	app.currentPath = filepath.Join(dir, "main.go")
+
+	var got runCommand
+	oldRun := startRun
+	defer func() { startRun = oldRun }()
+	startRun = func(rc runCommand, onOut func(string), onDone func(error)) error {
+		got = rc
+		return nil
+	}
+	if err := runCurrentPackage(&app); err != nil {
+		t.Fatalf("runCurrentPackage err: %v", err)
+	}
+	want := []string{"go", "test", "-run", "Test A", "./..."}
+	if got.dir != dir || fmt.Sprint(got.argv) != fmt.Sprint(want) {
+		t.Fatalf("started %q in %q, want %q in %q", got.argv, got.dir, want, dir)
+	}
+	if header := "$ (cd " + dir + " && go test -run 'Test A' ./...)"; !strings.HasPrefix(app.ed.String(), header) {
+		t.Fatalf("run header %q, want %q", app.ed.String(), header)
+	}
+
+	argv, err := splitCommandLine(`make "A=\"x y\"" b\ c ''`)
+	if err != nil || fmt.Sprint(len(argv), argv) != `4 [make A="x y" b c ]` {
+		t.Fatalf("splitCommandLine: %q %v", argv, err)
+	}
+	if _, err := splitCommandLine(`go run "x`); err == nil {
+		t.Fatalf("unterminated quote should fail")
+	}
+	if _, err := parseRunConfig(strings.NewReader("cmd = go run .\n")); err == nil {
+		t.Fatalf("unknown setting should fail")
+	}
+	if rc, err := loadRunCommand(t.TempDir(), ""); err != nil || fmt.Sprint(rc.argv) != "[go run .]" {
+		t.Fatalf("default run command: %q %v", rc.argv, err)
+	}
+}
+
+func TestRunConfigSetsEnvAndDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "testdata", "work"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cfg := "command = go test ./...\nenv = GOFLAGS=-race -count=1\nenv = CGO_ENABLED=0\ndir = testdata/work\n"
+	if err := os.WriteFile(filepath.Join(root, runConfigName), []byte(cfg), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	app := appState{openRoot: root}
+	app.initBuffers(editor.NewEditor("package main\n"))
+	app.currentPath = filepath.Join(root, "main.go")
+
+	var got runCommand
+	oldRun := startRun
+	defer func() { startRun = oldRun }()
+	startRun = func(rc runCommand, onOut func(string), onDone func(error)) error {
+		got = rc
+		return nil
+	}
+	if err := runCurrentPackage(&app); err != nil {
+		t.Fatalf("runCurrentPackage err: %v", err)
+	}
+	work := filepath.Join(root, "testdata", "work")
+	if got.dir != work || fmt.Sprint(got.env) != "[GOFLAGS=-race -count=1 CGO_ENABLED=0]" {
+		t.Fatalf("started in %q with env %q", got.dir, got.env)
+	}
+	header := "$ (cd " + work + " && GOFLAGS='-race -count=1' CGO_ENABLED=0 go test ./...)"
+	if !strings.HasPrefix(app.ed.String(), header) {
+		t.Fatalf("run header %q, want %q", app.ed.String(), header)
+	}
+
+	cmd := got.execCmd()
+	if cmd.Dir != work || len(cmd.Env) < 2 || cmd.Env[len(cmd.Env)-1] != "CGO_ENABLED=0" {
+		t.Fatalf("exec.Cmd dir %q, env tail %q", cmd.Dir, cmd.Env[max(0, len(cmd.Env)-2):])
+	}
+	if cmd := (runCommand{dir: work, argv: defaultRunArgv}).execCmd(); cmd.Env != nil {
+		t.Fatalf("no env settings should inherit gc's environment, got %d entries", len(cmd.Env))
+	}
+
+	for _, bad := range []string{"env = GOFLAGS\n", "dir = missing\n"} {
+		if err := os.WriteFile(filepath.Join(root, runConfigName), []byte(bad), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := loadRunCommand(root, root); err == nil {
+			t.Fatalf("%q should be rejected", bad)
+		}
+	}
+}
+
+type exitCodeError int
+
+func (e exitCodeError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitCodeError) ExitCode() int { return int(e) }
+
+func TestRunFooterShowsExitCodeInErrorStyle(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("package main\n"))
+	app.currentPath = filepath.Join(t.TempDir(), "p.go")
+
+	oldRun := startRun
+	defer func() { startRun = oldRun }()
+	startRun = func(rc runCommand, onOut func(string), onDone func(error)) error {
+		onOut("[stderr] panic: bad\n")
+		onDone(fmt.Errorf("wait: %w", exitCodeError(2)))
+		return nil
+	}
+	if err := runCurrentPackage(&app); err != nil {
+		t.Fatalf("runCurrentPackage err: %v", err)
+	}
+	got := app.ed.String()
+	if !strings.Contains(got, "[stderr] panic: bad\n") || !strings.HasSuffix(got, "\n[exit] code=2\n") {
+		t.Fatalf("run buffer: %q", got)
+	}
+
+	lines, styles, _, _ := renderData(&app)
+	for i, ln := range lines {
+		st := lineStylesAt(styles, i)
+		failed := strings.HasPrefix(ln, "[stderr]") || strings.HasPrefix(ln, "[exit]")
+		if failed && (len(st) == 0 || st[0] != styleError) || !failed && len(st) > 0 && st[0] == styleError {
+			t.Fatalf("line %d %q styled %v", i, ln, st)
+		}
+	}
+	if runOutputStyles([]string{"$ go run .", "[exit] ok"}) != nil {
+		t.Fatalf("a clean run should not be styled")
+	}
+	if got := runExitFooter(errors.New("signal: killed")); got != "[exit] signal: killed" {
+		t.Fatalf("footer without exit code: %q", got)
+	}
+}
+
 func TestRunCurrentPackageUsesCwdFallback(t *testing.T) {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -615,11 +891,11 @@ func TestRunCurrentPackageUsesCwdFallback(t *testing.T) {
 	app.currentPath = ""
 	app.openRoot = ""
 
-	oldRun := startGoRun
-	defer func() { startGoRun = oldRun }()
-	startGoRun = func(runDir string, onOut func(string), onDone func(error)) error {
-		if runDir != cwd {
-			t.Fatalf("runDir=%q, want cwd %q", runDir, cwd)
+	oldRun := startRun
+	defer func() { startRun = oldRun }()
+	startRun = func(rc runCommand, onOut func(string), onDone func(error)) error {
+		if rc.dir != cwd {
+			t.Fatalf("runDir=%q, want cwd %q", rc.dir, cwd)
 		}
 		if onDone != nil {
 			onDone(nil)
@@ -634,3 +910,924 @@ func TestRunCurrentPackageUsesCwdFallback(t *testing.T) {
 		t.Fatalf("run buffer should include ok footer, got %q", app.ed.String())
 	}
 }
+
+func TestAsyncSelectorCompletionDropsSupersededResults(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.\n}\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "a.go"
+	app.ed.Caret = strings.Index(src, "fmt.") + len("fmt.")
+
+	posted := make(chan any, 4)
+	app.requestInterrupt = func(v any) { posted <- v }
+	oldComplete := completeGoCompletions
+	defer func() { completeGoCompletions = oldComplete }()
+	completeGoCompletions = func(_ *goplsClient, _ string, _ string, _ int, _ int) ([]completionItem, error) {
+		return []completionItem{{Label: "Println", Insert: "Println"}}, nil
+	}
+
+	if !tryManualCompletion(&app) {
+		t.Fatalf("selector completion should start a request")
+	}
+	first := app.completionReq.token
+	if app.completionPopup.active {
+		t.Fatalf("popup should wait for the async result")
+	}
+	if !tryManualCompletion(&app) {
+		t.Fatalf("second request should start")
+	}
+	stale := completionResultInterrupt{Token: first, Items: []completionItem{{Label: "Stale", Insert: "Stale"}}}
+	applyCompletionResult(&app, stale)
+	if app.completionPopup.active {
+		t.Fatalf("superseded result should be dropped")
+	}
+
+	select {
+	case v := <-posted:
+		res, ok := v.(completionResultInterrupt)
+		if !ok || res.Token == first {
+			t.Fatalf("only the latest request should reach gopls, got %#v", v)
+		}
+		applyCompletionResult(&app, res)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for completion result")
+	}
+	if !app.completionPopup.active || app.completionPopup.items[0].Label != "Println" {
+		t.Fatalf("latest result should open the popup, got %+v", app.completionPopup)
+	}
+	select {
+	case v := <-posted:
+		t.Fatalf("debounced request should not post, got %#v", v)
+	case <-time.After(2 * completionDebounce):
+	}
+}
+
+func TestAsyncCompletionResultIgnoredAfterEdit(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.\n}\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "a.go"
+	app.ed.Caret = strings.Index(src, "fmt.") + len("fmt.")
+	app.requestInterrupt = func(any) {}
+	oldComplete := completeGoCompletions
+	defer func() { completeGoCompletions = oldComplete }()
+	completeGoCompletions = func(_ *goplsClient, _ string, _ string, _ int, _ int) ([]completionItem, error) {
+		return nil, nil
+	}
+
+	tryManualCompletion(&app)
+	token := app.completionReq.token
+	handleTextEvent(&app, "P", 0)
+	applyCompletionResult(&app, completionResultInterrupt{Token: token, Items: []completionItem{{Label: "Println"}}})
+	if app.completionPopup.active {
+		t.Fatalf("result for an edited buffer should be dropped")
+	}
+}
+
+func TestAutoCompleteAfterSelectorDot(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt\n}\n"
+	newApp := func(posted chan any) *appState {
+		app := &appState{autoComplete: true}
+		app.initBuffers(editor.NewEditor(src))
+		app.currentPath = "a.go"
+		app.ed.Caret = strings.Index(src, "\tfmt") + len("\tfmt")
+		app.requestInterrupt = func(v any) { posted <- v }
+		return app
+	}
+	oldComplete := completeGoCompletions
+	defer func() { completeGoCompletions = oldComplete }()
+	completeGoCompletions = func(_ *goplsClient, _ string, _ string, _ int, _ int) ([]completionItem, error) {
+		return []completionItem{{Label: "Println", Insert: "Println"}}, nil
+	}
+
+	posted := make(chan any, 2)
+	app := newApp(posted)
+	handleTextEvent(app, ".", 0)
+	if app.completionReq.prefix != "fmt." || app.completionReq.token != app.completionSeq.Load() {
+		t.Fatalf("typing fmt. should arm a completion request, got %+v", app.completionReq)
+	}
+	select {
+	case v := <-posted:
+		applyCompletionResult(app, v.(completionResultInterrupt))
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for auto completion")
+	}
+	if !app.completionPopup.active {
+		t.Fatalf("auto completion should open the popup")
+	}
+
+	posted = make(chan any, 2)
+	app = newApp(posted)
+	handleTextEvent(app, ".", 0)
+	handleTextEvent(app, "P", 0)
+	select {
+	case v := <-posted:
+		t.Fatalf("typing past the dot should cancel the request, got %#v", v)
+	case <-time.After(2 * completionDebounce):
+	}
+
+	posted = make(chan any, 2)
+	app = newApp(posted)
+	app.autoComplete = false
+	handleTextEvent(app, ".", 0)
+	if app.completionReq.token != 0 {
+		t.Fatalf("auto completion is opt-in")
+	}
+}
+
+func TestParseSignatureHelpActiveParameter(t *testing.T) {
+	raw := json.RawMessage(`{"signatures":[{"label":"func Printf(format string, a ...any) (n int, err error)","documentation":{"kind":"markdown","value":"Printf formats."},"parameters":[{"label":"format string"},{"label":[27,35]}]}],"activeSignature":0,"activeParameter":1}`)
+	help, ok := parseSignatureHelp(raw)
+	if !ok {
+		t.Fatalf("expected signature help to parse")
+	}
+	if help.Active != 1 || help.Doc != "Printf formats." {
+		t.Fatalf("unexpected help %+v", help)
+	}
+	start, end, ok := help.activeRange()
+	if !ok || string([]rune(help.Label)[start:end]) != "a ...any" {
+		t.Fatalf("active range = %d..%d (%v), want a ...any", start, end, ok)
+	}
+	if got := help.Params[0]; string([]rune(help.Label)[got[0]:got[1]]) != "format string" {
+		t.Fatalf("string parameter label resolved to %v", got)
+	}
+	if _, ok := parseSignatureHelp(json.RawMessage(`null`)); ok {
+		t.Fatalf("null result should report no signature")
+	}
+}
+
+func TestSignatureHelpPopupShowsActiveParameter(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf\n}\n"
+	app := &appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "a.go"
+	app.buffers[0].path = "a.go"
+	app.ed.Caret = strings.Index(src, "Printf") + len("Printf")
+	oldHelp := goSignatureHelp
+	defer func() { goSignatureHelp = oldHelp }()
+	goSignatureHelp = func(_ *goplsClient, _ string, content string, _ int, _ int) (signatureHelp, bool, error) {
+		active := strings.Count(content, ",")
+		help, ok := parseSignatureHelp(json.RawMessage(`{"signatures":[{"label":"func Printf(format string, a ...any)","parameters":[{"label":"format string"},{"label":"a ...any"}]}],"activeParameter":` + strconv.Itoa(active) + `}`))
+		return help, ok, nil
+	}
+
+	handleTextEvent(app, "(", 0)
+	if !app.sigHelpActive || app.sigHelp.Active != 0 {
+		t.Fatalf("typing ( should open signature help on the first parameter, got %+v", app.sigHelp)
+	}
+	handleTextEvent(app, `"x"`, 0)
+	handleTextEvent(app, ",", 0)
+	if app.sigHelp.Active != 1 || app.lastEvent != "Signature: a ...any" {
+		t.Fatalf("typing , should advance the active parameter, got %d (%q)", app.sigHelp.Active, app.lastEvent)
+	}
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(100, 24)
+	drawTUI(s, app)
+	x, y := 100-detailBoxWidth(100)-1, 1
+	var row strings.Builder
+	highlighted := ""
+	for i := range len(app.sigHelp.Label) {
+		str, st, _ := s.Get(x+2+i, y+2)
+		row.WriteString(str)
+		if _, bg, _ := st.Decompose(); bg == tcell.ColorDarkCyan {
+			highlighted += str
+		}
+	}
+	if row.String() != app.sigHelp.Label || highlighted != "a ...any" {
+		t.Fatalf("popup row %q highlighted %q", row.String(), highlighted)
+	}
+
+	handleTextEvent(app, ")", 0)
+	if app.sigHelpActive {
+		t.Fatalf("typing ) should dismiss signature help")
+	}
+	handleTextEvent(app, "(", 0)
+	handleKeyEvent(app, keyEvent{down: true, key: keyEscape})
+	if app.sigHelpActive || app.cmdPrefixActive {
+		t.Fatalf("Esc should dismiss signature help without arming the prefix")
+	}
+}
+
+func TestDiagnosticsSummaryListsErrorLines(t *testing.T) {
+	app := &appState{syntaxCheck: newGoSyntaxChecker()}
+	app.initBuffers(editor.NewEditor("package a\n\nfunc f() {\n\tx :=\n}\n"))
+	app.currentPath = "/src/a.go"
+	app.buffers[0].path = "/src/a.go"
+	app.addBuffer()
+	app.ed.SetRunes([]rune("package b\n\nvar = 1\n"))
+	app.currentPath = "/src/b.go"
+	app.buffers[1].path = "/src/b.go"
+	app.addBuffer()
+	app.ed.SetRunes([]rune("not go at all {\n"))
+	app.buffers[2].path = "/src/notes.txt"
+	app.currentPath = "/src/notes.txt"
+
+	diags := collectDiagnostics(app)
+	if len(diags) < 2 || diags[0].path != "/src/a.go" || diags[len(diags)-1].path != "/src/b.go" {
+		t.Fatalf("expected diagnostics for both Go buffers only, got %+v", diags)
+	}
+
+	handleKeyEvent(app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(app, keyEvent{down: true, key: keyD})
+	want := fmt.Sprintf("[diagnostics] %d", len(diags))
+	if app.currentPath != want || !app.buffers[app.bufIdx].readOnly {
+		t.Fatalf("summary buffer title=%q readOnly=%v, want %q read-only", app.currentPath, app.buffers[app.bufIdx].readOnly, want)
+	}
+	lines := app.ed.Lines()
+	for i, d := range diags {
+		prefix := fmt.Sprintf("%s:%d: ", d.path, d.line+1)
+		if !strings.HasPrefix(lines[i], prefix) || d.msg == "" || !strings.HasSuffix(lines[i], d.msg) {
+			t.Fatalf("summary line %d = %q, want %q + message %q", i, lines[i], prefix, d.msg)
+		}
+	}
+	n := len(app.buffers)
+
+	// Ctrl+L on a summary line jumps to that buffer and line.
+	app.ed.Caret = len([]rune(lines[0])) + 1
+	handleKeyEvent(app, keyEvent{down: true, key: keyL, mods: modCtrl})
+	if app.currentPath != "/src/b.go" || editor.CaretLineAt(app.ed.Lines(), app.ed.Caret) != diags[1].line {
+		t.Fatalf("Ctrl+L should jump to %s:%d, got %q caret line %d", diags[1].path, diags[1].line+1, app.currentPath, editor.CaretLineAt(app.ed.Lines(), app.ed.Caret))
+	}
+
+	// A second Esc+d refreshes the existing summary buffer.
+	handleKeyEvent(app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(app, keyEvent{down: true, key: keyD})
+	if len(app.buffers) != n || app.currentPath != want {
+		t.Fatalf("Esc+d should reuse the summary buffer, got %d buffers (%q)", len(app.buffers), app.currentPath)
+	}
+}
+
+func TestSplitPathLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		path string
+		line int
+		ok   bool
+	}{
+		{"a.go:12: expected ';'", "a.go", 12, true},
+		{"/src/a.go:3:7: msg", "/src/a.go", 3, true},
+		{"a.go", "", 0, false},
+		{"a.go:x: msg", "", 0, false},
+		{"a.go:0: msg", "", 0, false},
+	}
+	for _, tc := range tests {
+		path, line, ok := splitPathLine(tc.in)
+		if path != tc.path || line != tc.line || ok != tc.ok {
+			t.Fatalf("splitPathLine(%q) = %q, %d, %v", tc.in, path, line, ok)
+		}
+	}
+}
+
+func TestRenumberOrderedListFixesScrambledNumbers(t *testing.T) {
+	in := []string{
+		"3. first",
+		"1. second",
+		"   continuation text",
+		"   2) nested a",
+		"   9) nested b",
+		"7. third",
+		"- bullet stays",
+		"not a list 4. item",
+	}
+	got, n := renumberOrderedList(in)
+	want := []string{
+		"1. first",
+		"2. second",
+		"   continuation text",
+		"   1) nested a",
+		"   2) nested b",
+		"3. third",
+		"- bullet stays",
+		"not a list 4. item",
+	}
+	if n != 5 || !slices.Equal(got, want) {
+		t.Fatalf("renumber changed %d:\n%q\nwant\n%q", n, got, want)
+	}
+}
+
+func TestEscShiftMRenumbersListAroundCaret(t *testing.T) {
+	src := "# Steps\n\n2. alpha\n2. beta\n5. gamma\n\n9. other list\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "notes.md"
+	app.ed.Caret = strings.Index(src, "beta")
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyM, mods: modShift})
+	if got := app.ed.String(); got != "# Steps\n\n1. alpha\n2. beta\n3. gamma\n\n9. other list\n" {
+		t.Fatalf("renumbered buffer = %q", got)
+	}
+	if !app.buffers[0].dirty || app.lastEvent != "Renumbered 2 list items" {
+		t.Fatalf("dirty=%v status=%q", app.buffers[0].dirty, app.lastEvent)
+	}
+	app.ed.Undo()
+	if app.ed.String() != src {
+		t.Fatalf("renumbering should be one undo step")
+	}
+
+	app.ed.Caret = 0
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyM, mods: modShift})
+	if app.lastEvent != "No ordered list at caret" {
+		t.Fatalf("status on a heading = %q", app.lastEvent)
+	}
+}
+
+func TestMarkdownPreviewRendersIntoReadOnlyBuffer(t *testing.T) {
+	src := "# Getting started\n\n* install it\n- read [the docs](docs/intro.md)\n\n```go\nfmt.Println(1)\n```\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "notes.md"
+	app.buffers[0].path = "notes.md"
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyP, mods: modShift})
+	if len(app.buffers) != 2 || app.bufIdx != 1 {
+		t.Fatalf("expected a preview buffer, got %d buffers (active %d)", len(app.buffers), app.bufIdx)
+	}
+	want := "GETTING STARTED\n───────────────\n\n• install it\n• read the docs (docs/intro.md)\n\nCode (go):\n    fmt.Println(1)\n"
+	if got := app.ed.String(); got != want {
+		t.Fatalf("preview =\n%q\nwant\n%q", got, want)
+	}
+	slot := app.buffers[1]
+	if slot.path != "[preview notes.md]" || !slot.readOnly || slot.dirty {
+		t.Fatalf("preview slot path=%q readOnly=%v dirty=%v", slot.path, slot.readOnly, slot.dirty)
+	}
+
+	// Re-running from the preview refreshes it from the edited source.
+	app.buffers[0].ed.SetRunes([]rune("## Changed\n"))
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyP, mods: modShift})
+	if len(app.buffers) != 2 || app.bufIdx != 1 || app.ed.String() != "CHANGED\n───────\n" {
+		t.Fatalf("refresh: %d buffers, active %d, text %q", len(app.buffers), app.bufIdx, app.ed.String())
+	}
+
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	app.buffers[0].path = "main.go"
+	app.currentPath = "main.go"
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyP, mods: modShift})
+	if app.bufIdx != 0 || app.lastEvent != "Preview needs a Markdown buffer" {
+		t.Fatalf("non-Markdown buffer: active %d status %q", app.bufIdx, app.lastEvent)
+	}
+}
+
+func TestGoFoldRangesNestedFunctions(t *testing.T) {
+	src := strings.Join([]string{
+		"package p", // 0
+		"",
+		"func a(x int) int {", // 2
+		"\tif x > 0 {",        // 3
+		"\t\treturn 1 // }",
+		"\t} else {", // 5
+		"\t\ts := \"{\"",
+		"\t\t_ = s",
+		"\t}",
+		"\treturn 0", // 9
+		"}",
+		"",
+		"func b() {",                   // 12
+		"\tfor {",                      // 13
+		"\t\tgo func() { _ = []int{1,", // 14
+		"\t\t\t2} }()",
+		"\t}",
+		"}", // 17
+	}, "\n")
+	lines := strings.Split(src, "\n")
+	got := goFoldRanges(lines)
+	want := [][2]int{{2, 10}, {3, 5}, {5, 8}, {12, 17}, {13, 16}, {14, 15}}
+	if len(got) != len(want) {
+		t.Fatalf("ranges = %+v", got)
+	}
+	for i, r := range got {
+		if r.start != want[i][0] || r.end != want[i][1] {
+			t.Fatalf("range %d = %d-%d, want %v (%+v)", i, r.start, r.end, want[i], got)
+		}
+		if rs := []rune(src); rs[r.anchor] != '{' || editor.CaretLineAt(lines, r.anchor) != r.start {
+			t.Fatalf("range %d anchor %d is not its opening brace", i, r.anchor)
+		}
+	}
+	if got[1].tail != "} else {" || got[0].tail != "}" || got[5].tail != "}()" {
+		t.Fatalf("tails = %q %q %q", got[0].tail, got[1].tail, got[5].tail)
+	}
+}
+
+func TestFoldHidesBlockAndCaretOpensIt(t *testing.T) {
+	src := "package p\n\nfunc a() {\n\tx := 1\n\t_ = x\n}\n\nvar z = 2\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "a.go"
+	app.buffers[0].path = "a.go"
+	app.ed.Caret = strings.Index(src, "x :=")
+	if err := app.RunCommand(CmdFold, ""); err != nil {
+		t.Fatalf("fold: %v", err)
+	}
+	if app.ed.Caret != strings.Index(src, "{") {
+		t.Fatalf("folding should move the caret to the brace, got %d", app.ed.Caret)
+	}
+	lines := app.ed.Lines()
+	v := newFoldView(closedFolds(&app, 0, syntaxGo, lines), len(lines))
+	if v.len() != len(lines)-3 || v.lineAt(3) != 6 || v.summary[2] != " … }" {
+		t.Fatalf("view rows=%v summary=%v", v.rows, v.summary)
+	}
+
+	// Down steps over the folded lines.
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	if line := editor.CaretLineAt(lines, app.ed.Caret); line != 6 {
+		t.Fatalf("Down from a fold should land on line 6, got %d", line)
+	}
+	// Editing above the block keeps the fold; a caret inside opens it.
+	app.ed.Caret = 0
+	app.ed.InsertText("// doc\n")
+	app.touchActiveBufferText()
+	lines = app.ed.Lines()
+	if closed := closedFolds(&app, 0, syntaxGo, lines); len(closed) != 1 || closed[0].start != 3 {
+		t.Fatalf("fold should follow the edit: %+v", closed)
+	}
+	// The block scan is cached until the text changes again.
+	slot := &app.buffers[0]
+	anchor, _ := app.ed.MarkPos(slot.folds[0])
+	scanned := slot.foldAnchors[anchor]
+	slot.foldAnchors[anchor] = foldRange{start: 99, end: 99, anchor: anchor}
+	if closed := closedFolds(&app, 0, syntaxGo, lines); len(closed) != 1 || closed[0].start != 99 {
+		t.Fatalf("fold blocks should come from the cache: %+v", closed)
+	}
+	slot.foldAnchors[anchor] = scanned
+	app.ed.Caret = strings.Index(app.ed.String(), "_ = x")
+	if closed := closedFolds(&app, 0, syntaxGo, lines); len(closed) != 0 || len(app.buffers[0].folds) != 0 {
+		t.Fatalf("caret inside should open the fold: %+v", closed)
+	}
+
+	app.ed.Caret = 0
+	if err := app.RunCommand(CmdFold, ""); err == nil {
+		t.Fatalf("no block at line 0 should fail")
+	}
+}
+
+func TestMarkdownOutlineListsHeadingsAndJumps(t *testing.T) {
+	src := strings.Join([]string{
+		"# Title", // 0
+		"intro",
+		"## Install", // 2
+		"```sh",
+		"# not a heading",
+		"```",
+		"### From source", // 6
+		"make",
+		"",
+		"## Usage", // 9
+		"run it",
+	}, "\n")
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "doc.md"
+	app.buffers[0].path = "doc.md"
+	app.ed.Caret = strings.Index(src, "make")
+	app.RunCommand(CmdOutline, "")
+	if !app.outline.active {
+		t.Fatalf("outline should open: %s", app.lastEvent)
+	}
+	var got []string
+	for _, it := range app.outline.items {
+		got = append(got, outlineLine(it))
+	}
+	want := []string{"Title  :1", "  Install  :3", "    From source  :7", "  Usage  :10"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("outline = %q", got)
+	}
+	if app.outline.selected != 2 {
+		t.Fatalf("selected = %d, want the heading above the caret", app.outline.selected)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.outline.active || app.ed.Caret != strings.Index(src, "## Usage") {
+		t.Fatalf("Enter should jump to Usage, caret=%d", app.ed.Caret)
+	}
+
+	// Sections fold up to the next heading of the same level.
+	ranges := markdownFoldRanges(app.ed.Lines())
+	spans := make([][2]int, len(ranges))
+	for i, r := range ranges {
+		spans[i] = [2]int{r.start, r.end}
+	}
+	if !slices.Equal(spans, [][2]int{{0, 10}, {2, 7}, {6, 7}, {9, 10}}) {
+		t.Fatalf("sections = %v", spans)
+	}
+
+	app.currentPath, app.buffers[0].path = "a.c", "a.c"
+	if err := app.RunCommand(CmdOutline, ""); err == nil || app.outline.active {
+		t.Fatalf("outline outside Markdown and Go should fail")
+	}
+}
+
+func TestTabExpandsIferrSnippet(t *testing.T) {
+	src := "package p\n\nfunc f() error {\n\terr := g()\n\tiferr\n}\n"
+	app := appState{noGopls: true}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	app.ed.Caret = strings.Index(src, "iferr") + len("iferr")
+	handleKeyEvent(&app, keyEvent{down: true, key: keyTab})
+	want := "package p\n\nfunc f() error {\n\terr := g()\n\tif err != nil {\n\t\treturn err\n\t}\n}\n"
+	if got := app.ed.String(); got != want {
+		t.Fatalf("expanded:\n%s", got)
+	}
+	if app.ed.Caret != strings.Index(want, "err\n\t}") {
+		t.Fatalf("caret = %d, want inside the block before err", app.ed.Caret)
+	}
+
+	// A snippet name that is a selector or part of an expression is left to
+	// completion.
+	for _, line := range []string{"\tx.test", "\ts.main", "\tcases := test"} {
+		src := "package p\n\nfunc f() {\n" + line + "\n}\n"
+		app := appState{noGopls: true}
+		app.initBuffers(editor.NewEditor(src))
+		app.currentPath = "p.go"
+		app.buffers[0].path = "p.go"
+		app.ed.Caret = strings.Index(src, line) + len(line)
+		handleKeyEvent(&app, keyEvent{down: true, key: keyTab})
+		if got := app.ed.String(); !strings.Contains(got, line+"\n}") {
+			t.Fatalf("%q should not expand:\n%s", line, got)
+		}
+	}
+}
+
+func TestLoadSnippetsExtendsBuiltins(t *testing.T) {
+	src := "# mine\nsnippet ctx\n\tctx, cancel := context.WithCancel(${1:ctx})\n\n\tdefer cancel()$0\n\nsnippet iferr\n\tif err != nil {\n\t\tpanic(err)\n\t}\n"
+	sn, err := loadSnippets(strings.NewReader(src), defaultSnippets)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if sn["ctx"] != "ctx, cancel := context.WithCancel(${1:ctx})\n\ndefer cancel()$0" {
+		t.Fatalf("ctx = %q", sn["ctx"])
+	}
+	if sn["iferr"] != "if err != nil {\n\tpanic(err)\n}" || sn["main"] == "" {
+		t.Fatalf("iferr should be overridden and main kept: %q", sn["iferr"])
+	}
+	if defaultSnippets["iferr"] == sn["iferr"] {
+		t.Fatalf("loading must not change the built-ins")
+	}
+	text, caret, a, b := expandSnippetBody(sn["ctx"], "\t", "\t")
+	if text != "ctx, cancel := context.WithCancel(ctx)\n\n\tdefer cancel()" || caret != len([]rune(text)) {
+		t.Fatalf("text=%q caret=%d", text, caret)
+	}
+	if text[a:b] != "ctx" || a != strings.Index(text, "(ctx)")+1 {
+		t.Fatalf("placeholder = %d-%d", a, b)
+	}
+	if _, err := loadSnippets(strings.NewReader("\tbody\n"), nil); err == nil {
+		t.Fatalf("a body before any snippet line should fail")
+	}
+}
+
+func TestProseWordsSkipCodeAndURLs(t *testing.T) {
+	lines := []string{
+		"Teh `fmt.Printf` call",
+		"```go",
+		"x := wrnog()",
+		"```",
+		"See https://exmaple.com, it's v2 ok_now",
+	}
+	var got []string
+	for _, w := range proseWords(lines, 0, len(lines)-1) {
+		got = append(got, fmt.Sprintf("%d:%d-%d:%s", w.line, w.col, w.end, w.text))
+	}
+	want := []string{"0:0-3:Teh", "0:17-21:call", "4:0-3:See", "4:25-29:it's"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("proseWords = %v, want %v", got, want)
+	}
+
+	// A fence opened above the checked lines still hides them.
+	if ws := proseWords(lines, 2, 2); len(ws) != 0 {
+		t.Fatalf("line inside a fence yielded %v", ws)
+	}
+
+	sc := spellChecker{words: map[string]struct{}{"see": {}, "it": {}, "call": {}}}
+	bad := misspelledWords(&sc, lines, 0, len(lines)-1)
+	if fmt.Sprint(bad) != "map[0:[[0 3]]]" {
+		t.Fatalf("misspelled = %v", bad)
+	}
+	sc.ignore("teh")
+	if bad := misspelledWords(&sc, lines, 0, len(lines)-1); bad != nil {
+		t.Fatalf("ignored word still flagged: %v", bad)
+	}
+}
+
+func TestCommentMarkersStyledAndListed(t *testing.T) {
+	src := "package p\n\n// TODO: x\nfunc f() {\n\ttodo := \"FIXME\" // NOTES, XXX\n}\n"
+	app := appState{syntaxHL: newGoHighlighter()}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+
+	lines, styles, _, _ := renderData(&app)
+	at := func(ln, col int) tokenStyle { return lineStylesAt(styles, ln)[col] }
+	if at(2, 3) != styleTodo || at(2, 6) != styleTodo || at(2, 7) != styleComment {
+		t.Fatalf("TODO in a comment should get the marker style: %v", lineStylesAt(styles, 2))
+	}
+	if i := strings.Index(lines[4], "FIXME"); at(4, i) == styleTodo {
+		t.Fatalf("FIXME in a string must not be marked")
+	}
+	if i := strings.Index(lines[4], "NOTES"); at(4, i) == styleTodo {
+		t.Fatalf("NOTES is not a marker word")
+	}
+	if i := strings.Index(lines[4], "XXX"); at(4, i) != styleTodo {
+		t.Fatalf("XXX after other comment text should be marked")
+	}
+
+	if err := app.RunCommand(CmdMarkers, ""); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range app.outline.items {
+		got = append(got, outlineLine(it))
+	}
+	if want := []string{"TODO: x  :3", "XXX  :5"}; !slices.Equal(got, want) {
+		t.Fatalf("markers = %q, want %q", got, want)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.outline.active || app.ed.Caret != strings.Index(src, "// TODO") {
+		t.Fatalf("Enter should jump to the TODO line, caret=%d", app.ed.Caret)
+	}
+}
+
+func TestGoplsHealthStatusAndRetry(t *testing.T) {
+	app := appState{gopls: newGoplsClient()}
+	app.initBuffers(editor.NewEditor("package main\n\nfunc main() {\n\tfmt.\n}\n"))
+	app.currentPath = "main.go"
+	app.buffers[0].path = "main.go"
+	app.ed.Caret = strings.Index(app.ed.String(), "fmt.") + len("fmt.")
+
+	oldComplete := completeGoCompletions
+	defer func() { completeGoCompletions = oldComplete }()
+	completeGoCompletions = func(_ *goplsClient, _ string, _ string, _ int, _ int) ([]completionItem, error) {
+		return nil, errors.New("exec: gopls not found")
+	}
+	statusOf := func() string {
+		s := tcell.NewSimulationScreen("UTF-8")
+		if err := s.Init(); err != nil {
+			t.Fatalf("init simulation screen: %v", err)
+		}
+		defer s.Fini()
+		s.SetSize(120, 6)
+		drawTUI(s, &app)
+		return screenRowText(s, 4, 120)
+	}
+	if got := statusOf(); !strings.Contains(got, "gopls: ok") {
+		t.Fatalf("status should report gopls ok, got %q", got)
+	}
+	buf := app.ed.Runes()
+	prefix, start, end, _ := selectorCompletionPrefix(buf, app.ed.Caret)
+	trySelectorCompletionPopup(&app, buf, prefix, start, end)
+	if !app.noGopls || !strings.Contains(app.lastEvent, "Esc+Shift+Y") {
+		t.Fatalf("failed request should turn gopls off and name the retry key: %v %q", app.noGopls, app.lastEvent)
+	}
+	if got := statusOf(); !strings.Contains(got, "gopls: off") {
+		t.Fatalf("status should report gopls off, got %q", got)
+	}
+
+	oldStart := startGopls
+	defer func() { startGopls = oldStart }()
+	restarts := 0
+	restartErr := errors.New("still missing")
+	startGopls = func(*goplsClient) error {
+		restarts++
+		return restartErr
+	}
+	if err := app.RunCommand(CmdGoplsRetry, ""); err == nil || !app.noGopls {
+		t.Fatalf("failed retry should keep gopls off: err=%v noGopls=%v", err, app.noGopls)
+	}
+	old := app.gopls
+	restartErr = nil
+	if err := app.RunCommand(CmdGoplsRetry, ""); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if app.noGopls || restarts != 2 || app.gopls == old || app.gopls == nil {
+		t.Fatalf("retry should clear noGopls and replace the client: noGopls=%v restarts=%d", app.noGopls, restarts)
+	}
+	if got := statusOf(); !strings.Contains(got, "gopls: ok") {
+		t.Fatalf("status after retry=%q", got)
+	}
+
+	// With an interrupt channel the new client starts off the UI thread and
+	// gopls stays off until it reports back.
+	posted := make(chan any, 1)
+	app.requestInterrupt = func(data any) { posted <- data }
+	release := make(chan struct{})
+	startGopls = func(*goplsClient) error {
+		<-release
+		return nil
+	}
+	if err := app.RunCommand(CmdGoplsRetry, ""); err != nil || !app.noGopls {
+		t.Fatalf("async retry should wait for the new client: err=%v noGopls=%v", err, app.noGopls)
+	}
+	close(release)
+	handleTUIInterrupt(&app, tcell.NewEventInterrupt(<-posted))
+	if app.noGopls || app.lastEvent != "gopls: ok (restarted)" {
+		t.Fatalf("started client should turn gopls back on: noGopls=%v %q", app.noGopls, app.lastEvent)
+	}
+	app.requestInterrupt = nil
+
+	// A request still running on the old client fails after the restart;
+	// that must not turn the new client off.
+	failOn := func(client *goplsClient) {
+		token := app.completionSeq.Add(1)
+		app.completionReq = completionRequest{token: token, bufIdx: app.bufIdx, textRev: app.buffers[app.bufIdx].textRev, caret: app.ed.Caret, client: client}
+		applyCompletionResult(&app, completionResultInterrupt{Token: token, Err: errors.New("broken pipe")})
+	}
+	failOn(old)
+	if app.noGopls {
+		t.Fatalf("a failure from the replaced client should be ignored (%s)", app.lastEvent)
+	}
+	failOn(app.gopls)
+	if !app.noGopls {
+		t.Fatalf("a failure from the current client should turn gopls off")
+	}
+}
+
+func TestGoOutlineFromSourceListsDeclarations(t *testing.T) {
+	src := strings.Join([]string{
+		"package p", // 0
+		"",
+		"// Point is a point.",
+		"type Point struct{ X, Y int }", // 3
+		"",
+		"func main() {", // 5
+		"\tvar local int",
+		"\t_ = local",
+		"}",
+		"",
+		"func (p *Point) Move(dx int) { p.X += dx }", // 10
+		"",
+		"func helper() {}", // 12
+	}, "\n")
+	var got []string
+	for _, it := range goOutlineFromSource(src) {
+		got = append(got, outlineLine(it))
+	}
+	want := []string{"type Point  :4", "func main  :6", "func (*Point).Move  :11", "func helper  :13"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("outline = %q", got)
+	}
+
+	app := appState{noGopls: true}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	app.ed.Caret = strings.Index(src, "_ = local")
+	if err := app.RunCommand(CmdOutline, ""); err != nil || app.outline.title != "Symbols" {
+		t.Fatalf("outline should open: err=%v title=%q", err, app.outline.title)
+	}
+	if app.outline.selected != 1 {
+		t.Fatalf("selected = %d, want main", app.outline.selected)
+	}
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	if app.outline.active || app.ed.Caret != strings.Index(src, "func helper") {
+		t.Fatalf("Enter should jump to helper, caret=%d", app.ed.Caret)
+	}
+}
+
+func TestGoOutlineUsesGoplsDocumentSymbols(t *testing.T) {
+	raw := json.RawMessage(`[
+		{"name":"helper","kind":12,"range":{"start":{"line":9}},"selectionRange":{"start":{"line":9}}},
+		{"name":"Point","kind":23,"range":{"start":{"line":2}},"selectionRange":{"start":{"line":3}},
+		 "children":[{"name":"X","kind":8,"selectionRange":{"start":{"line":3}}}]},
+		{"name":"(*Point).Move","kind":6,"selectionRange":{"start":{"line":6}}}
+	]`)
+	var got []string
+	for _, it := range parseDocumentSymbols(raw) {
+		got = append(got, outlineLine(it))
+	}
+	want := []string{"type Point  :4", "func (*Point).Move  :7", "func helper  :10"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("symbols = %q", got)
+	}
+
+	old := goDocumentSymbols
+	defer func() { goDocumentSymbols = old }()
+	goDocumentSymbols = func(*goplsClient, string, string) ([]outlineItem, error) {
+		return nil, fmt.Errorf("boom")
+	}
+	app := appState{}
+	app.initBuffers(editor.NewEditor("package p\n\nfunc f() {}\n"))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	if err := app.RunCommand(CmdOutline, ""); err != nil || len(app.outline.items) != 1 || !app.noGopls {
+		t.Fatalf("a gopls failure should fall back to parsing: err=%v items=%v noGopls=%v", err, app.outline.items, app.noGopls)
+	}
+}
+
+func TestQuickFixPopupListsAndAppliesStubbedActions(t *testing.T) {
+	src := "package p\n\nfunc f() int {\n\tx := 1\n}\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	app.ed.Caret = strings.Index(src, "x :=")
+
+	old := goCodeActions
+	defer func() { goCodeActions = old }()
+	var gotLine int
+	goCodeActions = func(_ *goplsClient, _ string, _ string, line int, _ string) ([]codeAction, error) {
+		gotLine = line
+		var rename, ret textEdit
+		rename.Range.Start = lspPosition{Line: 3, Character: 1}
+		rename.Range.End = lspPosition{Line: 3, Character: 2}
+		rename.NewText = "_"
+		ret.Range.Start = lspPosition{Line: 4, Character: 0}
+		ret.Range.End = lspPosition{Line: 4, Character: 0}
+		ret.NewText = "\treturn 0\n"
+		return []codeAction{
+			{title: "Rename x to _", edits: []textEdit{rename}},
+			{title: "Add return statement", edits: []textEdit{ret}},
+		}, nil
+	}
+	if err := app.RunCommand(CmdQuickFix, ""); err != nil || !app.codeActions.active {
+		t.Fatalf("quick fixes should open: err=%v event=%q", err, app.lastEvent)
+	}
+	if gotLine != 3 || len(app.codeActions.items) != 2 || app.codeActions.items[1].title != "Add return statement" {
+		t.Fatalf("line=%d items=%v", gotLine, app.codeActions.items)
+	}
+	handleTextEvent(&app, "z", 0)
+	handleKeyEvent(&app, keyEvent{down: true, key: keyDown})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyReturn})
+	want := "package p\n\nfunc f() int {\n\tx := 1\n\treturn 0\n}\n"
+	if app.codeActions.active || app.ed.String() != want || !app.buffers[0].dirty {
+		t.Fatalf("applying should edit the buffer, got %q", app.ed.String())
+	}
+	if app.ed.Caret != strings.Index(want, "x :=") {
+		t.Fatalf("caret=%d, want it kept on x", app.ed.Caret)
+	}
+	app.ed.Undo()
+	if app.ed.String() != src {
+		t.Fatalf("one undo should revert the fix, got %q", app.ed.String())
+	}
+
+	app.noGopls = true
+	if err := app.RunCommand(CmdQuickFix, ""); err == nil || app.codeActions.active {
+		t.Fatal("quick fixes should need gopls")
+	}
+}
+
+func TestParseCodeActionsKeepsSingleFileEdits(t *testing.T) {
+	raw := json.RawMessage(`[
+		{"title":"Fix here","kind":"quickfix","edit":{"changes":{"file:///p.go":[{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":0}},"newText":"x"}]}}},
+		{"title":"Fix elsewhere","kind":"quickfix","edit":{"changes":{"file:///q.go":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},"newText":"y"}]}}},
+		{"title":"Run a command","kind":"quickfix","command":{"command":"gopls.x"}},
+		{"title":"Doc change","kind":"quickfix","edit":{"documentChanges":[{"textDocument":{"uri":"file:///p.go","version":1},"edits":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":1}},"newText":""}]}]}}
+	]`)
+	got := parseCodeActions(raw, "file:///p.go")
+	if len(got) != 2 || got[0].title != "Fix here" || got[1].title != "Doc change" || got[0].edits[0].NewText != "x" {
+		t.Fatalf("actions = %+v", got)
+	}
+}
+
+func TestStatusMessagesAreLoggedAndListedInOrder(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("package p\n"))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	if err := app.RunCommand(CmdMessages, ""); err == nil {
+		t.Fatal("an empty log should report an error")
+	}
+	app.statusLog = statusLog{}
+	for _, msg := range []string{"Saved p.go", "Saved p.go", "", "LOAD ERR: no such file", "Undo"} {
+		app.lastEvent = msg
+		noteStatus(&app)
+	}
+	var got []string
+	for _, e := range app.statusLog.list() {
+		got = append(got, e.msg)
+	}
+	if want := []string{"Saved p.go", "LOAD ERR: no such file", "Undo"}; !slices.Equal(got, want) {
+		t.Fatalf("log = %q, want %q", got, want)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyE, mods: modShift})
+	if app.currentPath != messagesTitle || !app.buffers[app.bufIdx].readOnly {
+		t.Fatalf("Esc+Shift+E should open the message log, at %q: %s", app.currentPath, app.lastEvent)
+	}
+	lines := app.ed.Lines()
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], "  Saved p.go") || !strings.HasSuffix(lines[2], "  Undo") {
+		t.Fatalf("log buffer = %q", lines)
+	}
+	if _, err := time.Parse("15:04:05", strings.Fields(lines[1])[0]); err != nil {
+		t.Fatalf("entry should start with a timestamp: %q", lines[1])
+	}
+
+	// The ring keeps only the newest entries.
+	var l statusLog
+	for i := range statusLogSize + 5 {
+		l.add(time.Now(), strconv.Itoa(i))
+	}
+	entries := l.list()
+	if len(entries) != statusLogSize || entries[0].msg != "5" || entries[len(entries)-1].msg != strconv.Itoa(statusLogSize+4) {
+		t.Fatalf("ring kept %d entries from %q", len(entries), entries[0].msg)
+	}
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -15,24 +16,6 @@ import (
 	"github.com/gdamore/tcell/v2"
 )
 
-type memoryClipboard struct {
-	mu   sync.Mutex
-	text string
-}
-
-func (m *memoryClipboard) GetText() (string, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	return m.text, nil
-}
-
-func (m *memoryClipboard) SetText(text string) error {
-	m.mu.Lock()
-	m.text = text
-	m.mu.Unlock()
-	return nil
-}
-
 func main() {
 	if err := runTUI(); err != nil {
 		panic(err)
@@ -48,9 +31,10 @@ func runTUI() error {
 		return err
 	}
 	defer screen.Fini()
+	screen.EnableFocus()
 
 	root, _ := os.Getwd()
-	clip := &memoryClipboard{}
+	clip := newClipboard()
 	ed := editor.NewEditor("")
 	ed.SetClipboard(clip)
 	app := appState{
@@ -67,15 +51,33 @@ func runTUI() error {
 		_ = screen.PostEvent(tcell.NewEventInterrupt(data))
 	}
 	app.initBuffers(ed)
-	defer app.gopls.close()
+	// Close whichever client is current on exit; a retry replaces it.
+	defer func() { app.gopls.close() }()
 
 	if len(os.Args) > 1 {
 		loadStartupFiles(&app, filterArgsToFiles(os.Args[1:]))
 	}
+	km, err := loadUserKeymap(keymapPath())
+	app.keymap = km
+	if err != nil {
+		app.lastEvent = fmt.Sprintf("KEYMAP ERR: %v", err)
+	}
+	sn, err := loadUserSnippets(snippetsPath())
+	app.snippets = sn
+	if err != nil {
+		app.lastEvent = fmt.Sprintf("SNIPPETS ERR: %v", err)
+	}
+	if err := loadUserConfig(&app, configPath()); err != nil {
+		app.lastEvent = fmt.Sprintf("CONFIG ERR: %v", err)
+	}
 
 	for {
 		fastStartupPass := app.startupFast
+		noteStatus(&app)
 		drawTUI(screen, &app)
+		scheduleBlink(&app)
+		scheduleInlayHints(&app)
+		scheduleOccurrences(&app)
 		if fastStartupPass {
 			// Immediately draw again so startup can paint fast first, then enrich.
 			continue
@@ -90,6 +92,10 @@ func runTUI() error {
 			}
 		case *tcell.EventInterrupt:
 			handleTUIInterrupt(&app, e)
+		case *tcell.EventFocus:
+			if e.Focused {
+				checkExternalChange(&app)
+			}
 		}
 	}
 }
@@ -115,6 +121,23 @@ func handleTUIInterrupt(app *appState, ev *tcell.EventInterrupt) {
 			return
 		}
 		app.escHelpVisible = true
+	case blinkInterrupt:
+		// The loop redraws with the caret in its new phase.
+		app.blinkPending = false
+	case noMatchInterrupt:
+		// The loop redraws without the flash.
+	case completionResultInterrupt:
+		applyCompletionResult(app, data)
+	case signatureHelpInterrupt:
+		applySignatureHelpResult(app, data)
+	case inlayHintsInterrupt:
+		applyInlayHints(app, data)
+	case goplsRestartInterrupt:
+		if err := applyGoplsRestart(app, data); err != nil {
+			app.lastEvent = fmt.Sprintf("GOPLS ERR: %v", err)
+		}
+	case occurrenceInterrupt:
+		// The loop redraws with the occurrences once the caret has rested.
 	case completionDetailInterrupt:
 		if !app.completionPopup.active || data.Token != app.completionPopup.detailToken {
 			return
@@ -155,26 +178,26 @@ func handleTUIKey(app *appState, ev *tcell.EventKey) bool {
 			if inferShiftFromRune(ev.Rune()) {
 				keyMods |= modShift
 			}
-			keepRunning := dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: keyMods})
+			keepRunning := dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: keyMods})
 			// TUI prefix dispatch does not have a separate text-input event for this key.
 			app.suppressTextOnce = false
 			return keepRunning
 		}
 		// Unknown key still consumes the prefix and does not insert text.
-		keepRunning := dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: keyUnknown, mods: mods})
+		keepRunning := dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: keyUnknown, mods: mods})
 		app.suppressTextOnce = false
 		return keepRunning
 	}
 	if app.lessMode && ev.Key() == tcell.KeyRune && ev.Rune() == ' ' {
-		return dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: keySpace, mods: mods})
+		return dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: keySpace, mods: mods})
 	}
 
 	if ev.Key() == tcell.KeyRune && (ev.Modifiers()&tcell.ModCtrl) == 0 {
-		return dispatchTUIText(app, string(ev.Rune()), mods)
+		return dispatchTextEvent(app, string(ev.Rune()), mods)
 	}
 	if ev.Key() == tcell.KeyRune && (ev.Modifiers()&tcell.ModCtrl) != 0 {
 		if k, ok := ctrlRuneToKey(ev.Rune()); ok {
-			return dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: mods | modCtrl})
+			return dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: mods | modCtrl})
 		}
 	}
 
@@ -186,7 +209,7 @@ func handleTUIKey(app *appState, ev *tcell.EventKey) bool {
 		if ev.Key() == tcell.KeyBacktab {
 			keyMods |= modShift
 		}
-		return dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: keyMods})
+		return dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: keyMods})
 	}
 	return true
 }
@@ -200,7 +223,7 @@ func handleEscSeqKey(app *appState, ev *tcell.EventKey) bool {
 		app.escSeq = ""
 		app.cmdPrefixActive = false
 		if k, ok := tcellKeyToKeyCode(ev); ok {
-			return dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: tcellToMods(ev.Modifiers())})
+			return dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: tcellToMods(ev.Modifiers())})
 		}
 		return true
 	}
@@ -220,7 +243,7 @@ func handleEscSeqKey(app *appState, ev *tcell.EventKey) bool {
 	if !ok {
 		return true
 	}
-	return dispatchTUIKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: mods})
+	return dispatchKeyEvent(app, keyEvent{down: true, repeat: 0, key: k, mods: mods})
 }
 
 func decodeEscSeqArrow(seq string) (keyCode, modMask, bool, bool) {
@@ -301,26 +324,6 @@ func isEscSeqPrefix(seq string) bool {
 	return true
 }
 
-func dispatchTUIKeyEvent(app *appState, e keyEvent) bool {
-	if app.inputActive {
-		return handleInputKey(app, e)
-	}
-	if app.open.Active {
-		return handleOpenKeyEvent(app, e)
-	}
-	return handleKeyEvent(app, e)
-}
-
-func dispatchTUIText(app *appState, text string, mods modMask) bool {
-	if app.inputActive {
-		return handleInputText(app, text)
-	}
-	if app.open.Active {
-		return handleOpenTextEvent(app, text)
-	}
-	return handleTextEvent(app, text, mods)
-}
-
 func tcellToMods(m tcell.ModMask) modMask {
 	var out modMask
 	if (m & tcell.ModShift) != 0 {
@@ -396,6 +399,8 @@ func tcellKeyToKeyCode(ev *tcell.EventKey) (keyCode, bool) {
 		return keyV, true
 	case tcell.KeyCtrlX:
 		return keyX, true
+	case tcell.KeyCtrlY:
+		return keyY, true
 	case tcell.KeyRune:
 		switch strings.ToLower(string(ev.Rune())) {
 		case "/":
@@ -436,16 +441,24 @@ func drawTUI(s tcell.Screen, app *appState) {
 	}
 	lineH := 1
 	contentH := h - 2
+	app.visibleLines = contentH
+	app.screenW, app.screenH = w, h
 	cLine := editor.CaretLineAt(lines, app.ed.Caret)
 	cCol := editor.CaretColAt(lines, app.ed.Caret)
-	ensureCaretVisible(app, cLine, len(lines), contentH)
-	startLine := clamp(app.scrollLine, 0, max(0, len(lines)-contentH))
-	caretY := cLine - startLine
+	// With folds the scroll position counts display rows, not lines.
+	view := newFoldView(closedFolds(app, app.bufIdx, kind, lines), len(lines))
+	cRow := view.row(cLine)
+	followCaret(app, cRow, view.len(), contentH)
+	startLine := clamp(app.scrollLine, 0, max(0, view.len()-contentH))
+	caretY := cRow - startLine
 
 	base := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
 	gutter := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkCyan)
 	gutterErr := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorIndianRed)
-	current := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
+	current := base
+	if app.curLineColor != "" {
+		current = base.Background(tcell.GetColor(app.curLineColor))
+	}
 	if app.syntaxCheck == nil {
 		app.syntaxCheck = newGoSyntaxChecker()
 	}
@@ -455,37 +468,101 @@ func drawTUI(s tcell.Screen, app *appState) {
 		selA, selB := app.ed.Sel.Normalised()
 		sel = &selectionRange{a: selA, b: selB}
 	}
+	hlQuery, hlCurrent := activeHighlightQuery(app)
 	if lineStarts == nil {
 		lineStarts = computeLineStarts(lines)
 		if app.render.bufIdx == app.bufIdx && app.render.path == app.currentPath && len(app.render.lines) == len(lines) {
 			app.render.lineStarts = lineStarts
 		}
 	}
-	for row := 0; row < contentH; row += lineH {
-		ln := startLine + row
-		fillRow(s, row, w, base)
-		if ln >= len(lines) {
-			continue
+	areaX := 0
+	if sw := sidebarWidth(app, w); sw > 0 {
+		drawTUISidebar(s, app, sw, contentH)
+		areaX = sw + 1
+	}
+	areaW := w - areaX
+	focused := tuiPane{
+		x:          areaX,
+		w:          areaW,
+		numbers:    app.lineNumbers,
+		showWS:     app.showWhitespace,
+		rulerCol:   app.rulerCol,
+		lineLimit:  app.lineLimit,
+		lines:      lines,
+		lineStyles: lineStyles,
+		lineStarts: lineStarts,
+		view:       view,
+		startLine:  startLine,
+		caretLine:  cLine,
+		lineErrors: lineErrors,
+		marks:      bookmarkLines(app, app.ed, lines),
+		sel:        sel,
+		hlQuery:    hlQuery,
+		hlCurrent:  hlCurrent,
+		guideStep:  indentGuideStep(app, app.bufIdx, kind),
+		hints:      paneInlayHints(app, app.bufIdx),
+	}
+	focused.gutterW = paneGutterWidth(app, focused, kind)
+	if app.spellCheck && proseBuffer(kind, app.currentPath) {
+		// Only the visible lines are checked.
+		last := view.lineAt(min(view.len(), startLine+contentH) - 1)
+		focused.misspelled = misspelledWords(&app.spell, lines, view.lineAt(startLine), last)
+	}
+	if view.len() > startLine {
+		last := view.lineAt(min(view.len(), startLine+contentH) - 1)
+		focused.occurrences = visibleOccurrences(app, kind, lines, view.lineAt(startLine), last, time.Now())
+	}
+	if app.splitActive && len(app.buffers) > 0 {
+		leftW, rightW := splitPaneWidths(areaW)
+		other := otherSplitPane(app, contentH)
+		focused.w, other.w = leftW, rightW
+		other.x = areaX + leftW + 1
+		if app.splitFocusRight {
+			focused.x, focused.w = areaX+leftW+1, rightW
+			other.x, other.w = areaX, leftW
 		}
-		lineStyle := base
-		if ln == cLine {
-			lineStyle = current
+		// Draw left to right so the divider and right pane clip left overflow.
+		if app.splitFocusRight {
+			drawTUIPane(s, other, contentH, lineH, base, current, gutter, gutterErr)
+		} else {
+			drawTUIPane(s, focused, contentH, lineH, base, current, gutter, gutterErr)
 		}
-		g := fmt.Sprintf("%4d ", ln+1)
-		drawCellText(s, 0, row, g, gutter)
-		if _, ok := lineErrors[ln]; ok {
-			s.SetContent(0, row, '!', nil, gutterErr)
+		divider := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkSlateBlue)
+		for row := range contentH {
+			s.SetContent(areaX+leftW, row, '│', nil, divider)
 		}
-		drawStyledTUICellLine(
-			s, 5, row, lines[ln], lineStylesAt(lineStyles, ln), lineStyle,
-			lineStarts[ln], sel,
-		)
+		if app.splitFocusRight {
+			drawTUIPane(s, focused, contentH, lineH, base, current, gutter, gutterErr)
+		} else {
+			drawTUIPane(s, other, contentH, lineH, base, current, gutter, gutterErr)
+		}
+	} else {
+		drawTUIPane(s, focused, contentH, lineH, base, current, gutter, gutterErr)
 	}
 
-	status := fmt.Sprintf("%s | lang=%s | root=%s", bufferLabel(app), langMode, app.openRoot)
+	status := fmt.Sprintf("%s | lang=%s | root=%s", bufferLabel(app), langMode, displayRoot(app))
+	if kind == syntaxGo {
+		status += " | " + goplsStatus(app)
+	}
+	if len(app.buffers) > 0 {
+		if format := fileFormatStatus(&app.buffers[app.bufIdx]); format != "" {
+			status += " | " + format
+		}
+	}
 	if len(app.buffers) > 0 && app.buffers[app.bufIdx].dirty {
 		status += " | *unsaved*"
 	}
+	if app.splitActive {
+		status += " | split"
+	}
+	if app.macroRecording {
+		status += " | rec"
+	}
+	if len(app.buffers) > 0 {
+		if eof := finalNewlineStatus(&app.buffers[app.bufIdx]); eof != "" {
+			status += " | " + eof
+		}
+	}
 	if app.lastEvent != "" {
 		status += " | " + app.lastEvent
 	}
@@ -505,25 +582,49 @@ func drawTUI(s tcell.Screen, app *appState) {
 		input = "Go syntax error: " + msg
 		inputStyle = tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorIndianRed)
 	} else {
-		input = "Leap: unbound in TUI | Shift+Tab buffer cycle"
+		input = "Leap: Esc+j / Esc+` | select: Esc+Shift+J/K | Shift+Tab buffer cycle"
+	}
+	if !app.inputActive && !app.open.Active && !app.noMatchAt.IsZero() {
+		// The query matches nowhere: red text, after a brief red flash.
+		inputStyle = inputStyle.Foreground(tcell.ColorIndianRed)
+		if noMatchFlashing(app, time.Now()) {
+			inputStyle = inputStyle.Background(tcell.ColorDarkRed).Foreground(tcell.ColorWhite)
+		}
 	}
 	drawCellText(s, 0, h-1, padRight(input, w), inputStyle)
 
 	if strings.TrimSpace(app.symbolInfoPopup) != "" {
 		drawTUISymbolPopup(s, app, w, h)
 	}
+	if app.sigHelpActive && !app.completionPopup.active {
+		drawTUISignatureHelp(s, app, w, h)
+	}
 	if app.completionPopup.active {
 		drawTUICompletionPopup(s, app, w, h)
 		if app.completionPopup.detailVisible {
 			drawTUICompletionDetailPopup(s, app, w, h)
 		}
 	}
+	if app.palette.active {
+		drawTUIPalettePopup(s, app, w, h)
+	}
+	if app.outline.active {
+		drawTUIOutlinePopup(s, app, w, h)
+	}
+	if app.codeActions.active {
+		drawTUICodeActionPopup(s, app, w, h)
+	}
 	if app.escHelpVisible {
 		drawTUIEscHelpPopup(s, w, h)
 	}
 
-	caretX := 5 + visualColForRuneCol(lines[cLine], cCol, tabWidth)
-	if caretY >= 0 && caretY < contentH && caretX >= 0 && caretX < w {
+	caretX := focused.x + focused.gutterW + inlayHintVisualCol(lines[cLine], focused.hints[cLine], cCol)
+	cursorStyle := tcell.CursorStyleDefault
+	if app.noBlink || app.blinkPeriod > 0 {
+		cursorStyle = tcell.CursorStyleSteadyBlock
+	}
+	s.SetCursorStyle(cursorStyle)
+	if !app.sidebar.focused && caretY >= 0 && caretY < contentH && caretX >= focused.x && caretX < focused.x+focused.w && caretBlinkOn(app, time.Now()) {
 		s.ShowCursor(caretX, caretY)
 	} else {
 		s.HideCursor()
@@ -531,6 +632,308 @@ func drawTUI(s tcell.Screen, app *appState) {
 	s.Show()
 }
 
+// tuiPane is one buffer viewport in the content area.
+type tuiPane struct {
+	x, w       int
+	gutterW    int
+	numbers    lineNumberMode
+	lines      []string
+	lineStyles [][]tokenStyle
+	lineStarts []int
+	view       foldView
+	// startLine is the first display row (a line when nothing is folded).
+	startLine  int
+	caretLine  int
+	lineErrors map[int]struct{}
+	marks      map[int]struct{}
+	sel        *selectionRange
+	hlQuery    []rune
+	hlCurrent  int
+	showWS     bool
+	rulerCol   int
+	lineLimit  int
+	// misspelled holds the rune column ranges to underline per line.
+	misspelled map[int][][2]int
+	// guideStep is the indent guide spacing in columns (0 = no guides).
+	guideStep int
+	// hints are the inlay hints to draw per line.
+	hints map[int][]inlayHint
+	// occurrences holds the rune column ranges of the identifier at the
+	// caret per line.
+	occurrences map[int][][2]int
+}
+
+// paneGutterWidth is gutterWidth for p, except that with line numbers off a
+// pane that can show a gutter marker keeps one column for it: a line limit
+// is set, the buffer is syntax-checked (Go) or it holds a bookmark. Whether
+// a line currently has an error does not matter, so the text does not shift
+// while typing.
+func paneGutterWidth(app *appState, p tuiPane, kind syntaxKind) int {
+	if w := gutterWidth(app); w > 0 || (p.lineLimit <= 0 && kind != syntaxGo && len(p.marks) == 0) {
+		return w
+	}
+	return 1
+}
+
+func drawTUIPane(s tcell.Screen, p tuiPane, contentH, lineH int, base, current, gutter, gutterErr tcell.Style) {
+	var guides []int
+	firstLn := p.view.lineAt(p.startLine)
+	if p.guideStep > 0 && p.view.len() > p.startLine {
+		// Only the visible lines are measured.
+		lastLn := p.view.lineAt(min(p.view.len(), p.startLine+contentH) - 1)
+		guides = indentGuideLevels(p.lines, firstLn, lastLn, p.guideStep)
+	}
+	for row := 0; row < contentH; row += lineH {
+		ln := p.view.lineAt(p.startLine + row)
+		fillCells(s, p.x, row, p.w, base)
+		if ln >= len(p.lines) {
+			continue
+		}
+		lineStyle := base
+		if ln == p.caretLine {
+			lineStyle = current
+			fillCells(s, p.x+p.gutterW, row, p.w-p.gutterW, current)
+		}
+		if p.gutterW > 0 {
+			drawCellText(s, p.x, row, gutterLabel(p.numbers, ln, p.caretLine), gutter)
+			// In a one-column gutter the markers share the cell; an error
+			// wins over a bookmark, which wins over a long line.
+			if exceedsLineLimit(p.lines[ln], p.lineLimit) {
+				s.SetContent(p.x+p.gutterW-1, row, '>', nil, gutterLong)
+			}
+			if _, ok := p.marks[ln]; ok {
+				s.SetContent(p.x, row, '•', nil, gutterMark)
+			}
+			if _, ok := p.lineErrors[ln]; ok {
+				s.SetContent(p.x, row, '!', nil, gutterErr)
+			}
+		}
+		var hits *queryMatches
+		if starts := lineMatchOffsets(p.lines[ln], p.hlQuery); len(starts) > 0 {
+			hits = &queryMatches{starts: starts, length: len(p.hlQuery), current: p.hlCurrent - p.lineStarts[ln]}
+		}
+		drawStyledTUICellLine(
+			s, p.x+p.gutterW, row, p.lines[ln], lineStylesAt(p.lineStyles, ln), lineStyle,
+			p.lineStarts[ln], p.sel, hits, p.showWS,
+		)
+		if i := ln - firstLn; i >= 0 && i < len(guides) {
+			drawIndentGuides(s, p.x+p.gutterW, p.x+p.w, row, guides[i], p.guideStep)
+		}
+		for _, r := range p.misspelled[ln] {
+			underlineRuneCols(s, p.x+p.gutterW, p.x+p.w, row, p.lines[ln], r[0], r[1])
+		}
+		for _, r := range p.occurrences[ln] {
+			tintRuneCols(s, p.x+p.gutterW, p.x+p.w, row, p.lines[ln], r[0], r[1], occurrenceBackground)
+		}
+		if tail, ok := p.view.summary[ln]; ok {
+			x := p.x + p.gutterW + visualColForRuneCol(p.lines[ln], utf8.RuneCountInString(p.lines[ln]), tabWidth)
+			if room := p.x + p.w - x; room > 0 {
+				rs := []rune(tail)
+				drawCellText(s, x, row, string(rs[:min(len(rs), room)]), foldSummary)
+			}
+		}
+		if hs := p.hints[ln]; len(hs) > 0 {
+			drawInlayHints(s, p.x+p.gutterW, p.x+p.w, row, p.lines[ln], hs, lineStyle)
+		}
+	}
+	if x, ok := rulerCellX(p, p.rulerCol); ok {
+		for row := 0; row < contentH; row += lineH {
+			str, st, _ := s.Get(x, row)
+			r := ' '
+			if str != "" {
+				r = []rune(str)[0]
+			}
+			s.SetContent(x, row, r, nil, st.Background(rulerBackground))
+		}
+	}
+}
+
+// underlineRuneCols underlines the cells of rune columns a..b of line drawn at
+// x, clipped at maxX, keeping their colors.
+func underlineRuneCols(s tcell.Screen, x, maxX, row int, line string, a, b int) {
+	from := x + visualColForRuneCol(line, a, tabWidth)
+	to := min(maxX, x+visualColForRuneCol(line, b, tabWidth))
+	for cx := from; cx < to; cx++ {
+		str, st, _ := s.Get(cx, row)
+		r := ' '
+		if str != "" {
+			r = []rune(str)[0]
+		}
+		s.SetContent(cx, row, r, nil, st.Underline(true).Foreground(tcell.ColorIndianRed))
+	}
+}
+
+// defaultCurLineColor is the caret line background for a bare curline=on.
+const defaultCurLineColor = "#262635"
+
+// selectionBackground marks selected text. It is drawn over the caret line
+// color, which therefore may not equal it.
+var selectionBackground = tcell.ColorDarkSlateBlue
+
+// validColor reports whether name is a color name or #rrggbb that tcell knows.
+func validColor(name string) bool {
+	return tcell.GetColor(name) != tcell.ColorDefault
+}
+
+// isSelectionColor reports whether name is the selection background, which
+// would hide a selection on the caret line.
+func isSelectionColor(name string) bool {
+	return tcell.GetColor(name).TrueColor() == selectionBackground.TrueColor()
+}
+
+// gutterMark flags bookmarked lines.
+var gutterMark = tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorHotPink)
+
+// foldSummary draws the " … }" after a folded block's first line.
+var foldSummary = tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkCyan)
+
+// gutterLong marks lines wider than the configured limit.
+var gutterLong = tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorGold)
+
+// rulerBackground tints the ruler column behind the text.
+var rulerBackground = tcell.NewRGBColor(48, 40, 64)
+
+// rulerCellX maps a 1-based text column to the screen x of the pane's ruler.
+func rulerCellX(p tuiPane, col int) (int, bool) {
+	if col <= 0 {
+		return 0, false
+	}
+	x := p.x + p.gutterW + col - 1
+	if x >= p.x+p.w {
+		return 0, false
+	}
+	return x, true
+}
+
+// splitPaneWidths divides the screen width into left/right panes separated by
+// a one-column divider.
+func splitPaneWidths(w int) (int, int) {
+	if w < 3 {
+		return max(0, w), 0
+	}
+	left := (w - 1) / 2
+	return left, w - 1 - left
+}
+
+// sidebarWidth is the width of the file sidebar column, or 0 when it is
+// hidden or the screen is too narrow to share.
+func sidebarWidth(app *appState, w int) int {
+	if !app.sidebar.visible || w < 40 {
+		return 0
+	}
+	return clamp(w/4, 16, 32)
+}
+
+// drawTUISidebar draws the sidebar listing in columns [0, sw) with a divider
+// at sw, scrolling to keep the selected entry in view.
+func drawTUISidebar(s tcell.Screen, app *appState, sw, contentH int) {
+	sb := &app.sidebar
+	base := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorSilver)
+	title := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightYellow)
+	dir := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightCyan)
+	sel := tcell.StyleDefault.Background(tcell.ColorDarkSlateGray).Foreground(tcell.ColorWhite)
+	if sb.focused {
+		sel = tcell.StyleDefault.Background(tcell.ColorMidnightBlue).Foreground(tcell.ColorWhite)
+	}
+	divider := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkSlateBlue)
+
+	drawCellText(s, 0, 0, padRight(filepath.Base(sb.root)+"/", sw), title)
+	rows := max(0, contentH-1)
+	if sb.selected < sb.scroll {
+		sb.scroll = sb.selected
+	}
+	if rows > 0 && sb.selected >= sb.scroll+rows {
+		sb.scroll = sb.selected - rows + 1
+	}
+	for row := range rows {
+		i := sb.scroll + row
+		st, text := base, ""
+		if i < len(sb.entries) {
+			text = sb.entries[i]
+			if strings.HasSuffix(text, "/") || text == ".." {
+				st = dir
+			}
+			if i == sb.selected {
+				st = sel
+			}
+		}
+		drawCellText(s, 0, row+1, padRight(text, sw), st)
+	}
+	for row := range contentH {
+		s.SetContent(sw, row, '│', nil, divider)
+	}
+}
+
+// otherSplitPane builds the unfocused pane, which keeps its own scroll offset.
+func otherSplitPane(app *appState, contentH int) tuiPane {
+	idx := clamp(app.splitOther, 0, len(app.buffers)-1)
+	slot := &app.buffers[idx]
+	lines, lineStyles := bufferRenderData(app, idx)
+	cLine := editor.CaretLineAt(lines, slot.ed.Caret)
+	kind := slot.mode
+	if kind == syntaxNone {
+		kind = detectSyntax(slot.path, string(slot.ed.Runes()))
+	}
+	view := newFoldView(closedFolds(app, idx, kind, lines), len(lines))
+	app.splitOtherScroll = clamp(app.splitOtherScroll, 0, max(0, view.len()-contentH))
+	var sel *selectionRange
+	if slot.ed.Sel.Active {
+		a, b := slot.ed.Sel.Normalised()
+		sel = &selectionRange{a: a, b: b}
+	}
+	p := tuiPane{
+		numbers:    app.lineNumbers,
+		showWS:     app.showWhitespace,
+		rulerCol:   app.rulerCol,
+		lineLimit:  app.lineLimit,
+		lines:      lines,
+		lineStyles: lineStyles,
+		lineStarts: computeLineStarts(lines),
+		view:       view,
+		startLine:  app.splitOtherScroll,
+		caretLine:  cLine,
+		marks:      bookmarkLines(app, slot.ed, lines),
+		sel:        sel,
+		hlCurrent:  -1,
+		guideStep:  indentGuideStep(app, idx, kind),
+		hints:      paneInlayHints(app, idx),
+	}
+	p.gutterW = paneGutterWidth(app, p, kind)
+	return p
+}
+
+// bufferRenderData returns lines and styles for a non-active buffer using the
+// per-slot render cache.
+func bufferRenderData(app *appState, idx int) ([]string, [][]tokenStyle) {
+	slot := &app.buffers[idx]
+	if slot.cachedTextRev == slot.textRev &&
+		slot.cachedMode == slot.mode &&
+		slot.cachedPath == slot.path &&
+		len(slot.cachedLines) > 0 {
+		return slot.cachedLines, slot.cachedLineStyles
+	}
+	buf := slot.ed.Runes()
+	lines := slot.ed.Lines()
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	kind := slot.mode
+	if kind == syntaxNone {
+		kind = detectSyntax(slot.path, string(buf))
+	}
+	lineStyles := app.syntaxHL.lineStyleForKind(slot.path, string(buf), lines, kind)
+	if strings.HasPrefix(slot.path, runBufferPrefix) {
+		lineStyles = runOutputStyles(lines)
+	}
+	slot.cachedTextRev = slot.textRev
+	slot.cachedMode = slot.mode
+	slot.cachedPath = slot.path
+	slot.cachedLines = lines
+	slot.cachedLineStyles = lineStyles
+	slot.cachedLangMode = syntaxKindLabel(kind)
+	return lines, lineStyles
+}
+
 type escShortcutCategory struct {
 	title string
 	items []string
@@ -541,9 +944,15 @@ var escHelpCategories = []escShortcutCategory{
 		title: "Files",
 		items: []string{
 			"b  new buffer",
+			"B  close other buffers",
+			"L  reopen last closed file",
+			"F  file sidebar",
+			"W  rename picker entry",
 			"w  write as...",
 			"f  save + fmt/fix + reload",
 			"S  save dirty buffers",
+			"R  toggle read-only",
+			"g  toggle foo.go / foo_test.go",
 		},
 	},
 	{
@@ -551,16 +960,49 @@ var escHelpCategories = []escShortcutCategory{
 		items: []string{
 			"/  search mode",
 			"x  line highlight mode",
+			"=  expand selection",
+			"(  wrap selection in delimiters",
+			"+/X  increment / decrement number",
+			"$  end buffer with one newline",
+			";  switch line endings LF/CRLF",
+			"!  accept word for spell check",
+			"#  list TODO/FIXME comments",
+			"j/`  leap fwd/back",
+			"n/N  leap again fwd/back",
+			"}/{  paste as lines below/above",
+			"J/K  leap select fwd/back",
+			"t/T  jump to char fwd/back",
+			"h  cycle leap history",
+			"\"/'  set / jump to bookmark",
+			"-/_  jump back / forward",
+			"H  fold / unfold block or section",
+			"I  Markdown heading / Go symbol outline",
 			"m  cycle language mode",
 			"i  symbol info popup",
+			"a  gopls quick fixes for the line",
+			"d  diagnostics summary buffer",
+			"E  recent status messages",
+			"C  word/line/char count",
+			"D/G  unique lines (adjacent/all)",
+			"|  align lines on a delimiter",
+			"U  cycle case UPPER/lower/Title",
+			"M  renumber ordered list",
+			"P  Markdown preview",
+			"O  set option (numbers, ruler...)",
+			"z/Z  record macro / replay",
+			"y  repeat last edit",
+			":  command palette",
 		},
 	},
 	{
 		title: "Navigation",
 		items: []string{
+			"1-9  count for the next move",
+			"arrows  extend selection (as Shift)",
 			",  page up",
 			".  page down",
 			"Space  less mode",
+			"V/p  split view / switch pane",
 			"Esc  close current buffer",
 		},
 	},
@@ -568,6 +1010,7 @@ var escHelpCategories = []escShortcutCategory{
 		title: "Session",
 		items: []string{
 			"Q  quit all buffers",
+			"Y  restart gopls",
 			"Delete  clear buffer contents",
 		},
 	},
@@ -676,7 +1119,7 @@ func renderData(app *appState) ([]string, [][]tokenStyle, string, []int) {
 		return app.render.lines, app.render.lineStyles, app.render.langMode, nil
 	}
 
-	lines := editor.SplitLines(app.ed.Runes())
+	lines := app.ed.Lines()
 	if len(lines) == 0 {
 		lines = []string{""}
 	}
@@ -689,6 +1132,9 @@ func renderData(app *appState) ([]string, [][]tokenStyle, string, []int) {
 	}
 	src := string(buf)
 	lineStyles := app.syntaxHL.lineStyleForKind(path, src, lines, kind)
+	if strings.HasPrefix(path, runBufferPrefix) {
+		lineStyles = runOutputStyles(lines)
+	}
 	langMode := syntaxKindLabel(kind)
 	if slot != nil {
 		slot.cachedTextRev = textRev
@@ -729,8 +1175,12 @@ func runewidth(r rune) int {
 }
 
 func fillRow(s tcell.Screen, y, w int, st tcell.Style) {
-	for x := range w {
-		s.SetContent(x, y, ' ', nil, st)
+	fillCells(s, 0, y, w, st)
+}
+
+func fillCells(s tcell.Screen, x, y, w int, st tcell.Style) {
+	for i := range w {
+		s.SetContent(x+i, y, ' ', nil, st)
 	}
 }
 
@@ -759,6 +1209,69 @@ type selectionRange struct {
 	b int
 }
 
+// queryMatches marks search/leap hits on one line; offsets are rune columns.
+type queryMatches struct {
+	starts  []int
+	length  int
+	current int // line-relative start of the current match, if on this line
+}
+
+func (m *queryMatches) at(col int) (hit bool, current bool) {
+	if m == nil {
+		return false, false
+	}
+	for _, st := range m.starts {
+		if col >= st && col < st+m.length {
+			return true, st == m.current
+		}
+		if st > col {
+			break
+		}
+	}
+	return false, false
+}
+
+// activeHighlightQuery returns the query whose occurrences should be shown in
+// the viewport and the buffer offset of the current match (-1 if none).
+func activeHighlightQuery(app *appState) ([]rune, int) {
+	if app == nil || app.ed == nil {
+		return nil, -1
+	}
+	if app.searchActive && len(app.searchQuery) > 0 {
+		return app.searchQuery, app.searchLastMatch
+	}
+	if app.ed.Leap.Active && len(app.ed.Leap.Query) > 0 {
+		return app.ed.Leap.Query, app.ed.Leap.LastFoundPos
+	}
+	return nil, -1
+}
+
+// lineMatchOffsets returns the rune offsets of non-overlapping, case-insensitive
+// occurrences of query in line, matching the search/leap semantics.
+func lineMatchOffsets(line string, query []rune) []int {
+	if len(query) == 0 || len(line) < len(query) {
+		return nil
+	}
+	rs := []rune(line)
+	var out []int
+	for i := 0; i+len(query) <= len(rs); {
+		match := true
+		for j, q := range query {
+			if unicode.ToLower(rs[i+j]) != unicode.ToLower(q) {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, i)
+			i += len(query)
+			continue
+		}
+		i++
+	}
+	return out
+}
+
 func drawStyledTUICellLine(
 	s tcell.Screen,
 	x, y int,
@@ -767,7 +1280,13 @@ func drawStyledTUICellLine(
 	base tcell.Style,
 	lineStart int,
 	sel *selectionRange,
+	hits *queryMatches,
+	showWS bool,
 ) {
+	var markers []rune
+	if showWS {
+		markers = whitespaceMarkers(line)
+	}
 	visual := 0
 	i := 0
 	for _, r := range line {
@@ -776,27 +1295,75 @@ func drawStyledTUICellLine(
 			ts = style[i]
 		}
 		st := tuiStyleForToken(base, ts)
+		if hit, cur := hits.at(i); hit {
+			if cur {
+				st = st.Underline(true).Bold(true)
+			} else {
+				st = st.Background(tcell.ColorDarkOliveGreen)
+			}
+		}
 		if sel != nil {
 			abs := lineStart + i
 			if abs >= sel.a && abs < sel.b {
-				st = st.Background(tcell.ColorDarkSlateBlue).Foreground(tcell.ColorWhite)
+				st = st.Background(selectionBackground).Foreground(tcell.ColorWhite)
 			}
 		}
+		var mark rune
+		if i < len(markers) {
+			mark = markers[i]
+		}
+		markSt := st.Foreground(tcell.ColorDimGray)
 		if r == '\t' {
 			next := ((visual / tabWidth) + 1) * tabWidth
 			for visual < next {
-				s.SetContent(x+visual, y, ' ', nil, st)
+				if mark != 0 {
+					s.SetContent(x+visual, y, mark, nil, markSt)
+					mark = ' '
+				} else {
+					s.SetContent(x+visual, y, ' ', nil, st)
+				}
 				visual++
 			}
 			i++
 			continue
 		}
+		if mark != 0 {
+			s.SetContent(x+visual, y, mark, nil, markSt)
+			visual++
+			i++
+			continue
+		}
 		s.SetContent(x+visual, y, r, nil, st)
 		visual++
 		i++
 	}
 }
 
+// whitespaceMarkers returns, per rune of line, the glyph drawn in whitespace
+// mode (0 keeps the rune). Tabs always become an arrow; spaces are only dotted
+// in the leading and trailing runs so code stays readable.
+func whitespaceMarkers(line string) []rune {
+	rs := []rune(line)
+	out := make([]rune, len(rs))
+	lead := 0
+	for lead < len(rs) && (rs[lead] == ' ' || rs[lead] == '\t') {
+		lead++
+	}
+	trail := len(rs)
+	for trail > lead && (rs[trail-1] == ' ' || rs[trail-1] == '\t') {
+		trail--
+	}
+	for i, r := range rs {
+		switch {
+		case r == '\t':
+			out[i] = '→'
+		case r == ' ' && (i < lead || i >= trail):
+			out[i] = '·'
+		}
+	}
+	return out
+}
+
 func tuiStyleForToken(base tcell.Style, ts tokenStyle) tcell.Style {
 	switch ts {
 	case styleKeyword:
@@ -817,6 +1384,10 @@ func tuiStyleForToken(base tcell.Style, ts tokenStyle) tcell.Style {
 		return base.Foreground(tcell.ColorLightCyan)
 	case stylePunctuation:
 		return base.Foreground(tcell.ColorThistle)
+	case styleTodo:
+		return base.Foreground(tcell.ColorGold).Bold(true)
+	case styleError:
+		return base.Foreground(tcell.ColorTomato)
 	default:
 		return base
 	}
@@ -844,6 +1415,8 @@ func ctrlRuneToKey(r rune) (keyCode, bool) {
 		return keyK, true
 	case 'u':
 		return keyU, true
+	case 'y':
+		return keyY, true
 	case 'c':
 		return keyC, true
 	case 'x':
@@ -930,10 +1503,30 @@ func runeToKeyCode(r rune) (keyCode, bool) {
 		return keyComma, true
 	case '>':
 		return keyPeriod, true
-	case '-':
+	case '-', '_':
 		return keyMinus, true
-	case '=':
+	case '=', '+':
 		return keyEquals, true
+	case '(':
+		return keyParen, true
+	case '$':
+		return keyDollar, true
+	case '!':
+		return keyBang, true
+	case '#':
+		return keyHash, true
+	case '`', '~':
+		return keyBacktick, true
+	case '[', '{':
+		return keyBracketL, true
+	case ']', '}':
+		return keyBracketR, true
+	case '\\', '|':
+		return keyBackslash, true
+	case ';', ':':
+		return keySemicolon, true
+	case '\'', '"':
+		return keyQuote, true
 	case ' ':
 		return keySpace, true
 	}
@@ -945,7 +1538,7 @@ func inferShiftFromRune(r rune) bool {
 		return true
 	}
 	switch r {
-	case '<', '>', '?', '_', '+':
+	case '<', '>', '?', '_', '+', '|', ':', '"', '~', '{', '}':
 		return true
 	default:
 		return false
@@ -973,11 +1566,7 @@ func drawTUISymbolPopup(s tcell.Screen, app *appState, w, h int) {
 		Foreground(tcell.ColorLightGreen).
 		Attributes(tcell.AttrItalic)
 
-	boxW := min(w-6, 88)
-	if boxW < 32 {
-		boxW = w - 2
-	}
-	boxH := max(min(h-4, 16), 6)
+	boxW, boxH := symbolPopupSize(w, h)
 	x := max(1, (w-boxW)/2)
 	y := max(1, (h-boxH)/2)
 
@@ -1009,13 +1598,70 @@ func drawTUISymbolPopup(s tcell.Screen, app *appState, w, h int) {
 	contentW := boxW - 4
 	lines := wrapPopupText(app.symbolInfoPopup, max(10, contentW))
 	maxLines := boxH - 4
+	matches := popupMatches(lines, app.symbolInfoQuery)
 	start := clamp(app.symbolInfoScroll, 0, max(0, len(lines)-1))
 	visible := popupVisibleLines(lines, start, maxLines)
 	for i := range visible {
 		st := symbolPopupLineStyle(visible[i], bg, code)
-		drawCellText(s, x+2, y+2+i, padRight(visible[i], contentW), st)
+		drawCellText(s, x+2, y+2+i, padRight(expandTabs(visible[i]), contentW), st)
+	}
+	for i, m := range matches {
+		row := m.line - start
+		if row < 0 || row >= len(visible) {
+			continue
+		}
+		line := lines[m.line]
+		from := visualColForRuneCol(line, m.col, tabWidth)
+		to := min(contentW, visualColForRuneCol(line, m.col+len(app.symbolInfoQuery), tabWidth))
+		for c := from; c < to; c++ {
+			str, st, _ := s.Get(x+2+c, y+2+row)
+			r := ' '
+			if str != "" {
+				r = []rune(str)[0]
+			}
+			if i == app.symbolInfoMatch {
+				st = st.Underline(true).Bold(true)
+			} else {
+				st = st.Background(tcell.ColorDarkOliveGreen)
+			}
+			s.SetContent(x+2+c, y+2+row, r, nil, st)
+		}
+	}
+	footer := "Esc close, Ctrl+F search, Ctrl+C copy"
+	if app.symbolInfoSearch || len(app.symbolInfoQuery) > 0 {
+		footer = symbolInfoSearchFooter(app, len(matches))
+	}
+	drawCellText(s, x+2, y+boxH-2, padRight(footer, contentW), dim)
+}
+
+// symbolPopupSize is the symbol-info popup's box size on a w×h screen; the
+// text inside is 4 columns narrower and 4 rows shorter.
+func symbolPopupSize(w, h int) (boxW, boxH int) {
+	boxW = min(w-6, 88)
+	if boxW < 32 {
+		boxW = w - 2
+	}
+	return boxW, max(min(h-4, 16), 6)
+}
+
+// expandTabs replaces each tab with spaces up to the next tabWidth stop.
+func expandTabs(line string) string {
+	if !strings.ContainsRune(line, '\t') {
+		return line
+	}
+	var b strings.Builder
+	vis := 0
+	for _, r := range line {
+		if r == '\t' {
+			next := (vis/tabWidth + 1) * tabWidth
+			b.WriteString(strings.Repeat(" ", next-vis))
+			vis = next
+			continue
+		}
+		b.WriteRune(r)
+		vis++
 	}
-	drawCellText(s, x+2, y+boxH-2, padRight("Esc close", contentW), dim)
+	return b.String()
 }
 
 func symbolPopupLineStyle(line string, base, code tcell.Style) tcell.Style {
@@ -1042,6 +1688,54 @@ func drawTUICompletionPopup(s tcell.Screen, app *appState, w, h int) {
 	if app == nil || !app.completionPopup.active || len(app.completionPopup.items) == 0 {
 		return
 	}
+	header := app.completionPopup.title
+	if strings.TrimSpace(header) == "" {
+		header = "Completion"
+	}
+	rows := make([]string, len(app.completionPopup.items))
+	for i, item := range app.completionPopup.items {
+		rows[i] = completionPopupLine(item)
+	}
+	drawTUIListPopup(s, w, h, header, rows, app.completionPopup.selected, "Tab/Shift+Tab choose, Enter apply, Esc cancel")
+}
+
+// drawTUIPalettePopup draws the command palette with the query as its header.
+func drawTUIPalettePopup(s tcell.Screen, app *appState, w, h int) {
+	if app == nil || !app.palette.active {
+		return
+	}
+	rows := make([]string, len(app.palette.matches))
+	for i, spec := range app.palette.matches {
+		rows[i] = paletteLine(spec)
+	}
+	if len(rows) == 0 {
+		rows = []string{"(no matching commands)"}
+	}
+	drawTUIListPopup(s, w, h, "Command: "+string(app.palette.query), rows, app.palette.selected, "Type to filter, Tab/Up/Down choose, Enter run, Esc cancel")
+}
+
+// drawTUIOutlinePopup draws the heading, symbol or marker outline.
+func drawTUIOutlinePopup(s tcell.Screen, app *appState, w, h int) {
+	rows := make([]string, len(app.outline.items))
+	for i, it := range app.outline.items {
+		rows[i] = outlineLine(it)
+	}
+	drawTUIListPopup(s, w, h, cmp.Or(app.outline.title, "Outline")+": "+bufferLabel(app), rows, app.outline.selected, "Up/Down choose, Enter jump, Esc close")
+}
+
+// drawTUICodeActionPopup draws the quick fixes offered for a line.
+func drawTUICodeActionPopup(s tcell.Screen, app *appState, w, h int) {
+	rows := make([]string, len(app.codeActions.items))
+	for i, act := range app.codeActions.items {
+		rows[i] = act.title
+	}
+	header := fmt.Sprintf("Quick fixes: line %d", app.codeActions.line+1)
+	drawTUIListPopup(s, w, h, header, rows, app.codeActions.selected, "Up/Down choose, Enter apply, Esc close")
+}
+
+// drawTUIListPopup draws a bordered list box above the status line with a
+// header row, up to ten rows scrolled to keep selected visible, and a footer.
+func drawTUIListPopup(s tcell.Screen, w, h int, header string, items []string, selected int, footer string) {
 	bg := tcell.StyleDefault.Background(tcell.ColorDarkSlateGray).Foreground(tcell.ColorWhite)
 	border := tcell.StyleDefault.Background(tcell.ColorDarkSlateGray).Foreground(tcell.ColorLightCyan)
 	title := tcell.StyleDefault.Background(tcell.ColorDarkSlateGray).Foreground(tcell.ColorLightYellow)
@@ -1052,7 +1746,7 @@ func drawTUICompletionPopup(s tcell.Screen, app *appState, w, h int) {
 	if boxW < 44 {
 		boxW = w - 2
 	}
-	maxRows := min(len(app.completionPopup.items), 10)
+	maxRows := min(len(items), 10)
 	boxH := max(6, maxRows+4)
 	boxH = min(boxH, h-2)
 	x := max(1, w-boxW-1)
@@ -1081,31 +1775,25 @@ func drawTUICompletionPopup(s tcell.Screen, app *appState, w, h int) {
 			s.SetContent(x+xx, y+yy, ch, nil, st)
 		}
 	}
-	header := app.completionPopup.title
-	if strings.TrimSpace(header) == "" {
-		header = "Completion"
-	}
 	drawCellText(s, x+2, y+1, padRight(header, boxW-4), title)
 
 	rows := boxH - 3
 	start := 0
-	if app.completionPopup.selected >= rows {
-		start = app.completionPopup.selected - rows + 1
+	if selected >= rows {
+		start = selected - rows + 1
 	}
 	for row := range rows {
 		idx := start + row
-		if idx >= len(app.completionPopup.items) {
+		if idx >= len(items) {
 			break
 		}
-		item := app.completionPopup.items[idx]
-		line := completionPopupLine(item)
 		st := bg
-		if idx == app.completionPopup.selected {
+		if idx == selected {
 			st = sel
 		}
-		drawCellText(s, x+2, y+2+row, padRight(line, boxW-4), st)
+		drawCellText(s, x+2, y+2+row, padRight(items[idx], boxW-4), st)
 	}
-	drawCellText(s, x+2, y+boxH-2, padRight("Tab/Shift+Tab choose, Enter apply, Esc cancel", boxW-4), dim)
+	drawCellText(s, x+2, y+boxH-2, padRight(footer, boxW-4), dim)
 }
 
 func completionPopupLine(item completionItem) string {
@@ -1130,22 +1818,79 @@ func drawTUICompletionDetailPopup(s tcell.Screen, app *appState, w, h int) {
 		return
 	}
 	bg := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
-	border := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkCyan)
-	title := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightYellow)
+	x, y, contentW, maxLines := drawTUIDetailBox(s, w, h, 14, "Completion Details")
+	lines := wrapPopupText(text, max(12, contentW))
+	for i := 0; i < maxLines && i < len(lines); i++ {
+		st := symbolPopupLineStyle(lines[i], bg, tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightGreen).Attributes(tcell.AttrItalic))
+		drawCellText(s, x+2, y+2+i, padRight(lines[i], contentW), st)
+	}
+}
+
+// drawTUISignatureHelp shows the signature of the call being typed in the
+// detail-popup box, with the active parameter highlighted.
+func drawTUISignatureHelp(s tcell.Screen, app *appState, w, h int) {
+	if app == nil || !app.sigHelpActive {
+		return
+	}
+	label := []rune(app.sigHelp.Label)
+	if len(label) == 0 {
+		return
+	}
+	bg := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
+	active := tcell.StyleDefault.Background(tcell.ColorDarkCyan).Foreground(tcell.ColorWhite).Bold(true)
+	contentW := detailBoxWidth(w) - 4
+	if contentW < 1 {
+		return
+	}
+	labelRows := (len(label) + contentW - 1) / contentW
+	var doc []string
+	if text := strings.TrimSpace(app.sigHelp.Doc); text != "" {
+		doc = wrapPopupText(text, contentW)
+	}
+	x, y, contentW, maxLines := drawTUIDetailBox(s, w, h, labelRows+len(doc)+3, "Signature")
+	start, end, hasActive := app.sigHelp.activeRange()
+	for i, r := range label {
+		row := i / contentW
+		if row >= maxLines {
+			break
+		}
+		st := bg
+		if hasActive && i >= start && i < end {
+			st = active
+		}
+		s.SetContent(x+2+i%contentW, y+2+row, r, nil, st)
+	}
+	for i := 0; labelRows+i < maxLines && i < len(doc); i++ {
+		drawCellText(s, x+2, y+2+labelRows+i, padRight(doc[i], contentW), bg)
+	}
+}
 
+func detailBoxWidth(w int) int {
 	boxW := min(w-8, 88)
 	if boxW < 36 {
 		boxW = w - 2
 	}
-	boxH := min(h-4, 14)
+	return boxW
+}
+
+// drawTUIDetailBox draws the bordered upper-right popup frame used for
+// completion details and signature help, at most maxH rows tall. It returns
+// the box origin, the usable content width, and the number of content rows.
+func drawTUIDetailBox(s tcell.Screen, w, h, maxH int, heading string) (x, y, contentW, maxLines int) {
+	bg := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
+	border := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorDarkCyan)
+	title := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightYellow)
+
+	boxW := detailBoxWidth(w)
+	boxH := min(h-4, maxH)
 	if boxH < 6 {
-		boxH = h - 2
+		boxH = min(h-2, max(maxH, 6))
 	}
-	x := max(1, w-boxW-1)
-	y := 1
+	x = max(1, w-boxW-1)
+	y = 1
 
 	for yy := range boxH {
-		for xx := 0; xx < boxW; xx++ {
+		for xx := range boxW {
 			ch := ' '
 			st := bg
 			if yy == 0 || yy == boxH-1 || xx == 0 || xx == boxW-1 {
@@ -1167,12 +1912,6 @@ func drawTUICompletionDetailPopup(s tcell.Screen, app *appState, w, h int) {
 			s.SetContent(x+xx, y+yy, ch, nil, st)
 		}
 	}
-	drawCellText(s, x+2, y+1, padRight("Completion Details", boxW-4), title)
-	contentW := boxW - 4
-	lines := wrapPopupText(text, max(12, contentW))
-	maxLines := boxH - 3
-	for i := 0; i < maxLines && i < len(lines); i++ {
-		st := symbolPopupLineStyle(lines[i], bg, tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorLightGreen).Attributes(tcell.AttrItalic))
-		drawCellText(s, x+2, y+2+i, padRight(lines[i], contentW), st)
-	}
+	drawCellText(s, x+2, y+1, padRight(heading, boxW-4), title)
+	return x, y, boxW - 4, boxH - 3
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -76,7 +77,7 @@ func TestDrawStyledTUICellLine_TabKeepsStyleAlignment(t *testing.T) {
 	base := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorBlack)
 	line := "\tif x"
 	styles := []tokenStyle{styleDefault, styleKeyword, styleKeyword, styleDefault, styleDefault}
-	drawStyledTUICellLine(s, 0, 0, line, styles, base, 0, nil)
+	drawStyledTUICellLine(s, 0, 0, line, styles, base, 0, nil, nil, false)
 
 	_, got, _ := s.Get(tabWidth, 0)
 	gotFg, _, _ := got.Decompose()
@@ -236,6 +237,190 @@ func TestTUIEscPrefixThenFInvokesFormat(t *testing.T) {
 	}
 }
 
+func TestFormatWithFmtDiffKeepsCaretOnItsText(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package main\n\nfunc main() {\n    println(1)\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = path
+	app.buffers[0].path = path
+	if _, err := applyOption(&app, "fmtdiff=on"); err != nil {
+		t.Fatalf("fmtdiff=on: %v", err)
+	}
+	app.ed.Caret = strings.Index(src, "(1)")
+
+	formatted := "package main\n\nfunc main() {\n\tprintln(1)\n}\n"
+	oldRun := runFmtFix
+	defer func() { runFmtFix = oldRun }()
+	runFmtFix = func(p string) error {
+		return os.WriteFile(p, []byte(formatted), 0644)
+	}
+	if err := formatFixReloadCurrent(&app); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if got := app.ed.String(); got != formatted {
+		t.Fatalf("buffer=%q", got)
+	}
+	if want := strings.Index(formatted, "(1)"); app.ed.Caret != want {
+		t.Fatalf("caret=%d, want %d (still before \"(1)\")", app.ed.Caret, want)
+	}
+	if app.buffers[0].dirty {
+		t.Fatal("formatted buffer matches the file and should be clean")
+	}
+	app.ed.Undo()
+	if got := app.ed.String(); got != src {
+		t.Fatalf("one undo should restore the unformatted text, got %q", got)
+	}
+}
+
+func TestFormatOrganizesImportsWithStubbedEdits(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tfmt.Println(1)\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = path
+	app.buffers[0].path = path
+	if _, err := applyOption(&app, "organizeimports=on"); err != nil {
+		t.Fatalf("organizeimports=on: %v", err)
+	}
+	app.ed.Caret = strings.Index(src, "Println")
+
+	oldRun, oldOrganize := runFmtFix, goOrganizeImports
+	defer func() { runFmtFix, goOrganizeImports = oldRun, oldOrganize }()
+	runFmtFix = func(string) error { return nil }
+	goOrganizeImports = func(_ *appState, _ string, content string) ([]textEdit, error) {
+		if content != src {
+			t.Fatalf("organize got %q", content)
+		}
+		// Drop the unused "os" line, as gopls does.
+		var e textEdit
+		e.Range.Start = lspPosition{Line: 4, Character: 0}
+		e.Range.End = lspPosition{Line: 5, Character: 0}
+		return []textEdit{e}, nil
+	}
+	if err := formatFixReloadCurrent(&app); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	want := "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(1)\n}\n"
+	if got := app.ed.String(); got != want {
+		t.Fatalf("buffer=%q", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Fatalf("saved file=%q", data)
+	}
+	if app.ed.Caret != strings.Index(want, "Println") {
+		t.Fatalf("caret=%d, want it still on Println", app.ed.Caret)
+	}
+
+	// Without the option the edits are not asked for.
+	app.organizeImports = false
+	goOrganizeImports = func(*appState, string, string) ([]textEdit, error) {
+		t.Fatal("organize imports should be off")
+		return nil, nil
+	}
+	if err := formatFixReloadCurrent(&app); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+}
+
+func TestApplyTextEditsKeepsInsertOrder(t *testing.T) {
+	var a, b textEdit
+	a.Range.Start, a.Range.End = lspPosition{Line: 1, Character: 0}, lspPosition{Line: 1, Character: 0}
+	a.NewText = "x"
+	b.Range = a.Range
+	b.NewText = "y"
+	got, err := applyTextEdits([]rune("ab\ncd"), []textEdit{a, b})
+	if err != nil || string(got) != "ab\nxycd" {
+		t.Fatalf("got %q, %v", string(got), err)
+	}
+	a.Range.End.Line = 3
+	if _, err := applyTextEdits([]rune("ab"), []textEdit{a}); err == nil {
+		t.Fatal("an edit past the end should fail")
+	}
+}
+
+func TestGoplsClientConvertsUTF16Columns(t *testing.T) {
+	line := "s := \"😀\" + x"
+	if got := utf16Col(line, 9); got != 10 {
+		t.Fatalf("utf16Col = %d, want 10", got)
+	}
+	if got := runeColFromUTF16(line, 10); got != 9 {
+		t.Fatalf("runeColFromUTF16 = %d, want 9", got)
+	}
+	if got := runeColFromUTF16(line, 7); got != 6 {
+		t.Fatalf("offset inside a surrogate pair = %d, want 6", got)
+	}
+
+	// An edit replacing x, as gopls reports it in UTF-16 units.
+	var e textEdit
+	e.Range.Start, e.Range.End = lspPosition{Line: 0, Character: 12}, lspPosition{Line: 0, Character: 13}
+	e.NewText = "y"
+	edits := []textEdit{e}
+	c := &goplsClient{utf16: true}
+	c.editsToRuneCols([]string{line}, edits)
+	got, err := applyTextEdits([]rune(line), edits)
+	if err != nil || string(got) != "s := \"😀\" + y" {
+		t.Fatalf("got %q, %v", string(got), err)
+	}
+	if p := c.position(line, 0, 12); p["character"] != 13 {
+		t.Fatalf("position = %v, want character 13", p)
+	}
+	c.utf16 = false
+	if p := c.position(line, 0, 12); p["character"] != 12 {
+		t.Fatalf("utf-32 position = %v, want character 12", p)
+	}
+}
+
+func TestTUIEscUnderscoreJumpsForward(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha\nbeta\n"))
+	first := app.ed
+	app.addBuffer()
+	app.ed.InsertText("other")
+	second := app.ed
+	recordJump(&app)
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	first.Caret = 2
+
+	press := func(r rune) {
+		t.Helper()
+		handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+		handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	press('-')
+	if app.ed != second {
+		t.Fatalf("Esc - should jump back to the second buffer (%s)", app.lastEvent)
+	}
+	press('_')
+	if app.ed != first || first.Caret != 2 {
+		t.Fatalf("Esc _ should jump forward to the first buffer, caret=%d (%s)", app.ed.Caret, app.lastEvent)
+	}
+	if first.String() != "alpha\nbeta\n" {
+		t.Fatalf("prefixed _ must not insert text: %q", first.String())
+	}
+}
+
+func TestTUIEscPlusIncrementsNumber(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("n = 9\n"))
+	app.ed.Caret = 2
+
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, '+', 0))
+	if got := app.ed.String(); got != "n = 10\n" {
+		t.Fatalf("Esc + should increment the number: %q (%s)", got, app.lastEvent)
+	}
+}
+
 func TestTUIEscPrefixWPromptsAndSavesToProvidedFilename(t *testing.T) {
 	dir := t.TempDir()
 	app := appState{openRoot: dir}
@@ -373,6 +558,88 @@ func TestTUIEscPrefixCommandDoesNotSwallowNextTypedRune(t *testing.T) {
 	}
 }
 
+func TestTUIEscJAndBacktickLeapAndCommit(t *testing.T) {
+	src := "alpha beta gamma beta"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.ed.Caret = 7
+	keys := func(evs ...*tcell.EventKey) {
+		t.Helper()
+		for _, ev := range evs {
+			if !handleTUIKey(&app, ev) {
+				t.Fatalf("key %v should continue", ev.Name())
+			}
+		}
+	}
+	esc := tcell.NewEventKey(tcell.KeyEscape, 0, 0)
+	r := func(c rune) *tcell.EventKey { return tcell.NewEventKey(tcell.KeyRune, c, 0) }
+
+	keys(esc, r('j'))
+	if !app.ed.Leap.Active || app.ed.Leap.Dir != editor.DirFwd || app.ed.Leap.Selecting {
+		t.Fatalf("Esc+j should start a forward leap (%s)", app.lastEvent)
+	}
+	keys(r('b'), r('e'))
+	if app.ed.Caret != 17 || app.ed.String() != src {
+		t.Fatalf("leap query should move to the next \"be\" without typing it: caret %d, text %q", app.ed.Caret, app.ed.String())
+	}
+	keys(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if app.ed.Leap.Active || app.ed.Caret != 17 || string(app.ed.Leap.LastCommit) != "be" {
+		t.Fatalf("Enter should commit: active=%v caret=%d last=%q", app.ed.Leap.Active, app.ed.Caret, string(app.ed.Leap.LastCommit))
+	}
+
+	keys(esc, r('`'))
+	if !app.ed.Leap.Active || app.ed.Leap.Dir != editor.DirBack {
+		t.Fatalf("Esc+` should start a backward leap (%s)", app.lastEvent)
+	}
+	keys(r('a'), r('l'))
+	if app.ed.Caret != 0 {
+		t.Fatalf("backward leap to \"al\": caret %d", app.ed.Caret)
+	}
+	keys(esc)
+	if app.ed.Leap.Active || app.ed.Caret != 17 {
+		t.Fatalf("Esc should cancel back to the origin: caret %d", app.ed.Caret)
+	}
+}
+
+func TestTUILeapAgainStepsAndWraps(t *testing.T) {
+	src := "be one be two be"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	esc := tcell.NewEventKey(tcell.KeyEscape, 0, 0)
+	r := func(c rune) *tcell.EventKey { return tcell.NewEventKey(tcell.KeyRune, c, 0) }
+	keys := func(evs ...*tcell.EventKey) {
+		t.Helper()
+		for _, ev := range evs {
+			handleTUIKey(&app, ev)
+		}
+	}
+
+	keys(esc, r('n'))
+	if app.lastEvent != "LEAP ERR: no committed leap to repeat" {
+		t.Fatalf("leap again before any leap: %q", app.lastEvent)
+	}
+	app.ed.Caret = 1
+	keys(esc, r('j'), r('b'), r('e'), tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if app.ed.Caret != 7 {
+		t.Fatalf("committed leap: caret %d", app.ed.Caret)
+	}
+	for _, want := range []int{14, 0, 7} {
+		keys(esc, r('n'))
+		if app.ed.Caret != want || app.lastEvent != `Leap again: "be"` {
+			t.Fatalf("Esc+n: caret %d want %d (%s)", app.ed.Caret, want, app.lastEvent)
+		}
+	}
+	for _, want := range []int{0, 14} {
+		keys(esc, r('N'))
+		if app.ed.Caret != want {
+			t.Fatalf("Esc+Shift+N: caret %d want %d (%s)", app.ed.Caret, want, app.lastEvent)
+		}
+	}
+	if app.ed.String() != src {
+		t.Fatalf("leaping should not edit: %q", app.ed.String())
+	}
+}
+
 func TestTUIShiftArrowsActivateSelection(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -1103,3 +1370,581 @@ func TestRenderDataStartupFastSkipsHighlightOnce(t *testing.T) {
 		t.Fatalf("expected second render to include highlighting")
 	}
 }
+
+func TestLineMatchOffsets(t *testing.T) {
+	cases := []struct {
+		line  string
+		query string
+		want  []int
+	}{
+		{"Go go GO", "go", []int{0, 3, 6}},
+		{"banana", "ana", []int{1}},
+		{"aaaa", "aa", []int{0, 2}},
+		{"héllo héllo", "LLO", []int{2, 8}},
+		{"abc", "", nil},
+		{"ab", "abc", nil},
+	}
+	for _, tc := range cases {
+		got := lineMatchOffsets(tc.line, []rune(tc.query))
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("lineMatchOffsets(%q, %q)=%v, want %v", tc.line, tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestDrawStyledTUICellLine_HighlightsQueryMatches(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+
+	base := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorBlack)
+	line := "ab ab ab"
+	hits := &queryMatches{starts: lineMatchOffsets(line, []rune("ab")), length: 2, current: 3}
+	drawStyledTUICellLine(s, 0, 0, line, nil, base, 0, nil, hits, false)
+
+	_, other, _ := s.Get(0, 0)
+	if _, bg, _ := other.Decompose(); bg != tcell.ColorDarkOliveGreen {
+		t.Fatalf("non-current match background=%v", bg)
+	}
+	_, cur, _ := s.Get(4, 0)
+	if cur.GetUnderlineStyle() == tcell.UnderlineStyleNone {
+		t.Fatalf("current match should be underlined")
+	}
+	_, plain, _ := s.Get(2, 0)
+	if _, bg, _ := plain.Decompose(); bg != tcell.ColorBlack {
+		t.Fatalf("gap between matches should keep base background, got %v", bg)
+	}
+}
+
+func TestActiveHighlightQueryPrefersSearchThenLeap(t *testing.T) {
+	app := &appState{}
+	app.initBuffers(editor.NewEditor("x go go"))
+	if q, _ := activeHighlightQuery(app); q != nil {
+		t.Fatalf("no query expected outside search/leap")
+	}
+	app.ed.LeapStart(editor.DirFwd)
+	app.ed.LeapAppend("go")
+	if q, cur := activeHighlightQuery(app); string(q) != "go" || cur != 2 {
+		t.Fatalf("leap query=%q cur=%d", string(q), cur)
+	}
+	app.searchActive = true
+	app.searchQuery = []rune("x")
+	app.searchLastMatch = 0
+	if q, cur := activeHighlightQuery(app); string(q) != "x" || cur != 0 {
+		t.Fatalf("search query=%q cur=%d", string(q), cur)
+	}
+}
+
+func TestSplitPaneWidths(t *testing.T) {
+	cases := []struct{ w, left, right int }{
+		{80, 39, 40},
+		{81, 40, 40},
+		{3, 1, 1},
+		{2, 2, 0},
+	}
+	for _, tc := range cases {
+		l, r := splitPaneWidths(tc.w)
+		if l != tc.left || r != tc.right {
+			t.Fatalf("splitPaneWidths(%d)=(%d,%d), want (%d,%d)", tc.w, l, r, tc.left, tc.right)
+		}
+		if tc.right > 0 && l+r+1 != tc.w {
+			t.Fatalf("panes plus divider should fill width %d", tc.w)
+		}
+	}
+}
+
+func TestSplitFocusSwitchSwapsActiveBufferAndScroll(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("left"))
+	app.addBuffer()
+	app.ed.SetRunes([]rune("right"))
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	app.scrollLine = 7
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modShift})
+	if !app.splitActive || app.splitOther != 1 || app.bufIdx != 0 || app.splitFocusRight {
+		t.Fatalf("split on: active=%v other=%d buf=%d right=%v", app.splitActive, app.splitOther, app.bufIdx, app.splitFocusRight)
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyP})
+	handleTextEvent(&app, "p", 0)
+	if app.bufIdx != 1 || app.splitOther != 0 || !app.splitFocusRight {
+		t.Fatalf("focus switch: buf=%d other=%d right=%v", app.bufIdx, app.splitOther, app.splitFocusRight)
+	}
+	if app.scrollLine != 0 || app.splitOtherScroll != 7 {
+		t.Fatalf("scroll should follow panes: focused=%d other=%d", app.scrollLine, app.splitOtherScroll)
+	}
+	app.ed.Caret = app.ed.RuneLen()
+	handleTextEvent(&app, "!", 0)
+	if app.buffers[1].ed.String() != "right!" || app.buffers[0].ed.String() != "left" {
+		t.Fatalf("typing should go to the focused pane only")
+	}
+
+	handleKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+	handleKeyEvent(&app, keyEvent{down: true, key: keyV, mods: modShift})
+	if app.splitActive {
+		t.Fatalf("second Esc+Shift+V should turn split off")
+	}
+}
+
+func TestDrawTUISplitRendersBothPanes(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(41, 10)
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+	app.addBuffer()
+	app.ed.SetRunes([]rune("bbb"))
+	app.bufIdx = 0
+	app.syncActiveBuffer()
+	app.toggleSplit()
+
+	drawTUI(s, &app)
+
+	leftW, _ := splitPaneWidths(41)
+	if str, _, _ := s.Get(leftW, 0); str != "│" {
+		t.Fatalf("expected divider at column %d, got %q", leftW, str)
+	}
+	if str, _, _ := s.Get(leftW+1+5, 0); str != "b" {
+		t.Fatalf("right pane should show the other buffer, got %q", str)
+	}
+	if str, _, _ := s.Get(leftW-1, 0); str != "a" {
+		t.Fatalf("left pane should show the active buffer, got %q", str)
+	}
+}
+
+func TestWhitespaceMarkersLeadingTabsAndTrailingSpaces(t *testing.T) {
+	got := whitespaceMarkers("\t\tx = a\tb  ")
+	want := []rune{'→', '→', 0, 0, 0, 0, 0, '→', 0, '·', '·'}
+	if !slices.Equal(got, want) {
+		t.Fatalf("markers=%q, want %q", got, want)
+	}
+	if got := whitespaceMarkers("  "); !slices.Equal(got, []rune{'·', '·'}) {
+		t.Fatalf("blank line markers=%q", got)
+	}
+}
+
+func TestIndentLevelTabsAndSpaces(t *testing.T) {
+	cases := []struct {
+		line string
+		step int
+		want int
+	}{
+		{"x := 1", tabWidth, 0},
+		{"\tx := 1", tabWidth, 1},
+		{"\t\tx := 1", tabWidth, 2},
+		{"    x", 4, 1},
+		{"        x", 4, 2},
+		{"      x", 4, 1},
+		{"    x", 2, 2},
+		{"  \tx", tabWidth, 1},
+		{"\t\t", tabWidth, 2},
+	}
+	for _, tc := range cases {
+		if got := indentLevel(tc.line, tc.step); got != tc.want {
+			t.Fatalf("indentLevel(%q, %d) = %d, want %d", tc.line, tc.step, got, tc.want)
+		}
+	}
+}
+
+func TestIndentGuideLevelsCarryThroughBlankLines(t *testing.T) {
+	lines := []string{
+		"func f() {",
+		"\tif x {",
+		"\t\ty()",
+		"",
+		"\t\tz()",
+		"\t}",
+		"",
+		"}",
+	}
+	got := indentGuideLevels(lines, 0, len(lines)-1, tabWidth)
+	want := []int{0, 1, 2, 2, 2, 1, 0, 0}
+	if !slices.Equal(got, want) {
+		t.Fatalf("levels=%v, want %v", got, want)
+	}
+	if got := indentGuideLevels(lines, 2, 4, tabWidth); !slices.Equal(got, []int{2, 2, 2}) {
+		t.Fatalf("visible range levels=%v", got)
+	}
+	spaces := []string{"def f():", "    if x:", "        y()", "    return"}
+	if got := indentGuideLevels(spaces, 0, 3, 4); !slices.Equal(got, []int{0, 1, 2, 1}) {
+		t.Fatalf("space levels=%v", got)
+	}
+}
+
+func TestDrawTUIIndentGuidesBehindText(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("func f() {\n\tif x {\n\t\ty()\n\t}\n}\n"))
+	app.currentPath = "main.go"
+	app.buffers[0].path = "main.go"
+	app.ed.Caret = 0
+	drawTUI(s, &app)
+
+	x := gutterWidth(&app)
+	if str, _, _ := s.Get(x, 2); str != "│" {
+		t.Fatalf("level 1 guide=%q, want │", str)
+	}
+	if str, _, _ := s.Get(x+tabWidth, 2); str != "│" {
+		t.Fatalf("level 2 guide=%q, want │", str)
+	}
+	if str, _, _ := s.Get(x+2*tabWidth, 2); str != "y" {
+		t.Fatalf("text should stay on top of guides, got %q", str)
+	}
+	if str, _, _ := s.Get(x, 0); str != "f" {
+		t.Fatalf("unindented line should have no guide, got %q", str)
+	}
+
+	if _, err := applyOption(&app, "guides=off"); err != nil {
+		t.Fatalf("guides=off: %v", err)
+	}
+	drawTUI(s, &app)
+	if str, _, _ := s.Get(x, 2); str != " " {
+		t.Fatalf("guides=off should hide guides, got %q", str)
+	}
+}
+
+func TestInlayHintVisualColCountsHintsAtOrBeforeColumn(t *testing.T) {
+	line := "\tf(1, x)"
+	hints := []inlayHint{{col: 3, label: "a: "}, {col: 6, label: "b: "}}
+	for _, tc := range []struct{ col, want int }{
+		{0, 0},
+		{2, tabWidth + 1},
+		{3, tabWidth + 2 + 3},
+		{6, tabWidth + 5 + 6},
+		{8, tabWidth + 7 + 6},
+	} {
+		if got := inlayHintVisualCol(line, hints, tc.col); got != tc.want {
+			t.Errorf("col %d -> %d, want %d", tc.col, got, tc.want)
+		}
+	}
+	byLine := inlayHintsByLine([]inlayHint{{line: 1, col: 5, label: "y"}, {line: 1, col: 2, label: "x"}, {line: 0, col: 1, label: "z"}})
+	if len(byLine[0]) != 1 || byLine[1][0].label != "x" || byLine[1][1].label != "y" {
+		t.Fatalf("byLine = %v", byLine)
+	}
+}
+
+func TestDrawTUIInlayHintsToggle(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+
+	src := "package p\n\nvar v = f(1, 2)\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	app.ed.Caret = strings.Index(src, "2)")
+	posted := make(chan any, 1)
+	app.requestInterrupt = func(data any) { posted <- data }
+	old := goInlayHints
+	defer func() { goInlayHints = old }()
+	goInlayHints = func(_ *goplsClient, _ string, content string) ([]inlayHint, error) {
+		return []inlayHint{{line: 2, col: 10, label: "a: "}, {line: 2, col: 13, label: "b: "}}, nil
+	}
+
+	scheduleInlayHints(&app)
+	select {
+	case <-posted:
+		t.Fatal("hints are off by default")
+	case <-time.After(inlayHintDebounce + 100*time.Millisecond):
+	}
+	if got, err := applyOption(&app, "inlayhints"); err != nil || got != "inlayhints=on" {
+		t.Fatalf("bare inlayhints should turn them on: %q, %v", got, err)
+	}
+	scheduleInlayHints(&app)
+	res, ok := (<-posted).(inlayHintsInterrupt)
+	if !ok {
+		t.Fatal("want an inlay hints result")
+	}
+	applyInlayHints(&app, res)
+	drawTUI(s, &app)
+	x := gutterWidth(&app)
+	if got := strings.TrimRight(screenRowText(s, 2, 40)[x:], " "); got != "var v = f(a: 1, b: 2)" {
+		t.Fatalf("row = %q", got)
+	}
+	if cx, cy, _ := s.GetCursor(); cx != x+strings.Index("var v = f(a: 1, b: 2)", "2)") || cy != 2 {
+		t.Fatalf("cursor at %d,%d, want it on the 2 after its hint", cx, cy)
+	}
+	if app.ed.String() != src {
+		t.Fatal("hints must not change the buffer")
+	}
+
+	if got, _ := applyOption(&app, "inlayhints"); got != "inlayhints=off" {
+		t.Fatalf("bare inlayhints should toggle off, got %q", got)
+	}
+	drawTUI(s, &app)
+	if got := strings.TrimRight(screenRowText(s, 2, 40)[x:], " "); got != "var v = f(1, 2)" {
+		t.Fatalf("row after toggling off = %q", got)
+	}
+}
+
+func TestIdentOccurrencesWholeWordsOnVisibleLines(t *testing.T) {
+	lines := []string{
+		"n := 1", // 0: outside the range
+		"n = n + nn + n2 + _n",
+		"fmt.Println(n, N, len(n))",
+		"n++", // 3: outside the range
+	}
+	got := identOccurrences(lines, 1, 2, "n")
+	if len(got) != 2 || got[0] != nil || got[3] != nil {
+		t.Fatalf("occurrences = %v, want lines 1 and 2 only", got)
+	}
+	if want := [][2]int{{0, 1}, {4, 5}}; !slices.Equal(got[1], want) {
+		t.Fatalf("line 1 = %v, want %v", got[1], want)
+	}
+	if want := [][2]int{{12, 13}, {22, 23}}; !slices.Equal(got[2], want) {
+		t.Fatalf("line 2 = %v, want %v", got[2], want)
+	}
+}
+
+func TestDrawTUIHighlightsIdentifierOccurrencesAfterRest(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+
+	src := "package p\n\nfunc f(n int) int {\n\treturn n + nn\n}\n"
+	app := appState{}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "p.go"
+	app.buffers[0].path = "p.go"
+	app.ed.Caret = strings.Index(src, "n int")
+	x := gutterWidth(&app)
+	nCol := x + tabWidth + len("return ")
+	tintedAt := func(x int) bool {
+		_, st, _ := s.Get(x, 3)
+		_, bg, _ := st.Decompose()
+		return bg == occurrenceBackground
+	}
+
+	scheduleOccurrences(&app)
+	drawTUI(s, &app)
+	if tintedAt(nCol) {
+		t.Fatal("occurrences should wait for the caret to rest")
+	}
+	app.occur.since = time.Now().Add(-time.Second)
+	drawTUI(s, &app)
+	if !tintedAt(nCol) {
+		t.Fatal("n should be highlighted once the caret rested")
+	}
+	if tintedAt(nCol + len("n + ")) {
+		t.Fatal("nn is another identifier")
+	}
+
+	app.ed.Caret = strings.Index(src, "func")
+	scheduleOccurrences(&app)
+	app.occur.since = time.Now().Add(-time.Second)
+	drawTUI(s, &app)
+	if tintedAt(nCol) {
+		t.Fatal("moving away should drop the highlight, and keywords get none")
+	}
+}
+
+func TestDrawStyledTUICellLine_ShowsWhitespaceWithoutShiftingText(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+
+	base := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorBlack)
+	drawStyledTUICellLine(s, 0, 0, "\tx ", nil, base, 0, nil, nil, true)
+
+	want := map[int]string{0: "→", 1: " ", tabWidth: "x", tabWidth + 1: "·"}
+	for x, w := range want {
+		if str, _, _ := s.Get(x, 0); str != w {
+			t.Fatalf("cell %d=%q, want %q", x, str, w)
+		}
+	}
+}
+
+func TestDrawTUIFoldedBlockSummary(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(80, 24)
+
+	src := "package main\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n}\nvar x = 1\n"
+	app := appState{syntaxHL: newGoHighlighter(), syntaxCheck: newGoSyntaxChecker()}
+	app.initBuffers(editor.NewEditor(src))
+	app.currentPath = "main.go"
+	app.buffers[0].path = "main.go"
+	app.ed.Caret = strings.Index(src, "{")
+	if err := app.RunCommand(CmdFold, ""); err != nil {
+		t.Fatalf("fold: %v", err)
+	}
+	drawTUI(s, &app)
+	gw := gutterWidth(&app)
+	if got := strings.TrimRight(screenRowText(s, 1, 80)[gw:], " "); got != "func main() { … }" {
+		t.Fatalf("summary row = %q", got)
+	}
+	if got := strings.TrimRight(screenRowText(s, 2, 80)[gw:], " "); got != "var x = 1" {
+		t.Fatalf("row after the fold = %q", got)
+	}
+}
+
+func TestTUIEscParenWrapsWordAndSelection(t *testing.T) {
+	app := appState{}
+	app.initBuffers(editor.NewEditor("make it bold now"))
+	app.ed.Caret = 9
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, '(', 0))
+	if app.inputKind != "wrap" {
+		t.Fatalf("Esc+( should prompt for a delimiter, kind=%q", app.inputKind)
+	}
+	for _, r := range "**" {
+		handleTUIKey(&app, tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	handleTUIKey(&app, tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got := app.ed.String(); got != "make it **bold** now" {
+		t.Fatalf("wrapped = %q (%s)", got, app.lastEvent)
+	}
+	if a, b := app.ed.Sel.Normalised(); !app.ed.Sel.Active || a != 10 || b != 14 {
+		t.Fatalf("selection should cover the inner word: %v", app.ed.Sel)
+	}
+
+	app.ed.Sel = editor.Sel{Active: true, A: 0, B: 7}
+	if err := app.RunCommand(CmdWrap, "["); err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	if got := app.ed.String(); got != "[make it] **bold** now" {
+		t.Fatalf("wrapped selection = %q", got)
+	}
+	for open, want := range map[string]string{"`": "`", "_": "_", "([": "])", "<b>": "</b>", `"`: `"`} {
+		if got := closingDelimiter(open); got != want {
+			t.Fatalf("closer for %q = %q, want %q", open, got, want)
+		}
+	}
+}
+
+func TestDrawTUICurrentLineColorOption(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 10)
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("one\ntwo words\nthree"))
+	app.ed.Caret = 4
+	bg := func(x, y int) tcell.Color {
+		_, st, _ := s.Get(x, y)
+		_, b, _ := st.Decompose()
+		return b
+	}
+	gw := gutterWidth(&app)
+
+	drawTUI(s, &app)
+	if got := bg(gw+20, 1); got != tcell.ColorBlack {
+		t.Fatalf("caret line should not be highlighted by default, bg %v", got)
+	}
+
+	if _, err := applyOption(&app, "curline=#303040"); err != nil {
+		t.Fatal(err)
+	}
+	drawTUI(s, &app)
+	want := tcell.GetColor("#303040")
+	if got := bg(gw+1, 1); got != want {
+		t.Fatalf("caret line text bg %v, want %v", got, want)
+	}
+	if got := bg(gw+20, 1); got != want {
+		t.Fatalf("caret line past the text bg %v, want %v", got, want)
+	}
+	if got := bg(gw+1, 0); got != tcell.ColorBlack {
+		t.Fatalf("other lines keep the base bg, got %v", got)
+	}
+
+	// A selection on the caret line stays distinct from it.
+	app.ed.Sel = editor.Sel{Active: true, A: 4, B: 7}
+	drawTUI(s, &app)
+	if got := bg(gw+1, 1); got == want || got == tcell.ColorBlack {
+		t.Fatalf("selected cell should use the selection color, got %v", got)
+	}
+	app.ed.Sel = editor.Sel{}
+
+	if _, err := applyOption(&app, "curline=off"); err != nil {
+		t.Fatal(err)
+	}
+	drawTUI(s, &app)
+	if got := bg(gw+1, 1); got != tcell.ColorBlack {
+		t.Fatalf("curline=off should drop the highlight, bg %v", got)
+	}
+	if _, err := applyOption(&app, "curline=notacolor"); err == nil {
+		t.Fatalf("unknown color should be rejected")
+	}
+}
+
+func TestNoMatchQueryFlashesInputLine(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init simulation screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(40, 6)
+	cell := func() (tcell.Color, tcell.Color) {
+		_, st, _ := s.Get(0, 5)
+		fg, bg, _ := st.Decompose()
+		return fg, bg
+	}
+
+	app := appState{}
+	app.initBuffers(editor.NewEditor("alpha beta"))
+	app.ed.Caret = 2
+	app.RunCommand(CmdLeapForward, "")
+	dispatchTextEvent(&app, "b", 0)
+	if !app.noMatchAt.IsZero() || app.ed.Caret != 6 {
+		t.Fatalf("matching query: noMatchAt %v, caret %d", app.noMatchAt, app.ed.Caret)
+	}
+	dispatchTextEvent(&app, "x", 0)
+	if app.noMatchAt.IsZero() || app.ed.Caret != 2 {
+		t.Fatalf("no match should set the mark and return to the origin: caret %d", app.ed.Caret)
+	}
+	drawTUI(s, &app)
+	if _, bg := cell(); bg != tcell.ColorDarkRed {
+		t.Fatalf("input line should flash, bg %v", bg)
+	}
+	app.noMatchAt = app.noMatchAt.Add(-noMatchFlash)
+	drawTUI(s, &app)
+	if fg, bg := cell(); bg != tcell.ColorBlack || fg != tcell.ColorIndianRed {
+		t.Fatalf("after the flash the query stays red: fg %v bg %v", fg, bg)
+	}
+	dispatchKeyEvent(&app, keyEvent{down: true, key: keyBackspace})
+	if !app.noMatchAt.IsZero() || app.ed.Caret != 6 {
+		t.Fatalf("matching again should clear the mark: caret %d", app.ed.Caret)
+	}
+	dispatchKeyEvent(&app, keyEvent{down: true, key: keyEscape})
+
+	startSearchMode(&app)
+	dispatchTextEvent(&app, "q", 0)
+	if app.noMatchAt.IsZero() || app.ed.Caret != 2 {
+		t.Fatalf("search without a match: noMatchAt %v, caret %d", app.noMatchAt, app.ed.Caret)
+	}
+	dispatchKeyEvent(&app, keyEvent{down: true, key: keyBackspace})
+	if !app.noMatchAt.IsZero() {
+		t.Fatalf("an empty search query should clear the mark")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gc/editor"
+)
+
+// markdownLinkAt returns the target of the [text](target) link covering rune
+// column col of line.
+func markdownLinkAt(line string, col int) (string, bool) {
+	if col < 0 {
+		return "", false
+	}
+	off := len(line)
+	if col < utf8.RuneCountInString(line) {
+		off = 0
+		for range col {
+			_, size := utf8.DecodeRuneInString(line[off:])
+			off += size
+		}
+	}
+	for _, m := range mdLinkRE.FindAllStringSubmatchIndex(line, -1) {
+		if off >= m[0] && off < m[1] {
+			return strings.TrimSpace(line[m[4]:m[5]]), true
+		}
+	}
+	return "", false
+}
+
+// isExternalLink reports link targets that name a URL rather than a file.
+func isExternalLink(target string) bool {
+	lower := strings.ToLower(target)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// followMarkdownLink opens the relative file linked under the caret in a
+// Markdown buffer, or reports an external URL in the status line. It returns
+// false when there is no link at the caret so Ctrl+L can load the line as a
+// path instead.
+func followMarkdownLink(app *appState) (bool, error) {
+	if app == nil || app.ed == nil || len(app.buffers) == 0 {
+		return false, nil
+	}
+	if bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) != syntaxMarkdown {
+		return false, nil
+	}
+	lines := app.ed.Lines()
+	ln := editor.CaretLineAt(lines, app.ed.Caret)
+	if ln < 0 || ln >= len(lines) {
+		return false, nil
+	}
+	target, ok := markdownLinkAt(lines[ln], editor.CaretColAt(lines, app.ed.Caret))
+	if !ok {
+		return false, nil
+	}
+	if isExternalLink(target) {
+		app.lastEvent = "Link: " + target
+		return true, nil
+	}
+	target, _, _ = strings.Cut(target, "#")
+	if target == "" {
+		return true, fmt.Errorf("link has no file")
+	}
+	dir := app.openRoot
+	if app.currentPath != "" {
+		dir = filepath.Dir(app.currentPath)
+	}
+	full := target
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(dir, target)
+	}
+	full = filepath.Clean(full)
+	from := currentJump(app)
+	if err := showEditor(app, nil, full, false); err != nil {
+		return true, err
+	}
+	pushJump(app, from)
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gc/editor"
+)
+
+// orderedListItem is a Markdown ordered-list line split around its marker.
+type orderedListItem struct {
+	indent string
+	num    int
+	delim  byte // '.' or ')'
+	body   string
+}
+
+// parseOrderedListItem recognises "N. text" and "N) text" lines, keeping the
+// leading indentation and everything after the marker's space.
+func parseOrderedListItem(line string) (orderedListItem, bool) {
+	rest := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(rest)]
+	i := 0
+	for i < len(rest) && i < 9 && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i == 0 || i+1 >= len(rest) || (rest[i] != '.' && rest[i] != ')') || (rest[i+1] != ' ' && rest[i+1] != '\t') {
+		return orderedListItem{}, false
+	}
+	n, err := strconv.Atoi(rest[:i])
+	if err != nil {
+		return orderedListItem{}, false
+	}
+	return orderedListItem{indent: indent, num: n, delim: rest[i], body: rest[i+2:]}, true
+}
+
+// renumberOrderedList numbers the ordered-list items in lines 1, 2, ... per
+// indentation level; a nested list restarts at 1 and the outer count resumes
+// after it. Other lines are kept as they are. It returns the new lines and
+// how many changed.
+func renumberOrderedList(lines []string) ([]string, int) {
+	out := make([]string, len(lines))
+	changed := 0
+	type level struct {
+		indent string
+		count  int
+	}
+	var stack []level
+	for i, ln := range lines {
+		out[i] = ln
+		it, ok := parseOrderedListItem(ln)
+		if !ok {
+			continue
+		}
+		for len(stack) > 0 && len(stack[len(stack)-1].indent) > len(it.indent) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 || len(stack[len(stack)-1].indent) < len(it.indent) {
+			stack = append(stack, level{indent: it.indent})
+		}
+		top := &stack[len(stack)-1]
+		top.count++
+		if top.count != it.num {
+			out[i] = fmt.Sprintf("%s%d%c %s", it.indent, top.count, it.delim, it.body)
+			changed++
+		}
+	}
+	return out, changed
+}
+
+// orderedListBlock finds the list around line idx: contiguous non-blank lines
+// that are ordered items or indented continuations, containing an item.
+func orderedListBlock(lines []string, idx int) (int, int, bool) {
+	inBlock := func(ln string) bool {
+		if strings.TrimSpace(ln) == "" {
+			return false
+		}
+		if _, ok := parseOrderedListItem(ln); ok {
+			return true
+		}
+		return ln[0] == ' ' || ln[0] == '\t'
+	}
+	if idx < 0 || idx >= len(lines) || !inBlock(lines[idx]) {
+		return 0, 0, false
+	}
+	first, last := idx, idx
+	for first > 0 && inBlock(lines[first-1]) {
+		first--
+	}
+	for last < len(lines)-1 && inBlock(lines[last+1]) {
+		last++
+	}
+	for _, ln := range lines[first : last+1] {
+		if _, ok := parseOrderedListItem(ln); ok {
+			return first, last, true
+		}
+	}
+	return 0, 0, false
+}
+
+// renumberList renumbers the ordered list in the selected lines, or the list
+// block around the caret, as one undo step.
+func renumberList(app *appState) {
+	if app == nil || app.ed == nil || readOnlyBlocked(app) {
+		return
+	}
+	ed := app.ed
+	lines := ed.Lines()
+	first, last, ok := ed.SelectedLineRange(lines)
+	if !ok {
+		first, last, ok = orderedListBlock(lines, editor.CaretLineAt(lines, ed.Caret))
+		if !ok {
+			app.lastEvent = "No ordered list at caret"
+			return
+		}
+	}
+	out, n := renumberOrderedList(lines[first : last+1])
+	if n == 0 {
+		app.lastEvent = "List already numbered"
+		return
+	}
+	caret := ed.Caret
+	ed.ReplaceLines(lines, first, last, out)
+	ed.Caret = min(caret, ed.RuneLen())
+	app.markDirty()
+	app.lastEvent = fmt.Sprintf("Renumbered %s", plural(n, "list item"))
+}
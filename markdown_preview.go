@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gc/editor"
+)
+
+// markdownPreviewTitle names the read-only preview buffer for a Markdown
+// file. The closing bracket keeps the title from being detected as Markdown,
+// so the rendered text is shown plain.
+func markdownPreviewTitle(path string) string {
+	name := filepath.Base(path)
+	if path == "" {
+		name = "<untitled>"
+	}
+	return "[preview " + name + "]"
+}
+
+// renderMarkdownPreview renders Markdown source as plain text: headings are
+// upper-cased and underlined, bullets become "•", links read "text (url)",
+// and fenced code is indented.
+func renderMarkdownPreview(src string) string {
+	out := formatHoverMarkdown(src)
+	if out == "" {
+		return ""
+	}
+	return out + "\n"
+}
+
+// openMarkdownPreview renders the active Markdown buffer into its read-only
+// preview buffer, reusing an earlier one so re-running the command refreshes
+// it; run from the preview itself, it re-renders from the source buffer. The
+// preview is not updated live.
+func openMarkdownPreview(app *appState) {
+	if app == nil || app.ed == nil || len(app.buffers) == 0 {
+		return
+	}
+	if i, ok := previewSource(app); ok {
+		app.bufIdx = i
+		app.syncActiveBuffer()
+	}
+	src := app.ed.String()
+	if bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) != syntaxMarkdown {
+		app.lastEvent = "Preview needs a Markdown buffer"
+		return
+	}
+	title := markdownPreviewTitle(app.currentPath)
+	idx := -1
+	for i, b := range app.buffers {
+		if b.path == title {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		app.addBuffer()
+		idx = app.bufIdx
+	} else {
+		app.bufIdx = idx
+		app.syncActiveBuffer()
+	}
+	slot := &app.buffers[idx]
+	slot.path = title
+	slot.dirty = false
+	slot.readOnly = true
+	slot.mode = syntaxNone
+	app.currentPath = title
+	app.ed.SetRunes([]rune(renderMarkdownPreview(src)))
+	app.ed.Caret = 0
+	app.ed.Sel = editor.Sel{}
+	app.touchActiveBufferText()
+	app.lastEvent = "Markdown preview (Esc+Shift+P again to refresh)"
+}
+
+// previewSource finds the source buffer of the active preview buffer.
+func previewSource(app *appState) (int, bool) {
+	if app == nil || app.bufIdx < 0 || app.bufIdx >= len(app.buffers) {
+		return 0, false
+	}
+	cur := app.buffers[app.bufIdx].path
+	if !strings.HasPrefix(cur, "[preview ") {
+		return 0, false
+	}
+	for i, b := range app.buffers {
+		if i != app.bufIdx && b.path != "" && !strings.HasPrefix(b.path, "[") && markdownPreviewTitle(b.path) == cur {
+			return i, true
+		}
+	}
+	return 0, false
+}
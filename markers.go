@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gc/editor"
+)
+
+// commentMarkerWords are the words emphasized inside comments.
+var commentMarkerWords = []string{"TODO", "FIXME", "XXX", "NOTE"}
+
+// markCommentMarkers restyles marker words found in comment spans of
+// lineStyles as styleTodo. Only whole words count, so TODOS or NOTES do not.
+func markCommentMarkers(lines []string, lineStyles [][]tokenStyle) {
+	for ln, styles := range lineStyles {
+		if styles == nil || ln >= len(lines) {
+			continue
+		}
+		rs := []rune(lines[ln])
+		for i := 0; i < len(rs) && i < len(styles); i++ {
+			if styles[i] != styleComment || (i > 0 && isIdentRune(rs[i-1])) {
+				continue
+			}
+			for _, w := range commentMarkerWords {
+				end := i + len(w)
+				if end > len(rs) || end > len(styles) || string(rs[i:end]) != w {
+					continue
+				}
+				if end < len(rs) && isIdentRune(rs[end]) {
+					continue
+				}
+				for j := i; j < end; j++ {
+					styles[j] = styleTodo
+				}
+				i = end - 1
+				break
+			}
+		}
+	}
+}
+
+// commentMarkers lists the lines holding a marker, titled from the marker to
+// the end of the line.
+func commentMarkers(lines []string, lineStyles [][]tokenStyle) []outlineItem {
+	var out []outlineItem
+	for ln, styles := range lineStyles {
+		i := -1
+		for j, st := range styles {
+			if st == styleTodo {
+				i = j
+				break
+			}
+		}
+		if i < 0 || ln >= len(lines) {
+			continue
+		}
+		title := strings.TrimSpace(string([]rune(lines[ln])[i:]))
+		title = strings.TrimSpace(strings.TrimSuffix(title, "*/"))
+		out = append(out, outlineItem{line: ln, level: 1, title: title})
+	}
+	return out
+}
+
+// openMarkerList lists the TODO/FIXME/XXX/NOTE comments of the active buffer
+// in the outline popup, the one at or above the caret selected.
+func openMarkerList(app *appState) error {
+	lines, lineStyles, _, _ := renderData(app)
+	items := commentMarkers(lines, lineStyles)
+	if len(items) == 0 {
+		return fmt.Errorf("no TODO, FIXME, XXX or NOTE comments")
+	}
+	cLine := editor.CaretLineAt(lines, app.ed.Caret)
+	sel := 0
+	for i, it := range items {
+		if it.line <= cLine {
+			sel = i
+		}
+	}
+	app.outline = outlineState{active: true, title: "Markers", items: items, selected: sel}
+	app.lastEvent = fmt.Sprintf("%d markers: Up/Down choose, Enter jumps, Esc closes", len(items))
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gc/editor"
+)
+
+// messagesTitle names the read-only buffer listing recent status messages.
+const messagesTitle = "[messages]"
+
+// statusLogSize is how many status messages the log keeps.
+const statusLogSize = 200
+
+type statusEntry struct {
+	at  time.Time
+	msg string
+}
+
+// statusLog is a ring of the most recent status-line messages.
+type statusLog struct {
+	entries [statusLogSize]statusEntry
+	next    int // slot the next entry goes to
+	n       int
+	last    string
+}
+
+// add records msg unless it is empty or repeats the previous message.
+func (l *statusLog) add(at time.Time, msg string) {
+	if msg == "" || msg == l.last {
+		return
+	}
+	l.last = msg
+	l.entries[l.next] = statusEntry{at: at, msg: msg}
+	l.next = (l.next + 1) % statusLogSize
+	l.n = min(l.n+1, statusLogSize)
+}
+
+// list returns the kept entries, oldest first.
+func (l *statusLog) list() []statusEntry {
+	out := make([]statusEntry, 0, l.n)
+	for i := range l.n {
+		out = append(out, l.entries[(l.next-l.n+i+statusLogSize)%statusLogSize])
+	}
+	return out
+}
+
+// noteStatus logs the status message when it changed since the last call.
+// The UI loop calls it after every event.
+func noteStatus(app *appState) {
+	app.statusLog.add(time.Now(), app.lastEvent)
+}
+
+// statusLogLines formats entries as "HH:MM:SS  message".
+func statusLogLines(entries []statusEntry) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.at.Format("15:04:05")+"  "+e.msg)
+	}
+	return out
+}
+
+// openMessages lists the logged status messages in a read-only buffer,
+// oldest first with the caret on the newest, reusing an earlier one.
+func openMessages(app *appState) error {
+	noteStatus(app)
+	entries := app.statusLog.list()
+	if len(entries) == 0 {
+		return fmt.Errorf("no messages yet")
+	}
+	idx := -1
+	for i, b := range app.buffers {
+		if b.path == messagesTitle {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		app.addBuffer()
+		idx = app.bufIdx
+	} else {
+		app.bufIdx = idx
+		app.syncActiveBuffer()
+	}
+	slot := &app.buffers[idx]
+	slot.path = messagesTitle
+	slot.dirty = false
+	slot.readOnly = true
+	app.currentPath = messagesTitle
+	lines := statusLogLines(entries)
+	app.ed.SetRunes([]rune(strings.Join(lines, "\n") + "\n"))
+	app.ed.Caret = editor.LineStartOffset(app.ed.Lines(), len(lines)-1)
+	app.ed.Sel = editor.Sel{}
+	app.touchActiveBufferText()
+	app.lastEvent = fmt.Sprintf("%d messages", len(entries))
+	return nil
+}
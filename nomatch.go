@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// noMatchFlash is how long the input line flashes when a leap or search
+// query stops matching.
+const noMatchFlash = 350 * time.Millisecond
+
+// noMatchInterrupt asks the UI loop to redraw when the no-match flash ends.
+type noMatchInterrupt struct{}
+
+// queryHasNoMatch reports whether the active leap or search query is
+// non-empty and matches nowhere.
+func queryHasNoMatch(app *appState) bool {
+	if app.ed != nil && app.ed.Leap.Active {
+		return len(app.ed.Leap.Query) > 0 && app.ed.Leap.LastFoundPos < 0
+	}
+	return app.searchActive && len(app.searchQuery) > 0 && app.searchLastMatch < 0
+}
+
+// noteQueryMatch records when the leap or search query stopped matching, or
+// clears the mark once it matches again. A new no-match flashes the input
+// line and schedules the redraw that ends the flash.
+func noteQueryMatch(app *appState) {
+	if !queryHasNoMatch(app) {
+		app.noMatchAt = time.Time{}
+		return
+	}
+	if !app.noMatchAt.IsZero() {
+		return
+	}
+	app.noMatchAt = time.Now()
+	if post := app.requestInterrupt; post != nil {
+		time.AfterFunc(noMatchFlash, func() { post(noMatchInterrupt{}) })
+	}
+}
+
+// noMatchFlashing reports whether the no-match flash is showing at now.
+func noMatchFlashing(app *appState, now time.Time) bool {
+	return !app.noMatchAt.IsZero() && now.Sub(app.noMatchAt) < noMatchFlash
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gc/editor"
+)
+
+// numberAt finds the integer on the caret's line that holds the caret or
+// starts after it. A '-' directly before the digits is its sign unless it
+// follows a word character (item-3 is the number 3). end is exclusive.
+func numberAt(buf []rune, caret int) (start, end int, ok bool) {
+	lineEnd := caret
+	for lineEnd < len(buf) && buf[lineEnd] != '\n' {
+		lineEnd++
+	}
+	lineStart := caret
+	for lineStart > 0 && buf[lineStart-1] != '\n' {
+		lineStart--
+	}
+	i := caret
+	for i < lineEnd && !isDigitRune(buf[i]) {
+		i++
+	}
+	if i == lineEnd {
+		return 0, 0, false
+	}
+	start, end = i, i
+	for start > lineStart && isDigitRune(buf[start-1]) {
+		start--
+	}
+	for end < lineEnd && isDigitRune(buf[end]) {
+		end++
+	}
+	if start > lineStart && buf[start-1] == '-' && (start-1 == lineStart || !isIdentRune(buf[start-2])) {
+		start--
+	}
+	return start, end, true
+}
+
+func isDigitRune(r rune) bool { return r >= '0' && r <= '9' }
+
+// formatStepped prints n in place of old, keeping old's zero-padded width
+// ("007" - 1 is "006").
+func formatStepped(old string, n int64) string {
+	digits := strings.TrimPrefix(old, "-")
+	s := strconv.FormatInt(n, 10)
+	if len(digits) < 2 || digits[0] != '0' {
+		return s
+	}
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	return sign + strings.Repeat("0", max(0, len(digits)-len(s))) + s
+}
+
+// stepNumber adds delta to the integer at or after the caret on its line,
+// as one undo step, and leaves the caret on its last digit.
+func stepNumber(app *appState, delta int) error {
+	buf := app.ed.Runes()
+	start, end, ok := numberAt(buf, app.ed.Caret)
+	if !ok {
+		return fmt.Errorf("no number on the line")
+	}
+	old := string(buf[start:end])
+	n, err := strconv.ParseInt(old, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: number too large", old)
+	}
+	text := formatStepped(old, n+int64(delta))
+	app.ed.ReplaceRange(start, end, text)
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = start + len([]rune(text)) - 1
+	app.markDirty()
+	app.lastEvent = fmt.Sprintf("%s → %s", old, text)
+	return nil
+}
+
+// stepNumberKey maps '+' and '-' typed while a count is pending to their
+// command, so Esc+5 then + adds 5.
+func stepNumberKey(e keyEvent) (Command, bool) {
+	if (e.mods & (modCtrl | modLAlt | modRAlt)) != 0 {
+		return CmdNone, false
+	}
+	r, ok := keyToRune(e.key, e.mods)
+	switch {
+	case ok && r == '+':
+		return CmdIncrement, true
+	case ok && r == '-':
+		return CmdDecrement, true
+	}
+	return CmdNone, false
+}
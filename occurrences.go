@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/token"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"gc/editor"
+)
+
+// occurrenceDelay is how long the caret must rest on an identifier before its
+// other occurrences are highlighted.
+const occurrenceDelay = 250 * time.Millisecond
+
+// occurrenceBackground tints the occurrences of the identifier at the caret.
+var occurrenceBackground = tcell.NewRGBColor(60, 60, 76)
+
+// occurrenceInterrupt asks the UI loop to redraw once the caret has rested.
+type occurrenceInterrupt struct{}
+
+// occurrenceState records where the caret came to rest and when.
+type occurrenceState struct {
+	ed      *editor.Editor
+	caret   int
+	textRev int
+	since   time.Time
+}
+
+// identOccurrences returns, per line in first..last, the rune column ranges
+// where ident occurs as a whole word (case-sensitive): a match next to
+// another identifier rune is part of a longer name and is skipped.
+func identOccurrences(lines []string, first, last int, ident string) map[int][][2]int {
+	want := []rune(ident)
+	if len(want) == 0 {
+		return nil
+	}
+	out := map[int][][2]int{}
+	for ln := max(first, 0); ln <= last && ln < len(lines); ln++ {
+		rs := []rune(lines[ln])
+		for i := 0; i+len(want) <= len(rs); i++ {
+			if i > 0 && isIdentRune(rs[i-1]) {
+				continue
+			}
+			if string(rs[i:i+len(want)]) != ident {
+				continue
+			}
+			if end := i + len(want); end < len(rs) && isIdentRune(rs[end]) {
+				continue
+			}
+			out[ln] = append(out[ln], [2]int{i, i + len(want)})
+			i += len(want) - 1
+		}
+	}
+	return out
+}
+
+// caretIdentifier returns the identifier at the caret of a code buffer, or
+// "" for numbers and, in Go, keywords.
+func caretIdentifier(app *appState, kind syntaxKind) string {
+	switch kind {
+	case syntaxGo, syntaxC, syntaxMiranda:
+	default:
+		return ""
+	}
+	word := symbolUnderCaret(app.ed.Runes(), app.ed.Caret)
+	if r, _ := utf8.DecodeRuneInString(word); word == "" || (r >= '0' && r <= '9') {
+		return ""
+	}
+	if kind == syntaxGo && token.Lookup(word).IsKeyword() {
+		return ""
+	}
+	return word
+}
+
+// scheduleOccurrences notes when the caret or text changed and arranges a
+// redraw once the caret has rested for occurrenceDelay.
+func scheduleOccurrences(app *appState) {
+	if app.noOccurrences || app.ed == nil || len(app.buffers) == 0 {
+		return
+	}
+	st := &app.occur
+	rev := app.buffers[app.bufIdx].textRev
+	if st.ed == app.ed && st.caret == app.ed.Caret && st.textRev == rev {
+		return
+	}
+	*st = occurrenceState{ed: app.ed, caret: app.ed.Caret, textRev: rev, since: time.Now()}
+	if post := app.requestInterrupt; post != nil {
+		time.AfterFunc(occurrenceDelay, func() { post(occurrenceInterrupt{}) })
+	}
+}
+
+// visibleOccurrences returns the occurrences of the identifier at the
+// caret on lines first..last, once the caret has rested there. Nothing is
+// shown while text is selected or a search or leap query is highlighted.
+func visibleOccurrences(app *appState, kind syntaxKind, lines []string, first, last int, now time.Time) map[int][][2]int {
+	st := app.occur
+	if app.noOccurrences || len(app.buffers) == 0 || app.ed.Sel.Active {
+		return nil
+	}
+	if st.ed != app.ed || st.caret != app.ed.Caret || st.textRev != app.buffers[app.bufIdx].textRev || now.Sub(st.since) < occurrenceDelay {
+		return nil
+	}
+	if q, _ := activeHighlightQuery(app); len(q) > 0 {
+		return nil
+	}
+	ident := caretIdentifier(app, kind)
+	if ident == "" {
+		return nil
+	}
+	return identOccurrences(lines, first, last, ident)
+}
+
+// tintRuneCols sets the background of the cells of rune columns a..b of line
+// drawn at x, clipped at maxX.
+func tintRuneCols(s tcell.Screen, x, maxX, row int, line string, a, b int, bg tcell.Color) {
+	from := x + visualColForRuneCol(line, a, tabWidth)
+	to := min(maxX, x+visualColForRuneCol(line, b, tabWidth))
+	for cx := from; cx < to; cx++ {
+		str, st, _ := s.Get(cx, row)
+		r := ' '
+		if str != "" {
+			r = []rune(str)[0]
+		}
+		s.SetContent(cx, row, r, nil, st.Background(bg))
+	}
+}
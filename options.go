@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// lineNumberMode selects how the gutter labels lines.
+type lineNumberMode int
+
+const (
+	lineNumbersAbsolute lineNumberMode = iota
+	lineNumbersRelative
+	lineNumbersOff
+)
+
+// gutterWidth is the number of cells reserved for line numbers (0 when hidden).
+func gutterWidth(app *appState) int {
+	if app != nil && app.lineNumbers == lineNumbersOff {
+		return 0
+	}
+	return 5
+}
+
+// gutterLabel formats the gutter for line ln (0-based). In relative mode the
+// caret line keeps its absolute number and other lines show their distance.
+func gutterLabel(mode lineNumberMode, ln, caretLine int) string {
+	switch mode {
+	case lineNumbersOff:
+		return ""
+	case lineNumbersRelative:
+		if ln != caretLine {
+			d := ln - caretLine
+			if d < 0 {
+				d = -d
+			}
+			return fmt.Sprintf("%4d ", d)
+		}
+	}
+	return fmt.Sprintf("%4d ", ln+1)
+}
+
+// pathMode selects how paths appear in the status line and buffer labels.
+type pathMode int
+
+const (
+	// pathsFull shows the root in full and buffers by base name.
+	pathsFull pathMode = iota
+	// pathsHome collapses $HOME to "~".
+	pathsHome
+	// pathsRelative shows buffers relative to the open root.
+	pathsRelative
+)
+
+func pathModeName(m pathMode) string {
+	switch m {
+	case pathsHome:
+		return "home"
+	case pathsRelative:
+		return "relative"
+	default:
+		return "full"
+	}
+}
+
+// formatPath renders p for display. In relative mode paths under root are
+// shown relative to it and others fall back to the "~" form.
+func formatPath(mode pathMode, p, root, home string) string {
+	if p == "" || mode == pathsFull {
+		return p
+	}
+	if mode == pathsRelative && root != "" {
+		if rel, err := filepath.Rel(root, p); err == nil && rel != "." && outsideRoot(root, p) == nil {
+			return rel
+		}
+	}
+	if home == "" {
+		return p
+	}
+	if p == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(p, home+string(filepath.Separator)); ok {
+		return filepath.Join("~", rest)
+	}
+	return p
+}
+
+// displayPath renders p under the paths option.
+func displayPath(app *appState, p string) string {
+	home, _ := os.UserHomeDir()
+	return formatPath(app.pathMode, p, app.openRoot, home)
+}
+
+// displayRoot renders the open root for the status line; relative mode has
+// nothing to be relative to, so it collapses $HOME like home mode.
+func displayRoot(app *appState) string {
+	if app.pathMode == pathsRelative {
+		home, _ := os.UserHomeDir()
+		return formatPath(pathsHome, app.openRoot, "", home)
+	}
+	return displayPath(app, app.openRoot)
+}
+
+func promptSetOption(app *appState) {
+	if app == nil {
+		return
+	}
+	app.inputActive = true
+	app.inputPrompt = "Set: "
+	app.inputValue = ""
+	app.inputKind = "set"
+	app.lastEvent = "Set option: name=value (e.g. numbers=rel), Enter to apply, Esc to cancel"
+}
+
+// applyOption parses a "name=value" option spec and updates app state. It
+// returns a short description of the new setting for the status line.
+func applyOption(app *appState, spec string) (string, error) {
+	if app == nil {
+		return "", fmt.Errorf("no app state")
+	}
+	name, raw, _ := strings.Cut(strings.TrimSpace(spec), "=")
+	name = strings.ToLower(strings.TrimSpace(name))
+	raw = strings.TrimSpace(raw)
+	value := strings.ToLower(raw)
+	switch name {
+	case "":
+		return "", fmt.Errorf("empty option")
+	case "numbers", "nu":
+		switch value {
+		case "abs", "absolute", "on", "":
+			app.lineNumbers = lineNumbersAbsolute
+		case "rel", "relative":
+			app.lineNumbers = lineNumbersRelative
+		case "off", "none":
+			app.lineNumbers = lineNumbersOff
+		default:
+			return "", fmt.Errorf("numbers: want abs, rel, or off")
+		}
+		return "numbers=" + lineNumberModeName(app.lineNumbers), nil
+	case "whitespace", "ws", "list":
+		on, err := parseOptionBool(value, app.showWhitespace)
+		if err != nil {
+			return "", fmt.Errorf("whitespace: %v", err)
+		}
+		app.showWhitespace = on
+		return "whitespace=" + onOff(on), nil
+	case "autocomplete", "ac":
+		on, err := parseOptionBool(value, app.autoComplete)
+		if err != nil {
+			return "", fmt.Errorf("autocomplete: %v", err)
+		}
+		app.autoComplete = on
+		return "autocomplete=" + onOff(on), nil
+	case "doublespace", "ds":
+		on, err := parseOptionBool(value, !app.noDoubleSpace)
+		if err != nil {
+			return "", fmt.Errorf("doublespace: %v", err)
+		}
+		app.noDoubleSpace = !on
+		return "doublespace=" + onOff(on), nil
+	case "pasteindent", "pi":
+		on, err := parseOptionBool(value, !app.noPasteIndent)
+		if err != nil {
+			return "", fmt.Errorf("pasteindent: %v", err)
+		}
+		app.noPasteIndent = !on
+		return "pasteindent=" + onOff(on), nil
+	case "guides", "ig":
+		on, err := parseOptionBool(value, !app.noIndentGuides)
+		if err != nil {
+			return "", fmt.Errorf("guides: %v", err)
+		}
+		app.noIndentGuides = !on
+		return "guides=" + onOff(on), nil
+	case "occurrences", "occ":
+		on, err := parseOptionBool(value, !app.noOccurrences)
+		if err != nil {
+			return "", fmt.Errorf("occurrences: %v", err)
+		}
+		app.noOccurrences = !on
+		return "occurrences=" + onOff(on), nil
+	case "wordchars", "wc":
+		// Characters are taken as typed; letters, digits and _ always count.
+		chars := raw
+		if value == "default" || value == "none" {
+			chars = ""
+		}
+		if strings.IndexFunc(chars, unicode.IsSpace) >= 0 {
+			return "", fmt.Errorf("wordchars: whitespace cannot be a word character")
+		}
+		app.wordChars = chars
+		for i := range app.buffers {
+			app.buffers[i].ed.SetWordChars(chars)
+		}
+		if chars == "" {
+			return "wordchars=default", nil
+		}
+		return "wordchars=" + chars, nil
+	case "fmtdiff", "fd":
+		on, err := parseOptionBool(value, app.fmtDiff)
+		if err != nil {
+			return "", fmt.Errorf("fmtdiff: %v", err)
+		}
+		app.fmtDiff = on
+		return "fmtdiff=" + onOff(on), nil
+	case "inlayhints", "ih":
+		on, err := parseOptionBool(value, app.inlayHints)
+		if err != nil {
+			return "", fmt.Errorf("inlayhints: %v", err)
+		}
+		app.inlayHints = on
+		app.inlay = inlayHintState{}
+		return "inlayhints=" + onOff(on), nil
+	case "organizeimports", "oi":
+		on, err := parseOptionBool(value, app.organizeImports)
+		if err != nil {
+			return "", fmt.Errorf("organizeimports: %v", err)
+		}
+		app.organizeImports = on
+		return "organizeimports=" + onOff(on), nil
+	case "gitignore", "gi":
+		on, err := parseOptionBool(value, !app.noGitignore)
+		if err != nil {
+			return "", fmt.Errorf("gitignore: %v", err)
+		}
+		app.noGitignore = !on
+		return "gitignore=" + onOff(on), nil
+	case "findlimit":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("findlimit: want a positive count")
+		}
+		app.findLimit = n
+		return fmt.Sprintf("findlimit=%d", n), nil
+	case "ignore":
+		// Directory names are case-sensitive, so use the raw value.
+		app.ignoreNames = nil
+		for n := range strings.SplitSeq(raw, ",") {
+			if n = strings.TrimSpace(n); n != "" && !slices.Contains(app.ignoreNames, n) {
+				app.ignoreNames = append(app.ignoreNames, n)
+			}
+		}
+		if len(app.ignoreNames) == 0 {
+			return "ignore=none", nil
+		}
+		return "ignore=" + strings.Join(app.ignoreNames, ","), nil
+	case "details":
+		on, err := parseOptionBool(value, app.pickerDetails)
+		if err != nil {
+			return "", fmt.Errorf("details: %v", err)
+		}
+		app.pickerDetails = on
+		return "details=" + onOff(on), nil
+	case "paths":
+		switch value {
+		case "full", "":
+			app.pathMode = pathsFull
+		case "home", "~":
+			app.pathMode = pathsHome
+		case "relative", "rel":
+			app.pathMode = pathsRelative
+		default:
+			return "", fmt.Errorf("paths: want full, home, or relative")
+		}
+		return "paths=" + pathModeName(app.pathMode), nil
+	case "curline", "cursorline":
+		switch value {
+		case "off", "none":
+			app.curLineColor = ""
+			return "curline=off", nil
+		case "on", "":
+			app.curLineColor = defaultCurLineColor
+		default:
+			if !validColor(value) {
+				return "", fmt.Errorf("curline: want on, off, a color name or #rrggbb")
+			}
+			if isSelectionColor(value) {
+				return "", fmt.Errorf("curline: %s is the selection color", value)
+			}
+			app.curLineColor = value
+		}
+		return "curline=" + app.curLineColor, nil
+	case "blink":
+		return applyBlinkOption(app, value)
+	case "spell":
+		on, err := parseOptionBool(value, app.spellCheck)
+		if err != nil {
+			return "", fmt.Errorf("spell: %v", err)
+		}
+		if err := setSpellCheck(app, on); err != nil {
+			return "", fmt.Errorf("spell: %v", err)
+		}
+		return "spell=" + onOff(on), nil
+	case "ruler", "colorcolumn", "cc":
+		switch value {
+		case "off", "none", "0":
+			app.rulerCol = 0
+			return "ruler=off", nil
+		case "":
+			app.rulerCol = 80
+		default:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "", fmt.Errorf("ruler: want a column number or off")
+			}
+			app.rulerCol = n
+		}
+		return fmt.Sprintf("ruler=%d", app.rulerCol), nil
+	case "limit", "linelimit":
+		switch value {
+		case "off", "none", "0":
+			app.lineLimit = 0
+			return "limit=off", nil
+		case "":
+			// A bare limit follows the ruler so both guides agree.
+			app.lineLimit = app.rulerCol
+			if app.lineLimit == 0 {
+				app.lineLimit = 80
+			}
+		default:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "", fmt.Errorf("limit: want a column count or off")
+			}
+			app.lineLimit = n
+		}
+		return fmt.Sprintf("limit=%d", app.lineLimit), nil
+	}
+	return "", fmt.Errorf("unknown option %q", name)
+}
+
+// parseOptionBool reads on/off style values; an empty value toggles cur.
+func parseOptionBool(value string, cur bool) (bool, error) {
+	switch value {
+	case "":
+		return !cur, nil
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return cur, fmt.Errorf("want on or off")
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func lineNumberModeName(m lineNumberMode) string {
+	switch m {
+	case lineNumbersRelative:
+		return "rel"
+	case lineNumbersOff:
+		return "off"
+	default:
+		return "abs"
+	}
+}
+
+// configPath is the user option file, e.g. ~/.config/gocat/config.
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gocat", "config")
+}
+
+// loadConfig applies one "name=value" option per line from r, as typed at
+// the Set: prompt. Blank lines and "#" comments are skipped; the first bad
+// line stops loading.
+func loadConfig(app *appState, r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := applyOption(app, line); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// loadUserConfig applies the user's option file. A missing file is not an
+// error.
+func loadUserConfig(app *appState, path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := loadConfig(app, f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// goOrganizeImports returns the edits that organize the imports of a Go
+// file: the gopls source.organizeImports code action, or goimports run on
+// the content when gopls is off or fails.
+var goOrganizeImports = func(app *appState, path string, content string) ([]textEdit, error) {
+	if !app.noGopls && app.gopls != nil {
+		edits, err := app.gopls.organizeImports(path, content)
+		if err == nil {
+			return edits, nil
+		}
+		goplsFailed(app, "Organize imports")
+	}
+	return goimportsEdits(path, content)
+}
+
+// goimportsEdits runs goimports on content and returns its output as one
+// edit replacing the whole file.
+func goimportsEdits(path string, content string) ([]textEdit, error) {
+	bin, err := exec.LookPath("goimports")
+	if err != nil {
+		return nil, fmt.Errorf("needs gopls or goimports")
+	}
+	cmd := exec.Command(bin, "-srcdir", filepath.Dir(path))
+	cmd.Stdin = strings.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("goimports: %s", msg)
+		}
+		return nil, fmt.Errorf("goimports: %v", err)
+	}
+	lines := strings.Split(content, "\n")
+	var e textEdit
+	e.Range.End = lspPosition{Line: len(lines) - 1, Character: utf8.RuneCountInString(lines[len(lines)-1])}
+	e.NewText = string(out)
+	return []textEdit{e}, nil
+}
+
+// organizeCurrentImports applies the import edits to the active Go buffer as
+// minimal edits, so the caret stays on its text and one undo reverts them.
+func organizeCurrentImports(app *appState) error {
+	src := app.ed.Runes()
+	edits, err := goOrganizeImports(app, app.currentPath, string(src))
+	if err != nil {
+		return err
+	}
+	out, err := applyTextEdits(src, edits)
+	if err != nil {
+		return err
+	}
+	if app.ed.ApplyText(out) {
+		app.touchActiveBufferText()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"gc/editor"
+)
+
+// outlineItem is one Markdown heading or Go declaration: its line, level
+// (the number of #s; 1 for declarations) and title.
+type outlineItem struct {
+	line  int
+	level int
+	title string
+}
+
+// outlineState is the heading outline popup of a Markdown buffer. The Go
+// symbol outline and the comment marker list reuse it with their own title.
+type outlineState struct {
+	active   bool
+	title    string
+	items    []outlineItem
+	selected int
+}
+
+// markdownHeadings lists the ATX headings of lines in order, skipping fenced
+// code blocks.
+func markdownHeadings(lines []string) []outlineItem {
+	var out []outlineItem
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if title, ok := parseMarkdownHeading(line); ok {
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			out = append(out, outlineItem{line: i, level: level, title: title})
+		}
+	}
+	return out
+}
+
+// markdownFoldRanges makes each heading's section foldable: the lines up to
+// the next heading of the same or a higher level, less trailing blank lines.
+func markdownFoldRanges(lines []string) []foldRange {
+	heads := markdownHeadings(lines)
+	starts := computeLineStarts(lines)
+	var out []foldRange
+	for i, h := range heads {
+		end := len(lines) - 1
+		for _, next := range heads[i+1:] {
+			if next.level <= h.level {
+				end = next.line - 1
+				break
+			}
+		}
+		for end > h.line && strings.TrimSpace(lines[end]) == "" {
+			end--
+		}
+		if end > h.line {
+			out = append(out, foldRange{start: h.line, end: end, anchor: starts[h.line]})
+		}
+	}
+	return out
+}
+
+// openOutline lists the headings of the active Markdown buffer, or the
+// declarations of a Go buffer, with the one at or above the caret selected.
+func openOutline(app *appState) error {
+	switch bufferSyntaxKind(app, app.currentPath, app.ed.Runes()) {
+	case syntaxGo:
+		return openGoOutline(app)
+	case syntaxMarkdown:
+	default:
+		return fmt.Errorf("outline needs a Markdown or Go buffer")
+	}
+	lines := app.ed.Lines()
+	items := markdownHeadings(lines)
+	if len(items) == 0 {
+		return fmt.Errorf("no headings")
+	}
+	cLine := editor.CaretLineAt(lines, app.ed.Caret)
+	sel := 0
+	for i, it := range items {
+		if it.line <= cLine {
+			sel = i
+		}
+	}
+	app.outline = outlineState{active: true, items: items, selected: sel}
+	app.lastEvent = "Outline: Up/Down choose, Enter jumps, Esc closes"
+	return nil
+}
+
+// outlineJump closes the outline and puts the caret on the selected heading.
+func outlineJump(app *appState) {
+	o := app.outline
+	app.outline = outlineState{}
+	if o.selected < 0 || o.selected >= len(o.items) {
+		return
+	}
+	it := o.items[o.selected]
+	recordJump(app)
+	app.ed.Sel = editor.Sel{}
+	app.ed.Caret = editor.LineStartOffset(app.ed.Lines(), it.line)
+	app.lastEvent = fmt.Sprintf("%s: %q (line %d)", cmp.Or(o.title, "Outline"), it.title, it.line+1)
+}
+
+// handleOutlineKey handles keys while the outline is open.
+func handleOutlineKey(app *appState, e keyEvent) bool {
+	o := &app.outline
+	switch e.key {
+	case keyEscape:
+		app.outline = outlineState{}
+		app.lastEvent = "Outline closed"
+	case keyReturn, keyKpEnter:
+		outlineJump(app)
+	case keyUp:
+		o.selected = max(0, o.selected-1)
+	case keyDown:
+		o.selected = min(len(o.items)-1, o.selected+1)
+	case keyPageUp:
+		o.selected = max(0, o.selected-10)
+	case keyPageDown:
+		o.selected = min(len(o.items)-1, o.selected+10)
+	case keyHome:
+		o.selected = 0
+	case keyEnd:
+		o.selected = len(o.items) - 1
+	}
+	return true
+}
+
+// outlineLine formats a heading indented by its level, with its line number.
+func outlineLine(it outlineItem) string {
+	return fmt.Sprintf("%s%s  :%d", strings.Repeat("  ", it.level-1), it.title, it.line+1)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// outsideRootError refuses a path outside the open root. Interactive opens
+// turn it into a confirmation prompt.
+type outsideRootError struct {
+	path, root string
+}
+
+func (e *outsideRootError) Error() string {
+	return fmt.Sprintf("refusing to open outside %s", e.root)
+}
+
+// outsideRoot returns an *outsideRootError when root is set and path is
+// not under it. Names that merely start with ".." (such as "..foo") are
+// inside.
+func outsideRoot(root, path string) error {
+	if root == "" {
+		return nil
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &outsideRootError{path: path, root: root}
+	}
+	return nil
+}
+
+// promptOpenOutsideRoot asks whether to open a path refused by err for being
+// outside the root. It reports false for other errors.
+func promptOpenOutsideRoot(app *appState, err error) bool {
+	var out *outsideRootError
+	if !errors.As(err, &out) {
+		return false
+	}
+	app.inputActive = true
+	app.inputValue = ""
+	app.inputKind = "openoutside"
+	app.inputPrompt = fmt.Sprintf("Open %s outside %s? (y/N, r = also make its folder the root): ", displayPath(app, out.path), displayPath(app, out.root))
+	app.outsidePath = out.path
+	app.lastEvent = fmt.Sprintf("%s is outside the root", filepath.Base(out.path))
+	return true
+}
+
+// openOutsideRoot opens a confirmed path in a new buffer, or switches to it.
+// With moveRoot the file's directory becomes the open root; otherwise the
+// root is kept.
+func openOutsideRoot(app *appState, path string, moveRoot bool) error {
+	from := currentJump(app)
+	if err := showEditor(app, nil, path, true); err != nil {
+		return err
+	}
+	pushJump(app, from)
+	if moveRoot {
+		app.openRoot = filepath.Dir(path)
+	}
+	return nil
+}
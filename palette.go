@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// paletteState is the command palette: a query narrowing the registered
+// commands, with one of the matches selected.
+type paletteState struct {
+	active   bool
+	query    []rune
+	matches  []commandSpec
+	selected int
+}
+
+// fuzzyScore matches query as a case-insensitive subsequence of text. Runs
+// of consecutive runes and matches at word starts score higher; ok is false
+// when a query rune is missing.
+func fuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, qi, prev := 0, 0, -2
+	for i, r := range t {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1]) {
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// paletteMatches returns the commands whose name or description fuzzy-matches
+// query, best first; ties keep registry order.
+func paletteMatches(query string) []commandSpec {
+	type scored struct {
+		spec  commandSpec
+		score int
+	}
+	var hits []scored
+	for _, s := range commandSpecs {
+		best, found := 0, false
+		for _, text := range []string{s.name, s.desc} {
+			if n, ok := fuzzyScore(query, text); ok && (!found || n > best) {
+				best, found = n, true
+			}
+		}
+		if found {
+			hits = append(hits, scored{s, best})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	out := make([]commandSpec, len(hits))
+	for i, h := range hits {
+		out[i] = h.spec
+	}
+	return out
+}
+
+func openCommandPalette(app *appState) {
+	if app == nil {
+		return
+	}
+	app.palette = paletteState{active: true, matches: paletteMatches("")}
+	app.lastEvent = "Command palette: type to filter, Enter runs, Esc cancels"
+}
+
+func closeCommandPalette(app *appState) {
+	if app == nil {
+		return
+	}
+	app.palette = paletteState{}
+}
+
+func paletteSetQuery(app *appState, q []rune) {
+	app.palette.query = q
+	app.palette.matches = paletteMatches(string(q))
+	app.palette.selected = 0
+}
+
+func paletteMove(app *appState, delta int) {
+	n := len(app.palette.matches)
+	if n == 0 {
+		return
+	}
+	app.palette.selected = (app.palette.selected + delta + n) % n
+}
+
+// paletteRun closes the palette and runs the selected command.
+func paletteRun(app *appState) {
+	p := app.palette
+	closeCommandPalette(app)
+	if p.selected < 0 || p.selected >= len(p.matches) {
+		app.lastEvent = fmt.Sprintf("No command matches %q", string(p.query))
+		return
+	}
+	app.RunCommand(p.matches[p.selected].cmd, "")
+}
+
+// handlePaletteKey handles keys while the palette is open; typed text arrives
+// through handlePaletteText.
+func handlePaletteKey(app *appState, e keyEvent) bool {
+	switch e.key {
+	case keyEscape:
+		closeCommandPalette(app)
+		app.lastEvent = "Command palette closed"
+	case keyReturn, keyKpEnter:
+		paletteRun(app)
+	case keyUp:
+		paletteMove(app, -1)
+	case keyDown:
+		paletteMove(app, 1)
+	case keyTab:
+		if (e.mods & modShift) != 0 {
+			paletteMove(app, -1)
+		} else {
+			paletteMove(app, 1)
+		}
+	case keyBackspace:
+		if n := len(app.palette.query); n > 0 {
+			paletteSetQuery(app, app.palette.query[:n-1])
+		}
+	}
+	return true
+}
+
+func handlePaletteText(app *appState, text string) bool {
+	paletteSetQuery(app, append(app.palette.query, []rune(text)...))
+	return true
+}
+
+// paletteLine formats one palette row: name, description and key binding.
+func paletteLine(s commandSpec) string {
+	return fmt.Sprintf("%-14s %s  (%s)", s.name, s.desc, s.keys)
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 
 	"gc/editor"
+
+	treesitter "github.com/odvcencio/gotreesitter"
 )
 
 func BenchmarkEditorInsertAtCaret(b *testing.B) {
@@ -108,3 +111,136 @@ func BenchmarkActiveBufferSyntaxErrorsCache(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkCaretMoveLinesCache(b *testing.B) {
+	app := &appState{}
+	app.initBuffers(editor.NewEditor(strings.Repeat("var x = 12345\n", 50000)))
+	app.ed.Caret = app.ed.RuneLen() / 2
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keyDown
+		if i%2 == 1 {
+			key = keyUp
+		}
+		handleKeyEvent(app, keyEvent{down: true, key: key})
+	}
+}
+
+func largeGoSource() string {
+	var src strings.Builder
+	src.WriteString("package main\n\n")
+	for i := range 2000 {
+		src.WriteString("func f")
+		src.WriteString(strconv.Itoa(i))
+		src.WriteString("() int { return ")
+		src.WriteString(strconv.Itoa(i))
+		src.WriteString(" }\n")
+	}
+	return src.String()
+}
+
+func BenchmarkHighlightFullParse(b *testing.B) {
+	src := largeGoSource()
+	lines := editor.SplitLines([]rune(src))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h := newGoHighlighter()
+		h.lineStyleForKind("big.go", src, lines, syntaxGo)
+	}
+}
+
+func BenchmarkHighlightSingleEdit(b *testing.B) {
+	base := largeGoSource()
+	before, after, _ := strings.Cut(base, "return 1000")
+	edited := [2]string{base, before + "return 91000" + after}
+	lines := [2][]string{editor.SplitLines([]rune(edited[0])), editor.SplitLines([]rune(edited[1]))}
+	h := newGoHighlighter()
+	h.lineStyleForKind("big.go", edited[0], lines[0], syntaxGo)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := (i + 1) % 2
+		h.lineStyleForKind("big.go", edited[k], lines[k], syntaxGo)
+	}
+}
+
+// largeGoSourceEdit inserts one digit in the middle of largeGoSource and
+// returns both texts with the tree-sitter edit between them.
+func largeGoSourceEdit() (base, edited string, edit treesitter.InputEdit) {
+	base = largeGoSource()
+	before, after, _ := strings.Cut(base, "return 1000")
+	edited = before + "return 91000" + after
+	at := uint32(len(before) + len("return "))
+	pt := treesitter.Point{
+		Row:    uint32(strings.Count(before, "\n")),
+		Column: at - uint32(strings.LastIndexByte(before, '\n')+1),
+	}
+	end := treesitter.Point{Row: pt.Row, Column: pt.Column + 1}
+	edit = treesitter.InputEdit{
+		StartByte: at, OldEndByte: at, NewEndByte: at + 1,
+		StartPoint: pt, OldEndPoint: pt, NewEndPoint: end,
+	}
+	return base, edited, edit
+}
+
+func goTreeSitterHighlighter(tb testing.TB) *treesitter.Highlighter {
+	tb.Helper()
+	tsSpecsOnce.Do(initTreeSitterSpecs)
+	hl, err := tsSpecs[syntaxGo].highlighterForKind()
+	if err != nil {
+		tb.Fatalf("go highlighter: %v", err)
+	}
+	return hl
+}
+
+// TestHighlightIncrementalMatchesFullParse is what the highlighter needs
+// before it can reuse the previous tree on an edit. gotreesitter v0.5.2
+// fails it: the Go reparse falls into error recovery, loses captures and
+// runs several times slower than a full parse.
+func TestHighlightIncrementalMatchesFullParse(t *testing.T) {
+	hl := goTreeSitterHighlighter(t)
+	base, edited, edit := largeGoSourceEdit()
+	_, tree := hl.HighlightIncremental([]byte(base), nil)
+	tree.Edit(edit)
+	got, tree := hl.HighlightIncremental([]byte(edited), tree)
+	want := hl.Highlight([]byte(edited))
+	if tree.RootNode().HasError() || !slices.Equal(got, want) {
+		t.Skipf("incremental reparse differs from a full parse (error tree %v, %d of %d ranges); highlighting keeps full parses",
+			tree.RootNode().HasError(), len(got), len(want))
+	}
+}
+
+// BenchmarkHighlightIncrementalEdit times the library's incremental
+// re-highlight after the edit of BenchmarkHighlightSingleEdit; compare it
+// with BenchmarkHighlightFullParse.
+func BenchmarkHighlightIncrementalEdit(b *testing.B) {
+	hl := goTreeSitterHighlighter(b)
+	base, edited, edit := largeGoSourceEdit()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		_, tree := hl.HighlightIncremental([]byte(base), nil)
+		tree.Edit(edit)
+		b.StartTimer()
+		hl.HighlightIncremental([]byte(edited), tree)
+	}
+}
+
+// BenchmarkEditorUndoLargeBuffer edits a 1MB buffer with a full undo history;
+// B/op should track the edit size, not the buffer size.
+func BenchmarkEditorUndoLargeBuffer(b *testing.B) {
+	e := editor.NewEditor(strings.Repeat("x", 1<<20))
+	e.Caret = e.RuneLen() / 2
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.InsertText("y")
+		if i%256 == 255 {
+			for range 256 {
+				e.Undo()
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pickerDetailsWidth caps the name column of an annotated picker listing;
+// longer names push their annotation right.
+const pickerDetailsWidth = 40
+
+// pickerDetailsRe matches an annotated picker line: the entry, then at least
+// two spaces, the size ("-" for directories) and the modification time.
+var pickerDetailsRe = regexp.MustCompile(`^(.+?) {2,}(?:-|[0-9.]+[BKMGT]) {2}\d{4}-\d\d-\d\d \d\d:\d\d$`)
+
+// pickerListing lists root for a picker buffer, annotated with sizes and
+// modification times when the details option is on.
+func pickerListing(app *appState, root string) ([]string, error) {
+	list, err := pickerLines(root, 500, app.walkFilter())
+	if err != nil || !app.pickerDetails {
+		return list, err
+	}
+	return annotatePickerLines(root, list), nil
+}
+
+// annotatePickerLines appends a right-aligned size and modification time to
+// each entry of a pickerLines listing. ".." and entries that cannot be
+// statted are left bare.
+func annotatePickerLines(root string, list []string) []string {
+	nameW := 0
+	for _, e := range list[min(1, len(list)):] {
+		nameW = max(nameW, len([]rune(e)))
+	}
+	nameW = min(nameW, pickerDetailsWidth)
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = e
+		if e == ".." {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(root, strings.TrimSuffix(e, "/")))
+		if err != nil {
+			continue
+		}
+		size := "-"
+		if !fi.IsDir() {
+			size = formatFileSize(fi.Size())
+		}
+		out[i] = fmt.Sprintf("%s  %6s  %s", padRight(e, max(nameW, len([]rune(e)))), size, fi.ModTime().Format("2006-01-02 15:04"))
+	}
+	return out
+}
+
+// pickerEntryName strips the details annotation from a picker line, leaving
+// the listed name (with its "/" for directories).
+func pickerEntryName(line string) string {
+	if m := pickerDetailsRe.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return line
+}
+
+// formatFileSize renders n bytes compactly: 512B, 1.5K, 20K, 3.1M.
+func formatFileSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	for _, unit := range []string{"K", "M", "G", "T"} {
+		v /= 1024
+		if v < 1024 || unit == "T" {
+			if v < 10 {
+				return fmt.Sprintf("%.1f%s", v, unit)
+			}
+			return fmt.Sprintf("%.0f%s", v, unit)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gc/editor"
+)
+
+// pickerEntryAtCaret resolves the picker line under the caret to a path.
+// ".." and blank lines are not entries.
+func pickerEntryAtCaret(app *appState) (string, error) {
+	if len(app.buffers) == 0 || !app.buffers[app.bufIdx].picker {
+		return "", fmt.Errorf("not in the file picker (Ctrl+O)")
+	}
+	slot := &app.buffers[app.bufIdx]
+	lines := slot.ed.Lines()
+	ln := editor.CaretLineAt(lines, slot.ed.Caret)
+	if ln < 0 || ln >= len(lines) {
+		return "", fmt.Errorf("no entry under caret")
+	}
+	name := strings.TrimSuffix(pickerEntryName(strings.TrimSpace(lines[ln])), "/")
+	if name == "" || name == ".." {
+		return "", fmt.Errorf("no entry under caret")
+	}
+	path := filepath.Clean(filepath.Join(slot.pickerRoot, name))
+	if err := checkInsideRoot(app, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkInsideRoot applies the open-root guard used by openPath.
+func checkInsideRoot(app *appState, path string) error {
+	if app.openRoot == "" {
+		return nil
+	}
+	if outsideRoot(app.openRoot, path) != nil || filepath.Clean(path) == filepath.Clean(app.openRoot) {
+		return fmt.Errorf("refusing to change outside %s", app.openRoot)
+	}
+	return nil
+}
+
+func promptPickerRename(app *appState) error {
+	path, err := pickerEntryAtCaret(app)
+	if err != nil {
+		return err
+	}
+	app.pickerOpPath = path
+	app.inputActive = true
+	app.inputPrompt = "Rename to: "
+	app.inputValue = filepath.Base(path)
+	app.inputKind = "rename"
+	app.lastEvent = "Rename: edit the name (relative to the picker directory), Enter renames, Esc cancels"
+	return nil
+}
+
+func promptPickerDelete(app *appState) error {
+	path, err := pickerEntryAtCaret(app)
+	if err != nil {
+		return err
+	}
+	app.pickerOpPath = path
+	app.inputActive = true
+	app.inputPrompt = fmt.Sprintf("Delete %s? (y/N): ", filepath.Base(path))
+	app.inputValue = ""
+	app.inputKind = "deletefile"
+	app.lastEvent = "Delete: y removes it from disk, Enter/Esc keeps it"
+	return nil
+}
+
+// renamePickerEntry renames from to name (relative to the picker directory)
+// and points open buffers at the new path.
+func renamePickerEntry(app *appState, from, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("name required")
+	}
+	to := name
+	if !filepath.IsAbs(to) {
+		to = filepath.Join(filepath.Dir(from), name)
+	}
+	to = filepath.Clean(to)
+	if err := checkInsideRoot(app, to); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Base(to))
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	for i := range app.buffers {
+		b := &app.buffers[i]
+		if b.path == "" {
+			continue
+		}
+		if outsideRoot(from, filepath.Clean(b.path)) != nil {
+			continue
+		}
+		rel, err := filepath.Rel(from, filepath.Clean(b.path))
+		if err != nil {
+			continue
+		}
+		b.path = filepath.Join(to, rel)
+		app.touchBuffer(i)
+		if i == app.bufIdx {
+			app.currentPath = b.path
+		}
+	}
+	app.lastEvent = fmt.Sprintf("Renamed %s to %s", filepath.Base(from), name)
+	return refreshPicker(app)
+}
+
+// deletePickerEntry removes a file or an empty directory.
+func deletePickerEntry(app *appState, path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	app.lastEvent = "Deleted " + filepath.Base(path)
+	return refreshPicker(app)
+}
+
+// refreshPicker re-lists the active picker buffer, keeping the caret line.
+func refreshPicker(app *appState) error {
+	slot := &app.buffers[app.bufIdx]
+	if !slot.picker {
+		return nil
+	}
+	list, err := pickerListing(app, slot.pickerRoot)
+	if err != nil {
+		return err
+	}
+	ln := editor.CaretLineAt(slot.ed.Lines(), slot.ed.Caret)
+	slot.ed.SetRunes([]rune(strings.Join(list, "\n")))
+	slot.ed.Caret = editor.LineStartOffset(slot.ed.Lines(), min(ln, len(list)-1))
+	app.touchActiveBufferText()
+	return nil
+}
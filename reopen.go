@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// closedBuffer is a closed file buffer that can be reopened.
+type closedBuffer struct {
+	path  string
+	caret int
+}
+
+// maxClosedBuffers bounds the reopen history.
+const maxClosedBuffers = 20
+
+// rememberClosed records the active buffer as it is closed. Untitled and
+// picker buffers have nothing to reopen and are skipped.
+func (app *appState) rememberClosed() {
+	b := app.buffers[app.bufIdx]
+	if b.path == "" || b.picker {
+		return
+	}
+	app.closed = append(app.closed, closedBuffer{path: b.path, caret: b.ed.Caret})
+	if len(app.closed) > maxClosedBuffers {
+		app.closed = app.closed[len(app.closed)-maxClosedBuffers:]
+	}
+}
+
+// reopenClosedBuffer opens the most recently closed file again with its caret
+// restored, or switches to it when it is already open.
+func reopenClosedBuffer(app *appState) error {
+	if len(app.closed) == 0 {
+		return fmt.Errorf("no closed file to reopen")
+	}
+	c := app.closed[len(app.closed)-1]
+	app.closed = app.closed[:len(app.closed)-1]
+	n, from := len(app.buffers), currentJump(app)
+	if err := showEditor(app, nil, c.path, false); err != nil {
+		return err
+	}
+	pushJump(app, from)
+	if len(app.buffers) > n {
+		app.ed.Caret = clamp(c.caret, 0, app.ed.RuneLen())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+// repeatKind names an edit the repeat command can perform again.
+type repeatKind int
+
+const (
+	repeatNone repeatKind = iota
+	repeatInsert
+	repeatDeleteWord
+	repeatDeleteLine
+	repeatKillLine
+	repeatToggleComment
+)
+
+var repeatNames = [...]string{
+	repeatInsert:        "insert",
+	repeatDeleteWord:    "delete word",
+	repeatDeleteLine:    "delete line",
+	repeatKillLine:      "kill line",
+	repeatToggleComment: "toggle comment",
+}
+
+// repeatEdit is the most recent mutating edit; text holds an insert run.
+type repeatEdit struct {
+	kind repeatKind
+	text string
+}
+
+// noteInsert extends the open insert run with text, or starts a new run.
+// Any key other than Enter closes the run.
+func noteInsert(app *appState, text string) {
+	if app.insertRunOpen && app.lastEdit.kind == repeatInsert {
+		app.lastEdit.text += text
+	} else {
+		app.lastEdit = repeatEdit{kind: repeatInsert, text: text}
+	}
+	app.insertRunOpen = true
+}
+
+// noteEdit records a completed edit as the repeat target.
+func noteEdit(app *appState, kind repeatKind) {
+	app.lastEdit = repeatEdit{kind: kind}
+	app.insertRunOpen = false
+}
+
+// repeatLastEdit performs the last recorded edit again at the caret.
+// Navigation never becomes the repeat target, so move and repeat.
+func repeatLastEdit(app *appState) {
+	edit := app.lastEdit
+	if edit.kind == repeatNone {
+		app.lastEvent = "Nothing to repeat"
+		return
+	}
+	if readOnlyBlocked(app) {
+		return
+	}
+	ed := app.ed
+	changed := true
+	switch edit.kind {
+	case repeatInsert:
+		ed.InsertText(edit.text)
+	case repeatDeleteWord:
+		ed.Sel.Active = false
+		changed = ed.DeleteWordAtCaret()
+	case repeatDeleteLine:
+		changed = ed.DeleteLineAtCaret()
+	case repeatKillLine:
+		ed.KillToLineEnd(ed.Lines())
+	case repeatToggleComment:
+		toggleComment(ed)
+	}
+	if changed {
+		app.markDirty()
+	}
+	app.insertRunOpen = false
+	app.lastEvent = "Repeated " + repeatNames[edit.kind]
+}
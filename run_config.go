@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runConfigName is the per-project file naming the Ctrl+R command.
+const runConfigName = ".gocat-run"
+
+// defaultRunArgv is run when no run config is found.
+var defaultRunArgv = []string{"go", "run", "."}
+
+// runCommand is a command for the run buffer: argv run in dir, with env
+// (NAME=value entries) added to gc's environment.
+type runCommand struct {
+	dir  string
+	argv []string
+	env  []string
+}
+
+// String renders rc for the run buffer header, its variables first, quoting
+// arguments the shell would split.
+func (rc runCommand) String() string {
+	parts := make([]string, 0, len(rc.env)+len(rc.argv))
+	for _, kv := range rc.env {
+		k, v, _ := strings.Cut(kv, "=")
+		parts = append(parts, k+"="+shellQuote(v))
+	}
+	for _, a := range rc.argv {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// execCmd builds the process for rc: its argv in its dir, with its variables
+// added to gc's environment (later entries win).
+func (rc runCommand) execCmd() *exec.Cmd {
+	cmd := exec.Command(rc.argv[0], rc.argv[1:]...)
+	cmd.Dir = rc.dir
+	if len(rc.env) > 0 {
+		cmd.Env = append(os.Environ(), rc.env...)
+	}
+	return cmd
+}
+
+// findRunConfig returns the nearest run config at or above dir, looking no
+// higher than root (only dir itself when dir is not under root).
+func findRunConfig(dir, root string) (string, bool) {
+	for {
+		p := filepath.Join(dir, runConfigName)
+		if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() {
+			return p, true
+		}
+		if root == "" || dir == root {
+			return "", false
+		}
+		if outsideRoot(root, dir) != nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// parseRunConfig reads "name = value" lines; blank lines and lines starting
+// with '#' are skipped. command is the command line to run, each env adds a
+// NAME=value variable, and dir is the working directory as written.
+func parseRunConfig(r io.Reader) (runCommand, error) {
+	var rc runCommand
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return rc, fmt.Errorf("line %d: want name = value", n)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch name {
+		case "command":
+			argv, err := splitCommandLine(value)
+			if err != nil {
+				return rc, fmt.Errorf("line %d: %v", n, err)
+			}
+			if len(argv) == 0 {
+				return rc, fmt.Errorf("line %d: empty command", n)
+			}
+			rc.argv = argv
+		case "env":
+			if k, _, ok := strings.Cut(value, "="); !ok || strings.TrimSpace(k) != k || k == "" {
+				return rc, fmt.Errorf("line %d: want env = NAME=value", n)
+			}
+			rc.env = append(rc.env, value)
+		case "dir":
+			if value == "" {
+				return rc, fmt.Errorf("line %d: empty dir", n)
+			}
+			rc.dir = value
+		default:
+			return rc, fmt.Errorf("line %d: unknown setting %q", n, name)
+		}
+	}
+	return rc, sc.Err()
+}
+
+// loadRunCommand is the command Ctrl+R runs for dir: the nearest run config,
+// else go run . in dir. A relative dir setting is taken from the config
+// file's directory.
+func loadRunCommand(dir, root string) (runCommand, error) {
+	rc := runCommand{dir: dir, argv: defaultRunArgv}
+	path, ok := findRunConfig(dir, root)
+	if !ok {
+		return rc, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return rc, err
+	}
+	defer f.Close()
+	cfg, err := parseRunConfig(f)
+	if err != nil {
+		return rc, fmt.Errorf("%s: %v", path, err)
+	}
+	if cfg.argv != nil {
+		rc.argv = cfg.argv
+	}
+	rc.env = cfg.env
+	if cfg.dir != "" {
+		rc.dir = cfg.dir
+		if !filepath.IsAbs(rc.dir) {
+			rc.dir = filepath.Join(filepath.Dir(path), rc.dir)
+		}
+		if st, err := os.Stat(rc.dir); err != nil || !st.IsDir() {
+			return rc, fmt.Errorf("%s: dir %s is not a directory", path, rc.dir)
+		}
+	}
+	return rc, nil
+}
+
+// splitCommandLine splits s into arguments at unquoted blanks. Single quotes
+// keep text literally, double quotes allow \" and \\, and a backslash outside
+// quotes escapes the next character. No other shell syntax is understood.
+func splitCommandLine(s string) ([]string, error) {
+	var (
+		args  []string
+		cur   strings.Builder
+		inArg bool
+		quote rune
+	)
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(rs) && (rs[i+1] == '"' || rs[i+1] == '\\'):
+				i++
+				cur.WriteRune(rs[i])
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == '\\':
+			if i+1 == len(rs) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			cur.WriteRune(rs[i])
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// shellQuote single-quotes a for display when it is empty or holds blanks,
+// quotes or shell metacharacters.
+func shellQuote(a string) string {
+	if a != "" && !strings.ContainsAny(a, " \t'\"\\$`&|;<>()*?[]{}~#") {
+		return a
+	}
+	return "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+}
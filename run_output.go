@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// runBufferPrefix starts the title of a run output buffer.
+const runBufferPrefix = "[run] "
+
+// runExitFooter is the last line of a run buffer: "[exit] ok", the process
+// exit code for a failed run, or the error when the process never got one
+// (it could not start, or a signal ended it).
+func runExitFooter(err error) string {
+	if err == nil {
+		return "[exit] ok"
+	}
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) && exit.ExitCode() >= 0 {
+		return fmt.Sprintf("[exit] code=%d", exit.ExitCode())
+	}
+	return fmt.Sprintf("[exit] %v", err)
+}
+
+// runOutputStyles colors the stderr lines and a failed exit footer of a run
+// buffer with styleError; other lines stay plain.
+func runOutputStyles(lines []string) [][]tokenStyle {
+	var out [][]tokenStyle
+	for i, ln := range lines {
+		if !strings.HasPrefix(ln, "[stderr] ") && (!strings.HasPrefix(ln, "[exit] ") || ln == "[exit] ok") {
+			continue
+		}
+		if out == nil {
+			out = make([][]tokenStyle, len(lines))
+		}
+		styles := make([]tokenStyle, len([]rune(ln)))
+		for j := range styles {
+			styles[j] = styleError
+		}
+		out[i] = styles
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// saveRoot is the directory a relative save-as name is resolved against: the
+// open root, else the working directory.
+func saveRoot(app *appState) string {
+	if app.openRoot != "" {
+		return app.openRoot
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		return cwd
+	}
+	return ""
+}
+
+// savePathCompletions lists the entries of the directory named by value that
+// complete its last element, directories ending in '/'. Names starting with
+// the partial element come first; without any, the picker entries that
+// fuzzy-match it are returned best first.
+func savePathCompletions(app *appState, value string) (dir, partial string, names []string, err error) {
+	dir, partial = "", value
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		dir, partial = value[:i+1], value[i+1:]
+	}
+	abs := dir
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(saveRoot(app), dir)
+	}
+	entries, err := pickerLines(abs, 500, app.walkFilter())
+	if err != nil {
+		return dir, partial, nil, err
+	}
+	entries = entries[1:] // ".."
+	for _, e := range entries {
+		if strings.HasPrefix(e, partial) {
+			names = append(names, e)
+		}
+	}
+	if len(names) > 0 || partial == "" {
+		return dir, partial, names, nil
+	}
+	scores := map[string]int{}
+	for _, e := range entries {
+		if s, ok := fuzzyScore(partial, e); ok {
+			scores[e] = s
+			names = append(names, e)
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return scores[names[i]] > scores[names[j]] })
+	return dir, partial, names, nil
+}
+
+// completeSavePath extends the save-as input from the directory it names: a
+// single candidate is taken whole, several prefix matches are completed to
+// their common prefix and listed in the status line.
+func completeSavePath(app *appState) {
+	dir, partial, names, err := savePathCompletions(app, app.inputValue)
+	switch {
+	case err != nil:
+		app.lastEvent = fmt.Sprintf("SAVE: %v", err)
+		return
+	case len(names) == 0:
+		app.lastEvent = fmt.Sprintf("SAVE: nothing matches %q", partial)
+		return
+	case len(names) == 1:
+		app.inputValue = dir + names[0]
+		app.lastEvent = "Save: " + app.inputValue
+		return
+	}
+	if strings.HasPrefix(names[0], partial) {
+		app.inputValue = dir + commonPrefix(names)
+	}
+	shown := names[:min(len(names), 8)]
+	more := ""
+	if len(names) > len(shown) {
+		more = fmt.Sprintf(" (+%d)", len(names)-len(shown))
+	}
+	app.lastEvent = "Save: " + strings.Join(shown, "  ") + more
+}
+
+// commonPrefix is the longest byte prefix shared by names, cut back to a
+// whole rune.
+func commonPrefix(names []string) string {
+	p := names[0]
+	for _, n := range names[1:] {
+		i := 0
+		for i < len(p) && i < len(n) && p[i] == n[i] {
+			i++
+		}
+		p = p[:i]
+	}
+	return strings.ToValidUTF8(p, "")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sidebarState is the file sidebar: a directory listing pinned to the left
+// of the TUI content area. While focused it takes the navigation keys.
+type sidebarState struct {
+	visible  bool
+	focused  bool
+	root     string
+	entries  []string
+	selected int
+	scroll   int
+}
+
+// maxSidebarEntries bounds the directory listing, as for the file picker.
+const maxSidebarEntries = 500
+
+// focusSidebar shows the sidebar (listing the open root the first time) and
+// gives it the keyboard.
+func focusSidebar(app *appState) error {
+	sb := &app.sidebar
+	if sb.root == "" {
+		root := app.openRoot
+		if root == "" {
+			if cwd, err := os.Getwd(); err == nil {
+				root = cwd
+			}
+		}
+		if err := sidebarList(app, root); err != nil {
+			return err
+		}
+	}
+	sb.visible, sb.focused = true, true
+	app.lastEvent = "Sidebar: Up/Down move, Enter opens, Backspace goes up, Esc returns, q hides"
+	return nil
+}
+
+// hideSidebar removes the sidebar; its directory is kept for the next show.
+func hideSidebar(app *appState) {
+	app.sidebar.visible, app.sidebar.focused = false, false
+	app.lastEvent = "Sidebar hidden"
+}
+
+// sidebarList lists dir into the sidebar with the first entry selected.
+func sidebarList(app *appState, dir string) error {
+	list, err := pickerLines(dir, maxSidebarEntries, app.walkFilter())
+	if err != nil {
+		return err
+	}
+	app.sidebar.root = dir
+	app.sidebar.entries = list
+	app.sidebar.selected = 0
+	app.sidebar.scroll = 0
+	return nil
+}
+
+func sidebarMove(app *appState, delta int) {
+	sb := &app.sidebar
+	sb.selected = clamp(sb.selected+delta, 0, max(0, len(sb.entries)-1))
+}
+
+// sidebarEnter acts on the selected entry: ".." and "dir/" re-list the
+// sidebar, a file opens in a buffer (or switches to it) and focus returns to
+// the editor. A file outside the open root (after going up past it) is
+// refused with an *outsideRootError for the caller to confirm.
+func sidebarEnter(app *appState) error {
+	sb := &app.sidebar
+	if sb.selected < 0 || sb.selected >= len(sb.entries) {
+		return fmt.Errorf("empty directory")
+	}
+	entry := sb.entries[sb.selected]
+	switch {
+	case entry == "..":
+		return sidebarUp(app)
+	case strings.HasSuffix(entry, "/"):
+		return sidebarList(app, filepath.Join(sb.root, strings.TrimSuffix(entry, "/")))
+	}
+	if err := outsideRoot(app.openRoot, filepath.Join(sb.root, entry)); err != nil {
+		return err
+	}
+	if err := openListedPath(app, sb.root, entry); err != nil {
+		return err
+	}
+	sb.focused = false
+	return nil
+}
+
+// sidebarUp lists the parent directory with the directory just left selected.
+func sidebarUp(app *appState) error {
+	from := filepath.Base(app.sidebar.root) + "/"
+	if err := sidebarList(app, filepath.Dir(app.sidebar.root)); err != nil {
+		return err
+	}
+	for i, e := range app.sidebar.entries {
+		if e == from {
+			app.sidebar.selected = i
+			break
+		}
+	}
+	return nil
+}
+
+// handleSidebarKey handles keys while the sidebar is focused; typed text
+// arrives through handleSidebarText.
+func handleSidebarKey(app *appState, e keyEvent) bool {
+	var err error
+	switch e.key {
+	case keyEscape:
+		app.sidebar.focused = false
+		app.lastEvent = "Editor focused (Esc+Shift+F returns to the sidebar)"
+	case keyReturn, keyKpEnter:
+		err = sidebarEnter(app)
+	case keyBackspace, keyLeft:
+		err = sidebarUp(app)
+	case keyUp:
+		sidebarMove(app, -1)
+	case keyDown:
+		sidebarMove(app, 1)
+	case keyPageUp:
+		sidebarMove(app, -pageLines(app))
+	case keyPageDown:
+		sidebarMove(app, pageLines(app))
+	case keyHome:
+		sidebarMove(app, -len(app.sidebar.entries))
+	case keyEnd:
+		sidebarMove(app, len(app.sidebar.entries))
+	}
+	if promptOpenOutsideRoot(app, err) {
+		app.sidebar.focused = false
+	} else if err != nil {
+		app.lastEvent = fmt.Sprintf("SIDEBAR ERR: %v", err)
+	}
+	return true
+}
+
+func handleSidebarText(app *appState, text string) bool {
+	if text == "q" {
+		hideSidebar(app)
+	}
+	return true
+}
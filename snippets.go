@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gc/editor"
+)
+
+// defaultSnippets are the built-in Go snippets, expanded by Tab after their
+// name. Bodies indent with tabs; "$0" marks where the caret ends up and
+// "${1:text}" is a placeholder inserted as text, the first one selected.
+var defaultSnippets = map[string]string{
+	"main":  "package main\n\nfunc main() {\n\t$0\n}",
+	"iferr": "if err != nil {\n\treturn $0err\n}",
+	"fori":  "for i := 0; i < $0; i++ {\n}",
+	"test":  "func Test${1:Name}(t *testing.T) {\n\t$0\n}",
+	"errf":  "fmt.Errorf(\"${1:msg}: %w\", err)$0",
+}
+
+// snippetPlaceholderRe matches "${N:text}" placeholders.
+var snippetPlaceholderRe = regexp.MustCompile(`\$\{(\d+):([^}]*)\}`)
+
+// snippetsPath is the user snippet file, e.g. ~/.config/gocat/snippets.
+func snippetsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gocat", "snippets")
+}
+
+// loadSnippets reads snipMate-style definitions from r on top of base: a
+// "snippet <name>" line followed by body lines indented by one tab (the tab
+// is stripped). Blank lines inside a body are kept; "#" comments between
+// snippets are skipped.
+func loadSnippets(r io.Reader, base map[string]string) (map[string]string, error) {
+	out := maps.Clone(base)
+	if out == nil {
+		out = map[string]string{}
+	}
+	name := ""
+	var body []string
+	flush := func() {
+		if name != "" {
+			for len(body) > 0 && body[len(body)-1] == "" {
+				body = body[:len(body)-1]
+			}
+			out[name] = strings.Join(body, "\n")
+		}
+		name, body = "", nil
+	}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \r")
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			if name == "" {
+				return nil, fmt.Errorf("line %d: body outside a snippet", n)
+			}
+			body = append(body, line[1:])
+		case line == "":
+			if name != "" {
+				body = append(body, "")
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "snippet "):
+			flush()
+			name = strings.TrimSpace(strings.TrimPrefix(line, "snippet "))
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("line %d: bad snippet name", n)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: want \"snippet <name>\" or a tab-indented body", n)
+		}
+	}
+	flush()
+	return out, sc.Err()
+}
+
+// loadUserSnippets returns the built-in snippets extended from the user's
+// snippet file. A missing file is not an error; a bad one leaves the
+// built-ins.
+func loadUserSnippets(path string) (map[string]string, error) {
+	if path == "" {
+		return defaultSnippets, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return defaultSnippets, nil
+	}
+	if err != nil {
+		return defaultSnippets, err
+	}
+	defer f.Close()
+	sn, err := loadSnippets(f, defaultSnippets)
+	if err != nil {
+		return defaultSnippets, fmt.Errorf("%s: %v", path, err)
+	}
+	return sn, nil
+}
+
+// expandSnippetBody indents body for a line starting with indent, using unit
+// for each leading tab, and resolves its markers. It returns the text, the
+// caret offset within it and the selected placeholder range (a == b for none).
+func expandSnippetBody(body, indent, unit string) (text string, caret, a, b int) {
+	lines := strings.Split(body, "\n")
+	for i, l := range lines {
+		tabs := len(l) - len(strings.TrimLeft(l, "\t"))
+		l = strings.Repeat(unit, tabs) + l[tabs:]
+		if i > 0 && l != "" {
+			l = indent + l
+		}
+		lines[i] = l
+	}
+	text = strings.Join(lines, "\n")
+	// Resolve placeholders first, noting the lowest-numbered one.
+	first := 0
+	a, b = -1, -1
+	for {
+		m := snippetPlaceholderRe.FindStringSubmatchIndex(text)
+		if m == nil {
+			break
+		}
+		num, _ := strconv.Atoi(text[m[2]:m[3]])
+		def := text[m[4]:m[5]]
+		if a < 0 || num < first {
+			first = num
+			a = utf8.RuneCountInString(text[:m[0]])
+			b = a + utf8.RuneCountInString(def)
+		}
+		text = text[:m[0]] + def + text[m[1]:]
+	}
+	caret = utf8.RuneCountInString(text)
+	if i := strings.Index(text, "$0"); i >= 0 {
+		caret = utf8.RuneCountInString(text[:i])
+		text = text[:i] + text[i+2:]
+		if a >= caret {
+			a, b = a-2, b-2
+		}
+	}
+	if a < 0 {
+		a, b = caret, caret
+	}
+	return text, caret, a, b
+}
+
+// trySnippetExpansion replaces a snippet name before the caret with its body.
+// The name must be the only token on its line, so selectors such as x.test
+// and identifiers inside expressions are left to completion. The first
+// placeholder, if any, is selected; otherwise the caret goes to $0.
+func trySnippetExpansion(app *appState, buf []rune) bool {
+	start := identPrefixStart(buf, app.ed.Caret)
+	name := string(buf[start:app.ed.Caret])
+	snippets := app.snippets
+	if snippets == nil {
+		snippets = defaultSnippets
+	}
+	body, ok := snippets[name]
+	if !ok || name == "" {
+		return false
+	}
+	lineStart := start
+	for lineStart > 0 && buf[lineStart-1] != '\n' {
+		lineStart--
+	}
+	indentEnd := lineStart
+	for indentEnd < start && (buf[indentEnd] == ' ' || buf[indentEnd] == '\t') {
+		indentEnd++
+	}
+	lineEnd := app.ed.Caret
+	for lineEnd < len(buf) && (buf[lineEnd] == ' ' || buf[lineEnd] == '\t') {
+		lineEnd++
+	}
+	if indentEnd != start || (lineEnd < len(buf) && buf[lineEnd] != '\n') {
+		return false
+	}
+	text, caret, a, b := expandSnippetBody(body, string(buf[lineStart:indentEnd]), indentUnit(app))
+	app.ed.ReplaceRange(start, app.ed.Caret, text)
+	app.markDirty()
+	if a != b {
+		app.ed.Sel = editor.Sel{Active: true, A: start + a, B: start + b}
+		app.ed.Caret = start + b
+	} else {
+		app.ed.Caret = start + caret
+	}
+	app.lastEvent = fmt.Sprintf("Snippet %s", name)
+	return true
+}
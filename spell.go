@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gc/editor"
+)
+
+// spellDictPaths are the system word lists tried when spell checking is
+// turned on.
+var spellDictPaths = []string{"/usr/share/dict/words", "/usr/dict/words"}
+
+// spellChecker holds the dictionary, lower-cased, and the words ignored for
+// the session.
+type spellChecker struct {
+	words   map[string]struct{}
+	ignored map[string]struct{}
+}
+
+// loadSpellWords reads a word list with one word per line.
+func loadSpellWords(r io.Reader) (map[string]struct{}, error) {
+	words := map[string]struct{}{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if w := strings.TrimSpace(sc.Text()); w != "" {
+			words[strings.ToLower(w)] = struct{}{}
+		}
+	}
+	return words, sc.Err()
+}
+
+// loadSystemSpellWords reads the first system word list found.
+func loadSystemSpellWords() (map[string]struct{}, error) {
+	for _, p := range spellDictPaths {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		return loadSpellWords(f)
+	}
+	return nil, fmt.Errorf("no dictionary (%s)", strings.Join(spellDictPaths, ", "))
+}
+
+// known reports whether w is spelled correctly: in the dictionary in any
+// case, possibly with a possessive 's, or ignored.
+func (sc *spellChecker) known(w string) bool {
+	lw := strings.ToLower(w)
+	if _, ok := sc.ignored[lw]; ok {
+		return true
+	}
+	if _, ok := sc.words[lw]; ok {
+		return true
+	}
+	if base, ok := strings.CutSuffix(lw, "'s"); ok {
+		_, ok = sc.words[base]
+		return ok
+	}
+	return false
+}
+
+// ignore adds w to the session's ignored words.
+func (sc *spellChecker) ignore(w string) {
+	if sc.ignored == nil {
+		sc.ignored = map[string]struct{}{}
+	}
+	sc.ignored[strings.ToLower(w)] = struct{}{}
+}
+
+// proseWord is a word of a prose line; col and end are rune columns.
+type proseWord struct {
+	line     int
+	col, end int
+	text     string
+}
+
+// proseWords lists the words of lines first..last, skipping fenced code
+// blocks, inline `code`, URLs, and tokens holding digits or underscores.
+// Lines before first are only scanned for fences.
+func proseWords(lines []string, first, last int) []proseWord {
+	inFence := false
+	var out []proseWord
+	for ln := 0; ln <= last && ln < len(lines); ln++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[ln]), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || ln < first {
+			continue
+		}
+		out = appendProseWords(out, ln, []rune(lines[ln]))
+	}
+	return out
+}
+
+func appendProseWords(out []proseWord, ln int, rs []rune) []proseWord {
+	inCode := false
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '`':
+			inCode = !inCode
+			i++
+			continue
+		case inCode:
+			i++
+			continue
+		case unicode.IsSpace(r):
+			i++
+			continue
+		}
+		// A whitespace-separated chunk holding "://" is a URL.
+		j := i
+		for j < len(rs) && !unicode.IsSpace(rs[j]) && rs[j] != '`' {
+			j++
+		}
+		if strings.Contains(string(rs[i:j]), "://") {
+			i = j
+			continue
+		}
+		if !isWordRune(r) {
+			i++
+			continue
+		}
+		j = i
+		for j < len(rs) && (isWordRune(rs[j]) || (rs[j] == '\'' && j+1 < len(rs) && unicode.IsLetter(rs[j+1]))) {
+			j++
+		}
+		word := string(rs[i:j])
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsDigit(r) || r == '_' }) < 0 {
+			out = append(out, proseWord{line: ln, col: i, end: j, text: word})
+		}
+		i = j
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// proseBuffer reports whether a buffer of this kind and path is prose: a
+// Markdown buffer, or a plain one named .txt or without an extension.
+func proseBuffer(kind syntaxKind, path string) bool {
+	switch kind {
+	case syntaxMarkdown:
+		return true
+	case syntaxNone:
+		ext := strings.ToLower(filepath.Ext(path))
+		return ext == "" || ext == ".txt"
+	}
+	return false
+}
+
+// misspelledWords maps lines first..last to the column ranges of their
+// misspelled words.
+func misspelledWords(sc *spellChecker, lines []string, first, last int) map[int][][2]int {
+	var out map[int][][2]int
+	for _, w := range proseWords(lines, first, last) {
+		if utf8.RuneCountInString(w.text) < 2 || sc.known(w.text) {
+			continue
+		}
+		if out == nil {
+			out = map[int][][2]int{}
+		}
+		out[w.line] = append(out[w.line], [2]int{w.col, w.end})
+	}
+	return out
+}
+
+// setSpellCheck turns spell checking on, loading the system dictionary the
+// first time, or off.
+func setSpellCheck(app *appState, on bool) error {
+	if on && app.spell.words == nil {
+		words, err := loadSystemSpellWords()
+		if err != nil {
+			return err
+		}
+		app.spell.words = words
+	}
+	app.spellCheck = on
+	return nil
+}
+
+// spellIgnoreWord adds word, or the word at the caret, to the session's
+// ignored words.
+func spellIgnoreWord(app *appState, word string) error {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		lines := app.ed.Lines()
+		ln := editor.CaretLineAt(lines, app.ed.Caret)
+		col := editor.CaretColAt(lines, app.ed.Caret)
+		for _, w := range proseWords(lines, ln, ln) {
+			if w.col <= col && col <= w.end {
+				word = w.text
+				break
+			}
+		}
+	}
+	if word == "" {
+		return fmt.Errorf("no word at the caret")
+	}
+	app.spell.ignore(word)
+	app.lastEvent = fmt.Sprintf("Spelling: ignoring %q this session", word)
+	return nil
+}
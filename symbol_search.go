@@ -0,0 +1,119 @@
+package main
+
+import "fmt"
+
+// popupMatch is a query hit in the wrapped symbol-info lines; col is a rune
+// column.
+type popupMatch struct {
+	line, col int
+}
+
+// popupMatches lists the case-insensitive occurrences of query in the wrapped
+// popup lines, in reading order.
+func popupMatches(lines []string, query []rune) []popupMatch {
+	var out []popupMatch
+	for ln, line := range lines {
+		for _, col := range lineMatchOffsets(line, query) {
+			out = append(out, popupMatch{line: ln, col: col})
+		}
+	}
+	return out
+}
+
+// popupRevealScroll returns the scroll offset that shows line in a window of
+// rows lines, moving as little as possible from scroll.
+func popupRevealScroll(scroll, line, rows int) int {
+	if rows <= 0 {
+		return line
+	}
+	if line < scroll {
+		return line
+	}
+	if line >= scroll+rows {
+		return line - rows + 1
+	}
+	return scroll
+}
+
+// resetSymbolInfoSearch drops the popup query, e.g. when the popup opens or
+// closes.
+func resetSymbolInfoSearch(app *appState) {
+	app.symbolInfoSearch = false
+	app.symbolInfoQuery = nil
+	app.symbolInfoMatch = 0
+}
+
+// revealSymbolInfoMatch wraps symbolInfoMatch into the current matches and
+// scrolls the popup, laid out for the last drawn frame, to show it.
+func revealSymbolInfoMatch(app *appState) {
+	boxW, boxH := symbolPopupSize(app.screenW, app.screenH)
+	lines := wrapPopupText(app.symbolInfoPopup, max(10, boxW-4))
+	matches := popupMatches(lines, app.symbolInfoQuery)
+	n := len(matches)
+	if n == 0 {
+		app.symbolInfoMatch = 0
+		return
+	}
+	app.symbolInfoMatch = (app.symbolInfoMatch%n + n) % n
+	cur := clamp(app.symbolInfoScroll, 0, max(0, len(lines)-1))
+	app.symbolInfoScroll = popupRevealScroll(cur, matches[app.symbolInfoMatch].line, boxH-4)
+}
+
+// handleSymbolInfoSearchKey handles keys for the popup search: Ctrl+F starts
+// typing a query, Backspace edits it, Enter keeps it, Esc clears it, and
+// Tab/Shift+Tab step through the matches. It reports whether it used the key.
+func handleSymbolInfoSearchKey(app *appState, e keyEvent, prefixed bool) bool {
+	if app.symbolInfoPopup == "" || !e.down {
+		return false
+	}
+	switch {
+	case e.key == keyF && e.mods&modCtrl != 0 && !prefixed:
+		app.symbolInfoSearch = true
+		app.lastEvent = "Popup search: type to find, Tab next, Enter keeps, Esc clears"
+		return true
+	case app.symbolInfoSearch && e.key == keyBackspace:
+		if n := len(app.symbolInfoQuery); n > 0 {
+			app.symbolInfoQuery = app.symbolInfoQuery[:n-1]
+			app.symbolInfoMatch = 0
+			revealSymbolInfoMatch(app)
+		}
+		return true
+	case app.symbolInfoSearch && (e.key == keyReturn || e.key == keyKpEnter):
+		app.symbolInfoSearch = false
+		app.lastEvent = fmt.Sprintf("Popup search: %q", string(app.symbolInfoQuery))
+		return true
+	case (app.symbolInfoSearch || len(app.symbolInfoQuery) > 0) && e.key == keyEscape && e.repeat == 0:
+		resetSymbolInfoSearch(app)
+		app.cmdPrefixActive = false
+		app.lastEvent = "Popup search cleared"
+		return true
+	case len(app.symbolInfoQuery) > 0 && e.key == keyTab:
+		if e.mods&modShift != 0 {
+			app.symbolInfoMatch--
+		} else {
+			app.symbolInfoMatch++
+		}
+		revealSymbolInfoMatch(app)
+		return true
+	}
+	return false
+}
+
+// handleSymbolInfoSearchText adds typed text to the popup query.
+func handleSymbolInfoSearchText(app *appState, text string) {
+	app.symbolInfoQuery = append(app.symbolInfoQuery, []rune(text)...)
+	app.symbolInfoMatch = 0
+	revealSymbolInfoMatch(app)
+}
+
+// symbolInfoSearchFooter is the popup's last line while a query is set.
+func symbolInfoSearchFooter(app *appState, matches int) string {
+	q := "/" + string(app.symbolInfoQuery)
+	if len(app.symbolInfoQuery) == 0 {
+		return q + "  (type to find, Esc clears)"
+	}
+	if matches == 0 {
+		return q + "  no match"
+	}
+	return fmt.Sprintf("%s  %d/%d  Tab next, Esc clears", q, app.symbolInfoMatch+1, matches)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type textStats struct {
+	words, lines, chars int
+}
+
+// countText counts whitespace-separated words, lines (a trailing newline
+// does not start another line) and runes.
+func countText(rs []rune) textStats {
+	if len(rs) == 0 {
+		return textStats{}
+	}
+	s := string(rs)
+	lines := strings.Count(s, "\n")
+	if rs[len(rs)-1] != '\n' {
+		lines++
+	}
+	return textStats{words: len(strings.Fields(s)), lines: lines, chars: len(rs)}
+}
+
+// reportTextStats puts word, line and character counts for the selection, or
+// the whole buffer without one, on the status line.
+func reportTextStats(app *appState) {
+	if app == nil || app.ed == nil {
+		return
+	}
+	rs := app.ed.Runes()
+	scope := "Buffer"
+	if app.ed.Sel.Active {
+		a, b := app.ed.Sel.Normalised()
+		a, b = clamp(a, 0, len(rs)), clamp(b, 0, len(rs))
+		if a < b {
+			rs = rs[a:b]
+			scope = "Selection"
+		}
+	}
+	st := countText(rs)
+	app.lastEvent = fmt.Sprintf("%s: %s, %s, %s", scope,
+		plural(st.words, "word"), plural(st.lines, "line"), plural(st.chars, "char"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
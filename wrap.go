@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// bracketPairs maps opening brackets to their closers for closingDelimiter.
+var bracketPairs = map[rune]rune{'(': ')', '[': ']', '{': '}', '<': '>', '«': '»'}
+
+// htmlOpenTagRe matches an opening tag such as <b> or <a href="x">.
+var htmlOpenTagRe = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9-]*)(?:\s[^>]*)?>$`)
+
+// closingDelimiter infers the closer for an opening delimiter: brackets are
+// mirrored ("([" closes with "])"), a tag gets its end tag, and anything
+// else (quotes, backticks, "**", "_") closes with itself reversed.
+func closingDelimiter(open string) string {
+	if m := htmlOpenTagRe.FindStringSubmatch(open); m != nil {
+		return "</" + m[1] + ">"
+	}
+	rs := []rune(open)
+	slices.Reverse(rs)
+	for i, r := range rs {
+		if c, ok := bracketPairs[r]; ok {
+			rs[i] = c
+		}
+	}
+	return string(rs)
+}
+
+func promptWrap(app *appState) {
+	app.inputActive = true
+	app.inputPrompt = "Wrap with: "
+	app.inputValue = ""
+	app.inputKind = "wrap"
+	app.lastEvent = "Wrap selection or word: type the opening delimiter (\" ( ` ** _ <b>), Enter applies"
+}
+
+// wrapSelection wraps the selection (or the word at the caret) in open and
+// its inferred closer.
+func wrapSelection(app *appState, open string) error {
+	if strings.TrimSpace(open) == "" {
+		return fmt.Errorf("delimiter required")
+	}
+	closer := closingDelimiter(open)
+	if !app.ed.WrapSelection(open, closer) {
+		return fmt.Errorf("nothing to wrap")
+	}
+	app.markDirty()
+	app.lastEvent = fmt.Sprintf("Wrapped in %s…%s", open, closer)
+	return nil
+}